@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/rodaine/table"
@@ -11,11 +12,13 @@ import (
 )
 
 type App struct {
-	ID      string `json:"id"`
-	Name    string `json:"name"`
-	Version string `json:"version"`
-	Enabled bool   `json:"enabled"`
-	Ready   bool   `json:"ready"`
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	Version        string `json:"version"`
+	Enabled        bool   `json:"enabled"`
+	Ready          bool   `json:"ready"`
+	Crashed        bool   `json:"crashed"`
+	CrashedMessage string `json:"crashedMessage"`
 }
 
 var appsCmd = &cobra.Command{
@@ -132,8 +135,30 @@ var appsGetCmd = &cobra.Command{
 var appsRestartCmd = &cobra.Command{
 	Use:   "restart <name-or-id>",
 	Short: "Restart an app",
-	Args:  cobra.ExactArgs(1),
+	Long: `Restart an app, or with --unhealthy every enabled app that has crashed
+or is not ready.
+
+With --unhealthy, each app is restarted up to --retries times, waiting
+--wait after each restart for it to become ready.
+
+Examples:
+  homeyctl apps restart com.fibaro
+  homeyctl apps restart --unhealthy
+  homeyctl apps restart --unhealthy --retries 5 --wait 30s`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		unhealthy, _ := cmd.Flags().GetBool("unhealthy")
+		if unhealthy {
+			if len(args) > 0 {
+				return fmt.Errorf("--unhealthy takes no app")
+			}
+			retries, _ := cmd.Flags().GetInt("retries")
+			wait, _ := cmd.Flags().GetDuration("wait")
+			return restartUnhealthyApps(cmd, retries, wait)
+		}
+		if len(args) == 0 {
+			return fmt.Errorf("requires an app, or --unhealthy")
+		}
 		nameOrID := args[0]
 
 		data, err := apiClient.GetApps()
@@ -173,4 +198,7 @@ func init() {
 	appsCmd.AddCommand(appsListCmd)
 	appsCmd.AddCommand(appsGetCmd)
 	appsCmd.AddCommand(appsRestartCmd)
+	appsRestartCmd.Flags().Bool("unhealthy", false, "Restart every enabled app that has crashed or is not ready")
+	appsRestartCmd.Flags().Int("retries", 3, "Restarts per app before giving up (with --unhealthy)")
+	appsRestartCmd.Flags().Duration("wait", 15*time.Second, "Time to wait for an app to become ready after a restart (with --unhealthy)")
 }
@@ -0,0 +1,253 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/fishfisher/homeyctl/internal/client"
+	"github.com/rodaine/table"
+	"github.com/spf13/cobra"
+)
+
+// fetchApps lists all apps sorted by name
+func fetchApps() ([]App, error) {
+	data, err := apiClient.GetApps()
+	if err != nil {
+		return nil, err
+	}
+	var byID map[string]App
+	if err := json.Unmarshal(data, &byID); err != nil {
+		return nil, fmt.Errorf("failed to parse apps: %w", err)
+	}
+	apps := make([]App, 0, len(byID))
+	for _, a := range byID {
+		apps = append(apps, a)
+	}
+	sort.Slice(apps, func(i, j int) bool { return strings.ToLower(apps[i].Name) < strings.ToLower(apps[j].Name) })
+	return apps, nil
+}
+
+// appHealth is ok, crashed, not ready or disabled
+func appHealth(a App) string {
+	switch {
+	case !a.Enabled:
+		return "disabled"
+	case a.Crashed:
+		return "crashed"
+	case !a.Ready:
+		return "not ready"
+	}
+	return "ok"
+}
+
+// isUnhealthy reports whether an enabled app has crashed or is not ready
+func isUnhealthy(a App) bool {
+	h := appHealth(a)
+	return h == "crashed" || h == "not ready"
+}
+
+// restartApp restarts an app until it is ready, at most retries times,
+// and returns the number of restarts
+func restartApp(app App, retries int, wait time.Duration) (int, error) {
+	for attempt := 1; attempt <= retries; attempt++ {
+		if err := apiClient.RestartApp(app.ID); err != nil {
+			if errors.Is(err, client.ErrDryRun) {
+				return attempt, nil
+			}
+			return attempt, err
+		}
+		time.Sleep(wait)
+
+		data, err := apiClient.GetApp(app.ID)
+		if err != nil {
+			return attempt, err
+		}
+		var current App
+		if err := json.Unmarshal(data, &current); err != nil {
+			return attempt, fmt.Errorf("failed to parse app: %w", err)
+		}
+		if current.Ready && !current.Crashed {
+			return attempt, nil
+		}
+	}
+	return retries, fmt.Errorf("%s is still not ready after %d restart(s)", app.Name, retries)
+}
+
+// restartUnhealthyApps restarts every unhealthy app, carrying on past
+// apps that do not recover
+func restartUnhealthyApps(cmd *cobra.Command, retries int, wait time.Duration) error {
+	if retries < 1 {
+		return fmt.Errorf("--retries must be at least 1")
+	}
+	apps, err := fetchApps()
+	if err != nil {
+		return err
+	}
+
+	type restartResult struct {
+		ID       string `json:"id"`
+		Name     string `json:"name"`
+		Restarts int    `json:"restarts"`
+		Ready    bool   `json:"ready"`
+		Error    string `json:"error,omitempty"`
+	}
+	results := []restartResult{}
+	failed := 0
+	for _, a := range apps {
+		if !isUnhealthy(a) {
+			continue
+		}
+		n, err := restartApp(a, retries, wait)
+		r := restartResult{ID: a.ID, Name: a.Name, Restarts: n, Ready: err == nil}
+		if err != nil {
+			r.Error = err.Error()
+			failed++
+		}
+		results = append(results, r)
+
+		if isJSON() {
+			continue
+		}
+		if err != nil {
+			color.Red("Failed: %s\n", err)
+		} else {
+			color.Green("Restarted app: %s (%d restart(s))\n", a.Name, n)
+		}
+	}
+
+	if isJSON() {
+		out, _ := json.MarshalIndent(results, "", "  ")
+		fmt.Println(string(out))
+	} else if len(results) == 0 {
+		fmt.Println("All apps are healthy")
+	}
+	if failed > 0 {
+		cmd.SilenceUsage = true
+		return fmt.Errorf("%d of %d app(s) did not recover", failed, len(results))
+	}
+	return nil
+}
+
+var appsStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show which apps have crashed or are not ready",
+	Long: `Show the health of every app: ok, crashed, not ready or disabled.
+
+The exit status is 1 when an enabled app has crashed or is not ready, so
+it can be used in scripts and health checks. Restart those apps with
+'homeyctl apps restart --unhealthy'.
+
+Examples:
+  homeyctl apps status
+  homeyctl apps status --unhealthy
+  homeyctl apps status || homeyctl apps restart --unhealthy`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		onlyUnhealthy, _ := cmd.Flags().GetBool("unhealthy")
+
+		apps, err := fetchApps()
+		if err != nil {
+			return err
+		}
+
+		type appStatus struct {
+			ID      string `json:"id"`
+			Name    string `json:"name"`
+			Version string `json:"version"`
+			Status  string `json:"status"`
+			Message string `json:"message,omitempty"`
+		}
+		statuses := []appStatus{}
+		unhealthy := 0
+		for _, a := range apps {
+			if isUnhealthy(a) {
+				unhealthy++
+			} else if onlyUnhealthy {
+				continue
+			}
+			statuses = append(statuses, appStatus{ID: a.ID, Name: a.Name, Version: a.Version, Status: appHealth(a), Message: a.CrashedMessage})
+		}
+
+		if isJSON() {
+			out, _ := json.MarshalIndent(statuses, "", "  ")
+			fmt.Println(string(out))
+		} else if len(statuses) == 0 {
+			fmt.Println("All apps are healthy")
+		} else {
+			headerFmt := color.New(color.FgCyan, color.Underline).SprintfFunc()
+			tbl := table.New("Name", "Version", "Status", "Message", "ID")
+			tbl.WithHeaderFormatter(headerFmt)
+			for _, s := range statuses {
+				status := s.Status
+				switch status {
+				case "crashed", "not ready":
+					status = color.RedString(status)
+				case "disabled":
+					status = color.YellowString(status)
+				}
+				tbl.AddRow(s.Name, s.Version, status, s.Message, s.ID)
+			}
+			tbl.Print()
+		}
+
+		if unhealthy > 0 {
+			cmd.SilenceUsage = true
+			return errFalse
+		}
+		return nil
+	},
+}
+
+// appUsage is an app's resource usage as reported by the app manager
+type appUsage struct {
+	CPU    float64 `json:"cpu"`    // Fraction of one core
+	Memory int64   `json:"memory"` // Bytes
+}
+
+// rankAppUsage fetches usage for every running app, highest first by
+// memory or cpu. Apps whose usage cannot be read are skipped.
+func rankAppUsage(sortBy string) ([]App, map[string]appUsage, error) {
+	if sortBy != "memory" && sortBy != "cpu" {
+		return nil, nil, fmt.Errorf("invalid --sort: %s (use: memory, cpu)", sortBy)
+	}
+	apps, err := fetchApps()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var running []App
+	usage := map[string]appUsage{}
+	for _, a := range apps {
+		if !a.Enabled || !a.Ready {
+			continue
+		}
+		data, err := apiClient.GetAppUsage(a.ID)
+		if err != nil {
+			continue
+		}
+		var u appUsage
+		if err := json.Unmarshal(data, &u); err != nil {
+			continue
+		}
+		usage[a.ID] = u
+		running = append(running, a)
+	}
+
+	sort.SliceStable(running, func(i, j int) bool {
+		a, b := usage[running[i].ID], usage[running[j].ID]
+		if sortBy == "cpu" {
+			return a.CPU > b.CPU
+		}
+		return a.Memory > b.Memory
+	})
+	return running, usage, nil
+}
+
+func init() {
+	appsCmd.AddCommand(appsStatusCmd)
+	appsStatusCmd.Flags().Bool("unhealthy", false, "Only show apps that have crashed or are not ready")
+}
@@ -3,8 +3,11 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"os"
+	"sort"
 
 	"github.com/fatih/color"
+	"github.com/rodaine/table"
 	"github.com/spf13/cobra"
 )
 
@@ -189,10 +192,27 @@ var appsUsageCmd = &cobra.Command{
 	Short: "Show app resource usage",
 	Long: `Show app resource usage (CPU, memory).
 
+With --all, every running app is listed, highest memory use first, or
+highest CPU use with --sort cpu.
+
 Examples:
-  homeyctl apps usage com.fibaro`,
-	Args: cobra.ExactArgs(1),
+  homeyctl apps usage com.fibaro
+  homeyctl apps usage --all
+  homeyctl apps usage --all --sort cpu`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		all, _ := cmd.Flags().GetBool("all")
+		if all {
+			if len(args) > 0 {
+				return fmt.Errorf("--all takes no app")
+			}
+			sortBy, _ := cmd.Flags().GetString("sort")
+			return showAllAppUsage(sortBy)
+		}
+		if len(args) == 0 {
+			return fmt.Errorf("requires an app, or --all")
+		}
+
 		app, err := findApp(args[0])
 		if err != nil {
 			return err
@@ -208,10 +228,7 @@ Examples:
 			return nil
 		}
 
-		var usage struct {
-			CPU    float64 `json:"cpu"`
-			Memory int64   `json:"memory"`
-		}
+		var usage appUsage
 		if err := json.Unmarshal(data, &usage); err != nil {
 			return err
 		}
@@ -223,6 +240,151 @@ Examples:
 	},
 }
 
+func showAllAppUsage(sortBy string) error {
+	apps, usage, err := rankAppUsage(sortBy)
+	if err != nil {
+		return err
+	}
+
+	if isJSON() {
+		type appUsageRow struct {
+			ID     string  `json:"id"`
+			Name   string  `json:"name"`
+			CPU    float64 `json:"cpu"`
+			Memory int64   `json:"memory"`
+		}
+		rows := []appUsageRow{}
+		for _, a := range apps {
+			rows = append(rows, appUsageRow{ID: a.ID, Name: a.Name, CPU: usage[a.ID].CPU, Memory: usage[a.ID].Memory})
+		}
+		out, _ := json.MarshalIndent(rows, "", "  ")
+		fmt.Println(string(out))
+		return nil
+	}
+
+	headerFmt := color.New(color.FgCyan, color.Underline).SprintfFunc()
+	tbl := table.New("Name", "Memory", "CPU", "ID")
+	tbl.WithHeaderFormatter(headerFmt)
+	for _, a := range apps {
+		u := usage[a.ID]
+		tbl.AddRow(a.Name, fmt.Sprintf("%.2f MB", float64(u.Memory)/(1024*1024)), fmt.Sprintf("%.2f%%", u.CPU*100), a.ID)
+	}
+	tbl.Print()
+	return nil
+}
+
+// appSettingsExport is the file format of 'apps settings export'
+type appSettingsExport struct {
+	App      string                 `json:"app"`
+	Settings map[string]interface{} `json:"settings"`
+}
+
+var appsSettingsExportCmd = &cobra.Command{
+	Use:   "export <app> [file]",
+	Short: "Export app settings to a file",
+	Long: `Export all settings of an app, to move its configuration to another
+Homey with 'apps settings import'.
+
+The file can contain credentials the app stores in its settings, such as
+API tokens, so keep it private.
+
+Examples:
+  homeyctl apps settings export com.tibber tibber.json
+  homeyctl apps settings export com.tibber > tibber.json`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		app, err := findApp(args[0])
+		if err != nil {
+			return err
+		}
+
+		data, err := apiClient.GetAppSettings(app.ID)
+		if err != nil {
+			return err
+		}
+		export := appSettingsExport{App: app.ID}
+		if err := json.Unmarshal(data, &export.Settings); err != nil {
+			return fmt.Errorf("failed to parse app settings: %w", err)
+		}
+		out, _ := json.MarshalIndent(export, "", "  ")
+
+		if len(args) == 2 {
+			if err := os.WriteFile(args[1], append(out, '\n'), 0o600); err != nil {
+				return fmt.Errorf("failed to write file: %w", err)
+			}
+			color.Green("Exported %d setting(s) of %s to %s\n", len(export.Settings), app.Name, args[1])
+			return nil
+		}
+		fmt.Println(string(out))
+		return nil
+	},
+}
+
+var appsSettingsImportCmd = &cobra.Command{
+	Use:   "import <file> [app]",
+	Short: "Import app settings from a file",
+	Long: `Set every setting in a file written by 'apps settings export'.
+
+The settings go to the app named in the file, or to the given app.
+Use --only to import some of the settings.
+
+Examples:
+  homeyctl apps settings import tibber.json
+  homeyctl apps settings import tibber.json com.tibber --only token,homeId`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		only, _ := cmd.Flags().GetStringSlice("only")
+
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to read file: %w", err)
+		}
+		var export appSettingsExport
+		if err := json.Unmarshal(data, &export); err != nil {
+			return fmt.Errorf("invalid settings file: %w", err)
+		}
+
+		target := export.App
+		if len(args) == 2 {
+			target = args[1]
+		}
+		if target == "" {
+			return fmt.Errorf("the file names no app, give one as the second argument")
+		}
+		app, err := findApp(target)
+		if err != nil {
+			return err
+		}
+
+		names := only
+		if len(names) == 0 {
+			for name := range export.Settings {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+		}
+		for _, name := range names {
+			if _, ok := export.Settings[name]; !ok {
+				return fmt.Errorf("setting not in file: %s", name)
+			}
+		}
+
+		for _, name := range names {
+			if err := ignoreDryRun(apiClient.SetAppSetting(app.ID, name, export.Settings[name])); err != nil {
+				return fmt.Errorf("failed to set %s: %w", name, err)
+			}
+		}
+
+		if isJSON() {
+			out, _ := json.MarshalIndent(map[string]interface{}{"app": app.ID, "settings": names}, "", "  ")
+			fmt.Println(string(out))
+			return nil
+		}
+		color.Green("Imported %d setting(s) to %s\n", len(names), app.Name)
+		return nil
+	},
+}
+
 func init() {
 	appsCmd.AddCommand(appsInstallCmd)
 	appsInstallCmd.Flags().String("channel", "", "App channel (live, test)")
@@ -237,6 +399,11 @@ func init() {
 	appsCmd.AddCommand(appsSettingsCmd)
 	appsSettingsCmd.AddCommand(appsSettingsListCmd)
 	appsSettingsCmd.AddCommand(appsSettingsSetCmd)
+	appsSettingsCmd.AddCommand(appsSettingsExportCmd)
+	appsSettingsCmd.AddCommand(appsSettingsImportCmd)
+	appsSettingsImportCmd.Flags().StringSlice("only", nil, "Only import these settings (comma-separated)")
 
 	appsCmd.AddCommand(appsUsageCmd)
+	appsUsageCmd.Flags().Bool("all", false, "Rank all running apps by resource usage")
+	appsUsageCmd.Flags().String("sort", "memory", "Rank by memory or cpu (with --all)")
 }
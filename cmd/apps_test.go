@@ -1,7 +1,13 @@
 package cmd
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/fishfisher/homeyctl/internal/fakehomey"
 )
 
 func TestAppsListCommand_Exists(t *testing.T) {
@@ -111,3 +117,107 @@ func TestAppsUsageCommand_Exists(t *testing.T) {
 		t.Errorf("expected command name 'usage', got '%s'", cmd.Name())
 	}
 }
+
+func seedUnhealthyApps() *fakehomey.Seed {
+	return &fakehomey.Seed{
+		Apps: fakehomey.Objects{
+			"com.ok":       {"id": "com.ok", "name": "Healthy", "version": "1.0.0", "enabled": true, "ready": true, "usage": map[string]interface{}{"cpu": 0.01, "memory": 20 << 20}},
+			"com.hung":     {"id": "com.hung", "name": "Hung", "version": "2.0.0", "enabled": true, "ready": false},
+			"com.crashed":  {"id": "com.crashed", "name": "Crashed", "version": "3.0.0", "enabled": true, "ready": true, "crashed": true, "crashedMessage": "TypeError"},
+			"com.disabled": {"id": "com.disabled", "name": "Disabled", "version": "4.0.0", "enabled": false, "ready": false},
+			"com.big":      {"id": "com.big", "name": "Big", "version": "1.0.0", "enabled": true, "ready": true, "usage": map[string]interface{}{"cpu": 0.002, "memory": 90 << 20}},
+		},
+	}
+}
+
+func TestAppsStatusAndRestartUnhealthy(t *testing.T) {
+	h := useFakeHomey(t, seedUnhealthyApps())
+
+	out, err := runCommand(t, "apps", "status")
+	if !errors.Is(err, errFalse) {
+		t.Fatalf("expected errFalse with unhealthy apps, got %v", err)
+	}
+	for _, want := range []string{"not ready", "crashed", "TypeError", "disabled", "Healthy"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("status output missing %q:\n%s", want, out)
+		}
+	}
+	out, _ = runCommand(t, "apps", "status", "--unhealthy")
+	if strings.Contains(out, "Healthy") || strings.Contains(out, "Disabled") {
+		t.Errorf("--unhealthy should only list problem apps:\n%s", out)
+	}
+
+	// The fake marks an app ready on restart but leaves crashed set, so
+	// the crashed app uses up its retries
+	_, err = runCommand(t, "apps", "restart", "--unhealthy", "--retries", "2", "--wait", "0s")
+	if err == nil || !strings.Contains(err.Error(), "1 of 2 app(s) did not recover") {
+		t.Fatalf("expected one app to fail, got %v", err)
+	}
+	restarts := map[string]int{}
+	for _, c := range h.Calls() {
+		if c.Method == "POST" && strings.HasSuffix(c.Path, "/restart") {
+			restarts[strings.Split(c.Path, "/")[5]]++
+		}
+	}
+	if restarts["com.hung"] != 1 || restarts["com.crashed"] != 2 || len(restarts) != 2 {
+		t.Errorf("unexpected restarts: %v", restarts)
+	}
+
+	if _, err := runCommand(t, "apps", "restart", "com.hung", "--unhealthy"); err == nil {
+		t.Error("expected an error for an app with --unhealthy")
+	}
+}
+
+func TestAppsUsageAll(t *testing.T) {
+	useFakeHomey(t, seedUnhealthyApps())
+
+	out, err := runCommand(t, "apps", "usage", "--all")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Index(out, "Big") > strings.Index(out, "Healthy") || !strings.Contains(out, "90.00 MB") {
+		t.Errorf("expected Big first by memory:\n%s", out)
+	}
+	if strings.Contains(out, "Hung") || strings.Contains(out, "Disabled") {
+		t.Errorf("expected only running apps:\n%s", out)
+	}
+	out, err = runCommand(t, "apps", "usage", "--all", "--sort", "cpu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Index(out, "Healthy") > strings.Index(out, "Big") {
+		t.Errorf("expected Healthy first by cpu:\n%s", out)
+	}
+	if _, err := runCommand(t, "apps", "usage", "--all", "--sort", "disk"); err == nil {
+		t.Error("expected an error for an invalid --sort")
+	}
+}
+
+func TestAppsSettingsExportImport(t *testing.T) {
+	h := useFakeHomey(t, &fakehomey.Seed{
+		Apps: fakehomey.Objects{
+			"com.tibber": {"id": "com.tibber", "name": "Tibber", "settings": map[string]interface{}{"token": "secret", "interval": 60, "debug": false}},
+			"com.copy":   {"id": "com.copy", "name": "Copy"},
+		},
+	})
+
+	file := filepath.Join(t.TempDir(), "tibber.json")
+	if _, err := runCommand(t, "apps", "settings", "export", "Tibber", file); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(file); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("expected a private settings file, got %v %v", info, err)
+	}
+
+	if _, err := runCommand(t, "apps", "settings", "import", file, "com.copy", "--only", "token,interval"); err != nil {
+		t.Fatal(err)
+	}
+	settings, _ := h.Object(fakehomey.Apps, "com.copy")["settings"].(map[string]interface{})
+	if settings["token"] != "secret" || settings["interval"] != float64(60) || len(settings) != 2 {
+		t.Errorf("unexpected imported settings: %v", settings)
+	}
+
+	if _, err := runCommand(t, "apps", "settings", "import", file, "com.copy", "--only", "missing"); err == nil {
+		t.Error("expected an error for a setting not in the file")
+	}
+}
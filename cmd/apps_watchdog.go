@@ -0,0 +1,345 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/fishfisher/homeyctl/internal/config"
+	"github.com/fishfisher/homeyctl/internal/notify"
+	"github.com/fishfisher/homeyctl/internal/watchdog"
+	"github.com/spf13/cobra"
+)
+
+// watchedApp is an app from the watchdog config, resolved on this Homey
+type watchedApp struct {
+	cfg     *watchdog.App
+	id      string
+	name    string
+	devices []string // Device ID per stale probe
+}
+
+// resolveWatchedApps looks up the configured apps and probe devices, so
+// typos fail at startup rather than on every check
+func resolveWatchedApps(c *watchdog.Config) ([]watchedApp, error) {
+	var apps []watchedApp
+	for _, a := range c.Apps {
+		app, err := findApp(a.App)
+		if err != nil {
+			return nil, err
+		}
+		w := watchedApp{cfg: a, id: app.ID, name: app.Name}
+		for _, p := range a.Stale {
+			d, err := findDevice(p.Device)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", a.App, err)
+			}
+			if _, ok := d.CapabilitiesObj[p.Capability]; !ok {
+				return nil, fmt.Errorf("%s: device %s has no capability %s", a.App, d.Name, p.Capability)
+			}
+			w.devices = append(w.devices, d.ID)
+		}
+		apps = append(apps, w)
+	}
+	return apps, nil
+}
+
+// observeApp reads an app's state, memory use when it has a limit, and
+// when its probe devices last reported
+func observeApp(w watchedApp, devices map[string]Device) (watchdog.Observation, error) {
+	data, err := apiClient.GetApp(w.id)
+	if err != nil {
+		return watchdog.Observation{}, err
+	}
+	var app App
+	if err := json.Unmarshal(data, &app); err != nil {
+		return watchdog.Observation{}, fmt.Errorf("failed to parse app: %w", err)
+	}
+	obs := watchdog.Observation{Enabled: app.Enabled, Ready: app.Ready, Crashed: app.Crashed, CrashedMessage: app.CrashedMessage, Memory: -1}
+
+	if w.cfg.Memory > 0 && app.Ready {
+		data, err := apiClient.GetAppUsage(w.id)
+		if err != nil {
+			return obs, err
+		}
+		var usage appUsage
+		if err := json.Unmarshal(data, &usage); err != nil {
+			return obs, fmt.Errorf("failed to parse app usage: %w", err)
+		}
+		obs.Memory = usage.Memory
+	}
+
+	for i, p := range w.cfg.Stale {
+		var updated time.Time
+		if c, ok := devices[w.devices[i]].CapabilitiesObj[p.Capability]; ok && c.LastUpdated != nil {
+			updated = *c.LastUpdated
+		}
+		obs.Updated = append(obs.Updated, updated)
+	}
+	return obs, nil
+}
+
+func watchdogStatePath() (string, error) {
+	configDir, err := config.Dir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find config dir: %w", err)
+	}
+	return filepath.Join(configDir, "history", "watchdog.json"), nil
+}
+
+// loadWatchdogState returns the restart history saved by earlier runs, or
+// nil when there is none
+func loadWatchdogState() (map[string]*watchdog.AppState, error) {
+	path, err := watchdogStatePath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state map[string]*watchdog.AppState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return state, nil
+}
+
+func saveWatchdogState(state map[string]*watchdog.AppState) error {
+	path, err := watchdogStatePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create history dir: %w", err)
+	}
+	data, _ := json.MarshalIndent(state, "", "  ")
+	return os.WriteFile(path, data, 0o644)
+}
+
+// watchdogEntry is one logged watchdog action
+type watchdogEntry struct {
+	Time time.Time `json:"time"`
+	Name string    `json:"name"`
+	watchdog.Decision
+	Error string `json:"error,omitempty"`
+}
+
+// message describes the action for logs and notifications
+func (e watchdogEntry) message(c *watchdog.Config) string {
+	problems := strings.Join(e.Problems, ", ")
+	switch {
+	case e.Error != "":
+		return fmt.Sprintf("Failed to restart %s (%s): %s", e.Name, problems, e.Error)
+	case e.Action == watchdog.Restart:
+		return fmt.Sprintf("Restarted %s (%s), restart %d of %d in %s", e.Name, problems, e.Restarts, c.MaxRestarts, c.Window.Duration)
+	case e.Action == watchdog.Recovered:
+		return fmt.Sprintf("%s is healthy again", e.Name)
+	case c.MaxRestarts == 0:
+		return fmt.Sprintf("%s is unhealthy (%s) but restarts are off (max_restarts: 0)", e.Name, problems)
+	}
+	return fmt.Sprintf("%s is unhealthy (%s) but has used its %d restarts in %s", e.Name, problems, c.MaxRestarts, c.Window.Duration)
+}
+
+func printWatchdogEntry(c *watchdog.Config, e watchdogEntry) {
+	if isJSON() {
+		out, _ := json.Marshal(e)
+		fmt.Println(string(out))
+		return
+	}
+	stamp := e.Time.Local().Format("2006-01-02 15:04:05")
+	switch {
+	case e.Error != "":
+		color.Red("%s %s\n", stamp, e.message(c))
+	case e.Action == watchdog.Exhausted:
+		color.Yellow("%s %s\n", stamp, e.message(c))
+	default:
+		color.Green("%s %s\n", stamp, e.message(c))
+	}
+}
+
+// appWatchdog checks the configured apps and restarts them as the
+// watchdog decides
+type appWatchdog struct {
+	cfg      *watchdog.Config
+	apps     []watchedApp
+	channels []string
+	dog      *watchdog.Watchdog
+}
+
+func (a *appWatchdog) hasProbes() bool {
+	for _, w := range a.apps {
+		if len(w.devices) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// check observes every app once. Apps that cannot be read are skipped
+// rather than restarted.
+func (a *appWatchdog) check(now time.Time) {
+	var devices map[string]Device
+	if a.hasProbes() {
+		data, err := apiClient.GetDevices()
+		if err == nil {
+			err = json.Unmarshal(data, &devices)
+		}
+		if err != nil {
+			color.Yellow("Warning: failed to read devices: %v\n", err)
+			return
+		}
+	}
+
+	for _, w := range a.apps {
+		obs, err := observeApp(w, devices)
+		if err != nil {
+			color.Yellow("Warning: %s: %v\n", w.name, err)
+			continue
+		}
+		d := a.dog.Check(w.id, w.cfg.Problems(obs, now), now)
+		if d.Action == watchdog.None {
+			continue
+		}
+
+		entry := watchdogEntry{Time: now, Name: w.name, Decision: d}
+		if d.Action == watchdog.Restart {
+			if err := ignoreDryRun(apiClient.RestartApp(w.id)); err != nil {
+				entry.Error = err.Error()
+			}
+		}
+		printWatchdogEntry(a.cfg, entry)
+		if len(a.channels) > 0 {
+			msg := notify.Message{Title: "homeyctl watchdog", Message: entry.message(a.cfg), Source: w.id, Time: now}
+			sendToChannels(a.channels, msg)
+		}
+	}
+}
+
+var appsWatchdogCmd = &cobra.Command{
+	Use:   "watchdog",
+	Short: "Restart apps that hang",
+	Long: `Watch apps and restart them when they hang, until interrupted.
+
+Every interval, each app in the config is checked for:
+  - not being ready, or having crashed (unless ready: false)
+  - memory use above its memory limit
+  - stale probes: devices the app drives that have not updated a
+    capability for a while
+
+An unhealthy app is restarted, then left alone for the backoff, which
+doubles after each restart up to max_backoff and resets once the app is
+healthy. An app gets at most max_restarts restarts per window; after that
+it is reported but not restarted until older restarts leave the window;
+max_restarts: 0 only reports. The restart history is kept in
+<config dir>/history/watchdog.json, so the budget and backoff also hold
+when --once runs from cron.
+
+Each restart, failure and recovery is logged, and sent to the notify
+channels in the config (see 'homeyctl notify send --help'). Disabled apps
+are skipped.
+
+Config (YAML):
+  interval: 1m          # default 1m
+  backoff: 2m           # default 2m
+  max_backoff: 1h       # default 1h
+  max_restarts: 5       # per app per window, default 5; 0 never restarts
+  window: 24h           # default 24h
+  notify: [timeline]
+  apps:
+    - app: Tibber
+      memory: 150MB
+      stale:
+        - device: Tibber Pulse
+          capability: measure_power
+          for: 5m
+    - app: com.sonos
+
+Examples:
+  homeyctl apps watchdog --config watchdog.yaml
+  homeyctl apps watchdog --config watchdog.yaml --once
+  homeyctl apps watchdog --config watchdog.yaml --dry-run`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configPath, _ := cmd.Flags().GetString("config")
+		once, _ := cmd.Flags().GetBool("once")
+		if configPath == "" {
+			return fmt.Errorf("--config is required")
+		}
+
+		c, err := watchdog.Load(configPath)
+		if err != nil {
+			return err
+		}
+		apps, err := resolveWatchedApps(c)
+		if err != nil {
+			return err
+		}
+		// Without notify channels, actions are only logged
+		var channels []string
+		if len(c.Notify) > 0 {
+			channels, err = resolveChannels(c.Notify)
+			if err != nil {
+				return err
+			}
+		}
+		dog := &appWatchdog{cfg: c, apps: apps, channels: channels, dog: watchdog.New(c)}
+		state, err := loadWatchdogState()
+		if err != nil {
+			return err
+		}
+		dog.dog.Restore(state)
+
+		// The restart history is saved after every check, so budget and
+		// backoff carry over between --once runs
+		check := func(now time.Time) error {
+			dog.check(now)
+			if dryRunFlag {
+				return nil
+			}
+			return saveWatchdogState(dog.dog.State())
+		}
+
+		if once {
+			return check(time.Now())
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		if !isJSON() {
+			fmt.Printf("Watching %d app(s) every %s (Ctrl+C to stop)\n", len(apps), c.Interval.Duration)
+		}
+		if err := check(time.Now()); err != nil {
+			return err
+		}
+
+		ticker := time.NewTicker(c.Interval.Duration)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case now := <-ticker.C:
+				if err := check(now); err != nil {
+					color.Yellow("Warning: failed to save watchdog state: %v\n", err)
+				}
+			}
+		}
+	},
+}
+
+func init() {
+	appsCmd.AddCommand(appsWatchdogCmd)
+	appsWatchdogCmd.Flags().String("config", "", "Watchdog config file (YAML)")
+	appsWatchdogCmd.Flags().Bool("once", false, "Check once and exit")
+}
@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fishfisher/homeyctl/internal/fakehomey"
+)
+
+func writeWatchdogConfig(t *testing.T, src string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "watchdog.yaml")
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestAppsWatchdogOnce(t *testing.T) {
+	h := useFakeHomey(t, &fakehomey.Seed{
+		Apps: fakehomey.Objects{
+			"com.tibber": {"id": "com.tibber", "name": "Tibber", "enabled": true, "ready": true, "usage": map[string]interface{}{"memory": 200 << 20}},
+			"com.sonos":  {"id": "com.sonos", "name": "Sonos", "enabled": true, "ready": true},
+			"com.off":    {"id": "com.off", "name": "Off", "enabled": false, "ready": false},
+		},
+		Devices: fakehomey.Objects{
+			"pulse": {"id": "pulse", "name": "Tibber Pulse", "class": "sensor", "capabilitiesObj": map[string]interface{}{
+				"measure_power": map[string]interface{}{"id": "measure_power", "value": 1200, "lastUpdated": "2020-01-01T00:00:00Z"},
+			}},
+		},
+	})
+
+	config := writeWatchdogConfig(t, `
+notify: [timeline]
+apps:
+  - app: Tibber
+    memory: 150MB
+    stale:
+      - device: Tibber Pulse
+        capability: measure_power
+        for: 5m
+  - app: com.sonos
+  - app: Off
+`)
+	out, err := runCommand(t, "apps", "watchdog", "--config", config, "--once", "--json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var entry watchdogEntry
+	if err := json.Unmarshal([]byte(strings.TrimSpace(out)), &entry); err != nil {
+		t.Fatalf("expected one JSON line, got %q: %v", out, err)
+	}
+	if entry.App != "com.tibber" || entry.Action != "restart" || entry.Restarts != 1 || len(entry.Problems) != 2 {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+	if !strings.Contains(entry.Problems[0], "memory 200.0 MB above 150.0 MB") || !strings.Contains(entry.Problems[1], "no measure_power reading from Tibber Pulse") {
+		t.Errorf("unexpected problems: %v", entry.Problems)
+	}
+
+	var restarted []string
+	for _, c := range h.Calls() {
+		if c.Method == "POST" && strings.HasSuffix(c.Path, "/restart") {
+			restarted = append(restarted, c.Path)
+		}
+	}
+	if len(restarted) != 1 || !strings.Contains(restarted[0], "com.tibber") {
+		t.Errorf("expected only Tibber to be restarted, got %v", restarted)
+	}
+	if ids := h.IDs(fakehomey.Notifications); len(ids) != 1 || !strings.Contains(h.Object(fakehomey.Notifications, ids[0])["excerpt"].(string), "Restarted Tibber") {
+		t.Errorf("expected a timeline notification, got %v", ids)
+	}
+}
+
+func TestAppsWatchdogConfigErrors(t *testing.T) {
+	useFakeHomey(t, &fakehomey.Seed{
+		Apps: fakehomey.Objects{"com.tibber": {"id": "com.tibber", "name": "Tibber", "enabled": true, "ready": true}},
+		Devices: fakehomey.Objects{
+			"pulse": {"id": "pulse", "name": "Tibber Pulse", "capabilitiesObj": map[string]interface{}{"measure_power": map[string]interface{}{"id": "measure_power"}}},
+		},
+	})
+
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"apps:\n  - app: Nope", "app not found: Nope"},
+		{"apps:\n  - app: Tibber\n    stale:\n      - device: Pulse 2\n        capability: measure_power\n        for: 5m", "Pulse 2"},
+		{"apps:\n  - app: Tibber\n    stale:\n      - device: Tibber Pulse\n        capability: meter_power\n        for: 5m", "has no capability meter_power"},
+		{"notify: [pager]\napps:\n  - app: Tibber", "unknown channel: pager"},
+	}
+	for _, tt := range tests {
+		_, err := runCommand(t, "apps", "watchdog", "--config", writeWatchdogConfig(t, tt.src), "--once")
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q: expected error containing %q, got %v", tt.src, tt.want, err)
+		}
+	}
+	if _, err := runCommand(t, "apps", "watchdog"); err == nil || !strings.Contains(err.Error(), "--config is required") {
+		t.Errorf("expected --config to be required, got %v", err)
+	}
+}
+
+func TestAppsWatchdogWithoutNotify(t *testing.T) {
+	h := useFakeHomey(t, &fakehomey.Seed{
+		Apps: fakehomey.Objects{"com.sonos": {"id": "com.sonos", "name": "Sonos", "enabled": true, "ready": false}},
+	})
+
+	out, err := runCommand(t, "apps", "watchdog", "--config", writeWatchdogConfig(t, "apps: [{app: com.sonos}]"), "--once")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "Restarted Sonos (not ready)") {
+		t.Errorf("expected the restart to be logged, got %q", out)
+	}
+	if ids := h.IDs(fakehomey.Notifications); len(ids) != 0 {
+		t.Errorf("expected no notifications, got %v", ids)
+	}
+}
+
+func TestAppsWatchdogOnce_KeepsRestartHistory(t *testing.T) {
+	// Restarting does not bring the memory use down
+	h := useFakeHomey(t, &fakehomey.Seed{
+		Apps: fakehomey.Objects{"com.tibber": {"id": "com.tibber", "name": "Tibber", "enabled": true, "ready": true, "usage": map[string]interface{}{"memory": 200 << 20}}},
+	})
+	configPath := writeWatchdogConfig(t, "backoff: 1h\napps: [{app: Tibber, memory: 150MB}]")
+
+	// The second run is within the backoff of the first restart
+	for i := 0; i < 2; i++ {
+		if _, err := runCommand(t, "apps", "watchdog", "--config", configPath, "--once"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var restarts int
+	for _, c := range h.Calls() {
+		if c.Method == "POST" && strings.HasSuffix(c.Path, "/restart") {
+			restarts++
+		}
+	}
+	if restarts != 1 {
+		t.Errorf("expected one restart across both runs, got %d", restarts)
+	}
+}
+
+func TestAppsWatchdogMaxRestartsZero(t *testing.T) {
+	h := useFakeHomey(t, &fakehomey.Seed{
+		Apps: fakehomey.Objects{"com.sonos": {"id": "com.sonos", "name": "Sonos", "enabled": true, "ready": false}},
+	})
+
+	out, err := runCommand(t, "apps", "watchdog", "--config", writeWatchdogConfig(t, "max_restarts: 0\napps: [{app: com.sonos}]"), "--once")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "restarts are off") {
+		t.Errorf("expected max_restarts: 0 to only report, got %q", out)
+	}
+	for _, c := range h.Calls() {
+		if strings.HasSuffix(c.Path, "/restart") {
+			t.Errorf("expected no restart, got %s %s", c.Method, c.Path)
+		}
+	}
+}
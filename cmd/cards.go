@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var cardsCmd = &cobra.Command{
+	Use:   "cards",
+	Short: "Run flow cards directly",
+	Long: `Run flow action and condition cards without building a flow first.
+
+Use 'homeyctl flows cards' to find card IDs.`,
+}
+
+var cardsRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Run an action or evaluate a condition card",
+	Long: `Run an action card or evaluate a condition card.
+
+Arguments are given as --arg name=value and converted by the argument type
+in the card definition:
+  text           as given
+  number, range  a number, checked against the card's min and max
+  checkbox       true or false
+  dropdown       a choice ID or label
+  autocomplete   a name or ID, looked up through the app's autocomplete
+  device         a device name or ID
+  time, date     HH:MM, DD-MM-YYYY
+  color          #rrggbb
+
+Unknown arguments and missing required arguments are errors.`,
+}
+
+// runFlowCardArgs finds a card and builds its arguments from --arg flags
+func runFlowCardArgs(cmd *cobra.Command, cardType, id string) (*flowCardDef, map[string]interface{}, error) {
+	argPairs, _ := cmd.Flags().GetStringArray("arg")
+	given, err := parseCardArgs(argPairs)
+	if err != nil {
+		return nil, nil, err
+	}
+	card, err := findFlowCard(cardType, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	args, err := buildCardArgs(cardType, card, given)
+	if err != nil {
+		return nil, nil, err
+	}
+	return card, args, nil
+}
+
+// conditionResult reads a condition run response, which is either a
+// boolean or an object with a result
+func conditionResult(data json.RawMessage) (bool, error) {
+	var result bool
+	if err := json.Unmarshal(data, &result); err == nil {
+		return result, nil
+	}
+	var obj struct {
+		Result *bool `json:"result"`
+	}
+	if err := json.Unmarshal(data, &obj); err != nil || obj.Result == nil {
+		return false, fmt.Errorf("unexpected condition result: %s", string(data))
+	}
+	return *obj.Result, nil
+}
+
+var cardsRunActionCmd = &cobra.Command{
+	Use:   "action <card-id>",
+	Short: "Run a flow action card",
+	Long: `Run a flow action card with typed arguments.
+
+Examples:
+  homeyctl cards run action homey:manager:notifications:create_notification --arg text="Hello"
+  homeyctl cards run action "homey:device:<device-id>:dim" --arg dim=0.4
+  homeyctl cards run action "homey:app:com.sonos:play_favorite" --arg favorite="Morning jazz"`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runActionCard(cmd, args[0])
+	},
+}
+
+var cardsRunConditionCmd = &cobra.Command{
+	Use:   "condition <card-id>",
+	Short: "Evaluate a flow condition card",
+	Long: `Evaluate a flow condition card and print true or false.
+
+The exit status is 0 when the condition holds, 1 when it does not and 2
+when it could not be evaluated, so it can be used directly in shell
+scripts. Conditions change nothing, so they are evaluated under --dry-run
+too.
+
+Examples:
+  homeyctl cards run condition "homey:device:<device-id>:on"
+  homeyctl cards run condition homey:manager:logic:lt --arg value=5 --arg comparator=10
+  if homeyctl cards run condition "homey:manager:presence:someone_home"; then echo home; fi`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		card, cardArgs, err := runFlowCardArgs(cmd, "condition", args[0])
+		if err != nil {
+			return &exitCodeError{code: 2, err: err}
+		}
+		data, err := apiClient.RunFlowCardCondition(card.OwnerURI(), card.ID, cardArgs)
+		if err != nil {
+			return &exitCodeError{code: 2, err: err}
+		}
+		result, err := conditionResult(data)
+		if err != nil {
+			return &exitCodeError{code: 2, err: err}
+		}
+
+		if isJSON() {
+			out, _ := json.MarshalIndent(map[string]interface{}{"card": card.ID, "result": result}, "", "  ")
+			fmt.Println(string(out))
+		} else {
+			fmt.Println(result)
+		}
+		if !result {
+			cmd.SilenceUsage = true
+			return errFalse
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cardsCmd)
+	cardsCmd.AddCommand(cardsRunCmd)
+	cardsRunCmd.AddCommand(cardsRunActionCmd)
+	cardsRunCmd.AddCommand(cardsRunConditionCmd)
+	cardsRunActionCmd.Flags().StringArray("arg", nil, "Card argument as name=value (repeatable)")
+	cardsRunConditionCmd.Flags().StringArray("arg", nil, "Card argument as name=value (repeatable)")
+}
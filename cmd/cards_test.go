@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/fishfisher/homeyctl/internal/fakehomey"
+)
+
+func TestConvertCardArg(t *testing.T) {
+	min, max := 0.0, 1.0
+	card := &flowCardDef{ID: "homey:app:test:card"}
+	tests := []struct {
+		arg   flowCardArg
+		value string
+		want  interface{}
+		err   string
+	}{
+		{flowCardArg{Name: "t", Type: "text"}, "42", "42", ""},
+		{flowCardArg{Name: "n", Type: "number"}, "21.5", 21.5, ""},
+		{flowCardArg{Name: "n", Type: "number"}, "warm", nil, "not a number"},
+		{flowCardArg{Name: "dim", Type: "range", Min: &min, Max: &max}, "1.5", nil, "outside 0..1"},
+		{flowCardArg{Name: "c", Type: "checkbox"}, "true", true, ""},
+		{flowCardArg{Name: "c", Type: "checkbox"}, "yes", nil, "not true or false"},
+		{flowCardArg{Name: "d", Type: "dropdown", Values: []flowCardArgValue{{ID: "up", Label: "Up"}, {ID: "down", Label: "Down"}}}, "down", "down", ""},
+		{flowCardArg{Name: "d", Type: "dropdown", Values: []flowCardArgValue{{ID: "up", Label: "Up"}}}, "UP", "up", ""},
+		{flowCardArg{Name: "d", Type: "dropdown", Values: []flowCardArgValue{{ID: "up", Label: "Up"}}}, "left", nil, "not one of up"},
+		{flowCardArg{Name: "at", Type: "time"}, "07:30", "07:30", ""},
+		{flowCardArg{Name: "at", Type: "time"}, "7.30", nil, "not a time"},
+		{flowCardArg{Name: "on", Type: "date"}, "24-12-2026", "24-12-2026", ""},
+		{flowCardArg{Name: "col", Type: "color"}, "#ff8800", "#ff8800", ""},
+		{flowCardArg{Name: "col", Type: "color"}, "orange", nil, "not a color"},
+		{flowCardArg{Name: "x"}, "false", false, ""},
+	}
+	for _, tt := range tests {
+		got, err := convertCardArg("action", card, &tt.arg, tt.value)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s=%q: expected error %q, got %v", tt.arg.Name, tt.value, tt.err, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%s=%q: got %v (%T), %v, want %v", tt.arg.Name, tt.value, got, got, err, tt.want)
+		}
+	}
+}
+
+func TestConditionResult(t *testing.T) {
+	for data, want := range map[string]bool{`true`: true, `false`: false, `{"result":true}`: true, `{"result":false}`: false} {
+		if got, err := conditionResult(json.RawMessage(data)); err != nil || got != want {
+			t.Errorf("conditionResult(%s) = %v, %v", data, got, err)
+		}
+	}
+	if _, err := conditionResult(json.RawMessage(`{}`)); err == nil {
+		t.Error("expected error for a response without a result")
+	}
+}
+
+func TestCardsRun(t *testing.T) {
+	h := useFakeHomey(t, &fakehomey.Seed{
+		Devices: fakehomey.Objects{"lamp": {"id": "lamp", "name": "Kitchen lamp"}},
+		FlowCards: fakehomey.FlowCards{
+			Action: []map[string]interface{}{
+				{"id": "homey:app:com.sonos:play_favorite", "uri": "homey:app:com.sonos", "args": []interface{}{
+					map[string]interface{}{"name": "speaker", "type": "device"},
+					map[string]interface{}{"name": "favorite", "type": "autocomplete", "values": []interface{}{
+						map[string]interface{}{"id": "fav1", "name": "Morning jazz"},
+						map[string]interface{}{"id": "fav2", "name": "Morning news"},
+						map[string]interface{}{"id": "fav3", "name": "Evening"},
+					}},
+					map[string]interface{}{"name": "volume", "type": "range", "min": 0, "max": 1, "required": false},
+				}},
+			},
+			Condition: []map[string]interface{}{
+				{"id": "homey:manager:logic:lt", "uri": "homey:manager:logic", "result": false, "args": []interface{}{
+					map[string]interface{}{"name": "value", "type": "number"},
+					map[string]interface{}{"name": "comparator", "type": "number"},
+				}},
+				{"id": "homey:device:lamp:on", "args": []interface{}{}},
+			},
+		},
+	})
+
+	run := func(args ...string) map[string]interface{} {
+		t.Helper()
+		if _, err := runCommand(t, append([]string{"cards", "run", "action", "play_favorite"}, args...)...); err != nil {
+			t.Fatal(err)
+		}
+		calls := h.Calls()
+		return calls[len(calls)-1].Body.(map[string]interface{})["args"].(map[string]interface{})
+	}
+	args := run("--arg", "speaker=Kitchen lamp", "--arg", "favorite=morning jazz", "--arg", "volume=0.3")
+	if args["favorite"].(map[string]interface{})["id"] != "fav1" || args["speaker"].(map[string]interface{})["id"] != "lamp" || args["volume"] != 0.3 {
+		t.Errorf("unexpected args: %v", args)
+	}
+	if args := run("--arg", "speaker=lamp", "--arg", "favorite=fav3"); args["favorite"].(map[string]interface{})["name"] != "Evening" {
+		t.Errorf("expected favorite by ID, got %v", args)
+	}
+	if args := run("--arg", "speaker=lamp", "--arg", "favorite=news"); args["favorite"].(map[string]interface{})["id"] != "fav2" {
+		t.Errorf("expected the single search match, got %v", args)
+	}
+	if _, err := runCommand(t, "cards", "run", "action", "play_favorite", "--arg", "speaker=lamp", "--arg", "favorite=morning"); err == nil || !strings.Contains(err.Error(), "matches 2 choices") {
+		t.Errorf("expected ambiguous autocomplete error, got %v", err)
+	}
+	if _, err := runCommand(t, "cards", "run", "action", "play_favorite", "--arg", "speaker=lamp", "--arg", "favorite=fav1", "--arg", "volume=2"); err == nil || !strings.Contains(err.Error(), "outside 0..1") {
+		t.Errorf("expected range error, got %v", err)
+	}
+
+	out, err := runCommand(t, "cards", "run", "condition", "homey:device:lamp:on")
+	if err != nil || strings.TrimSpace(out) != "true" {
+		t.Errorf("expected true, got %q, %v", out, err)
+	}
+	out, err = runCommand(t, "cards", "run", "condition", "lt", "--arg", "value=5", "--arg", "comparator=3")
+	if err != errFalse || strings.TrimSpace(out) != "false" {
+		t.Errorf("expected false with errFalse, got %q, %v", out, err)
+	}
+	calls := h.Calls()
+	if last := calls[len(calls)-1]; last.Body.(map[string]interface{})["args"].(map[string]interface{})["value"] != 5.0 {
+		t.Errorf("expected numeric args, got %v", last.Body)
+	}
+
+	// Errors exit 2, so scripts can tell them from a false condition
+	var exitErr *exitCodeError
+	if _, err := runCommand(t, "cards", "run", "condition", "no_such_card"); !errors.As(err, &exitErr) || exitErr.code != 2 {
+		t.Errorf("expected exit status 2 for an unknown card, got %v", err)
+	}
+
+	// A dry run still evaluates the condition
+	out, err = runCommand(t, "cards", "run", "condition", "homey:device:lamp:on", "--dry-run")
+	if err != nil || strings.TrimSpace(out) != "true" {
+		t.Errorf("expected the condition to be evaluated in a dry run, got %q, %v", out, err)
+	}
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -24,6 +25,8 @@ var dashboardsCmd = &cobra.Command{
 	Long:  `List, create, update, and delete dashboards.`,
 }
 
+var errDashboardNotFound = errors.New("dashboard not found")
+
 // findDashboard finds a dashboard by name or ID
 func findDashboard(nameOrID string) (*Dashboard, error) {
 	data, err := apiClient.GetDashboards()
@@ -42,7 +45,7 @@ func findDashboard(nameOrID string) (*Dashboard, error) {
 		}
 	}
 
-	return nil, fmt.Errorf("dashboard not found: %s", nameOrID)
+	return nil, fmt.Errorf("%w: %s", errDashboardNotFound, nameOrID)
 }
 
 var dashboardsListCmd = &cobra.Command{
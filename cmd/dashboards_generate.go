@@ -0,0 +1,445 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// deviceRefPrefix marks a device by name in exported dashboards and
+// templates, like "@device:Kitchen lamp" or "@device:Kitchen/Lamp" when
+// the name alone is ambiguous
+const deviceRefPrefix = "@device:"
+
+// dashboardSection groups devices into one dashboard column
+type dashboardSection struct {
+	Name    string
+	Classes []string
+	Caps    []string // Any of these capabilities, matched as prefixes
+}
+
+// dashboardSections are checked in order; a device goes in the first
+// section that matches its class, then the first that matches a capability
+var dashboardSections = []dashboardSection{
+	{Name: "Lights", Classes: []string{"light"}, Caps: []string{"dim", "light_hue", "light_temperature"}},
+	{Name: "Climate", Classes: []string{"thermostat", "heater", "fan", "airconditioning", "airpurifier", "dehumidifier", "humidifier"}, Caps: []string{"target_temperature", "thermostat_mode"}},
+	{Name: "Security", Classes: []string{"lock", "doorbell", "camera", "homealarm", "garagedoor"}, Caps: []string{"locked", "alarm_contact", "alarm_motion", "alarm_smoke", "alarm_water", "alarm_co"}},
+	{Name: "Media", Classes: []string{"speaker", "tv", "amplifier", "remote", "mediaplayer"}, Caps: []string{"speaker_playing", "volume_set"}},
+	{Name: "Blinds", Classes: []string{"blinds", "curtain", "sunshade", "windowcoverings"}, Caps: []string{"windowcoverings_"}},
+	{Name: "Energy", Classes: []string{"socket", "evcharger", "solarpanel", "battery"}, Caps: []string{"meter_power", "measure_power"}},
+	{Name: "Sensors", Classes: []string{"sensor"}, Caps: []string{"measure_", "alarm_"}},
+}
+
+// dashboardSectionFor picks the column a device belongs in
+func dashboardSectionFor(d Device) string {
+	for _, s := range dashboardSections {
+		for _, c := range s.Classes {
+			if d.Class == c {
+				return s.Name
+			}
+		}
+	}
+	for _, s := range dashboardSections {
+		for _, prefix := range s.Caps {
+			for capID := range d.CapabilitiesObj {
+				if capID == prefix || (strings.HasSuffix(prefix, "_") && strings.HasPrefix(capID, prefix)) {
+					return s.Name
+				}
+			}
+		}
+	}
+	return "Other"
+}
+
+// generateDashboard lays out devices in columns by section, one device
+// widget each, sorted by name
+func generateDashboard(name string, devices []Device) map[string]interface{} {
+	bySection := map[string][]Device{}
+	for _, d := range devices {
+		s := dashboardSectionFor(d)
+		bySection[s] = append(bySection[s], d)
+	}
+
+	order := []string{}
+	for _, s := range dashboardSections {
+		order = append(order, s.Name)
+	}
+	order = append(order, "Other")
+
+	columns := []interface{}{}
+	for _, section := range order {
+		list := bySection[section]
+		if len(list) == 0 {
+			continue
+		}
+		sort.Slice(list, func(i, j int) bool { return strings.ToLower(list[i].Name) < strings.ToLower(list[j].Name) })
+		widgets := []interface{}{}
+		for _, d := range list {
+			widgets = append(widgets, map[string]interface{}{"id": newCardID(), "type": "device", "deviceId": d.ID})
+		}
+		columns = append(columns, map[string]interface{}{"id": newCardID(), "title": section, "widgets": widgets})
+	}
+	return map[string]interface{}{"name": name, "columns": columns}
+}
+
+// deviceNames maps device IDs to portable references and back
+type deviceNames struct {
+	byID  map[string]string   // ID to reference
+	byRef map[string][]string // Lowercase name or zone/name to IDs
+}
+
+func newDeviceNames(zones map[string]Zone, devices map[string]Device) *deviceNames {
+	n := &deviceNames{byID: map[string]string{}, byRef: map[string][]string{}}
+	count := map[string]int{}
+	for _, d := range devices {
+		count[strings.ToLower(d.Name)]++
+	}
+	for _, d := range devices {
+		qualified := zones[d.Zone].Name + "/" + d.Name
+		n.byRef[strings.ToLower(d.Name)] = append(n.byRef[strings.ToLower(d.Name)], d.ID)
+		n.byRef[strings.ToLower(qualified)] = append(n.byRef[strings.ToLower(qualified)], d.ID)
+		if count[strings.ToLower(d.Name)] > 1 {
+			n.byID[d.ID] = qualified
+		} else {
+			n.byID[d.ID] = d.Name
+		}
+	}
+	return n
+}
+
+// walkStrings returns v with every string replaced by fn
+func walkStrings(v interface{}, fn func(string) string) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			out[k] = walkStrings(val, fn)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, val := range t {
+			out[i] = walkStrings(val, fn)
+		}
+		return out
+	case string:
+		return fn(t)
+	}
+	return v
+}
+
+// exportDeviceRefs replaces device IDs anywhere in a dashboard with
+// @device: references
+func exportDeviceRefs(v interface{}, names *deviceNames) interface{} {
+	return walkStrings(v, func(s string) string {
+		if ref, ok := names.byID[s]; ok {
+			return deviceRefPrefix + ref
+		}
+		return s
+	})
+}
+
+// importDeviceRefs replaces @device: references with device IDs
+func importDeviceRefs(v interface{}, names *deviceNames) (interface{}, error) {
+	var missing, ambiguous []string
+	out := walkStrings(v, func(s string) string {
+		ref, ok := strings.CutPrefix(s, deviceRefPrefix)
+		if !ok {
+			return s
+		}
+		ids := names.byRef[strings.ToLower(ref)]
+		switch len(ids) {
+		case 0:
+			missing = append(missing, ref)
+		case 1:
+			return ids[0]
+		default:
+			ambiguous = append(ambiguous, ref)
+		}
+		return s
+	})
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("device(s) not found: %s", strings.Join(missing, ", "))
+	}
+	if len(ambiguous) > 0 {
+		return nil, fmt.Errorf("device name(s) used more than once, use Zone/Name: %s", strings.Join(ambiguous, ", "))
+	}
+	return out, nil
+}
+
+var dashboardVarPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// substituteDashboardVars fills in {{ name }} variables in every string
+func substituteDashboardVars(v interface{}, vars map[string]string) (interface{}, error) {
+	undefined := map[string]bool{}
+	out := walkStrings(v, func(s string) string {
+		return dashboardVarPattern.ReplaceAllStringFunc(s, func(m string) string {
+			name := dashboardVarPattern.FindStringSubmatch(m)[1]
+			value, ok := vars[name]
+			if !ok {
+				undefined[name] = true
+				return m
+			}
+			return value
+		})
+	})
+	if len(undefined) > 0 {
+		var names []string
+		for name := range undefined {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("template variable(s) not set: %s (use --var name=value)", strings.Join(names, ", "))
+	}
+	return out, nil
+}
+
+// freshDashboardIDs gives columns and widgets new IDs, so an imported
+// layout does not share them with the dashboard it came from
+func freshDashboardIDs(dashboard map[string]interface{}) {
+	columns, _ := dashboard["columns"].([]interface{})
+	for _, c := range columns {
+		column, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if _, ok := column["id"]; ok {
+			column["id"] = newCardID()
+		}
+		widgets, _ := column["widgets"].([]interface{})
+		for _, w := range widgets {
+			if widget, ok := w.(map[string]interface{}); ok {
+				if _, ok := widget["id"]; ok {
+					widget["id"] = newCardID()
+				}
+			}
+		}
+	}
+}
+
+// saveDashboard creates a dashboard, or replaces the one with the same
+// name when replace is set
+func saveDashboard(dashboard map[string]interface{}, replace bool) error {
+	name, _ := dashboard["name"].(string)
+	if name == "" {
+		return fmt.Errorf("dashboard has no name")
+	}
+	existing, err := findDashboard(name)
+	if err != nil && !errors.Is(err, errDashboardNotFound) {
+		return err
+	}
+	if existing != nil {
+		if !replace {
+			return fmt.Errorf("dashboard %q already exists (use --replace to overwrite it)", name)
+		}
+		if err := apiClient.UpdateDashboard(existing.ID, dashboard); err != nil {
+			return err
+		}
+		color.Green("Updated dashboard: %s\n", name)
+		return nil
+	}
+
+	result, err := apiClient.CreateDashboard(dashboard)
+	if err != nil {
+		return err
+	}
+	if isJSON() {
+		outputJSON(result)
+		return nil
+	}
+	color.Green("Created dashboard: %s\n", name)
+	return nil
+}
+
+var dashboardsGenerateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generate a dashboard from the devices in a zone",
+	Long: `Generate a dashboard with a widget for every device in a zone.
+
+Devices are put in columns by class and capabilities: Lights, Climate,
+Security, Media, Blinds, Energy, Sensors and Other. Empty columns are left
+out.
+
+Examples:
+  homeyctl dashboards generate --zone Kitchen
+  homeyctl dashboards generate --zone "Ground floor" --subzones --name Downstairs
+  homeyctl dashboards generate --zone Kitchen --print > kitchen.json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		zoneName, _ := cmd.Flags().GetString("zone")
+		name, _ := cmd.Flags().GetString("name")
+		subzones, _ := cmd.Flags().GetBool("subzones")
+		printOnly, _ := cmd.Flags().GetBool("print")
+		replace, _ := cmd.Flags().GetBool("replace")
+
+		if zoneName == "" {
+			return fmt.Errorf("--zone is required")
+		}
+		zone, err := findZone(zoneName)
+		if err != nil {
+			return err
+		}
+		zones, devices, err := fetchZonesAndDevices()
+		if err != nil {
+			return err
+		}
+		zoneIDs := map[string]bool{zone.ID: true}
+		if subzones {
+			zoneIDs = zoneDescendants(zones, zone.ID)
+		}
+
+		var inZone []Device
+		for _, d := range devices {
+			if zoneIDs[d.Zone] {
+				inZone = append(inZone, d)
+			}
+		}
+		if len(inZone) == 0 {
+			return fmt.Errorf("no devices in zone %s", zone.Name)
+		}
+		if name == "" {
+			name = zone.Name
+		}
+
+		dashboard := generateDashboard(name, inZone)
+		if printOnly {
+			out, _ := json.MarshalIndent(dashboard, "", "  ")
+			fmt.Println(string(out))
+			return nil
+		}
+		return saveDashboard(dashboard, replace)
+	},
+}
+
+var dashboardsExportCmd = &cobra.Command{
+	Use:   "export <name-or-id> [file]",
+	Short: "Export a dashboard with device names instead of IDs",
+	Long: `Export a dashboard so its layout can be imported on another Homey.
+
+Device IDs are replaced with "@device:<name>" references, or
+"@device:<zone>/<name>" when several devices share a name. The file can
+be edited into a template with {{ variable }} placeholders for
+'dashboards import --var'.
+
+Examples:
+  homeyctl dashboards export "Kitchen" kitchen.json
+  homeyctl dashboards export "Kitchen" > kitchen.json`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		found, err := findDashboard(args[0])
+		if err != nil {
+			return err
+		}
+		data, err := apiClient.GetDashboard(found.ID)
+		if err != nil {
+			return err
+		}
+		var dashboard map[string]interface{}
+		if err := json.Unmarshal(data, &dashboard); err != nil {
+			return fmt.Errorf("failed to parse dashboard: %w", err)
+		}
+		delete(dashboard, "id")
+
+		zones, devices, err := fetchZonesAndDevices()
+		if err != nil {
+			return err
+		}
+		exported := exportDeviceRefs(dashboard, newDeviceNames(zones, devices))
+		out, _ := json.MarshalIndent(exported, "", "  ")
+
+		if len(args) == 2 {
+			if err := os.WriteFile(args[1], append(out, '\n'), 0o644); err != nil {
+				return fmt.Errorf("failed to write file: %w", err)
+			}
+			color.Green("Exported dashboard %s to %s\n", found.Name, args[1])
+			return nil
+		}
+		fmt.Println(string(out))
+		return nil
+	},
+}
+
+var dashboardsImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Import a dashboard, resolving device names to this Homey",
+	Long: `Import a dashboard exported with 'dashboards export', or a template.
+
+"@device:<name>" references are resolved to devices on this Homey by
+name. Templates can use {{ variable }} placeholders anywhere in strings,
+including the dashboard name and device references, filled in with --var.
+Columns and widgets get new IDs.
+
+Examples:
+  homeyctl dashboards import kitchen.json
+  homeyctl dashboards import kitchen.json --name "Kitchen (copy)"
+  homeyctl dashboards import room.json --var room=Bedroom --var light="Bedroom lamp"
+  homeyctl dashboards import kitchen.json --replace`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, _ := cmd.Flags().GetString("name")
+		varPairs, _ := cmd.Flags().GetStringArray("var")
+		replace, _ := cmd.Flags().GetBool("replace")
+
+		vars := map[string]string{}
+		for _, pair := range varPairs {
+			k, v, ok := strings.Cut(pair, "=")
+			if !ok || k == "" {
+				return fmt.Errorf("invalid --var %q (expected name=value)", pair)
+			}
+			vars[k] = v
+		}
+
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to read file: %w", err)
+		}
+		var raw map[string]interface{}
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return fmt.Errorf("invalid JSON: %w", err)
+		}
+
+		substituted, err := substituteDashboardVars(raw, vars)
+		if err != nil {
+			return err
+		}
+		zones, devices, err := fetchZonesAndDevices()
+		if err != nil {
+			return err
+		}
+		resolved, err := importDeviceRefs(substituted, newDeviceNames(zones, devices))
+		if err != nil {
+			return err
+		}
+
+		dashboard := resolved.(map[string]interface{})
+		delete(dashboard, "id")
+		if name != "" {
+			dashboard["name"] = name
+		}
+		freshDashboardIDs(dashboard)
+		return saveDashboard(dashboard, replace)
+	},
+}
+
+func init() {
+	dashboardsCmd.AddCommand(dashboardsGenerateCmd)
+	dashboardsCmd.AddCommand(dashboardsExportCmd)
+	dashboardsCmd.AddCommand(dashboardsImportCmd)
+
+	dashboardsGenerateCmd.Flags().String("zone", "", "Zone name or ID (required)")
+	dashboardsGenerateCmd.Flags().String("name", "", "Dashboard name (default the zone name)")
+	dashboardsGenerateCmd.Flags().Bool("subzones", false, "Include devices in zones below the zone")
+	dashboardsGenerateCmd.Flags().Bool("print", false, "Print the dashboard JSON instead of creating it")
+	dashboardsGenerateCmd.Flags().Bool("replace", false, "Overwrite a dashboard with the same name")
+
+	dashboardsImportCmd.Flags().String("name", "", "Dashboard name (default the name in the file)")
+	dashboardsImportCmd.Flags().StringArray("var", nil, "Template variable as name=value (repeatable)")
+	dashboardsImportCmd.Flags().Bool("replace", false, "Overwrite a dashboard with the same name")
+}
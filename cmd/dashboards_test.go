@@ -1,7 +1,14 @@
 package cmd
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/fishfisher/homeyctl/internal/fakehomey"
 )
 
 func TestDashboardsListCommand_Exists(t *testing.T) {
@@ -61,3 +68,163 @@ func TestDashboardsDeleteCommand_Exists(t *testing.T) {
 		t.Errorf("expected command name 'delete', got '%s'", cmd.Name())
 	}
 }
+
+func TestDashboardSectionFor(t *testing.T) {
+	caps := func(ids ...string) map[string]Capability {
+		m := map[string]Capability{}
+		for _, id := range ids {
+			m[id] = Capability{ID: id}
+		}
+		return m
+	}
+	tests := []struct {
+		device Device
+		want   string
+	}{
+		{Device{Class: "light", CapabilitiesObj: caps("onoff")}, "Lights"},
+		{Device{Class: "socket", CapabilitiesObj: caps("onoff", "dim")}, "Energy"},
+		{Device{Class: "other", CapabilitiesObj: caps("onoff", "dim")}, "Lights"},
+		{Device{Class: "thermostat"}, "Climate"},
+		{Device{Class: "sensor", CapabilitiesObj: caps("alarm_contact")}, "Sensors"},
+		{Device{Class: "other", CapabilitiesObj: caps("alarm_contact")}, "Security"},
+		{Device{Class: "other", CapabilitiesObj: caps("measure_humidity")}, "Sensors"},
+		{Device{Class: "other", CapabilitiesObj: caps("windowcoverings_state")}, "Blinds"},
+		{Device{Class: "button"}, "Other"},
+	}
+	for _, tt := range tests {
+		if got := dashboardSectionFor(tt.device); got != tt.want {
+			t.Errorf("%s %v: got %s, want %s", tt.device.Class, tt.device.CapabilitiesObj, got, tt.want)
+		}
+	}
+}
+
+func TestDashboardDeviceRefsAndVars(t *testing.T) {
+	zones := map[string]Zone{"k": {ID: "k", Name: "Kitchen"}, "b": {ID: "b", Name: "Bedroom"}}
+	devices := map[string]Device{
+		"d1": {ID: "d1", Name: "Fridge", Zone: "k"},
+		"d2": {ID: "d2", Name: "Lamp", Zone: "k"},
+		"d3": {ID: "d3", Name: "Lamp", Zone: "b"},
+	}
+	names := newDeviceNames(zones, devices)
+	dashboard := map[string]interface{}{"columns": []interface{}{map[string]interface{}{"widgets": []interface{}{
+		map[string]interface{}{"deviceId": "d1"}, map[string]interface{}{"deviceId": "d3", "note": "unchanged"},
+	}}}}
+	out, _ := json.Marshal(exportDeviceRefs(dashboard, names))
+	if !strings.Contains(string(out), `"@device:Fridge"`) || !strings.Contains(string(out), `"@device:Bedroom/Lamp"`) {
+		t.Errorf("unexpected export: %s", out)
+	}
+
+	var exported interface{}
+	json.Unmarshal(out, &exported)
+	back, err := importDeviceRefs(exported, names)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := json.Marshal(back); string(got) != `{"columns":[{"widgets":[{"deviceId":"d1"},{"deviceId":"d3","note":"unchanged"}]}]}` {
+		t.Errorf("round trip changed the dashboard: %s", got)
+	}
+	if _, err := importDeviceRefs("@device:lamp", names); err == nil || !strings.Contains(err.Error(), "use Zone/Name") {
+		t.Errorf("expected ambiguous name error, got %v", err)
+	}
+	if _, err := importDeviceRefs([]interface{}{"@device:Oven"}, names); err == nil || !strings.Contains(err.Error(), "not found: Oven") {
+		t.Errorf("expected missing device error, got %v", err)
+	}
+
+	tmpl := map[string]interface{}{"name": "{{ room }} overview", "device": "@device:{{room}}/Lamp"}
+	filled, err := substituteDashboardVars(tmpl, map[string]string{"room": "Kitchen"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := filled.(map[string]interface{}); m["name"] != "Kitchen overview" || m["device"] != "@device:Kitchen/Lamp" {
+		t.Errorf("unexpected substitution: %v", m)
+	}
+	if _, err := substituteDashboardVars(tmpl, nil); err == nil || !strings.Contains(err.Error(), "not set: room") {
+		t.Errorf("expected undefined variable error, got %v", err)
+	}
+}
+
+func TestDashboardsGenerateExportImport(t *testing.T) {
+	h := useFakeHomey(t, &fakehomey.Seed{
+		Zones: fakehomey.Objects{
+			"home":    {"id": "home", "name": "Home"},
+			"kitchen": {"id": "kitchen", "name": "Kitchen", "parent": "home"},
+			"pantry":  {"id": "pantry", "name": "Pantry", "parent": "kitchen"},
+		},
+		Devices: fakehomey.Objects{
+			"lamp":   {"id": "lamp", "name": "Kitchen lamp", "class": "light", "zone": "kitchen"},
+			"temp":   {"id": "temp", "name": "Kitchen sensor", "class": "sensor", "zone": "kitchen", "capabilitiesObj": map[string]interface{}{"measure_temperature": map[string]interface{}{"id": "measure_temperature"}}},
+			"fridge": {"id": "fridge", "name": "Fridge plug", "class": "socket", "zone": "pantry"},
+		},
+	})
+
+	if _, err := runCommand(t, "dashboards", "generate", "--zone", "Kitchen"); err != nil {
+		t.Fatal(err)
+	}
+	ids := h.IDs(fakehomey.Dashboards)
+	if len(ids) != 1 {
+		t.Fatalf("expected one dashboard, got %v", ids)
+	}
+	dash := h.Object(fakehomey.Dashboards, ids[0])
+	columns := dash["columns"].([]interface{})
+	if dash["name"] != "Kitchen" || len(columns) != 2 || columns[0].(map[string]interface{})["title"] != "Lights" {
+		t.Errorf("unexpected generated dashboard: %v", dash)
+	}
+	if _, err := runCommand(t, "dashboards", "generate", "--zone", "Kitchen"); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("expected existing dashboard error, got %v", err)
+	}
+	if _, err := runCommand(t, "dashboards", "generate", "--zone", "Kitchen", "--subzones", "--replace"); err != nil {
+		t.Fatal(err)
+	}
+	if columns := h.Object(fakehomey.Dashboards, ids[0])["columns"].([]interface{}); len(columns) != 3 {
+		t.Errorf("expected pantry devices with --subzones, got %v", columns)
+	}
+
+	file := filepath.Join(t.TempDir(), "kitchen.json")
+	if _, err := runCommand(t, "dashboards", "export", "Kitchen", file); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runCommand(t, "dashboards", "import", file, "--name", "Kitchen copy"); err != nil {
+		t.Fatal(err)
+	}
+	var copied map[string]interface{}
+	for _, id := range h.IDs(fakehomey.Dashboards) {
+		if d := h.Object(fakehomey.Dashboards, id); d["name"] == "Kitchen copy" {
+			copied = d
+		}
+	}
+	if copied == nil {
+		t.Fatal("expected an imported dashboard")
+	}
+	origJSON, _ := json.Marshal(h.Object(fakehomey.Dashboards, ids[0])["columns"])
+	copyJSON, _ := json.Marshal(copied["columns"])
+	if !strings.Contains(string(copyJSON), `"deviceId":"lamp"`) || string(origJSON) == string(copyJSON) {
+		t.Errorf("expected the same devices with new widget IDs:\n%s\n%s", origJSON, copyJSON)
+	}
+}
+
+func TestDashboardsGenerate_DoesNotCreateWhenLookupFails(t *testing.T) {
+	h := fakehomey.New(&fakehomey.Seed{
+		Zones:   fakehomey.Objects{"kitchen": {"id": "kitchen", "name": "Kitchen"}},
+		Devices: fakehomey.Objects{"lamp": {"id": "lamp", "name": "Kitchen lamp", "class": "light", "zone": "kitchen"}},
+	})
+	h.SetToken("test-token")
+	// Listing dashboards fails, so it is unknown whether Kitchen exists
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && r.URL.Path == "/api/manager/dashboards/dashboard/" {
+			http.Error(w, `{"error":"Missing Scopes"}`, http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("HOMEY_MODE", "local")
+	t.Setenv("HOMEY_LOCAL_ADDRESS", server.URL)
+	t.Setenv("HOMEY_LOCAL_TOKEN", "test-token")
+
+	if _, err := runCommand(t, "dashboards", "generate", "--zone", "Kitchen"); err == nil || !strings.Contains(err.Error(), "missing scopes") {
+		t.Errorf("expected the lookup error, got %v", err)
+	}
+	if ids := h.IDs(fakehomey.Dashboards); len(ids) != 0 {
+		t.Errorf("expected no dashboard to be created, got %v", ids)
+	}
+}
@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/fishfisher/homeyctl/internal/fakehomey"
+)
+
+var devCmd = &cobra.Command{
+	Use:   "dev",
+	Short: "Developer tools",
+	Long:  `Tools for developing and testing automations without a real Homey.`,
+}
+
+// statusRecorder remembers the status written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		fmt.Printf("%s %s %s %d (%s)\n", start.Format("15:04:05"), r.Method, r.URL.RequestURI(), rec.status, time.Since(start).Round(time.Microsecond))
+	})
+}
+
+var devFakeHomeyCmd = &cobra.Command{
+	Use:   "fake-homey",
+	Short: "Run an in-memory Homey API simulator",
+	Long: `Run an in-memory Homey that serves the API endpoints homeyctl uses.
+
+The house is read from a seed file with devices (including capability
+state), zones, flows, flow cards, variables, moods, insights, notifications,
+users, apps and more. Collections can be written as objects keyed by ID or
+as lists of objects with an "id". Paths under "values" are served as-is,
+e.g. "energy/live" or "weather/weather".
+
+Changes are kept in memory; use --save to write the final state to a file
+when the simulator stops.
+
+Seed example:
+  {
+    "devices": [
+      {"id": "lamp", "name": "Kitchen lamp", "class": "light", "zone": "kitchen",
+       "capabilitiesObj": {"onoff": {"id": "onoff", "value": false}}}
+    ],
+    "zones": [{"id": "kitchen", "name": "Kitchen"}],
+    "users": [{"id": "anna", "name": "Anna", "role": "owner", "present": true}],
+    "values": {"energy/live": {"W": 420}}
+  }
+
+Examples:
+  homeyctl dev fake-homey --seed house.json
+  homeyctl dev fake-homey --listen :4860 --seed house.json --save house-after.json
+
+  # In another shell
+  HOMEY_MODE=local HOMEY_LOCAL_ADDRESS=http://localhost:4859 HOMEY_LOCAL_TOKEN=fake-token homeyctl devices list`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		listen, _ := cmd.Flags().GetString("listen")
+		seedPath, _ := cmd.Flags().GetString("seed")
+		token, _ := cmd.Flags().GetString("token")
+		savePath, _ := cmd.Flags().GetString("save")
+
+		var seed *fakehomey.Seed
+		if seedPath != "" {
+			var err error
+			if seed, err = fakehomey.LoadSeed(seedPath); err != nil {
+				return err
+			}
+		}
+		homey := fakehomey.New(seed)
+		homey.SetToken(token)
+
+		var handler http.Handler = homey
+		if verboseFlag || traceFlag {
+			handler = logRequests(handler)
+		}
+
+		listener, err := net.Listen("tcp", listen)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %w", listen, err)
+		}
+		addr := listener.Addr().(*net.TCPAddr)
+		url := fmt.Sprintf("http://localhost:%d", addr.Port)
+
+		color.Green("Fake Homey listening on %s\n", url)
+		fmt.Printf("Devices: %d, zones: %d, flows: %d\n",
+			len(homey.IDs(fakehomey.Devices)), len(homey.IDs(fakehomey.Zones)),
+			len(homey.IDs(fakehomey.Flows))+len(homey.IDs(fakehomey.AdvancedFlows)))
+		fmt.Printf("\nPoint homeyctl at it with:\n")
+		fmt.Printf("  export HOMEY_MODE=local HOMEY_LOCAL_ADDRESS=%s", url)
+		if token != "" {
+			fmt.Printf(" HOMEY_LOCAL_TOKEN=%s", token)
+		}
+		fmt.Println()
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		server := &http.Server{Handler: handler}
+		go func() {
+			<-ctx.Done()
+			shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			server.Shutdown(shutdown)
+		}()
+
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+
+		if savePath != "" {
+			data, err := json.MarshalIndent(homey.Snapshot(), "", "  ")
+			if err != nil {
+				return err
+			}
+			if err := os.WriteFile(savePath, data, 0o644); err != nil {
+				return fmt.Errorf("failed to save state: %w", err)
+			}
+			color.Green("\nSaved state to %s\n", savePath)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(devCmd)
+	devCmd.AddCommand(devFakeHomeyCmd)
+	devFakeHomeyCmd.Flags().String("listen", ":4859", "Address to listen on")
+	devFakeHomeyCmd.Flags().String("seed", "", "JSON file with the initial house")
+	devFakeHomeyCmd.Flags().String("token", "fake-token", "Bearer token clients must send (empty accepts any)")
+	devFakeHomeyCmd.Flags().String("save", "", "Write the final state to this file on exit")
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fishfisher/homeyctl/internal/fakehomey"
+	"github.com/fishfisher/homeyctl/internal/fakehomey/fakehomeytest"
+)
+
+// useFakeHomey points commands at a fake Homey for the rest of the test
+func useFakeHomey(t *testing.T, seed *fakehomey.Seed) *fakehomey.Homey {
+	t.Helper()
+	h, server := fakehomeytest.NewServer(t, seed)
+	h.SetToken("test-token")
+	t.Setenv("HOMEY_MODE", "local")
+	t.Setenv("HOMEY_LOCAL_ADDRESS", server.URL)
+	t.Setenv("HOMEY_LOCAL_TOKEN", "test-token")
+	t.Setenv("HOMEY_TOKEN", "test-token")
+	return h
+}
+
+func TestFakeHomey_DevicesSet(t *testing.T) {
+	h := useFakeHomey(t, &fakehomey.Seed{
+		Devices: fakehomey.Objects{
+			"lamp": {"id": "lamp", "name": "Kitchen lamp", "class": "light", "capabilitiesObj": map[string]interface{}{
+				"onoff": map[string]interface{}{"id": "onoff", "value": false},
+			}},
+		},
+	})
+
+	out, err := runCommand(t, "devices", "set", "Kitchen lamp", "onoff", "true")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v, _ := h.CapabilityValue("lamp", "onoff"); v != true {
+		t.Errorf("expected lamp on, got %v (output: %s)", v, out)
+	}
+}
+
+func TestDevFakeHomeyCommand_Exists(t *testing.T) {
+	if _, _, err := rootCmd.Find([]string{"dev", "fake-homey"}); err != nil {
+		t.Fatalf("dev fake-homey not found: %v", err)
+	}
+	for _, flag := range []string{"listen", "seed", "token", "save"} {
+		if devFakeHomeyCmd.Flags().Lookup(flag) == nil {
+			t.Errorf("expected --%s flag", flag)
+		}
+	}
+	if !strings.Contains(devFakeHomeyCmd.Long, "HOMEY_LOCAL_ADDRESS") {
+		t.Error("expected connection instructions in help")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/rodaine/table"
@@ -21,9 +22,10 @@ type Device struct {
 
 // Capability represents a device capability
 type Capability struct {
-	ID    string      `json:"id"`
-	Value interface{} `json:"value"`
-	Title string      `json:"title"`
+	ID          string      `json:"id"`
+	Value       interface{} `json:"value"`
+	Title       string      `json:"title"`
+	LastUpdated *time.Time  `json:"lastUpdated,omitempty"`
 }
 
 var devicesCmd = &cobra.Command{
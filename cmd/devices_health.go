@@ -0,0 +1,357 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/rodaine/table"
+	"github.com/spf13/cobra"
+)
+
+// Health severities, from best to worst
+const (
+	healthOK = iota
+	healthWarning
+	healthCritical
+)
+
+var healthLevels = []string{"ok", "warning", "critical"}
+
+// defaultStaleAfter is how long a device of a class may go without any
+// capability update before it is flagged. Classes not listed are never
+// flagged, since lights and sockets only report when they are switched.
+var defaultStaleAfter = map[string]time.Duration{
+	"sensor":     6 * time.Hour,
+	"thermostat": 12 * time.Hour,
+	"heater":     24 * time.Hour,
+	"doorbell":   24 * time.Hour,
+	"lock":       24 * time.Hour,
+}
+
+// healthOptions are the thresholds used by 'devices health'
+type healthOptions struct {
+	BatteryWarn     float64                  // Battery at or below this percentage is a warning
+	BatteryCritical float64                  // Battery at or below this percentage is critical
+	StaleAfter      map[string]time.Duration // Per-class staleness thresholds
+	DefaultStale    time.Duration            // Threshold for classes not in StaleAfter; 0 never flags them
+}
+
+// healthDevice is the part of a device 'devices health' looks at
+type healthDevice struct {
+	ID                 string                `json:"id"`
+	Name               string                `json:"name"`
+	Class              string                `json:"class"`
+	Available          *bool                 `json:"available"`
+	UnavailableMessage string                `json:"unavailableMessage"`
+	CapabilitiesObj    map[string]Capability `json:"capabilitiesObj"`
+}
+
+// deviceHealth is the assessment of one device
+type deviceHealth struct {
+	Device       string     `json:"device"`
+	DeviceID     string     `json:"deviceId"`
+	Class        string     `json:"class"`
+	Status       string     `json:"status"`
+	Available    bool       `json:"available"`
+	Battery      *float64   `json:"battery,omitempty"`
+	BatteryAlarm bool       `json:"batteryAlarm,omitempty"`
+	LastUpdated  *time.Time `json:"lastUpdated,omitempty"`
+	StaleAfter   string     `json:"staleAfter,omitempty"`
+	Issues       []string   `json:"issues"`
+
+	severity int
+	age      time.Duration
+}
+
+func (h *deviceHealth) flag(severity int, format string, args ...interface{}) {
+	if severity > h.severity {
+		h.severity = severity
+	}
+	h.Issues = append(h.Issues, fmt.Sprintf(format, args...))
+}
+
+func (o healthOptions) staleAfter(class string) time.Duration {
+	if d, ok := o.StaleAfter[class]; ok {
+		return d
+	}
+	return o.DefaultStale
+}
+
+// assessDeviceHealth checks availability, battery and staleness of every
+// device and returns them worst-first
+func assessDeviceHealth(devices map[string]healthDevice, now time.Time, opts healthOptions) []deviceHealth {
+	var report []deviceHealth
+	for _, d := range devices {
+		h := deviceHealth{Device: d.Name, DeviceID: d.ID, Class: d.Class, Available: d.Available == nil || *d.Available, Issues: []string{}}
+
+		if !h.Available {
+			if d.UnavailableMessage != "" {
+				h.flag(healthCritical, "unavailable: %s", d.UnavailableMessage)
+			} else {
+				h.flag(healthCritical, "unavailable")
+			}
+		}
+
+		if c, ok := d.CapabilitiesObj["alarm_battery"]; ok {
+			if alarm, _ := c.Value.(bool); alarm {
+				h.BatteryAlarm = true
+				h.flag(healthCritical, "battery alarm")
+			}
+		}
+		if c, ok := d.CapabilitiesObj["measure_battery"]; ok {
+			if level, ok := c.Value.(float64); ok {
+				h.Battery = &level
+				switch {
+				case level <= opts.BatteryCritical:
+					h.flag(healthCritical, "battery %.0f%%", level)
+				case level <= opts.BatteryWarn:
+					h.flag(healthWarning, "battery %.0f%%", level)
+				}
+			}
+		}
+
+		for _, c := range d.CapabilitiesObj {
+			if c.LastUpdated != nil && (h.LastUpdated == nil || c.LastUpdated.After(*h.LastUpdated)) {
+				t := *c.LastUpdated
+				h.LastUpdated = &t
+			}
+		}
+		if h.LastUpdated != nil {
+			h.age = now.Sub(*h.LastUpdated)
+			if limit := opts.staleAfter(d.Class); limit > 0 {
+				h.StaleAfter = limit.String()
+				if h.age > limit {
+					h.flag(healthWarning, "no updates for %s", formatAge(h.age))
+				}
+			}
+		}
+
+		h.Status = healthLevels[h.severity]
+		report = append(report, h)
+	}
+
+	sort.Slice(report, func(i, j int) bool {
+		a, b := report[i], report[j]
+		if a.severity != b.severity {
+			return a.severity > b.severity
+		}
+		if len(a.Issues) != len(b.Issues) {
+			return len(a.Issues) > len(b.Issues)
+		}
+		if a.age != b.age {
+			return a.age > b.age
+		}
+		return strings.ToLower(a.Device) < strings.ToLower(b.Device)
+	})
+	return report
+}
+
+// formatAge shows a duration in its largest sensible unit
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd", int(d.Hours()/24))
+}
+
+// parseStaleFlags reads --stale class=duration overrides. The class
+// "default" sets the threshold for all classes without their own.
+func parseStaleFlags(values []string, opts *healthOptions) error {
+	for _, v := range values {
+		class, durStr, ok := strings.Cut(v, "=")
+		if !ok {
+			return fmt.Errorf("invalid --stale %q (use class=duration, e.g. sensor=2h)", v)
+		}
+		d, err := time.ParseDuration(durStr)
+		if err != nil {
+			return fmt.Errorf("invalid duration in --stale %q: %w", v, err)
+		}
+		if class == "default" {
+			opts.DefaultStale = d
+		} else {
+			opts.StaleAfter[class] = d
+		}
+	}
+	return nil
+}
+
+func parseHealthLevel(s string) (int, error) {
+	// Every device is at ok or worse, so ok is not a useful threshold
+	for i, level := range healthLevels {
+		if s == level && i > healthOK {
+			return i, nil
+		}
+	}
+	if s == "none" {
+		return -1, nil
+	}
+	return 0, fmt.Errorf("invalid level %q (use: warning, critical, none)", s)
+}
+
+// healthSummary is a short text for a timeline notification
+func healthSummary(report []deviceHealth, minSeverity int) string {
+	var parts []string
+	for _, h := range report {
+		if h.severity >= minSeverity && h.severity > healthOK {
+			parts = append(parts, fmt.Sprintf("%s (%s)", h.Device, strings.Join(h.Issues, ", ")))
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	text := fmt.Sprintf("Device health: %d device(s) need attention: %s", len(parts), strings.Join(parts, "; "))
+	// Keep timeline entries readable
+	if runes := []rune(text); len(runes) > 300 {
+		text = string(runes[:297]) + "..."
+	}
+	return text
+}
+
+var devicesHealthCmd = &cobra.Command{
+	Use:   "health",
+	Short: "Check device availability, batteries and reporting",
+	Long: `Check every device for problems, worst first:
+
+  - unavailable devices, with Homey's unavailable message (critical)
+  - battery alarms and batteries at or below --battery-critical (critical)
+  - batteries at or below --battery-warn (warning)
+  - devices whose capabilities have not updated for longer than the
+    threshold for their class (warning)
+
+Default staleness thresholds: sensor 6h, thermostat 12h, heater, doorbell
+and lock 24h. Other classes, like lights and sockets, only report when used
+and are not checked unless given a threshold with --stale.
+
+Use --fail-on to exit non-zero for cron or CI alerting, and --notify to
+post a summary to the Homey timeline when something needs attention.
+
+Examples:
+  homeyctl devices health
+  homeyctl devices health --problems
+  homeyctl devices health --stale sensor=2h --stale socket=48h
+  homeyctl devices health --fail-on critical --notify
+  homeyctl devices health --json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := healthOptions{StaleAfter: map[string]time.Duration{}}
+		for class, d := range defaultStaleAfter {
+			opts.StaleAfter[class] = d
+		}
+		opts.BatteryWarn, _ = cmd.Flags().GetFloat64("battery-warn")
+		opts.BatteryCritical, _ = cmd.Flags().GetFloat64("battery-critical")
+		stale, _ := cmd.Flags().GetStringArray("stale")
+		if err := parseStaleFlags(stale, &opts); err != nil {
+			return err
+		}
+		failOnStr, _ := cmd.Flags().GetString("fail-on")
+		failOn, err := parseHealthLevel(failOnStr)
+		if err != nil {
+			return fmt.Errorf("--fail-on: %w", err)
+		}
+		notify, _ := cmd.Flags().GetBool("notify")
+		problemsOnly, _ := cmd.Flags().GetBool("problems")
+
+		data, err := apiClient.GetDevices()
+		if err != nil {
+			return err
+		}
+		var devices map[string]healthDevice
+		if err := json.Unmarshal(data, &devices); err != nil {
+			return fmt.Errorf("failed to parse devices: %w", err)
+		}
+
+		report := assessDeviceHealth(devices, time.Now(), opts)
+		counts := make([]int, len(healthLevels))
+		for _, h := range report {
+			counts[h.severity]++
+		}
+
+		shown := report
+		if problemsOnly {
+			shown = nil
+			for _, h := range report {
+				if h.severity > healthOK {
+					shown = append(shown, h)
+				}
+			}
+		}
+
+		if isJSON() {
+			if shown == nil {
+				shown = []deviceHealth{}
+			}
+			out, _ := json.MarshalIndent(shown, "", "  ")
+			fmt.Println(string(out))
+		} else {
+			headerFmt := color.New(color.FgCyan, color.Underline).SprintfFunc()
+			tbl := table.New("Status", "Device", "Class", "Battery", "Last Update", "Issues")
+			tbl.WithHeaderFormatter(headerFmt)
+			for _, h := range shown {
+				status := color.GreenString(h.Status)
+				switch h.severity {
+				case healthWarning:
+					status = color.YellowString(h.Status)
+				case healthCritical:
+					status = color.RedString(h.Status)
+				}
+				battery := "-"
+				if h.Battery != nil {
+					battery = fmt.Sprintf("%.0f%%", *h.Battery)
+				}
+				lastUpdate := "-"
+				if h.LastUpdated != nil {
+					lastUpdate = formatAge(h.age) + " ago"
+				}
+				tbl.AddRow(status, h.Device, h.Class, battery, lastUpdate, strings.Join(h.Issues, "; "))
+			}
+			tbl.Print()
+			fmt.Printf("\n%d ok, %d warning, %d critical\n", counts[healthOK], counts[healthWarning], counts[healthCritical])
+		}
+
+		if notify {
+			minSeverity := failOn
+			if minSeverity < healthWarning {
+				minSeverity = healthWarning
+			}
+			if text := healthSummary(report, minSeverity); text != "" {
+				if err := apiClient.SendNotification(text); err != nil {
+					return fmt.Errorf("failed to send notification: %w", err)
+				}
+				if !isJSON() {
+					fmt.Println("Sent timeline notification")
+				}
+			}
+		}
+
+		if failOn > healthOK {
+			failing := 0
+			for level := failOn; level < len(healthLevels); level++ {
+				failing += counts[level]
+			}
+			if failing > 0 {
+				cmd.SilenceUsage = true
+				return fmt.Errorf("%d device(s) at %s or worse", failing, healthLevels[failOn])
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	devicesCmd.AddCommand(devicesHealthCmd)
+	devicesHealthCmd.Flags().Float64("battery-warn", 25, "Warn when a battery is at or below this percentage")
+	devicesHealthCmd.Flags().Float64("battery-critical", 10, "Critical when a battery is at or below this percentage")
+	devicesHealthCmd.Flags().StringArray("stale", nil, "Staleness threshold per class as class=duration, or default=duration (repeatable)")
+	devicesHealthCmd.Flags().String("fail-on", "none", "Exit non-zero if any device is at this level or worse: warning, critical, none")
+	devicesHealthCmd.Flags().Bool("notify", false, "Send a Homey timeline notification when devices need attention")
+	devicesHealthCmd.Flags().Bool("problems", false, "Only show devices with problems")
+}
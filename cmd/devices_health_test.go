@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fishfisher/homeyctl/internal/fakehomey"
+)
+
+func healthCap(value interface{}, updated time.Time) Capability {
+	return Capability{Value: value, LastUpdated: &updated}
+}
+
+func TestAssessDeviceHealth(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	no := false
+	devices := map[string]healthDevice{
+		"ok": {ID: "ok", Name: "Fresh sensor", Class: "sensor", CapabilitiesObj: map[string]Capability{
+			"measure_temperature": healthCap(21.0, now.Add(-time.Hour)),
+			"measure_battery":     healthCap(80.0, now.Add(-2*time.Hour)),
+		}},
+		"stale": {ID: "stale", Name: "Stale sensor", Class: "sensor", CapabilitiesObj: map[string]Capability{
+			"measure_temperature": healthCap(21.0, now.Add(-10*time.Hour)),
+		}},
+		"low": {ID: "low", Name: "Door sensor", Class: "sensor", CapabilitiesObj: map[string]Capability{
+			"alarm_contact":   healthCap(false, now.Add(-time.Hour)),
+			"measure_battery": healthCap(20.0, now.Add(-time.Hour)),
+		}},
+		"dead": {ID: "dead", Name: "Motion", Class: "sensor", Available: &no, UnavailableMessage: "Device unreachable", CapabilitiesObj: map[string]Capability{
+			"alarm_battery": healthCap(true, now.Add(-72*time.Hour)),
+		}},
+		"lamp": {ID: "lamp", Name: "Lamp", Class: "light", CapabilitiesObj: map[string]Capability{
+			"onoff": healthCap(true, now.Add(-30*24*time.Hour)),
+		}},
+	}
+	opts := healthOptions{BatteryWarn: 25, BatteryCritical: 10, StaleAfter: map[string]time.Duration{"sensor": 6 * time.Hour}}
+
+	report := assessDeviceHealth(devices, now, opts)
+	var order []string
+	for _, h := range report {
+		order = append(order, h.DeviceID+":"+h.Status)
+	}
+	want := "dead:critical stale:warning low:warning lamp:ok ok:ok"
+	if got := strings.Join(order, " "); got != want {
+		t.Errorf("order = %s, want %s", got, want)
+	}
+
+	dead := report[0]
+	if strings.Join(dead.Issues, "; ") != "unavailable: Device unreachable; battery alarm; no updates for 3d" || dead.Available {
+		t.Errorf("unexpected dead device issues: %v", dead.Issues)
+	}
+	if report[1].Issues[0] != "no updates for 10h" {
+		t.Errorf("unexpected stale issue: %v", report[1].Issues)
+	}
+	if *report[2].Battery != 20 || report[2].Issues[0] != "battery 20%" {
+		t.Errorf("unexpected battery issue: %+v", report[2])
+	}
+
+	// A default threshold applies to classes without their own
+	opts.DefaultStale = 7 * 24 * time.Hour
+	report = assessDeviceHealth(devices, now, opts)
+	for _, h := range report {
+		if h.DeviceID == "lamp" && h.Status != "warning" {
+			t.Errorf("expected lamp to be stale with a default threshold, got %s", h.Status)
+		}
+	}
+}
+
+func TestParseStaleFlags(t *testing.T) {
+	opts := healthOptions{StaleAfter: map[string]time.Duration{}}
+	if err := parseStaleFlags([]string{"sensor=2h", "default=48h"}, &opts); err != nil {
+		t.Fatal(err)
+	}
+	if opts.StaleAfter["sensor"] != 2*time.Hour || opts.DefaultStale != 48*time.Hour {
+		t.Errorf("unexpected options: %+v", opts)
+	}
+	for _, bad := range []string{"sensor", "sensor=soon"} {
+		if err := parseStaleFlags([]string{bad}, &opts); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestDevicesHealth_FailOnAndNotify(t *testing.T) {
+	recent := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+	h := useFakeHomey(t, &fakehomey.Seed{
+		Devices: fakehomey.Objects{
+			"d1": {"id": "d1", "name": "Hall sensor", "class": "sensor", "available": true, "capabilitiesObj": map[string]interface{}{
+				"measure_battery": map[string]interface{}{"id": "measure_battery", "value": 5.0, "lastUpdated": recent},
+			}},
+			"d2": {"id": "d2", "name": "Kitchen lamp", "class": "light", "available": true, "capabilitiesObj": map[string]interface{}{
+				"onoff": map[string]interface{}{"id": "onoff", "value": true, "lastUpdated": recent},
+			}},
+		},
+	})
+
+	out, err := runCommand(t, "devices", "health", "--problems")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out, "Hall sensor") || strings.Contains(out, "Kitchen lamp") || !strings.Contains(out, "1 ok, 0 warning, 1 critical") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+
+	devicesHealthCmd.SilenceUsage = false
+	_, err = runCommand(t, "devices", "health", "--fail-on", "warning", "--notify")
+	if err == nil || !strings.Contains(err.Error(), "1 device(s) at warning or worse") {
+		t.Errorf("expected --fail-on to fail, got %v", err)
+	}
+	if !devicesHealthCmd.SilenceUsage {
+		t.Error("expected usage to be silenced when the threshold is reached")
+	}
+	ids := h.IDs(fakehomey.Notifications)
+	if len(ids) != 1 || !strings.Contains(h.Object(fakehomey.Notifications, ids[0])["excerpt"].(string), "Hall sensor (battery 5%)") {
+		t.Errorf("expected a timeline notification, got %v", ids)
+	}
+
+	for _, level := range []string{"sometimes", "ok"} {
+		if _, err := runCommand(t, "devices", "health", "--fail-on", level); err == nil || !strings.Contains(err.Error(), "use: warning, critical, none") {
+			t.Errorf("expected --fail-on %s to be rejected, got %v", level, err)
+		}
+	}
+}
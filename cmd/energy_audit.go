@@ -0,0 +1,350 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/rodaine/table"
+	"github.com/spf13/cobra"
+)
+
+const hoursPerYear = 24 * 365
+
+// auditOptions are the thresholds used by 'energy audit'
+type auditOptions struct {
+	StandbyW   float64       // Base load at or above this is flagged as standby
+	JumpRatio  float64       // Recent/baseline ratio that counts as a jump
+	JumpMinW   float64       // Minimum increase in W for a jump
+	OffW       float64       // Power above this while off is flagged
+	Recent     time.Duration // Window compared against the baseline
+	StaleAfter time.Duration // Meters silent for longer are flagged
+}
+
+// auditFinding is one issue found by 'energy audit'
+type auditFinding struct {
+	Device     string  `json:"device"`
+	DeviceID   string  `json:"deviceId"`
+	Zone       string  `json:"zone,omitempty"`
+	Kind       string  `json:"kind"`
+	Detail     string  `json:"detail"`
+	Watts      float64 `json:"watts"`
+	YearlyKWh  float64 `json:"yearlyKWh"`
+	YearlyCost float64 `json:"yearlyCost"`
+}
+
+// EnergyAuditReport is the output of 'energy audit'
+type EnergyAuditReport struct {
+	From       time.Time      `json:"from"`
+	To         time.Time      `json:"to"`
+	PriceType  string         `json:"priceType"`
+	Price      float64        `json:"price"`
+	Unit       string         `json:"unit"`
+	YearlyCost float64        `json:"yearlyCost"` // Total with each device's idle draw counted once
+	Findings   []auditFinding `json:"findings"`
+}
+
+// percentile returns the p-th percentile (0..1) of values
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	return sorted[int(p*float64(len(sorted)-1))]
+}
+
+// timeWeightedAverage returns the average power over [from, to), holding
+// each sample until the next one (gaps longer than maxInsightGap are skipped).
+func timeWeightedAverage(points []insightPoint, from, to time.Time) (float64, bool) {
+	var sum, total float64
+	for i := 0; i+1 < len(points); i++ {
+		a, b := points[i], points[i+1]
+		start, end := a.T, b.T
+		if end.Sub(start) > maxInsightGap {
+			continue
+		}
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if !end.After(start) {
+			continue
+		}
+		h := end.Sub(start).Hours()
+		sum += a.V * h
+		total += h
+	}
+	if total == 0 {
+		return 0, false
+	}
+	return sum / total, true
+}
+
+// auditPowerSeries checks one device's power history for standby load and
+// consumption jumps against its own baseline.
+func auditPowerSeries(points []insightPoint, now time.Time, opts auditOptions) []auditFinding {
+	if len(points) < 2 {
+		return nil
+	}
+	var findings []auditFinding
+
+	values := make([]float64, len(points))
+	for i, p := range points {
+		values[i] = p.V
+	}
+	if base := percentile(values, 0.1); base >= opts.StandbyW {
+		findings = append(findings, auditFinding{
+			Kind:   "standby",
+			Detail: fmt.Sprintf("usually above %.1f W (min %.1f W)", base, percentile(values, 0)),
+			Watts:  base,
+		})
+	}
+
+	split := now.Add(-opts.Recent)
+	baseline, okBase := timeWeightedAverage(points, points[0].T, split)
+	recent, okRecent := timeWeightedAverage(points, split, now)
+	if okBase && okRecent && baseline > 0 &&
+		recent >= baseline*opts.JumpRatio && recent-baseline >= opts.JumpMinW {
+		findings = append(findings, auditFinding{
+			Kind:   "jump",
+			Detail: fmt.Sprintf("%.1f W recently vs %.1f W baseline (x%.1f)", recent, baseline, recent/baseline),
+			Watts:  recent - baseline,
+		})
+	}
+	return findings
+}
+
+// auditDeviceState checks current capability values for power reported while
+// switched off and for meters that stopped reporting.
+func auditDeviceState(d Device, now time.Time, opts auditOptions) []auditFinding {
+	var findings []auditFinding
+
+	if on, ok := capabilityBool(d, "onoff"); ok && !on {
+		if w, ok := capabilityFloat(d, "measure_power"); ok && w > opts.OffW {
+			findings = append(findings, auditFinding{
+				Kind:   "power-while-off",
+				Detail: fmt.Sprintf("%.1f W while onoff is false", w),
+				Watts:  w,
+			})
+		}
+	}
+
+	var latest *time.Time
+	for _, name := range []string{"measure_power", "meter_power"} {
+		if c, ok := d.CapabilitiesObj[name]; ok && c.LastUpdated != nil {
+			if latest == nil || c.LastUpdated.After(*latest) {
+				latest = c.LastUpdated
+			}
+		}
+	}
+	if latest != nil && now.Sub(*latest) > opts.StaleAfter {
+		findings = append(findings, auditFinding{
+			Kind:   "stale",
+			Detail: fmt.Sprintf("no power report since %s", latest.Local().Format("2006-01-02 15:04")),
+		})
+	}
+	return findings
+}
+
+// rankAuditFindings fills in yearly estimates and sorts by yearly cost,
+// then yearly kWh, so the most expensive issues come first.
+func rankAuditFindings(findings []auditFinding, price float64) {
+	for i := range findings {
+		findings[i].YearlyKWh = findings[i].Watts * hoursPerYear / 1000
+		findings[i].YearlyCost = findings[i].YearlyKWh * price
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].YearlyCost != findings[j].YearlyCost {
+			return findings[i].YearlyCost > findings[j].YearlyCost
+		}
+		if findings[i].YearlyKWh != findings[j].YearlyKWh {
+			return findings[i].YearlyKWh > findings[j].YearlyKWh
+		}
+		if findings[i].Device != findings[j].Device {
+			return findings[i].Device < findings[j].Device
+		}
+		return findings[i].Kind < findings[j].Kind
+	})
+}
+
+// auditYearlyCost totals the findings' yearly cost. Standby and power
+// while off both measure a device's idle draw, so only the larger of the
+// two counts for each device.
+func auditYearlyCost(findings []auditFinding) float64 {
+	idle := map[string]float64{}
+	var total float64
+	for _, f := range findings {
+		if f.Kind == "standby" || f.Kind == "power-while-off" {
+			idle[f.DeviceID] = max(idle[f.DeviceID], f.YearlyCost)
+			continue
+		}
+		total += f.YearlyCost
+	}
+	for _, cost := range idle {
+		total += cost
+	}
+	return total
+}
+
+// averagePrice returns the fixed price, or the mean of the dynamic intervals
+func (s *priceSchedule) averagePrice() float64 {
+	if s.Type == "fixed" {
+		return s.Fixed
+	}
+	if len(s.Intervals) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, p := range s.Intervals {
+		sum += p.Value
+	}
+	return sum / float64(len(s.Intervals))
+}
+
+var energyAuditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Find standby consumers, consumption jumps, and silent meters",
+	Long: `Audit power insights for every device with measure_power.
+
+Flags:
+  standby          Base load (10th percentile) at or above --standby-watts
+  jump             Recent average (--recent) well above the device's baseline
+  power-while-off  Reporting power while onoff is false
+  stale            Power/meter capability not updated within --stale
+
+Findings are ranked by estimated yearly cost, using the fixed price or the
+average dynamic price for today.
+
+Examples:
+  homeyctl energy audit
+  homeyctl energy audit --days 14 --standby-watts 5
+  homeyctl energy audit --json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		days, _ := cmd.Flags().GetInt("days")
+		opts := auditOptions{}
+		opts.StandbyW, _ = cmd.Flags().GetFloat64("standby-watts")
+		opts.JumpRatio, _ = cmd.Flags().GetFloat64("jump-ratio")
+		opts.JumpMinW, _ = cmd.Flags().GetFloat64("jump-watts")
+		opts.OffW, _ = cmd.Flags().GetFloat64("off-watts")
+		opts.Recent, _ = cmd.Flags().GetDuration("recent")
+		opts.StaleAfter, _ = cmd.Flags().GetDuration("stale")
+
+		if days <= 0 {
+			return fmt.Errorf("--days must be greater than 0")
+		}
+
+		now := time.Now()
+		from := now.AddDate(0, 0, -days)
+		resolution := insightResolutionFor(from, now)
+
+		zones, devices, err := fetchZonesAndDevices()
+		if err != nil {
+			return err
+		}
+		logs, err := fetchInsightLogs()
+		if err != nil {
+			return err
+		}
+		powers := deviceInsightLogs(logs, "measure_power")
+
+		prices, err := loadPriceSchedule(startOfDay(now), startOfDay(now).AddDate(0, 0, 1))
+		if err != nil {
+			return err
+		}
+
+		var findings []auditFinding
+		for _, d := range devices {
+			if _, ok := d.CapabilitiesObj["measure_power"]; !ok {
+				continue
+			}
+
+			deviceFindings := auditDeviceState(d, now, opts)
+			if l, ok := powers[d.ID]; ok {
+				data, err := apiClient.GetInsightEntries(l.OwnerURI, l.ID, resolution)
+				if err != nil {
+					return fmt.Errorf("%s: %w", d.Name, err)
+				}
+				points, err := parseInsightEntries(data)
+				if err != nil {
+					return fmt.Errorf("%s: %w", d.Name, err)
+				}
+				var window []insightPoint
+				for _, p := range points {
+					if !p.T.Before(from) {
+						window = append(window, p)
+					}
+				}
+				deviceFindings = append(deviceFindings, auditPowerSeries(window, now, opts)...)
+			}
+
+			zoneName := d.Zone
+			if z, ok := zones[d.Zone]; ok {
+				zoneName = z.Name
+			}
+			for _, f := range deviceFindings {
+				f.Device, f.DeviceID, f.Zone = d.Name, d.ID, zoneName
+				findings = append(findings, f)
+			}
+		}
+
+		report := EnergyAuditReport{
+			From:      from,
+			To:        now,
+			PriceType: prices.Type,
+			Price:     prices.averagePrice(),
+			Unit:      prices.Unit,
+			Findings:  findings,
+		}
+		rankAuditFindings(report.Findings, report.Price)
+		report.YearlyCost = auditYearlyCost(report.Findings)
+
+		if isJSON() {
+			if report.Findings == nil {
+				report.Findings = []auditFinding{}
+			}
+			out, _ := json.MarshalIndent(report, "", "  ")
+			fmt.Println(string(out))
+			return nil
+		}
+
+		if len(report.Findings) == 0 {
+			color.Green("No issues found in the last %d days\n", days)
+			return nil
+		}
+
+		color.New(color.Bold).Printf("Energy audit, last %d days (%s)\n\n", days, prices.Type)
+		headerFmt := color.New(color.FgCyan, color.Underline).SprintfFunc()
+		tbl := table.New("Device", "Zone", "Issue", "Detail", "kWh/year", "Cost/year")
+		tbl.WithHeaderFormatter(headerFmt)
+		for _, f := range report.Findings {
+			tbl.AddRow(f.Device, f.Zone, f.Kind, f.Detail, fmt.Sprintf("%.0f", f.YearlyKWh), fmt.Sprintf("%.0f", f.YearlyCost))
+		}
+		tbl.Print()
+
+		fmt.Println()
+		if report.Price == 0 {
+			color.Yellow("No electricity price available; yearly cost not estimated.\n")
+		} else {
+			fmt.Printf("Estimated yearly cost: %.0f %s (at %.2f %s)\n",
+				report.YearlyCost, strings.TrimSuffix(report.Unit, "/kWh"), report.Price, report.Unit)
+		}
+		return nil
+	},
+}
+
+func init() {
+	energyCmd.AddCommand(energyAuditCmd)
+	energyAuditCmd.Flags().Int("days", 30, "History to analyze in days")
+	energyAuditCmd.Flags().Float64("standby-watts", 2, "Flag base load at or above this many watts")
+	energyAuditCmd.Flags().Float64("jump-ratio", 1.5, "Flag recent consumption this many times the baseline")
+	energyAuditCmd.Flags().Float64("jump-watts", 5, "Minimum increase in watts for a jump")
+	energyAuditCmd.Flags().Float64("off-watts", 1, "Flag power above this many watts while off")
+	energyAuditCmd.Flags().Duration("recent", 72*time.Hour, "Recent window compared against the baseline")
+	energyAuditCmd.Flags().Duration("stale", 24*time.Hour, "Flag meters not reporting for this long")
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+var testAuditOptions = auditOptions{
+	StandbyW:   2,
+	JumpRatio:  1.5,
+	JumpMinW:   5,
+	OffW:       1,
+	Recent:     24 * time.Hour,
+	StaleAfter: 24 * time.Hour,
+}
+
+// hourlyPower returns one power sample per hour ending at now
+func hourlyPower(now time.Time, values ...float64) []insightPoint {
+	points := make([]insightPoint, len(values))
+	for i, v := range values {
+		points[i] = insightPoint{T: now.Add(time.Duration(i-len(values)+1) * time.Hour), V: v}
+	}
+	return points
+}
+
+func TestEnergyAuditCommand_Exists(t *testing.T) {
+	cmd, _, err := energyCmd.Find([]string{"audit"})
+	if err != nil {
+		t.Fatalf("audit command not found: %v", err)
+	}
+	for _, flag := range []string{"days", "standby-watts", "jump-ratio", "off-watts", "stale"} {
+		if cmd.Flags().Lookup(flag) == nil {
+			t.Errorf("expected --%s flag to be defined", flag)
+		}
+	}
+}
+
+func TestAuditPowerSeries_Standby(t *testing.T) {
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.Local)
+
+	values := make([]float64, 48)
+	for i := range values {
+		values[i] = 8
+	}
+	findings := auditPowerSeries(hourlyPower(now, values...), now, testAuditOptions)
+	if len(findings) != 1 || findings[0].Kind != "standby" || findings[0].Watts != 8 {
+		t.Fatalf("expected one standby finding at 8 W, got %+v", findings)
+	}
+
+	for i := range values {
+		if i%2 == 0 {
+			values[i] = 0
+		}
+	}
+	if findings := auditPowerSeries(hourlyPower(now, values...), now, testAuditOptions); len(findings) != 0 {
+		t.Errorf("expected no findings for device that switches off, got %+v", findings)
+	}
+}
+
+func TestAuditPowerSeries_Jump(t *testing.T) {
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.Local)
+
+	values := make([]float64, 96)
+	for i := range values {
+		values[i] = 0
+		if i%4 == 0 {
+			values[i] = 40 // 10 W baseline average
+		}
+		if i >= 72 {
+			values[i] = 30 // last day
+		}
+	}
+	findings := auditPowerSeries(hourlyPower(now, values...), now, testAuditOptions)
+
+	var jump *auditFinding
+	for i := range findings {
+		if findings[i].Kind == "jump" {
+			jump = &findings[i]
+		}
+	}
+	if jump == nil {
+		t.Fatalf("expected a jump finding, got %+v", findings)
+	}
+	if jump.Watts < 15 || jump.Watts > 25 {
+		t.Errorf("expected about 20 W increase, got %v", jump.Watts)
+	}
+}
+
+func TestAuditDeviceState(t *testing.T) {
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.Local)
+	old := now.Add(-48 * time.Hour)
+	fresh := now.Add(-time.Hour)
+
+	d := Device{ID: "tv", Name: "TV", CapabilitiesObj: map[string]Capability{
+		"onoff":         {Value: false},
+		"measure_power": {Value: 12.0, LastUpdated: &fresh},
+	}}
+	findings := auditDeviceState(d, now, testAuditOptions)
+	if len(findings) != 1 || findings[0].Kind != "power-while-off" {
+		t.Fatalf("expected power-while-off, got %+v", findings)
+	}
+
+	d.CapabilitiesObj["onoff"] = Capability{Value: true}
+	d.CapabilitiesObj["measure_power"] = Capability{Value: 12.0, LastUpdated: &old}
+	findings = auditDeviceState(d, now, testAuditOptions)
+	if len(findings) != 1 || findings[0].Kind != "stale" {
+		t.Fatalf("expected stale, got %+v", findings)
+	}
+
+	d.CapabilitiesObj["meter_power"] = Capability{Value: 100.0, LastUpdated: &fresh}
+	if findings := auditDeviceState(d, now, testAuditOptions); len(findings) != 0 {
+		t.Errorf("expected meter_power update to count as fresh, got %+v", findings)
+	}
+}
+
+func TestRankAuditFindings(t *testing.T) {
+	findings := []auditFinding{
+		{Device: "Router", Kind: "standby", Watts: 10},
+		{Device: "Meter", Kind: "stale"},
+		{Device: "Freezer", Kind: "jump", Watts: 50},
+	}
+	rankAuditFindings(findings, 2)
+
+	if findings[0].Device != "Freezer" || findings[1].Device != "Router" || findings[2].Device != "Meter" {
+		t.Errorf("unexpected order: %+v", findings)
+	}
+	if !approx(findings[1].YearlyKWh, 87.6) || !approx(findings[1].YearlyCost, 175.2) {
+		t.Errorf("unexpected yearly estimate: %+v", findings[1])
+	}
+
+	// Without a known price every cost is 0 and kWh decides
+	findings = []auditFinding{{Device: "Router", Watts: 10}, {Device: "Freezer", Watts: 50}}
+	rankAuditFindings(findings, 0)
+	if findings[0].Device != "Freezer" {
+		t.Errorf("expected kWh to break cost ties, got %+v", findings)
+	}
+}
+
+func TestAuditYearlyCost(t *testing.T) {
+	findings := []auditFinding{
+		{DeviceID: "tv", Kind: "standby", YearlyCost: 40},
+		{DeviceID: "tv", Kind: "power-while-off", YearlyCost: 50},
+		{DeviceID: "tv", Kind: "jump", YearlyCost: 100},
+		{DeviceID: "router", Kind: "standby", YearlyCost: 30},
+		{DeviceID: "meter", Kind: "stale"},
+	}
+	// The TV's idle draw counts once, at the larger estimate
+	if got := auditYearlyCost(findings); !approx(got, 180) {
+		t.Errorf("auditYearlyCost = %v, want 180", got)
+	}
+}
@@ -0,0 +1,378 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/rodaine/table"
+	"github.com/spf13/cobra"
+)
+
+// deviceEnergySeries is hourly consumption for one device
+type deviceEnergySeries struct {
+	ID    string
+	Name  string
+	Zone  string
+	Hours map[time.Time]float64
+}
+
+// energyCostRow is consumption and cost for one device, zone, or day
+type energyCostRow struct {
+	Name        string  `json:"name"`
+	ID          string  `json:"id,omitempty"`
+	KWh         float64 `json:"kWh"`
+	Cost        float64 `json:"cost"`
+	PeakKWh     float64 `json:"peakKWh"`
+	PeakShare   float64 `json:"peakShare"`
+	UnpricedKWh float64 `json:"unpricedKWh,omitempty"`
+}
+
+func (r *energyCostRow) add(kwh, price float64, priced, peak bool) {
+	r.KWh += kwh
+	if priced {
+		r.Cost += kwh * price
+	} else {
+		r.UnpricedKWh += kwh
+	}
+	if peak {
+		r.PeakKWh += kwh
+	}
+}
+
+func (r *energyCostRow) finish() {
+	if r.KWh > 0 {
+		r.PeakShare = r.PeakKWh / r.KWh
+	}
+}
+
+// EnergyCostReport is the output of 'energy cost'
+type EnergyCostReport struct {
+	From      time.Time       `json:"from"`
+	To        time.Time       `json:"to"`
+	PriceType string          `json:"priceType"`
+	Unit      string          `json:"unit"`
+	Total     energyCostRow   `json:"total"`
+	Devices   []energyCostRow `json:"devices"`
+	Zones     []energyCostRow `json:"zones"`
+	Days      []energyCostRow `json:"days"`
+}
+
+// buildEnergyCostReport prices hourly consumption and groups it per device,
+// zone, and day. isPeak decides whether an hour counts as a peak hour.
+func buildEnergyCostReport(series []deviceEnergySeries, prices *priceSchedule, isPeak func(time.Time) bool) EnergyCostReport {
+	report := EnergyCostReport{PriceType: prices.Type, Unit: prices.Unit, Total: energyCostRow{Name: "Total"}}
+	zones := make(map[string]*energyCostRow)
+	days := make(map[string]*energyCostRow)
+
+	for _, s := range series {
+		row := energyCostRow{Name: s.Name, ID: s.ID}
+		zone, ok := zones[s.Zone]
+		if !ok {
+			zone = &energyCostRow{Name: s.Zone}
+			zones[s.Zone] = zone
+		}
+
+		for hour, kwh := range s.Hours {
+			price, priced := prices.At(hour)
+			peak := isPeak(hour)
+			dayKey := hour.Local().Format("2006-01-02")
+			day, ok := days[dayKey]
+			if !ok {
+				day = &energyCostRow{Name: dayKey}
+				days[dayKey] = day
+			}
+
+			row.add(kwh, price, priced, peak)
+			zone.add(kwh, price, priced, peak)
+			day.add(kwh, price, priced, peak)
+			report.Total.add(kwh, price, priced, peak)
+		}
+
+		row.finish()
+		report.Devices = append(report.Devices, row)
+	}
+
+	for _, z := range zones {
+		z.finish()
+		report.Zones = append(report.Zones, *z)
+	}
+	for _, d := range days {
+		d.finish()
+		report.Days = append(report.Days, *d)
+	}
+	report.Total.finish()
+
+	byCost := func(rows []energyCostRow) {
+		sort.Slice(rows, func(i, j int) bool {
+			if rows[i].Cost != rows[j].Cost {
+				return rows[i].Cost > rows[j].Cost
+			}
+			return rows[i].KWh > rows[j].KWh
+		})
+	}
+	byCost(report.Devices)
+	byCost(report.Zones)
+	sort.Slice(report.Days, func(i, j int) bool { return report.Days[i].Name < report.Days[j].Name })
+	return report
+}
+
+// parseHourRange parses a clock range like "07-22" into start and end hours
+func parseHourRange(s string) (int, int, error) {
+	var start, end int
+	if _, err := fmt.Sscanf(s, "%d-%d", &start, &end); err != nil {
+		return 0, 0, fmt.Errorf("invalid hour range: %s (use e.g. 07-22)", s)
+	}
+	if start < 0 || start > 24 || end < 0 || end > 24 {
+		return 0, 0, fmt.Errorf("invalid hour range: %s (hours must be 0-24)", s)
+	}
+	return start, end, nil
+}
+
+// inHourRange reports whether the local hour of t falls in [start, end),
+// wrapping around midnight when start > end.
+func inHourRange(t time.Time, start, end int) bool {
+	h := t.Local().Hour()
+	if start <= end {
+		return h >= start && h < end
+	}
+	return h >= start || h < end
+}
+
+// peakFunc returns the peak-hour predicate: a fixed clock range if given,
+// otherwise hours priced above the day's average.
+func peakFunc(peakHours string, prices *priceSchedule) (func(time.Time) bool, error) {
+	if peakHours != "" {
+		start, end, err := parseHourRange(peakHours)
+		if err != nil {
+			return nil, err
+		}
+		return func(t time.Time) bool { return inHourRange(t, start, end) }, nil
+	}
+	return func(t time.Time) bool {
+		price, ok := prices.At(t)
+		if !ok {
+			return false
+		}
+		avg, ok := prices.dayAverage(t)
+		return ok && price > avg
+	}, nil
+}
+
+// parseDateRange parses --from/--to dates (YYYY-MM-DD, local time).
+// The end date is inclusive; the returned end is capped at now.
+func parseDateRange(fromStr, toStr string, defaultDays int, now time.Time) (time.Time, time.Time, error) {
+	from := startOfDay(now).AddDate(0, 0, -defaultDays)
+	to := now
+	if fromStr != "" {
+		t, err := time.ParseInLocation("2006-01-02", fromStr, time.Local)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid --from date: %s (use YYYY-MM-DD)", fromStr)
+		}
+		from = t
+	}
+	if toStr != "" {
+		t, err := time.ParseInLocation("2006-01-02", toStr, time.Local)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid --to date: %s (use YYYY-MM-DD)", toStr)
+		}
+		to = t.AddDate(0, 0, 1)
+	}
+	if to.After(now) {
+		to = now
+	}
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("--from must be before --to")
+	}
+	return from, to, nil
+}
+
+// fetchDeviceEnergy loads hourly consumption for every device with power
+// insights, preferring cumulative meter_power over integrated measure_power.
+func fetchDeviceEnergy(from, to time.Time, resolution string) ([]deviceEnergySeries, error) {
+	zones, devices, err := fetchZonesAndDevices()
+	if err != nil {
+		return nil, err
+	}
+	logs, err := fetchInsightLogs()
+	if err != nil {
+		return nil, err
+	}
+
+	meters := deviceInsightLogs(logs, "meter_power")
+	powers := deviceInsightLogs(logs, "measure_power")
+
+	var series []deviceEnergySeries
+	for _, d := range devices {
+		zoneName := d.Zone
+		if z, ok := zones[d.Zone]; ok {
+			zoneName = z.Name
+		}
+		s := deviceEnergySeries{ID: d.ID, Name: d.Name, Zone: zoneName}
+
+		if l, ok := meters[d.ID]; ok {
+			data, err := apiClient.GetInsightEntries(l.OwnerURI, l.ID, resolution)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", d.Name, err)
+			}
+			points, err := parseInsightEntries(data)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", d.Name, err)
+			}
+			s.Hours = hourlyEnergyFromMeter(points, from, to)
+		} else if l, ok := powers[d.ID]; ok {
+			data, err := apiClient.GetInsightEntries(l.OwnerURI, l.ID, resolution)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", d.Name, err)
+			}
+			points, err := parseInsightEntries(data)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", d.Name, err)
+			}
+			s.Hours = hourlyEnergyFromPower(points, from, to)
+		} else {
+			continue
+		}
+
+		if len(s.Hours) > 0 {
+			series = append(series, s)
+		}
+	}
+	return series, nil
+}
+
+func writeEnergyCostCSV(rows []energyCostRow, total energyCostRow) error {
+	w := csv.NewWriter(os.Stdout)
+	w.Write([]string{"name", "id", "kwh", "cost", "peak_kwh", "peak_share", "unpriced_kwh"})
+	for _, r := range append(rows, total) {
+		w.Write([]string{
+			r.Name,
+			r.ID,
+			fmt.Sprintf("%.3f", r.KWh),
+			fmt.Sprintf("%.2f", r.Cost),
+			fmt.Sprintf("%.3f", r.PeakKWh),
+			fmt.Sprintf("%.3f", r.PeakShare),
+			fmt.Sprintf("%.3f", r.UnpricedKWh),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+var energyCostCmd = &cobra.Command{
+	Use:   "cost",
+	Short: "Show energy cost per device, zone, and day",
+	Long: `Combine per-device consumption with electricity prices.
+
+Consumption is read from device insights (meter_power when available,
+otherwise measure_power integrated over time). Each hour is priced with
+the price type configured on Homey: dynamic spot prices per interval,
+or the fixed price.
+
+Peak hours are hours priced above the day's average price. Use
+--peak-hours to use a fixed clock range instead (e.g. for grid tariffs).
+
+Examples:
+  homeyctl energy cost                                  # Last 7 days
+  homeyctl energy cost --from 2025-01-01 --to 2025-01-31
+  homeyctl energy cost --by zone
+  homeyctl energy cost --by day --format csv > cost.csv
+  homeyctl energy cost --peak-hours 06-22 --json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fromStr, _ := cmd.Flags().GetString("from")
+		toStr, _ := cmd.Flags().GetString("to")
+		by, _ := cmd.Flags().GetString("by")
+		format, _ := cmd.Flags().GetString("format")
+		peakHours, _ := cmd.Flags().GetString("peak-hours")
+		resolution, _ := cmd.Flags().GetString("resolution")
+
+		if by != "device" && by != "zone" && by != "day" {
+			return fmt.Errorf("invalid --by: %s (use: device, zone, day)", by)
+		}
+		if format != "table" && format != "csv" {
+			return fmt.Errorf("invalid --format: %s (use: table, csv)", format)
+		}
+
+		now := time.Now()
+		from, to, err := parseDateRange(fromStr, toStr, 7, now)
+		if err != nil {
+			return err
+		}
+		if resolution == "" {
+			resolution = insightResolutionFor(from, now)
+		}
+
+		prices, err := loadPriceSchedule(from, to)
+		if err != nil {
+			return err
+		}
+		isPeak, err := peakFunc(peakHours, prices)
+		if err != nil {
+			return err
+		}
+
+		series, err := fetchDeviceEnergy(from, to, resolution)
+		if err != nil {
+			return err
+		}
+
+		report := buildEnergyCostReport(series, prices, isPeak)
+		report.From = from
+		report.To = to
+
+		if isJSON() {
+			out, _ := json.MarshalIndent(report, "", "  ")
+			fmt.Println(string(out))
+			return nil
+		}
+
+		rows := report.Devices
+		switch by {
+		case "zone":
+			rows = report.Zones
+		case "day":
+			rows = report.Days
+		}
+
+		if format == "csv" {
+			return writeEnergyCostCSV(rows, report.Total)
+		}
+
+		color.New(color.Bold).Printf("Energy cost %s – %s (%s)\n\n",
+			from.Format("2006-01-02"), to.Add(-time.Second).Format("2006-01-02"), prices.Type)
+		if prices.Type == "disabled" || prices.Type == "" {
+			color.Yellow("Electricity prices are disabled on Homey; showing consumption only.\n\n")
+		}
+
+		headerFmt := color.New(color.FgCyan, color.Underline).SprintfFunc()
+		tbl := table.New(strings.ToUpper(by[:1])+by[1:], "kWh", "Cost", "Peak share")
+		tbl.WithHeaderFormatter(headerFmt)
+		for _, r := range rows {
+			tbl.AddRow(r.Name, fmt.Sprintf("%.2f", r.KWh), fmt.Sprintf("%.2f", r.Cost), fmt.Sprintf("%.0f%%", r.PeakShare*100))
+		}
+		tbl.Print()
+
+		fmt.Println()
+		fmt.Printf("Total:      %.2f kWh, %.2f %s\n", report.Total.KWh, report.Total.Cost, strings.TrimSuffix(prices.Unit, "/kWh"))
+		fmt.Printf("Peak share: %.0f%%\n", report.Total.PeakShare*100)
+		if report.Total.UnpricedKWh > 0 {
+			color.Yellow("%.2f kWh had no price available and is not included in cost\n", report.Total.UnpricedKWh)
+		}
+		return nil
+	},
+}
+
+func init() {
+	energyCmd.AddCommand(energyCostCmd)
+	energyCostCmd.Flags().String("from", "", "Start date (YYYY-MM-DD, default: 7 days ago)")
+	energyCostCmd.Flags().String("to", "", "End date, inclusive (YYYY-MM-DD, default: today)")
+	energyCostCmd.Flags().String("by", "device", "Group by: device, zone, day")
+	energyCostCmd.Flags().String("format", "table", "Output format: table, csv (use --json for JSON)")
+	energyCostCmd.Flags().String("peak-hours", "", "Fixed peak hours as a clock range, e.g. 06-22")
+	energyCostCmd.Flags().String("resolution", "", "Insights resolution (default: chosen from --from)")
+}
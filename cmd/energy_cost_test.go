@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestEnergyCostCommand_Exists(t *testing.T) {
+	cmd, _, err := energyCmd.Find([]string{"cost"})
+	if err != nil {
+		t.Fatalf("cost command not found: %v", err)
+	}
+	for _, flag := range []string{"from", "to", "by", "format", "peak-hours"} {
+		if cmd.Flags().Lookup(flag) == nil {
+			t.Errorf("expected --%s flag to be defined", flag)
+		}
+	}
+}
+
+func TestPriceScheduleAt(t *testing.T) {
+	base := time.Date(2025, 1, 10, 0, 0, 0, 0, time.Local)
+	s := &priceSchedule{Type: "dynamic", Intervals: []priceInterval{
+		{Start: base, End: base.Add(time.Hour), Value: 1},
+		{Start: base.Add(time.Hour), End: base.Add(2 * time.Hour), Value: 3},
+	}}
+
+	if p, ok := s.At(base.Add(30 * time.Minute)); !ok || p != 1 {
+		t.Errorf("expected price 1, got %v %v", p, ok)
+	}
+	if p, ok := s.At(base.Add(time.Hour)); !ok || p != 3 {
+		t.Errorf("expected price 3 at interval boundary, got %v %v", p, ok)
+	}
+	if _, ok := s.At(base.Add(3 * time.Hour)); ok {
+		t.Error("expected no price outside intervals")
+	}
+	if avg, ok := s.dayAverage(base.Add(5 * time.Hour)); !ok || avg != 2 {
+		t.Errorf("expected day average 2, got %v %v", avg, ok)
+	}
+
+	fixed := &priceSchedule{Type: "fixed", Fixed: 0.5}
+	if p, ok := fixed.At(base); !ok || p != 0.5 {
+		t.Errorf("expected fixed price 0.5, got %v %v", p, ok)
+	}
+}
+
+func TestParseInsightEntries_BothFormats(t *testing.T) {
+	array := []byte(`[{"t":"2025-01-10T01:00:00Z","v":2},{"t":"2025-01-10T00:00:00Z","v":1},{"t":"2025-01-10T02:00:00Z","v":null}]`)
+	points, err := parseInsightEntries(array)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(points) != 2 || points[0].V != 1 || points[1].V != 2 {
+		t.Errorf("expected 2 sorted points without nulls, got %+v", points)
+	}
+
+	wrapped := []byte(`{"step":3600000,"values":[{"t":"2025-01-10T00:00:00Z","v":5}]}`)
+	points, err = parseInsightEntries(wrapped)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(points) != 1 || points[0].V != 5 {
+		t.Errorf("expected 1 point, got %+v", points)
+	}
+}
+
+func TestHourlyEnergyFromPower(t *testing.T) {
+	base := time.Date(2025, 1, 10, 10, 30, 0, 0, time.UTC)
+	points := []insightPoint{
+		{T: base, V: 1000},
+		{T: base.Add(time.Hour), V: 0},
+	}
+
+	buckets := hourlyEnergyFromPower(points, base.Add(-24*time.Hour), base.Add(24*time.Hour))
+
+	h10 := time.Date(2025, 1, 10, 10, 0, 0, 0, time.UTC)
+	if !approx(buckets[h10], 0.5) || !approx(buckets[h10.Add(time.Hour)], 0.5) {
+		t.Errorf("expected 1 kWh split evenly across two hours, got %v", buckets)
+	}
+}
+
+func TestHourlyEnergyFromMeter_SkipsResets(t *testing.T) {
+	base := time.Date(2025, 1, 10, 10, 0, 0, 0, time.UTC)
+	points := []insightPoint{
+		{T: base, V: 100},
+		{T: base.Add(time.Hour), V: 102},
+		{T: base.Add(2 * time.Hour), V: 1}, // meter reset
+		{T: base.Add(3 * time.Hour), V: 2},
+	}
+
+	buckets := hourlyEnergyFromMeter(points, base, base.Add(24*time.Hour))
+
+	var total float64
+	for _, v := range buckets {
+		total += v
+	}
+	if !approx(total, 3) {
+		t.Errorf("expected 3 kWh excluding reset, got %v", total)
+	}
+}
+
+func TestHourlyEnergy_ClipsToRange(t *testing.T) {
+	base := time.Date(2025, 1, 10, 10, 0, 0, 0, time.UTC)
+	points := []insightPoint{{T: base, V: 0}, {T: base.Add(2 * time.Hour), V: 2}}
+
+	buckets := hourlyEnergyFromMeter(points, base.Add(time.Hour), base.Add(24*time.Hour))
+	if len(buckets) != 1 || !approx(buckets[base.Add(time.Hour)], 1) {
+		t.Errorf("expected only the second hour to be counted, got %v", buckets)
+	}
+}
+
+func TestBuildEnergyCostReport(t *testing.T) {
+	day := time.Date(2025, 1, 10, 0, 0, 0, 0, time.Local)
+	prices := &priceSchedule{Type: "dynamic", Unit: "NOK/kWh", Intervals: []priceInterval{
+		{Start: day, End: day.Add(time.Hour), Value: 1},
+		{Start: day.Add(time.Hour), End: day.Add(2 * time.Hour), Value: 3},
+	}}
+	series := []deviceEnergySeries{
+		{ID: "heater", Name: "Heater", Zone: "Bath", Hours: map[time.Time]float64{day: 1, day.Add(time.Hour): 1}},
+		{ID: "lamp", Name: "Lamp", Zone: "Bath", Hours: map[time.Time]float64{day: 0.5, day.Add(5 * time.Hour): 0.5}},
+	}
+	isPeak, err := peakFunc("", prices)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	report := buildEnergyCostReport(series, prices, isPeak)
+
+	if !approx(report.Total.KWh, 3) || !approx(report.Total.Cost, 4.5) {
+		t.Errorf("expected 3 kWh / 4.5 cost, got %v / %v", report.Total.KWh, report.Total.Cost)
+	}
+	if !approx(report.Total.UnpricedKWh, 0.5) {
+		t.Errorf("expected 0.5 unpriced kWh, got %v", report.Total.UnpricedKWh)
+	}
+	if report.Devices[0].Name != "Heater" || !approx(report.Devices[0].PeakShare, 0.5) {
+		t.Errorf("expected Heater first with 50%% peak share, got %+v", report.Devices[0])
+	}
+	if len(report.Zones) != 1 || !approx(report.Zones[0].KWh, 3) {
+		t.Errorf("expected one zone with 3 kWh, got %+v", report.Zones)
+	}
+	if len(report.Days) != 1 || report.Days[0].Name != "2025-01-10" {
+		t.Errorf("expected one day, got %+v", report.Days)
+	}
+}
+
+func TestInHourRange(t *testing.T) {
+	at := func(h int) time.Time { return time.Date(2025, 1, 10, h, 0, 0, 0, time.Local) }
+
+	if !inHourRange(at(7), 6, 22) || inHourRange(at(22), 6, 22) {
+		t.Error("unexpected result for daytime range 06-22")
+	}
+	if !inHourRange(at(23), 22, 6) || !inHourRange(at(2), 22, 6) || inHourRange(at(12), 22, 6) {
+		t.Error("unexpected result for overnight range 22-06")
+	}
+	if _, _, err := parseHourRange("morning"); err == nil {
+		t.Error("expected error for invalid hour range")
+	}
+}
+
+func TestParseDateRange(t *testing.T) {
+	now := time.Date(2025, 1, 20, 12, 0, 0, 0, time.Local)
+
+	from, to, err := parseDateRange("2025-01-01", "2025-01-10", 7, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !from.Equal(time.Date(2025, 1, 1, 0, 0, 0, 0, time.Local)) || !to.Equal(time.Date(2025, 1, 11, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("unexpected range %v - %v", from, to)
+	}
+
+	from, to, err = parseDateRange("", "", 7, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !from.Equal(time.Date(2025, 1, 13, 0, 0, 0, 0, time.Local)) || !to.Equal(now) {
+		t.Errorf("unexpected default range %v - %v", from, to)
+	}
+
+	if _, _, err := parseDateRange("2025-01-10", "2025-01-01", 7, now); err == nil {
+		t.Error("expected error when --from is after --to")
+	}
+}
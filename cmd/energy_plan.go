@@ -0,0 +1,397 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/rodaine/table"
+	"github.com/spf13/cobra"
+)
+
+// planSlot is a continuous period in an energy plan
+type planSlot struct {
+	Start        time.Time `json:"start"`
+	End          time.Time `json:"end"`
+	AveragePrice float64   `json:"averagePrice"`
+}
+
+// EnergyPlan is the output of 'energy plan'
+type EnergyPlan struct {
+	Device        string     `json:"device"`
+	DeviceID      string     `json:"deviceId"`
+	From          time.Time  `json:"from"`
+	To            time.Time  `json:"to"`
+	Hours         float64    `json:"hours"`
+	Contiguous    bool       `json:"contiguous"`
+	Unit          string     `json:"unit"`
+	AveragePrice  float64    `json:"averagePrice"`
+	EstimatedCost *float64   `json:"estimatedCost,omitempty"`
+	Slots         []planSlot `json:"slots"`
+}
+
+// planCheapestSlots picks price intervals within [from, to) totalling
+// duration, either as one contiguous block or as the cheapest intervals
+// anywhere in the window. The last interval needed is cut short so a
+// fractional duration ends on the minute it is used up rather than at the
+// interval's end. Returns merged slots and the time-weighted average price.
+func planCheapestSlots(intervals []priceInterval, from, to time.Time, duration time.Duration, contiguous bool) ([]planSlot, float64, error) {
+	var window []priceInterval
+	var available time.Duration
+	for _, p := range intervals {
+		if !p.Start.Before(from) && !p.End.After(to) {
+			window = append(window, p)
+			available += p.End.Sub(p.Start)
+		}
+	}
+	sort.Slice(window, func(i, j int) bool { return window[i].Start.Before(window[j].Start) })
+
+	if available < duration {
+		return nil, 0, fmt.Errorf("only %.1f hours of prices available between %s and %s (need %.1f)",
+			available.Hours(), from.Local().Format("Jan 2 15:04"), to.Local().Format("Jan 2 15:04"), duration.Hours())
+	}
+
+	var chosen []priceInterval
+	if contiguous {
+		bestCost := -1.0
+		for i := range window {
+			var acc time.Duration
+			var cost float64
+			for j := i; j < len(window); j++ {
+				if j > i && !window[j].Start.Equal(window[j-1].End) {
+					break
+				}
+				used := window[j].End.Sub(window[j].Start)
+				if acc+used > duration {
+					used = duration - acc
+				}
+				acc += used
+				cost += window[j].Value * used.Hours()
+				if acc >= duration {
+					if bestCost < 0 || cost/acc.Hours() < bestCost {
+						bestCost = cost / acc.Hours()
+						chosen = append([]priceInterval(nil), window[i:j+1]...)
+						chosen[len(chosen)-1].End = chosen[len(chosen)-1].Start.Add(used)
+					}
+					break
+				}
+			}
+		}
+		if chosen == nil {
+			return nil, 0, fmt.Errorf("no contiguous block of %.1f hours with known prices", duration.Hours())
+		}
+	} else {
+		byPrice := append([]priceInterval(nil), window...)
+		sort.SliceStable(byPrice, func(i, j int) bool { return byPrice[i].Value < byPrice[j].Value })
+		var acc time.Duration
+		for _, p := range byPrice {
+			if acc >= duration {
+				break
+			}
+			if used := p.End.Sub(p.Start); acc+used > duration {
+				p.End = p.Start.Add(duration - acc)
+			}
+			chosen = append(chosen, p)
+			acc += p.End.Sub(p.Start)
+		}
+		sort.Slice(chosen, func(i, j int) bool { return chosen[i].Start.Before(chosen[j].Start) })
+	}
+
+	var slots []planSlot
+	var totalCost, totalHours float64
+	var slotCost, slotHours float64
+	for i, p := range chosen {
+		hours := p.End.Sub(p.Start).Hours()
+		totalCost += p.Value * hours
+		totalHours += hours
+
+		if i > 0 && p.Start.Equal(slots[len(slots)-1].End) {
+			slots[len(slots)-1].End = p.End
+		} else {
+			if len(slots) > 0 {
+				slots[len(slots)-1].AveragePrice = slotCost / slotHours
+			}
+			slots = append(slots, planSlot{Start: p.Start, End: p.End})
+			slotCost, slotHours = 0, 0
+		}
+		slotCost += p.Value * hours
+		slotHours += hours
+	}
+	slots[len(slots)-1].AveragePrice = slotCost / slotHours
+
+	return slots, totalCost / totalHours, nil
+}
+
+// nextClockTime returns the first time at or after t with the given HH:MM
+func nextClockTime(clock string, t time.Time) (time.Time, error) {
+	hm, err := time.Parse("15:04", clock)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time: %s (use HH:MM)", clock)
+	}
+	t = t.Local()
+	next := time.Date(t.Year(), t.Month(), t.Day(), hm.Hour(), hm.Minute(), 0, 0, time.Local)
+	if next.Before(t) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next, nil
+}
+
+// planFlowDisableCode is HomeyScript that disables the named advanced flow
+const planFlowDisableCode = `const name = %s;
+const flows = await Homey.flow.getAdvancedFlows();
+const flow = Object.values(flows).find(f => f.name === name);
+if (flow) await Homey.flow.updateAdvancedFlow({ id: flow.id, advancedflow: { enabled: false } });
+return true;`
+
+// buildPlanFlow builds an advanced flow that turns the device on at the
+// start of each slot and off at the end. Time triggers fire every day, so
+// the last off action is followed by a HomeyScript card that disables the
+// flow, making it run once for the planned window.
+func buildPlanFlow(name, deviceID string, slots []planSlot) map[string]interface{} {
+	cards := map[string]interface{}{}
+	addEdge := func(at time.Time, action string) map[string]interface{} {
+		triggerID, actionID := newCardID(), newCardID()
+		trigger := newAdvancedCard("trigger", "homey:manager:cron:time_exactly", map[string]interface{}{
+			"time": at.Local().Format("15:04"),
+		})
+		linkCards(trigger, "outputSuccess", actionID)
+		cards[triggerID] = trigger
+		cards[actionID] = newAdvancedCard("action", fmt.Sprintf("homey:device:%s:%s", deviceID, action), nil)
+		return cards[actionID].(map[string]interface{})
+	}
+	var last map[string]interface{}
+	for _, s := range slots {
+		addEdge(s.Start, "on")
+		last = addEdge(s.End, "off")
+	}
+	quoted, _ := json.Marshal(name)
+	disableID := newCardID()
+	cards[disableID] = newAdvancedCard("action", "homey:app:com.athom.homeyscript:runCode", map[string]interface{}{
+		"code": fmt.Sprintf(planFlowDisableCode, quoted),
+	})
+	linkCards(last, "outputSuccess", disableID)
+	layoutAdvancedFlowCards(cards)
+
+	return map[string]interface{}{
+		"name":    name,
+		"enabled": true,
+		"cards":   cards,
+	}
+}
+
+// findPlanFlow returns the advanced flow with the given name, ignoring
+// simple flows that happen to share it
+func findPlanFlow(name string) (*foundFlow, error) {
+	data, err := apiClient.GetAdvancedFlows()
+	if err != nil {
+		return nil, err
+	}
+	var flows map[string]json.RawMessage
+	if err := json.Unmarshal(data, &flows); err != nil {
+		return nil, fmt.Errorf("failed to parse advanced flows: %w", err)
+	}
+	for _, raw := range flows {
+		var f AdvancedFlow
+		if err := json.Unmarshal(raw, &f); err != nil {
+			continue
+		}
+		if strings.EqualFold(f.Name, name) {
+			return &foundFlow{ID: f.ID, Name: f.Name, Advanced: true, Raw: raw}, nil
+		}
+	}
+	return nil, nil
+}
+
+// runPlan switches the device on and off at the slot boundaries, waiting
+// in the foreground until the plan is complete.
+func runPlan(deviceID, deviceName string, slots []planSlot) error {
+	for _, s := range slots {
+		if wait := time.Until(s.Start); wait > 0 {
+			fmt.Printf("Waiting until %s to turn on %s\n", s.Start.Local().Format("15:04"), deviceName)
+			time.Sleep(wait)
+		}
+		if time.Now().Before(s.End) {
+			if err := apiClient.SetCapability(deviceID, "onoff", true); err != nil {
+				return err
+			}
+			color.Green("%s: turned on %s\n", time.Now().Format("15:04"), deviceName)
+			time.Sleep(time.Until(s.End))
+		}
+		if err := apiClient.SetCapability(deviceID, "onoff", false); err != nil {
+			return err
+		}
+		color.Green("%s: turned off %s\n", time.Now().Format("15:04"), deviceName)
+	}
+	return nil
+}
+
+var energyPlanCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Plan device runtime in the cheapest price intervals",
+	Long: `Find the cheapest time slots to run a device before a deadline.
+
+Uses dynamic electricity prices (pricesPerInterval) for today and tomorrow.
+By default the cheapest intervals are picked anywhere in the window; use
+--contiguous to require one continuous block.
+
+The plan can be printed, run once in the foreground (--run), or turned
+into an advanced flow with time triggers (--create-flow).
+
+Both --run and --create-flow switch the device once, for the planned
+window only. Homey time triggers fire every day, so the flow ends with a
+HomeyScript card that disables it after the last slot; this requires the
+HomeyScript app. Re-plan with --create-flow --replace (e.g. daily from
+cron) to schedule the next window.
+
+A fractional --hours value ends the last slot part-way through its price
+interval, so the device runs for the requested time and no longer.
+
+Examples:
+  homeyctl energy plan --device "Water heater" --hours 3 --before 07:00
+  homeyctl energy plan --device "Water heater" --hours 3 --before 07:00 --contiguous
+  homeyctl energy plan --device "EV charger" --hours 4 --after 22:00 --before 07:00 --kw 7.4
+  homeyctl energy plan --device "Water heater" --hours 3 --before 07:00 --create-flow --replace
+  homeyctl energy plan --device "Water heater" --hours 3 --before 07:00 --run`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		deviceName, _ := cmd.Flags().GetString("device")
+		hours, _ := cmd.Flags().GetFloat64("hours")
+		before, _ := cmd.Flags().GetString("before")
+		after, _ := cmd.Flags().GetString("after")
+		contiguous, _ := cmd.Flags().GetBool("contiguous")
+		kw, _ := cmd.Flags().GetFloat64("kw")
+		createFlow, _ := cmd.Flags().GetBool("create-flow")
+		replace, _ := cmd.Flags().GetBool("replace")
+		run, _ := cmd.Flags().GetBool("run")
+
+		if deviceName == "" {
+			return fmt.Errorf("--device is required")
+		}
+		if hours <= 0 {
+			return fmt.Errorf("--hours must be greater than 0")
+		}
+		if before == "" {
+			return fmt.Errorf("--before is required (HH:MM)")
+		}
+		if createFlow && run {
+			return fmt.Errorf("use either --create-flow or --run, not both")
+		}
+
+		device, err := findDevice(deviceName)
+		if err != nil {
+			return err
+		}
+		if _, ok := device.CapabilitiesObj["onoff"]; !ok && (createFlow || run) {
+			return fmt.Errorf("device %s has no onoff capability", device.Name)
+		}
+
+		now := time.Now()
+		from := now
+		if after != "" {
+			if from, err = nextClockTime(after, now); err != nil {
+				return err
+			}
+		}
+		to, err := nextClockTime(before, from)
+		if err != nil {
+			return err
+		}
+
+		unit, intervals, err := fetchPriceIntervals(from, to)
+		if err != nil {
+			return err
+		}
+
+		// Time triggers have minute resolution
+		duration := time.Duration(hours * float64(time.Hour)).Round(time.Minute)
+		if duration <= 0 {
+			return fmt.Errorf("--hours must be at least one minute")
+		}
+		slots, avg, err := planCheapestSlots(intervals, from, to, duration, contiguous)
+		if err != nil {
+			return err
+		}
+
+		plan := EnergyPlan{
+			Device:       device.Name,
+			DeviceID:     device.ID,
+			From:         from,
+			To:           to,
+			Hours:        hours,
+			Contiguous:   contiguous,
+			Unit:         unit,
+			AveragePrice: avg,
+			Slots:        slots,
+		}
+		if kw > 0 {
+			cost := avg * kw * hours
+			plan.EstimatedCost = &cost
+		}
+
+		if createFlow {
+			flowName := fmt.Sprintf("Energy plan: %s", device.Name)
+			if replace {
+				existing, err := findPlanFlow(flowName)
+				if err != nil {
+					return err
+				}
+				if existing != nil {
+					recordFlowHistory(existing, "delete")
+					if err := apiClient.DeleteAdvancedFlow(existing.ID); err != nil {
+						return err
+					}
+				}
+			}
+			result, err := apiClient.CreateAdvancedFlow(buildPlanFlow(flowName, device.ID, slots))
+			if err != nil {
+				return err
+			}
+			var created struct {
+				ID string `json:"id"`
+			}
+			json.Unmarshal(result, &created)
+			if !isJSON() {
+				color.Green("Created advanced flow: %s (ID: %s)\n", flowName, created.ID)
+				color.Yellow("Note: the flow disables itself after the last slot (requires the HomeyScript app)\n")
+			}
+		}
+
+		if isJSON() {
+			out, _ := json.MarshalIndent(plan, "", "  ")
+			fmt.Println(string(out))
+		} else {
+			color.New(color.Bold).Printf("Plan for %s: %.1f hours before %s\n\n", device.Name, hours, to.Format("Jan 2 15:04"))
+			headerFmt := color.New(color.FgCyan, color.Underline).SprintfFunc()
+			tbl := table.New("Start", "End", "Avg price")
+			tbl.WithHeaderFormatter(headerFmt)
+			for _, s := range slots {
+				tbl.AddRow(s.Start.Local().Format("Jan 2 15:04"), s.End.Local().Format("15:04"), fmt.Sprintf("%.2f", s.AveragePrice))
+			}
+			tbl.Print()
+			fmt.Printf("\nAverage price: %.2f %s\n", avg, unit)
+			if plan.EstimatedCost != nil {
+				fmt.Printf("Estimated cost: %.2f (at %.1f kW)\n", *plan.EstimatedCost, kw)
+			}
+		}
+
+		if run {
+			return runPlan(device.ID, device.Name, slots)
+		}
+		return nil
+	},
+}
+
+func init() {
+	energyCmd.AddCommand(energyPlanCmd)
+	energyPlanCmd.Flags().String("device", "", "Device to schedule (required)")
+	energyPlanCmd.Flags().Float64("hours", 0, "Runtime needed in hours (required)")
+	energyPlanCmd.Flags().String("before", "", "Deadline as HH:MM (required)")
+	energyPlanCmd.Flags().String("after", "", "Earliest start as HH:MM (default: now)")
+	energyPlanCmd.Flags().Bool("contiguous", false, "Require one continuous block")
+	energyPlanCmd.Flags().Float64("kw", 0, "Device power in kW for cost estimate")
+	energyPlanCmd.Flags().Bool("create-flow", false, "Create an advanced flow that switches the device at these times once")
+	energyPlanCmd.Flags().Bool("replace", false, "Replace an existing plan advanced flow for the device")
+	energyPlanCmd.Flags().Bool("run", false, "Switch the device at the planned times once (stays in foreground)")
+}
@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fishfisher/homeyctl/internal/fakehomey"
+)
+
+func hourlyPrices(start time.Time, values ...float64) []priceInterval {
+	var intervals []priceInterval
+	for i, v := range values {
+		s := start.Add(time.Duration(i) * time.Hour)
+		intervals = append(intervals, priceInterval{Start: s, End: s.Add(time.Hour), Value: v})
+	}
+	return intervals
+}
+
+func TestEnergyPlanCommand_Exists(t *testing.T) {
+	cmd, _, err := energyCmd.Find([]string{"plan"})
+	if err != nil {
+		t.Fatalf("plan command not found: %v", err)
+	}
+	for _, flag := range []string{"device", "hours", "before", "contiguous", "create-flow", "run"} {
+		if cmd.Flags().Lookup(flag) == nil {
+			t.Errorf("expected --%s flag to be defined", flag)
+		}
+	}
+}
+
+func TestPlanCheapestSlots_NonContiguous(t *testing.T) {
+	start := time.Date(2025, 1, 10, 0, 0, 0, 0, time.Local)
+	prices := hourlyPrices(start, 5, 1, 4, 2, 1.5, 6)
+
+	slots, avg, err := planCheapestSlots(prices, start, start.Add(6*time.Hour), 3*time.Hour, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Cheapest hours are 01 (1), 03 (2) and 04 (1.5); 03-05 merges into one slot
+	if len(slots) != 2 {
+		t.Fatalf("expected 2 slots, got %+v", slots)
+	}
+	if !slots[0].Start.Equal(start.Add(time.Hour)) || !slots[0].End.Equal(start.Add(2*time.Hour)) {
+		t.Errorf("unexpected first slot %+v", slots[0])
+	}
+	if !slots[1].Start.Equal(start.Add(3*time.Hour)) || !slots[1].End.Equal(start.Add(5*time.Hour)) {
+		t.Errorf("unexpected second slot %+v", slots[1])
+	}
+	if !approx(slots[1].AveragePrice, 1.75) {
+		t.Errorf("expected merged slot average 1.75, got %v", slots[1].AveragePrice)
+	}
+	if !approx(avg, 1.5) {
+		t.Errorf("expected average 1.5, got %v", avg)
+	}
+}
+
+func TestPlanCheapestSlots_Contiguous(t *testing.T) {
+	start := time.Date(2025, 1, 10, 0, 0, 0, 0, time.Local)
+	prices := hourlyPrices(start, 5, 1, 4, 2, 1.5, 6)
+
+	slots, avg, err := planCheapestSlots(prices, start, start.Add(6*time.Hour), 2*time.Hour, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(slots) != 1 || !slots[0].Start.Equal(start.Add(3*time.Hour)) {
+		t.Fatalf("expected one slot starting at 03:00, got %+v", slots)
+	}
+	if !approx(avg, 1.75) {
+		t.Errorf("expected average 1.75, got %v", avg)
+	}
+}
+
+func TestPlanCheapestSlots_FractionalHoursEndMidInterval(t *testing.T) {
+	start := time.Date(2025, 1, 10, 0, 0, 0, 0, time.Local)
+	prices := hourlyPrices(start, 5, 1, 4, 2, 1.5, 6)
+
+	slots, avg, err := planCheapestSlots(prices, start, start.Add(6*time.Hour), 150*time.Minute, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// 03:00-05:00 plus half of 05:00 would be 2.5h, but 01:00-03:30 is cheaper
+	if len(slots) != 1 || !slots[0].Start.Equal(start.Add(time.Hour)) || !slots[0].End.Equal(start.Add(210*time.Minute)) {
+		t.Fatalf("expected 01:00-03:30, got %+v", slots)
+	}
+	if !approx(avg, (1+4+1)/2.5) {
+		t.Errorf("expected average %.2f, got %v", (1+4+1)/2.5, avg)
+	}
+
+	slots, _, err = planCheapestSlots(prices, start, start.Add(6*time.Hour), 150*time.Minute, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var total time.Duration
+	for _, s := range slots {
+		total += s.End.Sub(s.Start)
+	}
+	// 01:00, 04:00 and the first half of 03:00
+	if total != 150*time.Minute || !slots[1].Start.Equal(start.Add(3*time.Hour)) || !slots[1].End.Equal(start.Add(210*time.Minute)) {
+		t.Errorf("expected 2.5 hours ending 03:30 and 05:00, got %+v", slots)
+	}
+}
+
+func TestPlanCheapestSlots_RespectsWindow(t *testing.T) {
+	start := time.Date(2025, 1, 10, 0, 0, 0, 0, time.Local)
+	prices := hourlyPrices(start, 1, 9, 9, 9)
+
+	slots, _, err := planCheapestSlots(prices, start.Add(time.Hour), start.Add(4*time.Hour), time.Hour, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if slots[0].Start.Before(start.Add(time.Hour)) {
+		t.Errorf("expected slot inside window, got %+v", slots[0])
+	}
+
+	if _, _, err := planCheapestSlots(prices, start, start.Add(2*time.Hour), 3*time.Hour, false); err == nil {
+		t.Error("expected error when not enough prices are available")
+	}
+}
+
+func TestNextClockTime(t *testing.T) {
+	now := time.Date(2025, 1, 10, 22, 30, 0, 0, time.Local)
+
+	next, err := nextClockTime("07:00", now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !next.Equal(time.Date(2025, 1, 11, 7, 0, 0, 0, time.Local)) {
+		t.Errorf("expected tomorrow 07:00, got %v", next)
+	}
+
+	next, _ = nextClockTime("23:00", now)
+	if !next.Equal(time.Date(2025, 1, 10, 23, 0, 0, 0, time.Local)) {
+		t.Errorf("expected today 23:00, got %v", next)
+	}
+
+	if _, err := nextClockTime("7am", now); err == nil {
+		t.Error("expected error for invalid time")
+	}
+}
+
+func TestBuildPlanFlow(t *testing.T) {
+	start := time.Date(2025, 1, 10, 2, 0, 0, 0, time.Local)
+	slots := []planSlot{{Start: start, End: start.Add(2 * time.Hour)}}
+
+	flow := buildPlanFlow("Energy plan: Heater", "dev1", slots)
+
+	cards := flow["cards"].(map[string]interface{})
+	if len(cards) != 5 {
+		t.Fatalf("expected 5 cards, got %d", len(cards))
+	}
+
+	var triggers, actions, disables int
+	for _, raw := range cards {
+		card := raw.(map[string]interface{})
+		switch card["type"] {
+		case "trigger":
+			triggers++
+			links := cardLinks(card)
+			if len(links) != 1 {
+				t.Errorf("expected trigger to link to one action, got %v", links)
+				continue
+			}
+			action := cards[links[0]].(map[string]interface{})
+			if action["ownerUri"] != "homey:device:dev1" {
+				t.Errorf("expected device ownerUri, got %v", action["ownerUri"])
+			}
+			if card["x"] != 0 || action["x"] != advancedCardColumnWidth {
+				t.Errorf("expected trigger in first column and action in second, got %v and %v", card["x"], action["x"])
+			}
+		case "action":
+			actions++
+			if card["id"] == "homey:device:dev1:off" {
+				links := cardLinks(card)
+				if len(links) != 1 || cards[links[0]].(map[string]interface{})["id"] != "homey:app:com.athom.homeyscript:runCode" {
+					t.Errorf("expected the off action to disable the flow, got %v", links)
+					continue
+				}
+				disables++
+				code := cards[links[0]].(map[string]interface{})["args"].(map[string]interface{})["code"].(string)
+				if !strings.Contains(code, `"Energy plan: Heater"`) || !strings.Contains(code, "enabled: false") {
+					t.Errorf("unexpected disable code:\n%s", code)
+				}
+			}
+		}
+	}
+	if triggers != 2 || actions != 3 || disables != 1 {
+		t.Errorf("expected 2 triggers, 3 actions and 1 disable link, got %d, %d and %d", triggers, actions, disables)
+	}
+}
+
+func TestEnergyPlan_ReplaceSkipsSimpleFlowWithSameName(t *testing.T) {
+	midnight := startOfDay(time.Now())
+	var prices []interface{}
+	for _, p := range hourlyPrices(midnight, make([]float64, 48)...) {
+		prices = append(prices, map[string]interface{}{
+			"periodStart": p.Start.Format(time.RFC3339), "periodEnd": p.End.Format(time.RFC3339), "value": 1,
+		})
+	}
+	dynamic, _ := json.Marshal(map[string]interface{}{"priceUnit": "NOK", "pricesPerInterval": prices})
+	h := useFakeHomey(t, &fakehomey.Seed{
+		Devices: fakehomey.Objects{
+			"heater": {"id": "heater", "name": "Heater", "capabilitiesObj": map[string]interface{}{
+				"onoff": map[string]interface{}{"id": "onoff", "value": false},
+			}},
+		},
+		Flows: fakehomey.Objects{
+			"s1": {"id": "s1", "name": "Energy plan: Heater"},
+		},
+		AdvancedFlows: fakehomey.Objects{
+			"a1": {"id": "a1", "name": "Energy plan: Heater", "cards": map[string]interface{}{}},
+		},
+		Values: map[string]json.RawMessage{"energy/price/electricity/dynamic": dynamic},
+	})
+
+	before := time.Now().Add(3 * time.Hour).Format("15:04")
+	if _, err := runCommand(t, "energy", "plan", "--device", "Heater", "--hours", "1", "--before", before, "--create-flow", "--replace"); err != nil {
+		t.Fatal(err)
+	}
+	if h.Object(fakehomey.Flows, "s1") == nil {
+		t.Error("expected the simple flow to be left alone")
+	}
+	ids := h.IDs(fakehomey.AdvancedFlows)
+	if len(ids) != 1 || ids[0] == "a1" {
+		t.Errorf("expected a1 to be replaced by a new flow, got %v", ids)
+	}
+}
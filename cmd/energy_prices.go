@@ -0,0 +1,317 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// priceInterval is one spot price period from the energy manager
+type priceInterval struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Value float64   `json:"value"`
+}
+
+// priceSchedule resolves the electricity price at a point in time,
+// either from a fixed price or from dynamic price intervals.
+type priceSchedule struct {
+	Type      string          `json:"type"`
+	Unit      string          `json:"unit"`
+	Fixed     float64         `json:"fixed,omitempty"`
+	Intervals []priceInterval `json:"intervals,omitempty"`
+}
+
+// At returns the price for the interval containing t
+func (s *priceSchedule) At(t time.Time) (float64, bool) {
+	switch s.Type {
+	case "fixed":
+		return s.Fixed, true
+	case "dynamic":
+		i := sort.Search(len(s.Intervals), func(i int) bool { return s.Intervals[i].End.After(t) })
+		if i < len(s.Intervals) && !t.Before(s.Intervals[i].Start) {
+			return s.Intervals[i].Value, true
+		}
+	}
+	return 0, false
+}
+
+// dayAverage returns the average dynamic price for the local day containing t
+func (s *priceSchedule) dayAverage(t time.Time) (float64, bool) {
+	if s.Type == "fixed" {
+		return s.Fixed, true
+	}
+	day := startOfDay(t)
+	var sum float64
+	var n int
+	for _, p := range s.Intervals {
+		if startOfDay(p.Start).Equal(day) {
+			sum += p.Value
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, false
+	}
+	return sum / float64(n), true
+}
+
+func startOfDay(t time.Time) time.Time {
+	t = t.Local()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+// parsePriceIntervals parses the response from GetElectricityPrice
+func parsePriceIntervals(data json.RawMessage) (string, []priceInterval, error) {
+	var prices struct {
+		PriceUnit         string `json:"priceUnit"`
+		PricesPerInterval []struct {
+			PeriodStart string  `json:"periodStart"`
+			PeriodEnd   string  `json:"periodEnd"`
+			Value       float64 `json:"value"`
+		} `json:"pricesPerInterval"`
+	}
+	if err := json.Unmarshal(data, &prices); err != nil {
+		return "", nil, fmt.Errorf("failed to parse electricity prices: %w", err)
+	}
+
+	intervals := make([]priceInterval, 0, len(prices.PricesPerInterval))
+	for _, p := range prices.PricesPerInterval {
+		start, err := time.Parse(time.RFC3339, p.PeriodStart)
+		if err != nil {
+			continue
+		}
+		end, err := time.Parse(time.RFC3339, p.PeriodEnd)
+		if err != nil {
+			continue
+		}
+		intervals = append(intervals, priceInterval{Start: start, End: end, Value: p.Value})
+	}
+	return prices.PriceUnit, intervals, nil
+}
+
+// fetchPriceIntervals loads dynamic prices for every local day in [from, to)
+func fetchPriceIntervals(from, to time.Time) (string, []priceInterval, error) {
+	var unit string
+	var all []priceInterval
+	seen := make(map[time.Time]bool)
+
+	for day := startOfDay(from); day.Before(to); day = day.AddDate(0, 0, 1) {
+		data, err := apiClient.GetElectricityPrice(day.Format("2006-01-02"))
+		if err != nil {
+			return "", nil, err
+		}
+		u, intervals, err := parsePriceIntervals(data)
+		if err != nil {
+			return "", nil, err
+		}
+		if unit == "" {
+			unit = u
+		}
+		for _, p := range intervals {
+			if !seen[p.Start] {
+				seen[p.Start] = true
+				all = append(all, p)
+			}
+		}
+	}
+
+	sort.Slice(all, func(i, j int) bool { return all[i].Start.Before(all[j].Start) })
+	return unit, all, nil
+}
+
+// loadPriceSchedule builds a price schedule for [from, to) using the price
+// type configured on Homey (fixed, dynamic or disabled).
+func loadPriceSchedule(from, to time.Time) (*priceSchedule, error) {
+	data, err := apiClient.GetElectricityPriceType()
+	if err != nil {
+		return nil, err
+	}
+	var priceType string
+	if err := json.Unmarshal(data, &priceType); err != nil {
+		return nil, fmt.Errorf("failed to parse price type: %w", err)
+	}
+
+	schedule := &priceSchedule{Type: priceType}
+	switch priceType {
+	case "fixed":
+		fixedData, err := apiClient.GetElectricityPriceFixed()
+		if err != nil {
+			return nil, err
+		}
+		var fixed struct {
+			Value struct {
+				Costs struct {
+					UserFixedBase struct {
+						Value float64 `json:"value"`
+					} `json:"user_fixed_base"`
+				} `json:"costs"`
+			} `json:"value"`
+		}
+		if err := json.Unmarshal(fixedData, &fixed); err != nil {
+			return nil, fmt.Errorf("failed to parse fixed price: %w", err)
+		}
+		schedule.Fixed = fixed.Value.Costs.UserFixedBase.Value
+		schedule.Unit = energyCurrency() + "/kWh"
+	case "dynamic":
+		unit, intervals, err := fetchPriceIntervals(from, to)
+		if err != nil {
+			return nil, err
+		}
+		schedule.Unit = unit
+		schedule.Intervals = intervals
+	}
+	return schedule, nil
+}
+
+// energyCurrency returns the configured energy currency, or an empty string
+func energyCurrency() string {
+	data, err := apiClient.GetEnergyCurrency()
+	if err != nil {
+		return ""
+	}
+	var currency string
+	json.Unmarshal(data, &currency)
+	return currency
+}
+
+// insightPoint is one value from an insight log
+type insightPoint struct {
+	T time.Time
+	V float64
+}
+
+// parseInsightEntries accepts both a bare entry array and an object with a
+// "values" array, skipping entries without a numeric value.
+func parseInsightEntries(data json.RawMessage) ([]insightPoint, error) {
+	type entry struct {
+		T time.Time `json:"t"`
+		V *float64  `json:"v"`
+	}
+	var entries []entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		var wrapped struct {
+			Values []entry `json:"values"`
+		}
+		if err2 := json.Unmarshal(data, &wrapped); err2 != nil {
+			return nil, fmt.Errorf("failed to parse entries: %w", err)
+		}
+		entries = wrapped.Values
+	}
+
+	points := make([]insightPoint, 0, len(entries))
+	for _, e := range entries {
+		if e.V != nil {
+			points = append(points, insightPoint{T: e.T, V: *e.V})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].T.Before(points[j].T) })
+	return points, nil
+}
+
+// insightResolutionFor picks the smallest insights resolution covering from..now
+func insightResolutionFor(from, now time.Time) string {
+	age := now.Sub(from)
+	switch {
+	case age <= 24*time.Hour:
+		return "last24Hours"
+	case age <= 7*24*time.Hour:
+		return "lastWeek"
+	case age <= 31*24*time.Hour:
+		return "lastMonth"
+	case age <= 366*24*time.Hour:
+		return "lastYear"
+	default:
+		return "last2Years"
+	}
+}
+
+// deviceInsightLogs maps device IDs to their insight logs for a capability
+// (e.g. "measure_power" or "meter_power").
+func deviceInsightLogs(logs []InsightLog, capability string) map[string]InsightLog {
+	result := make(map[string]InsightLog)
+	for _, l := range logs {
+		if !strings.HasPrefix(l.OwnerURI, "homey:device:") {
+			continue
+		}
+		if l.ID != capability && !strings.HasSuffix(l.ID, ":"+capability) {
+			continue
+		}
+		result[strings.TrimPrefix(l.OwnerURI, "homey:device:")] = l
+	}
+	return result
+}
+
+// fetchInsightLogs loads the list of all insight logs
+func fetchInsightLogs() ([]InsightLog, error) {
+	data, err := apiClient.GetInsights()
+	if err != nil {
+		return nil, err
+	}
+	var logs []InsightLog
+	if err := json.Unmarshal(data, &logs); err != nil {
+		return nil, fmt.Errorf("failed to parse insights: %w", err)
+	}
+	return logs, nil
+}
+
+// spreadEnergy distributes kWh consumed evenly over [start, end) into
+// hourly buckets, clipped to [from, to).
+func spreadEnergy(buckets map[time.Time]float64, start, end time.Time, kwh float64, from, to time.Time) {
+	total := end.Sub(start)
+	if total <= 0 {
+		return
+	}
+	for t := start; t.Before(end); {
+		next := t.Truncate(time.Hour).Add(time.Hour)
+		if next.After(end) {
+			next = end
+		}
+		segStart, segEnd := t, next
+		if segStart.Before(from) {
+			segStart = from
+		}
+		if segEnd.After(to) {
+			segEnd = to
+		}
+		if segEnd.After(segStart) {
+			buckets[t.Truncate(time.Hour)] += kwh * float64(segEnd.Sub(segStart)) / float64(total)
+		}
+		t = next
+	}
+}
+
+// maxInsightGap is the longest gap between two power samples that is still
+// treated as continuous consumption.
+const maxInsightGap = 6 * time.Hour
+
+// hourlyEnergyFromPower integrates a power series (W) into hourly kWh
+func hourlyEnergyFromPower(points []insightPoint, from, to time.Time) map[time.Time]float64 {
+	buckets := make(map[time.Time]float64)
+	for i := 0; i+1 < len(points); i++ {
+		a, b := points[i], points[i+1]
+		dt := b.T.Sub(a.T)
+		if dt <= 0 || dt > maxInsightGap || a.V <= 0 {
+			continue
+		}
+		spreadEnergy(buckets, a.T, b.T, a.V*dt.Hours()/1000, from, to)
+	}
+	return buckets
+}
+
+// hourlyEnergyFromMeter converts a cumulative meter series (kWh) into hourly kWh
+func hourlyEnergyFromMeter(points []insightPoint, from, to time.Time) map[time.Time]float64 {
+	buckets := make(map[time.Time]float64)
+	for i := 0; i+1 < len(points); i++ {
+		a, b := points[i], points[i+1]
+		delta := b.V - a.V
+		if delta <= 0 || !b.T.After(a.T) {
+			// Meter reset or no consumption
+			continue
+		}
+		spreadEnergy(buckets, a.T, b.T, delta, from, to)
+	}
+	return buckets
+}
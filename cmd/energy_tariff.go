@@ -0,0 +1,431 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/rodaine/table"
+	"github.com/spf13/cobra"
+
+	"github.com/fishfisher/homeyctl/internal/config"
+)
+
+const tariffExample = `# Add to config.toml (homeyctl config show prints its location).
+# Amounts are per kWh excluding VAT unless noted.
+
+[tariff]
+vat = 0.25                 # 25% VAT
+spot_includes_vat = false  # Set true if Homey's prices already include VAT
+markup = 0.02              # Supplier markup
+energy_tax = 0.0713        # Consumption tax (elavgift)
+subsidy_threshold = 0.75   # State subsidy applies above this spot price
+subsidy_rate = 0.9         # Share of the spot price above the threshold covered
+
+# Time-of-use grid fees; the first matching entry wins
+[[tariff.grid_fees]]
+hours = "06-22"
+days = "weekdays"
+price = 0.3975
+
+[[tariff.grid_fees]]
+price = 0.2975             # Nights and weekends
+
+# Capacity tiers by average of the 3 highest daily peaks in the month
+[[tariff.capacity_tiers]]
+max_kw = 2
+monthly = 125
+
+[[tariff.capacity_tiers]]
+max_kw = 5
+monthly = 206
+
+[[tariff.capacity_tiers]]
+max_kw = 10
+monthly = 350
+`
+
+// tariffBreakdown is the effective price for one interval
+type tariffBreakdown struct {
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Spot    float64   `json:"spot"`
+	Markup  float64   `json:"markup"`
+	Subsidy float64   `json:"subsidy"`
+	Grid    float64   `json:"grid"`
+	Tax     float64   `json:"tax"`
+	VAT     float64   `json:"vat"`
+	Total   float64   `json:"total"`
+}
+
+// gridFeeAt returns the first grid fee matching the local time of t
+func gridFeeAt(fees []config.GridFee, t time.Time) float64 {
+	t = t.Local()
+	weekend := t.Weekday() == time.Saturday || t.Weekday() == time.Sunday
+	for _, f := range fees {
+		switch f.Days {
+		case "weekdays":
+			if weekend {
+				continue
+			}
+		case "weekends":
+			if !weekend {
+				continue
+			}
+		}
+		if f.Hours != "" {
+			start, end, err := parseHourRange(f.Hours)
+			if err != nil || !inHourRange(t, start, end) {
+				continue
+			}
+		}
+		return f.Price
+	}
+	return 0
+}
+
+// effectiveTariff applies markup, subsidy, grid fees, tax, and VAT to a spot price
+func effectiveTariff(t config.TariffConfig, spot float64, at time.Time) tariffBreakdown {
+	if t.SpotIncludesVAT && t.VAT > 0 {
+		spot = spot / (1 + t.VAT)
+	}
+
+	b := tariffBreakdown{
+		Spot:   spot,
+		Markup: t.Markup,
+		Grid:   gridFeeAt(t.GridFees, at),
+		Tax:    t.EnergyTax,
+	}
+	if t.SubsidyRate > 0 && spot > t.SubsidyThreshold {
+		b.Subsidy = (spot - t.SubsidyThreshold) * t.SubsidyRate
+	}
+
+	net := b.Spot + b.Markup - b.Subsidy + b.Grid + b.Tax
+	b.VAT = net * t.VAT
+	b.Total = net + b.VAT
+	return b
+}
+
+// capacityPeak is the highest hourly consumption on one day
+type capacityPeak struct {
+	Day  string    `json:"day"`
+	Hour time.Time `json:"hour"`
+	KW   float64   `json:"kW"`
+}
+
+// CapacityReport is the output of 'energy capacity'
+type CapacityReport struct {
+	Month     string                `json:"month"`
+	Meter     string                `json:"meter"`
+	Peaks     []capacityPeak        `json:"peaks"`
+	AverageKW float64               `json:"averageKW"`
+	Tier      *config.CapacityTier  `json:"tier,omitempty"`
+	NextTier  *config.CapacityTier  `json:"nextTier,omitempty"`
+	Tiers     []config.CapacityTier `json:"tiers,omitempty"`
+	Headroom  *float64              `json:"headroomKW,omitempty"`
+}
+
+// topDailyPeaks returns the highest hour of each day, keeping the n highest days
+func topDailyPeaks(hours map[time.Time]float64, n int) []capacityPeak {
+	byDay := make(map[string]capacityPeak)
+	for hour, kwh := range hours {
+		day := hour.Local().Format("2006-01-02")
+		if p, ok := byDay[day]; !ok || kwh > p.KW {
+			byDay[day] = capacityPeak{Day: day, Hour: hour, KW: kwh}
+		}
+	}
+
+	peaks := make([]capacityPeak, 0, len(byDay))
+	for _, p := range byDay {
+		peaks = append(peaks, p)
+	}
+	sort.Slice(peaks, func(i, j int) bool {
+		if peaks[i].KW != peaks[j].KW {
+			return peaks[i].KW > peaks[j].KW
+		}
+		return peaks[i].Day < peaks[j].Day
+	})
+	if len(peaks) > n {
+		peaks = peaks[:n]
+	}
+	return peaks
+}
+
+// buildCapacityReport places the average of the top 3 daily peaks in a tier
+func buildCapacityReport(hours map[time.Time]float64, tiers []config.CapacityTier) CapacityReport {
+	report := CapacityReport{Peaks: topDailyPeaks(hours, 3)}
+	for _, p := range report.Peaks {
+		report.AverageKW += p.KW
+	}
+	if len(report.Peaks) > 0 {
+		report.AverageKW /= float64(len(report.Peaks))
+	}
+
+	sorted := append([]config.CapacityTier(nil), tiers...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].MaxKW < sorted[j].MaxKW })
+	report.Tiers = sorted
+	for i := range sorted {
+		if report.AverageKW <= sorted[i].MaxKW {
+			report.Tier = &sorted[i]
+			headroom := sorted[i].MaxKW - report.AverageKW
+			report.Headroom = &headroom
+			if i+1 < len(sorted) {
+				report.NextTier = &sorted[i+1]
+			}
+			break
+		}
+	}
+	if report.Tier == nil && len(sorted) > 0 {
+		report.Tier = &sorted[len(sorted)-1]
+	}
+	return report
+}
+
+// findMeterDevice returns the named device, or the first device Homey
+// marks as a cumulative (whole-home) energy meter.
+func findMeterDevice(nameOrID string) (*Device, error) {
+	if nameOrID != "" {
+		return findDevice(nameOrID)
+	}
+
+	data, err := apiClient.GetDevices()
+	if err != nil {
+		return nil, err
+	}
+	var devices map[string]json.RawMessage
+	if err := json.Unmarshal(data, &devices); err != nil {
+		return nil, fmt.Errorf("failed to parse devices: %w", err)
+	}
+
+	var candidates []Device
+	for _, raw := range devices {
+		var d struct {
+			Device
+			EnergyObj struct {
+				Cumulative bool `json:"cumulative"`
+			} `json:"energyObj"`
+		}
+		if err := json.Unmarshal(raw, &d); err != nil {
+			continue
+		}
+		if d.EnergyObj.Cumulative {
+			candidates = append(candidates, d.Device)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no cumulative energy meter found, use --meter to select a device")
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Name < candidates[j].Name })
+	return &candidates[0], nil
+}
+
+var energyTariffCmd = &cobra.Command{
+	Use:   "tariff",
+	Short: "Show effective prices with grid fees and taxes",
+	Long: `Combine electricity prices with a local tariff model.
+
+Homey only knows the spot or fixed price. The [tariff] section in
+config.toml adds time-of-use grid fees, consumption tax, supplier
+markup, state subsidy, and VAT on top.
+
+Run 'homeyctl energy tariff example' to see a sample configuration.`,
+}
+
+var energyTariffShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the effective price curve",
+	Long: `Show the effective price for each interval of a day.
+
+Examples:
+  homeyctl energy tariff show
+  homeyctl energy tariff show --date 2025-01-10
+  homeyctl energy tariff show --json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dateStr, _ := cmd.Flags().GetString("date")
+
+		day := startOfDay(time.Now())
+		if dateStr != "" {
+			t, err := time.ParseInLocation("2006-01-02", dateStr, time.Local)
+			if err != nil {
+				return fmt.Errorf("invalid date: %s (use YYYY-MM-DD)", dateStr)
+			}
+			day = t
+		}
+
+		prices, err := loadPriceSchedule(day, day.AddDate(0, 0, 1))
+		if err != nil {
+			return err
+		}
+
+		var intervals []priceInterval
+		switch prices.Type {
+		case "dynamic":
+			for _, p := range prices.Intervals {
+				if startOfDay(p.Start).Equal(day) {
+					intervals = append(intervals, p)
+				}
+			}
+		case "fixed":
+			for h := day; h.Before(day.AddDate(0, 0, 1)); h = h.Add(time.Hour) {
+				intervals = append(intervals, priceInterval{Start: h, End: h.Add(time.Hour), Value: prices.Fixed})
+			}
+		default:
+			return fmt.Errorf("electricity prices are disabled on Homey (see: homeyctl energy price type)")
+		}
+		if len(intervals) == 0 {
+			return fmt.Errorf("no prices available for %s", day.Format("2006-01-02"))
+		}
+
+		tariff := cfg.Tariff
+		var rows []tariffBreakdown
+		for _, p := range intervals {
+			b := effectiveTariff(tariff, p.Value, p.Start)
+			b.Start, b.End = p.Start, p.End
+			rows = append(rows, b)
+		}
+
+		if isJSON() {
+			out, _ := json.MarshalIndent(rows, "", "  ")
+			fmt.Println(string(out))
+			return nil
+		}
+
+		color.New(color.Bold).Printf("Effective prices for %s (%s, %s)\n\n", day.Format("2006-01-02"), prices.Type, prices.Unit)
+		if tariff.VAT == 0 && len(tariff.GridFees) == 0 && tariff.EnergyTax == 0 {
+			color.Yellow("No [tariff] configured; run 'homeyctl energy tariff example'\n\n")
+		}
+
+		now := time.Now()
+		headerFmt := color.New(color.FgCyan, color.Underline).SprintfFunc()
+		tbl := table.New("Time", "Spot", "Subsidy", "Grid", "Tax", "VAT", "Total")
+		tbl.WithHeaderFormatter(headerFmt)
+		for _, r := range rows {
+			marker := ""
+			if !now.Before(r.Start) && now.Before(r.End) {
+				marker = " <-- now"
+			}
+			tbl.AddRow(
+				fmt.Sprintf("%s-%s", r.Start.Local().Format("15:04"), r.End.Local().Format("15:04")),
+				fmt.Sprintf("%.2f", r.Spot+r.Markup),
+				fmt.Sprintf("%.2f", -r.Subsidy),
+				fmt.Sprintf("%.2f", r.Grid),
+				fmt.Sprintf("%.2f", r.Tax),
+				fmt.Sprintf("%.2f", r.VAT),
+				fmt.Sprintf("%.2f%s", r.Total, marker),
+			)
+		}
+		tbl.Print()
+		return nil
+	},
+}
+
+var energyTariffExampleCmd = &cobra.Command{
+	Use:   "example",
+	Short: "Print an example tariff configuration",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fmt.Print(tariffExample)
+		return nil
+	},
+}
+
+var energyCapacityCmd = &cobra.Command{
+	Use:   "capacity",
+	Short: "Show monthly peaks against capacity tariff tiers",
+	Long: `Show this month's capacity (effect) tariff position.
+
+The capacity tariff is based on the average of the three highest hourly
+peaks, each on a different day. Hourly consumption is read from the
+insights of your whole-home meter (detected automatically, or --meter).
+
+Tiers are configured as [[tariff.capacity_tiers]] in config.toml.
+
+Examples:
+  homeyctl energy capacity
+  homeyctl energy capacity --meter "HAN sensor"
+  homeyctl energy capacity --json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		meterName, _ := cmd.Flags().GetString("meter")
+
+		meter, err := findMeterDevice(meterName)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+		resolution := insightResolutionFor(monthStart, now)
+
+		logs, err := fetchInsightLogs()
+		if err != nil {
+			return err
+		}
+
+		var hours map[time.Time]float64
+		if l, ok := deviceInsightLogs(logs, "meter_power")[meter.ID]; ok {
+			data, err := apiClient.GetInsightEntries(l.OwnerURI, l.ID, resolution)
+			if err != nil {
+				return err
+			}
+			points, err := parseInsightEntries(data)
+			if err != nil {
+				return err
+			}
+			hours = hourlyEnergyFromMeter(points, monthStart, now)
+		} else if l, ok := deviceInsightLogs(logs, "measure_power")[meter.ID]; ok {
+			data, err := apiClient.GetInsightEntries(l.OwnerURI, l.ID, resolution)
+			if err != nil {
+				return err
+			}
+			points, err := parseInsightEntries(data)
+			if err != nil {
+				return err
+			}
+			hours = hourlyEnergyFromPower(points, monthStart, now)
+		} else {
+			return fmt.Errorf("no power insights found for %s", meter.Name)
+		}
+
+		report := buildCapacityReport(hours, cfg.Tariff.CapacityTiers)
+		report.Month = monthStart.Format("2006-01")
+		report.Meter = meter.Name
+
+		if isJSON() {
+			out, _ := json.MarshalIndent(report, "", "  ")
+			fmt.Println(string(out))
+			return nil
+		}
+
+		color.New(color.Bold).Printf("Capacity %s (%s)\n\n", report.Month, report.Meter)
+		headerFmt := color.New(color.FgCyan, color.Underline).SprintfFunc()
+		tbl := table.New("Day", "Hour", "kW")
+		tbl.WithHeaderFormatter(headerFmt)
+		for _, p := range report.Peaks {
+			tbl.AddRow(p.Day, p.Hour.Local().Format("15:04"), fmt.Sprintf("%.2f", p.KW))
+		}
+		tbl.Print()
+
+		fmt.Printf("\nAverage of top %d: %.2f kW\n", len(report.Peaks), report.AverageKW)
+		if report.Tier == nil {
+			color.Yellow("No capacity tiers configured; run 'homeyctl energy tariff example'\n")
+			return nil
+		}
+		fmt.Printf("Current tier:      up to %.0f kW (%.0f/month)\n", report.Tier.MaxKW, report.Tier.Monthly)
+		if report.Headroom != nil && report.NextTier != nil {
+			fmt.Printf("Next tier:         above %.0f kW (%.0f/month), %.2f kW headroom\n",
+				report.Tier.MaxKW, report.NextTier.Monthly, *report.Headroom)
+		}
+		return nil
+	},
+}
+
+func init() {
+	energyCmd.AddCommand(energyTariffCmd)
+	energyTariffCmd.AddCommand(energyTariffShowCmd)
+	energyTariffCmd.AddCommand(energyTariffExampleCmd)
+	energyTariffShowCmd.Flags().String("date", "", "Date to show (YYYY-MM-DD, default: today)")
+
+	energyCmd.AddCommand(energyCapacityCmd)
+	energyCapacityCmd.Flags().String("meter", "", "Whole-home meter device (default: auto-detect)")
+}
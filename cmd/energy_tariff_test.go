@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/fishfisher/homeyctl/internal/config"
+)
+
+func TestEnergyTariffCommands_Exist(t *testing.T) {
+	for _, path := range [][]string{{"tariff", "show"}, {"tariff", "example"}, {"capacity"}} {
+		if _, _, err := energyCmd.Find(path); err != nil {
+			t.Errorf("energy %v command not found: %v", path, err)
+		}
+	}
+}
+
+func TestGridFeeAt(t *testing.T) {
+	fees := []config.GridFee{
+		{Hours: "06-22", Days: "weekdays", Price: 0.4},
+		{Price: 0.3},
+	}
+
+	friday := time.Date(2025, 1, 10, 12, 0, 0, 0, time.Local)
+	if got := gridFeeAt(fees, friday); got != 0.4 {
+		t.Errorf("weekday day: expected 0.4, got %v", got)
+	}
+	if got := gridFeeAt(fees, friday.Add(11*time.Hour)); got != 0.3 {
+		t.Errorf("weekday night: expected 0.3, got %v", got)
+	}
+	saturday := friday.AddDate(0, 0, 1)
+	if got := gridFeeAt(fees, saturday); got != 0.3 {
+		t.Errorf("weekend: expected 0.3, got %v", got)
+	}
+	if got := gridFeeAt(nil, friday); got != 0 {
+		t.Errorf("no fees: expected 0, got %v", got)
+	}
+}
+
+func TestEffectiveTariff(t *testing.T) {
+	at := time.Date(2025, 1, 10, 12, 0, 0, 0, time.Local)
+	tariff := config.TariffConfig{
+		VAT:              0.25,
+		Markup:           0.1,
+		EnergyTax:        0.2,
+		SubsidyThreshold: 1,
+		SubsidyRate:      0.5,
+		GridFees:         []config.GridFee{{Price: 0.3}},
+	}
+
+	b := effectiveTariff(tariff, 2, at)
+	// net = 2 + 0.1 - 0.5 + 0.3 + 0.2 = 2.1, total = 2.1 * 1.25
+	if !approx(b.Subsidy, 0.5) {
+		t.Errorf("expected subsidy 0.5, got %v", b.Subsidy)
+	}
+	if !approx(b.Total, 2.625) {
+		t.Errorf("expected total 2.625, got %v", b.Total)
+	}
+
+	b = effectiveTariff(tariff, 0.5, at)
+	if b.Subsidy != 0 {
+		t.Errorf("expected no subsidy below threshold, got %v", b.Subsidy)
+	}
+
+	tariff.SpotIncludesVAT = true
+	b = effectiveTariff(tariff, 2.5, at)
+	if !approx(b.Spot, 2) {
+		t.Errorf("expected VAT removed from spot, got %v", b.Spot)
+	}
+	if !approx(b.Total, 2.625) {
+		t.Errorf("expected total 2.625 with VAT-inclusive spot, got %v", b.Total)
+	}
+
+	if got := effectiveTariff(config.TariffConfig{}, 1.5, at); got.Total != 1.5 {
+		t.Errorf("empty tariff should pass spot through, got %v", got.Total)
+	}
+}
+
+func TestBuildCapacityReport(t *testing.T) {
+	day := func(d, h int) time.Time { return time.Date(2025, 1, d, h, 0, 0, 0, time.Local) }
+	hours := map[time.Time]float64{
+		day(1, 8): 3, day(1, 18): 6, // day 1 peak 6
+		day(2, 7):  4, // day 2 peak 4
+		day(3, 19): 5, // day 3 peak 5
+		day(4, 9):  1, // day 4 peak 1 (not in top 3)
+	}
+	tiers := []config.CapacityTier{
+		{MaxKW: 10, Monthly: 350},
+		{MaxKW: 2, Monthly: 125},
+		{MaxKW: 5, Monthly: 206},
+	}
+
+	r := buildCapacityReport(hours, tiers)
+	if len(r.Peaks) != 3 {
+		t.Fatalf("expected 3 peaks, got %d", len(r.Peaks))
+	}
+	if r.Peaks[0].Day != "2025-01-01" || r.Peaks[0].KW != 6 {
+		t.Errorf("expected highest peak on 2025-01-01 at 6 kW, got %+v", r.Peaks[0])
+	}
+	if !approx(r.AverageKW, 5) {
+		t.Errorf("expected average 5, got %v", r.AverageKW)
+	}
+	if r.Tier == nil || r.Tier.MaxKW != 5 {
+		t.Fatalf("expected 5 kW tier, got %+v", r.Tier)
+	}
+	if r.NextTier == nil || r.NextTier.MaxKW != 10 {
+		t.Errorf("expected next tier 10 kW, got %+v", r.NextTier)
+	}
+	if r.Headroom == nil || !approx(*r.Headroom, 0) {
+		t.Errorf("expected zero headroom, got %v", r.Headroom)
+	}
+	if out, _ := json.Marshal(r.Tier); string(out) != `{"max_kw":5,"monthly":206}` {
+		t.Errorf("unexpected tier JSON: %s", out)
+	}
+
+	r = buildCapacityReport(map[time.Time]float64{day(1, 8): 20}, tiers)
+	if r.Tier == nil || r.Tier.MaxKW != 10 || r.NextTier != nil {
+		t.Errorf("expected top tier when above all thresholds, got %+v", r.Tier)
+	}
+}
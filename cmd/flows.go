@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/fatih/color"
+	"github.com/fishfisher/homeyctl/internal/config"
 	"github.com/rodaine/table"
 	"github.com/spf13/cobra"
 )
@@ -220,6 +221,21 @@ var flowsCreateCmd = &cobra.Command{
 
 Use --advanced flag to create an advanced flow.
 
+Use --dsl to write the flow in the flow DSL instead of JSON. Devices and
+users are referenced by name, and advanced flows are laid out automatically.
+Files ending in .yaml or .yml use the YAML form.
+
+  flow "Welcome home"
+  when presence.user_enter(user: "Anna")
+  if "Hall sensor".measure_temperature < 20
+  and not "Hall heater".on
+  then "Hall heater".on
+  then "Hall heater".target_temperature_set(target_temperature: 22)
+
+Add a line with 'advanced' to create an advanced flow, 'or' to start a new
+condition group, and 'else' for actions when conditions fail. Use
+'homeyctl flows decompile <flow>' to see an existing flow in the DSL.
+
 DISCOVERING IDs:
   - Device IDs:     homeyctl devices list
   - User IDs:       homeyctl users list
@@ -274,10 +290,12 @@ DROPTOKENS (for logic conditions):
 Examples:
   homeyctl flows create flow.json
   homeyctl flows create --advanced advanced-flow.json
+  homeyctl flows create --dsl welcome.flow
   cat flow.json | homeyctl flows create -`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		advanced, _ := cmd.Flags().GetBool("advanced")
+		dsl, _ := cmd.Flags().GetBool("dsl")
 
 		var data []byte
 		var err error
@@ -292,7 +310,21 @@ Examples:
 		}
 
 		var flow map[string]interface{}
-		if err := json.Unmarshal(data, &flow); err != nil {
+		if dsl {
+			f, err := readFlowDSL(args[0], data)
+			if err != nil {
+				return err
+			}
+			advanced = advanced || f.Advanced
+			f.Advanced = advanced
+			resolver, err := newFlowNameResolver()
+			if err != nil {
+				return err
+			}
+			if flow, err = compileFlowDSL(f, resolver); err != nil {
+				return err
+			}
+		} else if err := json.Unmarshal(data, &flow); err != nil {
 			return fmt.Errorf("invalid JSON: %w", err)
 		}
 
@@ -404,8 +436,9 @@ func validateFlow(flow map[string]interface{}, advanced bool) error {
 
 // validateAdvancedFlowUpdate checks that cards in an advanced flow update
 // have the required fields to avoid breaking the flow canvas.
-// Homey merges at the card-ID level, so sending a card with only "args"
-// will wipe id, type, ownerUri, x, y — leaving a broken flow.
+// Homey replaces the cards map as a whole (see client.MergeUpdate), so
+// sending a card with only "args" will wipe id, type, ownerUri, x, y —
+// leaving a broken flow — and cards left out are deleted.
 func validateAdvancedFlowUpdate(flow map[string]interface{}) error {
 	cards, ok := flow["cards"].(map[string]interface{})
 	if !ok {
@@ -420,8 +453,8 @@ func validateAdvancedFlowUpdate(flow map[string]interface{}) error {
 
 		cardType, _ := card["type"].(string)
 
-		// Start cards only need "type"
-		if cardType == "start" {
+		// Start, note, delay and any/all cards only need "type"
+		if cardType != "" && cardType != "trigger" && cardType != "condition" && cardType != "action" {
 			continue
 		}
 
@@ -439,7 +472,7 @@ func validateAdvancedFlowUpdate(flow map[string]interface{}) error {
 
 		if len(missing) > 0 {
 			return fmt.Errorf("validation error: card %q is missing required fields: %s\n"+
-				"Homey replaces entire cards during merge updates. Include all fields (id, type, ownerUri, x, y)\n"+
+				"Homey replaces all cards on update. Include every card with all fields (id, type, ownerUri, x, y)\n"+
 				"or use --backup to save the current flow first, then provide the complete card object.\n"+
 				"Tip: run 'homeyctl flows get <flow>' to see the full card structure",
 				cardID, strings.Join(missing, ", "))
@@ -479,12 +512,12 @@ func normalizeSimpleFlow(flow map[string]interface{}) {
 
 // backupFlow saves the current flow state to a JSON file and returns the path.
 func backupFlow(name string, rawData json.RawMessage) (string, error) {
-	configDir, err := os.UserConfigDir()
+	configDir, err := config.Dir()
 	if err != nil {
 		return "", fmt.Errorf("failed to find config dir: %w", err)
 	}
 
-	backupDir := filepath.Join(configDir, "homeyctl", "backups")
+	backupDir := filepath.Join(configDir, "backups")
 	if err := os.MkdirAll(backupDir, 0o755); err != nil {
 		return "", fmt.Errorf("failed to create backup dir: %w", err)
 	}
@@ -518,6 +551,9 @@ IMPORTANT: This does a partial/merge update - only fields you include will be
 changed. Fields you omit keep their existing values. To remove conditions or
 actions, explicitly set them to an empty array: "conditions": []
 
+Fields are replaced whole: "cards" in an advanced flow update must hold
+every card the flow keeps, and cards left out are deleted.
+
 Use --backup to save the current flow state before applying changes.
 Backups are stored in ~/Library/Application Support/homeyctl/backups/.
 
@@ -586,6 +622,7 @@ Examples:
 			if err := validateAdvancedFlowUpdate(flow); err != nil {
 				return err
 			}
+			recordFlowHistory(f, "update")
 			if _, err := apiClient.UpdateAdvancedFlow(f.ID, flow); err != nil {
 				return err
 			}
@@ -595,6 +632,7 @@ Examples:
 				return err
 			}
 			normalizeSimpleFlow(flow)
+			recordFlowHistory(f, "update")
 			if _, err := apiClient.UpdateFlow(f.ID, flow); err != nil {
 				return err
 			}
@@ -607,13 +645,18 @@ Examples:
 var flowsDeleteCmd = &cobra.Command{
 	Use:   "delete <name-or-id>",
 	Short: "Delete a flow",
-	Args:  cobra.ExactArgs(1),
+	Long: `Delete a flow.
+
+The flow is recorded in its history first, so it can be restored with
+flows rollback.`,
+	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		f, err := findFlow(args[0])
 		if err != nil {
 			return err
 		}
 
+		recordFlowHistory(f, "delete")
 		if f.Advanced {
 			if err := apiClient.DeleteAdvancedFlow(f.ID); err != nil {
 				return err
@@ -795,6 +838,7 @@ func init() {
 	flowsUpdateCmd.Flags().Bool("backup", false, "Save current flow state before updating")
 	flowsUpdateCmd.Flags().String("data", "", "Inline JSON data for the update")
 	flowsCreateCmd.Flags().Bool("advanced", false, "Create an advanced flow")
+	flowsCreateCmd.Flags().Bool("dsl", false, "Read the flow in the flow DSL instead of JSON")
 	flowsCardsCmd.Flags().String("type", "action", "Card type: trigger, condition, action")
 	flowsCardsCmd.Flags().String("filter", "", "Filter cards by name or ID")
 
@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Advanced flow cards link to each other through these output fields
+var advancedCardOutputs = []string{"outputSuccess", "outputTrue", "outputFalse", "outputError"}
+
+// Canvas spacing used when positioning generated cards
+const (
+	advancedCardColumnWidth = 420
+	advancedCardRowHeight   = 160
+)
+
+// newCardID returns a random UUID (v4) for a new advanced flow card
+func newCardID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// cardOwnerURI derives the owner URI from a card ID by dropping the last
+// segment, e.g. homey:device:<id>:on -> homey:device:<id>
+func cardOwnerURI(cardID string) string {
+	if i := strings.LastIndex(cardID, ":"); i != -1 {
+		return cardID[:i]
+	}
+	return cardID
+}
+
+// newAdvancedCard builds a card with the fields Homey requires on the canvas
+func newAdvancedCard(cardType, cardID string, args map[string]interface{}) map[string]interface{} {
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	return map[string]interface{}{
+		"type":     cardType,
+		"id":       cardID,
+		"ownerUri": cardOwnerURI(cardID),
+		"args":     args,
+		"x":        0,
+		"y":        0,
+	}
+}
+
+// linkCards appends a link from one card's output to another card
+func linkCards(from map[string]interface{}, output, toID string) {
+	links, _ := from[output].([]interface{})
+	from[output] = append(links, toID)
+}
+
+// cardLinks returns the IDs a card links to through any of its outputs
+func cardLinks(card map[string]interface{}) []string {
+	var ids []string
+	for _, output := range advancedCardOutputs {
+		switch links := card[output].(type) {
+		case []interface{}:
+			for _, l := range links {
+				if id, ok := l.(string); ok {
+					ids = append(ids, id)
+				}
+			}
+		case []string:
+			ids = append(ids, links...)
+		}
+	}
+	return ids
+}
+
+// layoutAdvancedFlowCards positions cards in columns by their distance from
+// the cards that start the flow. Cards in a column keep their relative
+// vertical order, falling back to card ID for new cards.
+func layoutAdvancedFlowCards(cards map[string]interface{}) {
+	incoming := make(map[string]int)
+	for _, raw := range cards {
+		card, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, id := range cardLinks(card) {
+			incoming[id]++
+		}
+	}
+
+	// Longest-path layering; cycles are broken by the visit bound
+	layer := make(map[string]int)
+	var queue []string
+	for id := range cards {
+		if incoming[id] == 0 {
+			layer[id] = 0
+			queue = append(queue, id)
+		}
+	}
+	sort.Strings(queue)
+	for steps := 0; len(queue) > 0 && steps < len(cards)*len(cards)+1; steps++ {
+		id := queue[0]
+		queue = queue[1:]
+		card, _ := cards[id].(map[string]interface{})
+		for _, next := range cardLinks(card) {
+			if _, ok := cards[next]; !ok {
+				continue
+			}
+			if l, seen := layer[next]; !seen || l < layer[id]+1 {
+				layer[next] = layer[id] + 1
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	columns := make(map[int][]string)
+	for id := range cards {
+		l, ok := layer[id]
+		if !ok {
+			l = 0
+		}
+		columns[l] = append(columns[l], id)
+	}
+
+	for l, ids := range columns {
+		sort.Slice(ids, func(i, j int) bool {
+			yi, yj := cardCoord(cards[ids[i]], "y"), cardCoord(cards[ids[j]], "y")
+			if yi != yj {
+				return yi < yj
+			}
+			return ids[i] < ids[j]
+		})
+		for row, id := range ids {
+			if card, ok := cards[id].(map[string]interface{}); ok {
+				card["x"] = l * advancedCardColumnWidth
+				card["y"] = row * advancedCardRowHeight
+			}
+		}
+	}
+}
+
+// cardCoord reads a numeric x/y coordinate from a card
+func cardCoord(raw interface{}, key string) float64 {
+	card, ok := raw.(map[string]interface{})
+	if !ok {
+		return 0
+	}
+	switch v := card[key].(type) {
+	case float64:
+		return v
+	case int:
+		return float64(v)
+	}
+	return 0
+}
@@ -0,0 +1,362 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// flowCardArg is an argument in a flow card definition
+type flowCardArg struct {
+	Name        string             `json:"name"`
+	Type        string             `json:"type"` // text, number, range, checkbox, dropdown, autocomplete, ...
+	Title       string             `json:"title"`
+	Placeholder string             `json:"placeholder"`
+	Required    *bool              `json:"required"` // Homey treats a missing value as required
+	Min         *float64           `json:"min"`
+	Max         *float64           `json:"max"`
+	Step        *float64           `json:"step"`
+	Values      []flowCardArgValue `json:"values"` // dropdown choices
+}
+
+// flowCardArgValue is one choice of a dropdown argument
+type flowCardArgValue struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+}
+
+// IsRequired reports whether the card cannot run without the argument
+func (a flowCardArg) IsRequired() bool {
+	return a.Required == nil || *a.Required
+}
+
+// flowCardToken is a value a trigger card passes on to the flow
+type flowCardToken struct {
+	Name    string      `json:"name"`
+	Type    string      `json:"type"`
+	Title   string      `json:"title"`
+	Example interface{} `json:"example"`
+}
+
+// flowCardDef is a flow card as listed by the flow card endpoints
+type flowCardDef struct {
+	ID             string          `json:"id"`
+	URI            string          `json:"uri"`  // Owner, like homey:manager:mobile
+	Type           string          `json:"type"` // trigger, condition or action
+	Title          string          `json:"title"`
+	TitleFormatted string          `json:"titleFormatted"` // Title with [[arg]] placeholders
+	Hint           string          `json:"hint"`
+	Args           []flowCardArg   `json:"args"`
+	Tokens         []flowCardToken `json:"tokens"`
+}
+
+// OwnerURI is the card's owner, derived from the ID when not listed
+func (c flowCardDef) OwnerURI() string {
+	if c.URI != "" {
+		return c.URI
+	}
+	return cardOwnerURI(c.ID)
+}
+
+// Arg returns the named argument, or nil
+func (c flowCardDef) Arg(name string) *flowCardArg {
+	for i := range c.Args {
+		if c.Args[i].Name == name {
+			return &c.Args[i]
+		}
+	}
+	return nil
+}
+
+// fetchFlowCards lists the cards of one type: trigger, condition or action
+func fetchFlowCards(cardType string) ([]flowCardDef, error) {
+	var data json.RawMessage
+	var err error
+	switch cardType {
+	case "trigger":
+		data, err = apiClient.GetFlowTriggers()
+	case "condition":
+		data, err = apiClient.GetFlowConditions()
+	case "action":
+		data, err = apiClient.GetFlowActions()
+	default:
+		return nil, fmt.Errorf("invalid card type: %s (use: trigger, condition, action)", cardType)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cards []flowCardDef
+	if err := json.Unmarshal(data, &cards); err != nil {
+		return nil, fmt.Errorf("failed to parse flow cards: %w", err)
+	}
+	for i := range cards {
+		cards[i].Type = cardType
+	}
+	return cards, nil
+}
+
+var errFlowCardNotFound = errors.New("flow card not found")
+
+// matchFlowCard finds a card by its full ID, or by the last part of the
+// ID when that is unique
+func matchFlowCard(cards []flowCardDef, id string) (*flowCardDef, error) {
+	var matches []*flowCardDef
+	for i, c := range cards {
+		if c.ID == id {
+			return &cards[i], nil
+		}
+		if strings.HasSuffix(c.ID, ":"+id) {
+			matches = append(matches, &cards[i])
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("%w: %s", errFlowCardNotFound, id)
+	case 1:
+		return matches[0], nil
+	}
+	ids := make([]string, len(matches))
+	for i, m := range matches {
+		ids[i] = m.ID
+	}
+	sort.Strings(ids)
+	return nil, fmt.Errorf("flow card %s is ambiguous, use the full ID: %s", id, strings.Join(ids, ", "))
+}
+
+// findFlowCard fetches the cards of a type and finds one by ID
+func findFlowCard(cardType, id string) (*flowCardDef, error) {
+	cards, err := fetchFlowCards(cardType)
+	if err != nil {
+		return nil, err
+	}
+	return matchFlowCard(cards, id)
+}
+
+// parseCardArgs reads --arg name=value pairs
+func parseCardArgs(pairs []string) (map[string]string, error) {
+	args := map[string]string{}
+	for _, pair := range pairs {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid --arg %q (expected name=value)", pair)
+		}
+		args[name] = value
+	}
+	return args, nil
+}
+
+var (
+	cardTimePattern  = regexp.MustCompile(`^([01]\d|2[0-3]):[0-5]\d$`)
+	cardDatePattern  = regexp.MustCompile(`^\d{2}-\d{2}-\d{4}$`)
+	cardColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+)
+
+// convertCardArg turns a command line value into what the argument's type
+// takes. Autocomplete values are looked up on the Homey.
+func convertCardArg(cardType string, card *flowCardDef, arg *flowCardArg, value string) (interface{}, error) {
+	switch arg.Type {
+	case "text", "textarea":
+		return value, nil
+	case "number", "range":
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("argument %s: %q is not a number", arg.Name, value)
+		}
+		if (arg.Min != nil && n < *arg.Min) || (arg.Max != nil && n > *arg.Max) {
+			return nil, fmt.Errorf("argument %s: %v is outside %s", arg.Name, n, formatArgRange(arg))
+		}
+		return n, nil
+	case "checkbox":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("argument %s: %q is not true or false", arg.Name, value)
+		}
+		return b, nil
+	case "dropdown":
+		var ids []string
+		for _, v := range arg.Values {
+			if v.ID == value || strings.EqualFold(v.Label, value) {
+				return v.ID, nil
+			}
+			ids = append(ids, v.ID)
+		}
+		return nil, fmt.Errorf("argument %s: %q is not one of %s", arg.Name, value, strings.Join(ids, ", "))
+	case "time":
+		if !cardTimePattern.MatchString(value) {
+			return nil, fmt.Errorf("argument %s: %q is not a time (HH:MM)", arg.Name, value)
+		}
+		return value, nil
+	case "date":
+		if !cardDatePattern.MatchString(value) {
+			return nil, fmt.Errorf("argument %s: %q is not a date (DD-MM-YYYY)", arg.Name, value)
+		}
+		return value, nil
+	case "color":
+		if !cardColorPattern.MatchString(value) {
+			return nil, fmt.Errorf("argument %s: %q is not a color (#rrggbb)", arg.Name, value)
+		}
+		return value, nil
+	case "device":
+		d, err := findDevice(value)
+		if err != nil {
+			return nil, fmt.Errorf("argument %s: %w", arg.Name, err)
+		}
+		return map[string]interface{}{"id": d.ID, "name": d.Name}, nil
+	case "autocomplete":
+		return resolveAutocomplete(cardType, card, arg.Name, value)
+	}
+	return parseValue(value), nil
+}
+
+func formatArgRange(arg *flowCardArg) string {
+	lo, hi := "-inf", "inf"
+	if arg.Min != nil {
+		lo = strconv.FormatFloat(*arg.Min, 'f', -1, 64)
+	}
+	if arg.Max != nil {
+		hi = strconv.FormatFloat(*arg.Max, 'f', -1, 64)
+	}
+	return lo + ".." + hi
+}
+
+// resolveAutocomplete asks the app for an autocomplete argument's choices
+// and picks the one matching value by ID or name. A search that leaves a
+// single choice picks that one.
+func resolveAutocomplete(cardType string, card *flowCardDef, name, value string) (interface{}, error) {
+	search := func(query string) ([]map[string]interface{}, error) {
+		data, err := apiClient.GetFlowCardAutocomplete("flowcard"+cardType, card.OwnerURI(), card.ID, name, query)
+		if err != nil {
+			return nil, fmt.Errorf("argument %s: %w", name, err)
+		}
+		var items []map[string]interface{}
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil, fmt.Errorf("argument %s: failed to parse autocomplete: %w", name, err)
+		}
+		return items, nil
+	}
+	exact := func(items []map[string]interface{}) map[string]interface{} {
+		for _, item := range items {
+			label, _ := item["name"].(string)
+			if fmt.Sprint(item["id"]) == value || strings.EqualFold(label, value) {
+				return item
+			}
+		}
+		return nil
+	}
+
+	items, err := search(value)
+	if err != nil {
+		return nil, err
+	}
+	if item := exact(items); item != nil {
+		return item, nil
+	}
+	if len(items) == 1 {
+		return items[0], nil
+	}
+	if len(items) > 1 {
+		var names []string
+		for _, item := range items {
+			label, _ := item["name"].(string)
+			names = append(names, label)
+		}
+		return nil, fmt.Errorf("argument %s: %q matches %d choices: %s", name, value, len(items), strings.Join(names, ", "))
+	}
+	// The search is usually by name, so look for an ID in the full list
+	all, err := search("")
+	if err != nil {
+		return nil, err
+	}
+	if item := exact(all); item != nil {
+		return item, nil
+	}
+	return nil, fmt.Errorf("argument %s: no match for %q (see 'homeyctl flows autocomplete %s %s')", name, value, card.ID, name)
+}
+
+// buildCardArgs checks arguments against the card definition and
+// converts the values by argument type
+func buildCardArgs(cardType string, card *flowCardDef, given map[string]string) (map[string]interface{}, error) {
+	args := map[string]interface{}{}
+	for name, value := range given {
+		if card.Arg(name) == nil {
+			var known []string
+			for _, a := range card.Args {
+				known = append(known, a.Name)
+			}
+			if len(known) == 0 {
+				return nil, fmt.Errorf("card %s takes no arguments, got %s", card.ID, name)
+			}
+			return nil, fmt.Errorf("card %s has no argument %s (arguments: %s)", card.ID, name, strings.Join(known, ", "))
+		}
+		v, err := convertCardArg(cardType, card, card.Arg(name), value)
+		if err != nil {
+			return nil, err
+		}
+		args[name] = v
+	}
+
+	var missing []string
+	for _, a := range card.Args {
+		if _, ok := args[a.Name]; !ok && a.IsRequired() {
+			missing = append(missing, a.Name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("card %s is missing required argument(s): %s", card.ID, strings.Join(missing, ", "))
+	}
+	return args, nil
+}
+
+// runActionCard runs an action card with the --arg values of cmd
+func runActionCard(cmd *cobra.Command, id string) error {
+	card, cardArgs, err := runFlowCardArgs(cmd, "action", id)
+	if err != nil {
+		return err
+	}
+
+	result, err := apiClient.RunFlowCardAction(card.OwnerURI(), card.ID, cardArgs)
+	if err != nil {
+		return err
+	}
+
+	if isJSON() {
+		outputJSON(result)
+		return nil
+	}
+	color.Green("Ran card: %s\n", card.ID)
+	return nil
+}
+
+var flowsRunCardCmd = &cobra.Command{
+	Use:   "run-card <card-id>",
+	Short: "Run a flow action card directly",
+	Long: `Run any flow action card without creating a flow.
+
+Arguments are given as --arg name=value and checked against the card
+definition: unknown names and missing required arguments are errors, and
+values are converted by argument type (see 'homeyctl cards run --help').
+
+The card ID can be the full ID from 'homeyctl flows cards', or its last
+part when that is unique.
+
+Examples:
+  homeyctl flows run-card homey:manager:notifications:create_notification --arg text="Hello"
+  homeyctl flows run-card "homey:device:<device-id>:on"
+  homeyctl flows run-card create_notification --arg text="Hello"`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runActionCard(cmd, args[0])
+	},
+}
+
+func init() {
+	flowsCmd.AddCommand(flowsRunCardCmd)
+	flowsRunCardCmd.Flags().StringArray("arg", nil, "Card argument as name=value (repeatable)")
+}
@@ -0,0 +1,500 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/rodaine/table"
+	"github.com/spf13/cobra"
+)
+
+var flowCardTypes = []string{"trigger", "condition", "action"}
+
+// fetchCardCatalog lists the cards of one type, or of all types when
+// cardType is empty
+func fetchCardCatalog(cardType string) ([]flowCardDef, error) {
+	if cardType != "" {
+		return fetchFlowCards(cardType)
+	}
+	var all []flowCardDef
+	for _, t := range flowCardTypes {
+		cards, err := fetchFlowCards(t)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, cards...)
+	}
+	return all, nil
+}
+
+// findCatalogCard finds a card by ID in one type, or in all types when
+// the ID only exists in one of them
+func findCatalogCard(cardType, id string) (*flowCardDef, error) {
+	if cardType != "" {
+		return findFlowCard(cardType, id)
+	}
+	var found []*flowCardDef
+	var types []string
+	for _, t := range flowCardTypes {
+		cards, err := fetchFlowCards(t)
+		if err != nil {
+			return nil, err
+		}
+		card, err := matchFlowCard(cards, id)
+		if errors.Is(err, errFlowCardNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		found = append(found, card)
+		types = append(types, t)
+	}
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("%w: %s", errFlowCardNotFound, id)
+	case 1:
+		return found[0], nil
+	}
+	return nil, fmt.Errorf("flow card %s exists as %s; use --type", id, strings.Join(types, " and "))
+}
+
+// cardOwnerName describes who provides a card: an app, a device or Homey
+func cardOwnerName(card *flowCardDef) string {
+	owner := card.OwnerURI()
+	switch {
+	case strings.HasPrefix(owner, "homey:app:"):
+		id := strings.TrimPrefix(owner, "homey:app:")
+		if data, err := apiClient.GetApp(id); err == nil {
+			var app App
+			if json.Unmarshal(data, &app) == nil && app.Name != "" {
+				return fmt.Sprintf("%s (%s)", app.Name, id)
+			}
+		}
+		return id
+	case strings.HasPrefix(owner, "homey:device:"):
+		id := strings.TrimPrefix(owner, "homey:device:")
+		if d, err := findDevice(id); err == nil {
+			return fmt.Sprintf("device %s (%s)", d.Name, id)
+		}
+		return "device " + id
+	case strings.HasPrefix(owner, "homey:manager:"):
+		return "Homey " + strings.TrimPrefix(owner, "homey:manager:")
+	}
+	return owner
+}
+
+// describeArgConstraint summarises what values an argument takes
+func describeArgConstraint(a flowCardArg) string {
+	switch a.Type {
+	case "number", "range":
+		if a.Min != nil || a.Max != nil {
+			return formatArgRange(&a)
+		}
+	case "dropdown":
+		var ids []string
+		for _, v := range a.Values {
+			if v.Label != "" && v.Label != v.ID {
+				ids = append(ids, fmt.Sprintf("%s (%s)", v.ID, v.Label))
+			} else {
+				ids = append(ids, v.ID)
+			}
+		}
+		return strings.Join(ids, ", ")
+	case "autocomplete":
+		return "see 'flows autocomplete'"
+	case "time":
+		return "HH:MM"
+	case "date":
+		return "DD-MM-YYYY"
+	case "color":
+		return "#rrggbb"
+	}
+	return ""
+}
+
+var flowsCardsDescribeCmd = &cobra.Command{
+	Use:   "describe <card-id>",
+	Short: "Show a flow card's arguments and tokens",
+	Long: `Show what a flow card needs: its owner, title with argument placeholders,
+each argument (name, type, whether it is required, range or choices) and,
+for triggers, the tokens it passes to the flow.
+
+The card ID can be the full ID or its last part when that is unique.
+Without --type, all card types are searched.
+
+Examples:
+  homeyctl flows cards describe homey:manager:logic:lt
+  homeyctl flows cards describe "homey:device:<device-id>:dim" --type action
+  homeyctl flows cards describe user_enter --json`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cardType, _ := cmd.Flags().GetString("type")
+		card, err := findCatalogCard(cardType, args[0])
+		if err != nil {
+			return err
+		}
+
+		if isJSON() {
+			out, _ := json.MarshalIndent(card, "", "  ")
+			fmt.Println(string(out))
+			return nil
+		}
+
+		fmt.Printf("%s (%s)\n", card.Title, card.Type)
+		fmt.Printf("  ID:     %s\n", card.ID)
+		fmt.Printf("  Owner:  %s\n", cardOwnerName(card))
+		if card.TitleFormatted != "" && card.TitleFormatted != card.Title {
+			fmt.Printf("  Reads:  %s\n", card.TitleFormatted)
+		}
+		if card.Hint != "" {
+			fmt.Printf("  Hint:   %s\n", card.Hint)
+		}
+
+		headerFmt := color.New(color.FgCyan, color.Underline).SprintfFunc()
+		fmt.Println()
+		if len(card.Args) == 0 {
+			fmt.Println("No arguments.")
+		} else {
+			tbl := table.New("Argument", "Type", "Required", "Values", "Title")
+			tbl.WithHeaderFormatter(headerFmt)
+			for _, a := range card.Args {
+				required := "yes"
+				if !a.IsRequired() {
+					required = "no"
+				}
+				title := a.Title
+				if title == "" {
+					title = a.Placeholder
+				}
+				tbl.AddRow(a.Name, a.Type, required, describeArgConstraint(a), title)
+			}
+			tbl.Print()
+		}
+
+		if len(card.Tokens) > 0 {
+			fmt.Println()
+			tbl := table.New("Token", "Type", "Title", "Example")
+			tbl.WithHeaderFormatter(headerFmt)
+			for _, tok := range card.Tokens {
+				example := ""
+				if tok.Example != nil {
+					example = fmt.Sprint(tok.Example)
+				}
+				tbl.AddRow(tok.Name, tok.Type, tok.Title, example)
+			}
+			tbl.Print()
+		}
+		return nil
+	},
+}
+
+// cardMatch is a search hit with its rank
+type cardMatch struct {
+	Score int          `json:"score"`
+	Card  *flowCardDef `json:"card"`
+}
+
+// scoreCard ranks a card against a search. Every term must match
+// somewhere; matches in the title count most, then the ID, the owner and
+// argument names. Zero means no match.
+func scoreCard(card *flowCardDef, query string) int {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return 0
+	}
+	id := strings.ToLower(card.ID)
+	title := strings.ToLower(card.Title)
+
+	score := 0
+	switch {
+	case id == query || strings.HasSuffix(id, ":"+query):
+		score += 100
+	case title == query:
+		score += 80
+	case strings.HasPrefix(title, query):
+		score += 40
+	case strings.Contains(title, query):
+		score += 20
+	}
+
+	var argText []string
+	for _, a := range card.Args {
+		argText = append(argText, strings.ToLower(a.Name), strings.ToLower(a.Title))
+	}
+	for _, tok := range card.Tokens {
+		argText = append(argText, strings.ToLower(tok.Name), strings.ToLower(tok.Title))
+	}
+	args := strings.Join(argText, " ")
+	owner := strings.ToLower(card.OwnerURI())
+	hint := strings.ToLower(card.Hint)
+
+	for _, term := range strings.Fields(query) {
+		termScore := 0
+		for _, word := range strings.Fields(title) {
+			if word == term {
+				termScore += 5
+				break
+			}
+		}
+		switch {
+		case strings.Contains(title, term):
+			termScore += 10
+		case strings.Contains(id, term):
+			termScore += 6
+		case strings.Contains(owner, term):
+			termScore += 4
+		case strings.Contains(args, term):
+			termScore += 2
+		case strings.Contains(hint, term):
+			termScore += 1
+		default:
+			return 0
+		}
+		score += termScore
+	}
+	return score
+}
+
+// searchCards ranks cards by a query, best first
+func searchCards(cards []flowCardDef, query string) []cardMatch {
+	var matches []cardMatch
+	for i := range cards {
+		if score := scoreCard(&cards[i], query); score > 0 {
+			matches = append(matches, cardMatch{Score: score, Card: &cards[i]})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].Card.ID < matches[j].Card.ID
+	})
+	return matches
+}
+
+var flowsCardsSearchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Search triggers, conditions and actions",
+	Long: `Search flow cards of all types by title, ID, owner and argument names.
+
+Every word in the query must match. Results are ranked with title matches
+first.
+
+Examples:
+  homeyctl flows cards search "turn on"
+  homeyctl flows cards search temperature --type condition
+  homeyctl flows cards search sonos --limit 5 --json`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cardType, _ := cmd.Flags().GetString("type")
+		limit, _ := cmd.Flags().GetInt("limit")
+
+		cards, err := fetchCardCatalog(cardType)
+		if err != nil {
+			return err
+		}
+		matches := searchCards(cards, strings.Join(args, " "))
+		if limit > 0 && len(matches) > limit {
+			matches = matches[:limit]
+		}
+
+		if isJSON() {
+			out, _ := json.MarshalIndent(matches, "", "  ")
+			fmt.Println(string(out))
+			return nil
+		}
+		if len(matches) == 0 {
+			fmt.Println("No matching flow cards.")
+			return nil
+		}
+
+		headerFmt := color.New(color.FgCyan, color.Underline).SprintfFunc()
+		tbl := table.New("Type", "Title", "ID")
+		tbl.WithHeaderFormatter(headerFmt)
+		for _, m := range matches {
+			tbl.AddRow(m.Card.Type, m.Card.Title, m.Card.ID)
+		}
+		tbl.Print()
+		return nil
+	},
+}
+
+// tokenRefSchema matches a [[token]] reference, which Homey accepts in
+// place of most argument values
+var tokenRefSchema = map[string]interface{}{"type": "string", "pattern": `^\[\[.+\]\]$`}
+
+// argSchema is the JSON Schema for one card argument's value
+func argSchema(a flowCardArg) map[string]interface{} {
+	var s map[string]interface{}
+	switch a.Type {
+	case "text", "textarea":
+		s = map[string]interface{}{"type": "string"}
+	case "number", "range":
+		num := map[string]interface{}{"type": "number"}
+		if a.Min != nil {
+			num["minimum"] = *a.Min
+		}
+		if a.Max != nil {
+			num["maximum"] = *a.Max
+		}
+		s = map[string]interface{}{"anyOf": []interface{}{num, tokenRefSchema}}
+	case "checkbox":
+		s = map[string]interface{}{"anyOf": []interface{}{map[string]interface{}{"type": "boolean"}, tokenRefSchema}}
+	case "dropdown":
+		// Values can be missing from the catalog; any ID is then allowed
+		s = map[string]interface{}{"type": "string"}
+		if len(a.Values) > 0 {
+			var ids []interface{}
+			for _, v := range a.Values {
+				ids = append(ids, v.ID)
+			}
+			s = map[string]interface{}{"enum": ids}
+		}
+	case "autocomplete", "device":
+		s = map[string]interface{}{
+			"type":       "object",
+			"required":   []string{"id"},
+			"properties": map[string]interface{}{"id": map[string]interface{}{}, "name": map[string]interface{}{"type": "string"}},
+		}
+	case "time":
+		s = map[string]interface{}{"type": "string", "pattern": `^([01]\d|2[0-3]):[0-5]\d$`}
+	case "date":
+		s = map[string]interface{}{"type": "string", "pattern": `^\d{2}-\d{2}-\d{4}$`}
+	case "color":
+		s = map[string]interface{}{"type": "string", "pattern": `^#[0-9a-fA-F]{6}$`}
+	default:
+		s = map[string]interface{}{}
+	}
+	if a.Title != "" {
+		s["title"] = a.Title
+	}
+	if a.Placeholder != "" {
+		s["description"] = a.Placeholder
+	}
+	return s
+}
+
+// cardSchema is a JSON Schema for a card as it appears in a flow file:
+// an object with the card ID and its arguments
+func cardSchema(card *flowCardDef) map[string]interface{} {
+	props := map[string]interface{}{}
+	required := []string{}
+	for _, a := range card.Args {
+		props[a.Name] = argSchema(a)
+		if a.IsRequired() {
+			required = append(required, a.Name)
+		}
+	}
+	args := map[string]interface{}{
+		"type":                 "object",
+		"properties":           props,
+		"required":             required,
+		"additionalProperties": false,
+	}
+	top := []string{"id"}
+	if len(required) > 0 {
+		top = append(top, "args")
+	}
+	return map[string]interface{}{
+		"$schema":    "https://json-schema.org/draft/2020-12/schema",
+		"title":      card.Title,
+		"type":       "object",
+		"properties": map[string]interface{}{"id": map[string]interface{}{"const": card.ID}, "args": args},
+		"required":   top,
+	}
+}
+
+// schemaFileName turns a card ID into a file name
+func schemaFileName(id string) string {
+	return strings.NewReplacer(":", "_", "/", "_", "\\", "_").Replace(id) + ".schema.json"
+}
+
+var flowsCardsSchemaCmd = &cobra.Command{
+	Use:   "schema [card-id...]",
+	Short: "Generate JSON Schemas for flow cards",
+	Long: `Generate a JSON Schema for each flow card, describing the card as it
+appears in a flow file: {"id": "<card-id>", "args": {...}}. Editors can use
+them to validate and complete flow JSON.
+
+Without --out, the schema of a single card is printed. With --out, one
+<card-id>.schema.json file per card is written to the directory, for the
+given cards or for all cards (of --type, if set).
+
+Examples:
+  homeyctl flows cards schema homey:manager:logic:lt
+  homeyctl flows cards schema --out schemas/
+  homeyctl flows cards schema --type action --out schemas/actions/`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cardType, _ := cmd.Flags().GetString("type")
+		outDir, _ := cmd.Flags().GetString("out")
+
+		if outDir == "" {
+			if len(args) != 1 {
+				return fmt.Errorf("give one card ID, or use --out to write several")
+			}
+			card, err := findCatalogCard(cardType, args[0])
+			if err != nil {
+				return err
+			}
+			out, _ := json.MarshalIndent(cardSchema(card), "", "  ")
+			fmt.Println(string(out))
+			return nil
+		}
+
+		var cards []*flowCardDef
+		if len(args) > 0 {
+			for _, id := range args {
+				card, err := findCatalogCard(cardType, id)
+				if err != nil {
+					return err
+				}
+				cards = append(cards, card)
+			}
+		} else {
+			all, err := fetchCardCatalog(cardType)
+			if err != nil {
+				return err
+			}
+			for i := range all {
+				cards = append(cards, &all[i])
+			}
+		}
+
+		if err := os.MkdirAll(outDir, 0o755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", outDir, err)
+		}
+		// Device cards exist as both condition and action with one ID
+		written := map[string]bool{}
+		for _, card := range cards {
+			name := schemaFileName(card.ID)
+			if written[name] {
+				name = schemaFileName(card.ID + ":" + card.Type)
+			}
+			written[name] = true
+			out, _ := json.MarshalIndent(cardSchema(card), "", "  ")
+			if err := os.WriteFile(filepath.Join(outDir, name), append(out, '\n'), 0o644); err != nil {
+				return err
+			}
+		}
+		color.Green("Wrote %d schema(s) to %s\n", len(cards), outDir)
+		return nil
+	},
+}
+
+func init() {
+	flowsCardsCmd.AddCommand(flowsCardsDescribeCmd)
+	flowsCardsCmd.AddCommand(flowsCardsSearchCmd)
+	flowsCardsCmd.AddCommand(flowsCardsSchemaCmd)
+	for _, c := range []*cobra.Command{flowsCardsDescribeCmd, flowsCardsSearchCmd, flowsCardsSchemaCmd} {
+		c.Flags().String("type", "", "Card type: trigger, condition, action (default all)")
+	}
+	flowsCardsSearchCmd.Flags().Int("limit", 20, "Maximum number of results (0 for all)")
+	flowsCardsSchemaCmd.Flags().String("out", "", "Write one schema file per card to this directory")
+}
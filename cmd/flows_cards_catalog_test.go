@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fishfisher/homeyctl/internal/fakehomey"
+)
+
+var testCatalog = fakehomey.FlowCards{
+	Trigger: []map[string]interface{}{
+		{"id": "homey:manager:presence:user_enter", "uri": "homey:manager:presence", "title": "Someone came home",
+			"args":   []interface{}{map[string]interface{}{"name": "user", "type": "autocomplete", "title": "User"}},
+			"tokens": []interface{}{map[string]interface{}{"name": "name", "type": "string", "title": "Name", "example": "Ann"}}},
+		{"id": "homey:device:sensor:measure_temperature_changed", "title": "The temperature changed",
+			"tokens": []interface{}{map[string]interface{}{"name": "measure_temperature", "type": "number", "title": "Temperature"}}},
+	},
+	Condition: []map[string]interface{}{
+		{"id": "homey:manager:logic:lt", "uri": "homey:manager:logic", "title": "Is less than", "titleFormatted": "[[value]] is less than [[comparator]]",
+			"args": []interface{}{
+				map[string]interface{}{"name": "value", "type": "number"},
+				map[string]interface{}{"name": "comparator", "type": "number"},
+			}},
+		{"id": "homey:device:lamp:on", "title": "Is turned on"},
+	},
+	Action: []map[string]interface{}{
+		{"id": "homey:device:lamp:on", "title": "Turn on"},
+		{"id": "homey:app:com.sonos:play_favorite", "uri": "homey:app:com.sonos", "title": "Play a favorite",
+			"titleFormatted": "Play [[favorite]] at [[volume]]", "hint": "Starts a Sonos favorite",
+			"args": []interface{}{
+				map[string]interface{}{"name": "favorite", "type": "autocomplete", "title": "Favorite"},
+				map[string]interface{}{"name": "volume", "type": "range", "min": 0, "max": 1, "required": false},
+				map[string]interface{}{"name": "mode", "type": "dropdown", "values": []interface{}{
+					map[string]interface{}{"id": "replace", "label": "Replace queue"},
+					map[string]interface{}{"id": "append", "label": "Add to queue"},
+				}},
+			}},
+	},
+}
+
+func TestScoreAndSearchCards(t *testing.T) {
+	cards := []flowCardDef{
+		{ID: "homey:device:lamp:on", Title: "Turn on", Type: "action"},
+		{ID: "homey:device:lamp:off", Title: "Turn off", Type: "action"},
+		{ID: "homey:device:tv:on", Title: "Turn on the TV", Type: "action"},
+		{ID: "homey:manager:logic:lt", Title: "Is less than", Args: []flowCardArg{{Name: "temperature"}}},
+	}
+	matches := searchCards(cards, "turn on")
+	if len(matches) != 2 || matches[0].Card.ID != "homey:device:lamp:on" || matches[1].Card.ID != "homey:device:tv:on" {
+		t.Errorf("expected exact title first, got %+v", matches)
+	}
+	if got := searchCards(cards, "turn purple"); len(got) != 0 {
+		t.Errorf("expected every term to be required, got %+v", got)
+	}
+	if got := searchCards(cards, "lt"); len(got) == 0 || got[0].Card.ID != "homey:manager:logic:lt" {
+		t.Errorf("expected ID suffix match first, got %+v", got)
+	}
+	if got := searchCards(cards, "temperature"); len(got) != 1 {
+		t.Errorf("expected argument name match, got %+v", got)
+	}
+}
+
+func TestCardSchema(t *testing.T) {
+	min, max := 0.0, 1.0
+	no := false
+	card := &flowCardDef{ID: "homey:app:x:card", Title: "Card", Args: []flowCardArg{
+		{Name: "dim", Type: "range", Min: &min, Max: &max, Title: "Level"},
+		{Name: "mode", Type: "dropdown", Values: []flowCardArgValue{{ID: "a"}, {ID: "b"}}, Required: &no},
+		{Name: "scene", Type: "dropdown", Required: &no},
+	}}
+	out, _ := json.Marshal(cardSchema(card))
+	var s struct {
+		Required   []string `json:"required"`
+		Properties struct {
+			ID   map[string]interface{} `json:"id"`
+			Args struct {
+				Required   []string                          `json:"required"`
+				Properties map[string]map[string]interface{} `json:"properties"`
+			} `json:"args"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(out, &s); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(s.Required, ",") != "id,args" || s.Properties.ID["const"] != card.ID {
+		t.Errorf("unexpected top level: %s", out)
+	}
+	if strings.Join(s.Properties.Args.Required, ",") != "dim" || s.Properties.Args.Properties["dim"]["title"] != "Level" {
+		t.Errorf("unexpected args: %s", out)
+	}
+	if enum := s.Properties.Args.Properties["mode"]["enum"].([]interface{}); len(enum) != 2 {
+		t.Errorf("expected dropdown enum, got %v", enum)
+	}
+	if scene := s.Properties.Args.Properties["scene"]; scene["type"] != "string" || scene["enum"] != nil || strings.Contains(string(out), `"enum":null`) {
+		t.Errorf("expected a dropdown without values to accept any string, got %v", scene)
+	}
+	if !strings.Contains(string(out), `"maximum":1`) {
+		t.Errorf("expected range maximum: %s", out)
+	}
+}
+
+func TestFlowsCardsCatalogCommands(t *testing.T) {
+	useFakeHomey(t, &fakehomey.Seed{
+		FlowCards: testCatalog,
+		Apps:      fakehomey.Objects{"com.sonos": {"id": "com.sonos", "name": "Sonos"}},
+	})
+
+	out, err := runCommand(t, "flows", "cards", "describe", "play_favorite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Play a favorite (action)", "Sonos (com.sonos)", "Play [[favorite]] at [[volume]]", "0..1", "replace (Replace queue)", "see 'flows autocomplete'"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("describe missing %q:\n%s", want, out)
+		}
+	}
+	out, err = runCommand(t, "flows", "cards", "describe", "user_enter")
+	if err != nil || !strings.Contains(out, "Homey presence") || !strings.Contains(out, "Ann") {
+		t.Errorf("expected trigger tokens, got %v:\n%s", err, out)
+	}
+	if _, err := runCommand(t, "flows", "cards", "describe", "homey:device:lamp:on"); err == nil || !strings.Contains(err.Error(), "condition and action") {
+		t.Errorf("expected type ambiguity error, got %v", err)
+	}
+	if out, err := runCommand(t, "flows", "cards", "describe", "homey:device:lamp:on", "--type", "condition"); err != nil || !strings.Contains(out, "Is turned on") {
+		t.Errorf("expected condition card, got %v:\n%s", err, out)
+	}
+
+	out, err = runCommand(t, "flows", "cards", "search", "temperature", "--json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var matches []cardMatch
+	if err := json.Unmarshal([]byte(out), &matches); err != nil || len(matches) != 1 || matches[0].Card.Type != "trigger" {
+		t.Errorf("unexpected search result %v: %s", err, out)
+	}
+
+	dir := t.TempDir()
+	if _, err := runCommand(t, "flows", "cards", "schema", "--out", dir); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.schema.json"))
+	if len(files) != 6 {
+		t.Errorf("expected a schema per card, got %v", files)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "homey_manager_logic_lt.schema.json"))
+	if err != nil || !strings.Contains(string(data), `"const": "homey:manager:logic:lt"`) {
+		t.Errorf("unexpected schema file: %v\n%s", err, data)
+	}
+}
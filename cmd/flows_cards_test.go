@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fishfisher/homeyctl/internal/fakehomey"
+)
+
+var testPushCards = []map[string]interface{}{
+	{"id": "homey:manager:notifications:create_notification", "uri": "homey:manager:notifications", "title": "Create a notification",
+		"args": []interface{}{map[string]interface{}{"name": "text", "type": "text"}}},
+	{"id": "homey:manager:mobile:push_text", "uri": "homey:manager:mobile", "title": "Send a push notification",
+		"args": []interface{}{
+			map[string]interface{}{"name": "user", "type": "autocomplete", "values": []interface{}{
+				map[string]interface{}{"id": "athom-ann", "athomId": "athom-ann", "name": "Ann"},
+				map[string]interface{}{"id": "athom-bob", "athomId": "athom-bob", "name": "Bob"},
+			}},
+			map[string]interface{}{"name": "text", "type": "text"},
+		}},
+	{"id": "homey:manager:mobile:push_text_image", "uri": "homey:manager:mobile", "title": "Send a push notification with an image",
+		"args": []interface{}{
+			map[string]interface{}{"name": "user", "type": "autocomplete", "values": []interface{}{
+				map[string]interface{}{"id": "athom-ann", "athomId": "athom-ann", "name": "Ann"},
+			}},
+			map[string]interface{}{"name": "text", "type": "text"},
+			map[string]interface{}{"name": "image", "type": "image"},
+		}},
+	{"id": "homey:device:lamp:dim", "title": "Dim",
+		"args": []interface{}{
+			map[string]interface{}{"name": "dim", "type": "range"},
+			map[string]interface{}{"name": "duration", "type": "duration", "required": false},
+		}},
+	{"id": "homey:device:heater:dim", "title": "Dim"},
+}
+
+func TestMatchFlowCardAndArgs(t *testing.T) {
+	cards := []flowCardDef{
+		{ID: "homey:device:lamp:dim", Args: []flowCardArg{{Name: "dim"}, {Name: "duration", Required: new(bool)}}},
+		{ID: "homey:device:heater:dim"},
+		{ID: "homey:manager:logic:lt", URI: "homey:manager:logic"},
+	}
+	if c, err := matchFlowCard(cards, "lt"); err != nil || c.OwnerURI() != "homey:manager:logic" {
+		t.Errorf("expected short ID match, got %v, %v", c, err)
+	}
+	if c, err := matchFlowCard(cards, "homey:device:lamp:dim"); err != nil || c.OwnerURI() != "homey:device:lamp" {
+		t.Errorf("expected full ID match with derived owner, got %v, %v", c, err)
+	}
+	if _, err := matchFlowCard(cards, "dim"); err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Errorf("expected ambiguous error, got %v", err)
+	}
+
+	if _, err := parseCardArgs([]string{"novalue"}); err == nil {
+		t.Error("expected error for an arg without =")
+	}
+	given, _ := parseCardArgs([]string{"dim=0.5", "text=a=b"})
+	if given["text"] != "a=b" {
+		t.Errorf("expected value to keep '=', got %q", given["text"])
+	}
+	if _, err := buildCardArgs("action", &cards[0], given); err == nil || !strings.Contains(err.Error(), "no argument text (arguments: dim, duration)") {
+		t.Errorf("expected unknown argument error, got %v", err)
+	}
+	if _, err := buildCardArgs("action", &cards[0], map[string]string{}); err == nil || !strings.Contains(err.Error(), "missing required argument(s): dim") {
+		t.Errorf("expected missing argument error, got %v", err)
+	}
+	args, err := buildCardArgs("action", &cards[0], map[string]string{"dim": "0.5"})
+	if err != nil || args["dim"] != 0.5 {
+		t.Errorf("unexpected args %v, %v", args, err)
+	}
+}
+
+func TestFlowsRunCardAndNotifyPush(t *testing.T) {
+	h := useFakeHomey(t, &fakehomey.Seed{
+		FlowCards: fakehomey.FlowCards{Action: testPushCards},
+		Users: fakehomey.Objects{
+			"u1": {"id": "u1", "athomId": "athom-ann", "name": "Ann"},
+			"u2": {"id": "u2", "athomId": "athom-bob", "name": "Bob"},
+			"u3": {"id": "u3", "name": "Guest"},
+		},
+		Devices: fakehomey.Objects{
+			"cam": {"id": "cam", "name": "Front door camera"},
+		},
+		Images: fakehomey.Objects{
+			"img1": {"id": "img1", "ownerUri": "homey:device:cam", "url": "/api/image/img1"},
+		},
+	})
+
+	if _, err := runCommand(t, "flows", "run-card", "create_notification", "--arg", "text=Hello"); err != nil {
+		t.Fatal(err)
+	}
+	if ids := h.IDs(fakehomey.Notifications); len(ids) != 1 || h.Object(fakehomey.Notifications, ids[0])["excerpt"] != "Hello" {
+		t.Errorf("expected a notification, got %v", ids)
+	}
+	if _, err := runCommand(t, "flows", "run-card", "create_notification", "--arg", "txt=Hello"); err == nil || !strings.Contains(err.Error(), "no argument txt") {
+		t.Errorf("expected argument validation error, got %v", err)
+	}
+
+	lastCall := func() fakehomey.Call {
+		calls := h.Calls()
+		return calls[len(calls)-1]
+	}
+	if _, err := runCommand(t, "notify", "push", "ann", "Dinner is ready"); err != nil {
+		t.Fatal(err)
+	}
+	call := lastCall()
+	if !strings.HasSuffix(call.Path, "/homey:manager:mobile/homey:manager:mobile:push_text/run") {
+		t.Errorf("expected the text push card, got %s", call.Path)
+	}
+	args := call.Body.(map[string]interface{})["args"].(map[string]interface{})
+	if user := args["user"].(map[string]interface{}); user["athomId"] != "athom-ann" || args["text"] != "Dinner is ready" {
+		t.Errorf("unexpected push args: %v", args)
+	}
+
+	for _, ref := range []string{"Front door camera", "img1"} {
+		if _, err := runCommand(t, "notify", "push", "Ann", "Door", "--image", ref); err != nil {
+			t.Fatal(err)
+		}
+		call := lastCall()
+		args := call.Body.(map[string]interface{})["args"].(map[string]interface{})
+		if image, _ := args["image"].(map[string]interface{}); !strings.Contains(call.Path, "push_text_image") || image["id"] != "img1" {
+			t.Errorf("--image %s: expected the image card with img1, got %s %v", ref, call.Path, args["image"])
+		}
+	}
+	if _, err := runCommand(t, "notify", "push", "Ann", "Door", "--image", "Kitchen"); err == nil || !strings.Contains(err.Error(), "no image or device") {
+		t.Errorf("expected unknown image error, got %v", err)
+	}
+
+	before := len(h.Calls())
+	out, err := runCommand(t, "notify", "push", "--all-users", "Leaving")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(h.Calls()) - before; got != 2 || !strings.Contains(out, "Ann, Bob") {
+		t.Errorf("expected 2 pushes, got %d: %s", got, out)
+	}
+
+	if _, err := runCommand(t, "notify", "push", "Guest", "Hi"); err == nil || !strings.Contains(err.Error(), "cannot receive push") {
+		t.Errorf("expected recipient error, got %v", err)
+	}
+	if _, err := runCommand(t, "notify", "push", "Hi"); err == nil {
+		t.Error("expected error without a user or --all-users")
+	}
+}
+
+func TestNotifyPush_KeepsSendingAfterAFailure(t *testing.T) {
+	h := fakehomey.New(&fakehomey.Seed{
+		FlowCards: fakehomey.FlowCards{Action: testPushCards},
+		Users: fakehomey.Objects{
+			"u1": {"id": "u1", "athomId": "athom-ann", "name": "Ann"},
+			"u2": {"id": "u2", "athomId": "athom-bob", "name": "Bob"},
+		},
+	})
+	h.SetToken("test-token")
+	// Ann's phone is unreachable; Bob still gets the message
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/run") {
+			body, _ := io.ReadAll(r.Body)
+			if strings.Contains(string(body), "athom-ann") {
+				http.Error(w, `{"error":"device unreachable"}`, http.StatusInternalServerError)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		h.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("HOMEY_MODE", "local")
+	t.Setenv("HOMEY_LOCAL_ADDRESS", server.URL)
+	t.Setenv("HOMEY_LOCAL_TOKEN", "test-token")
+
+	out, err := runCommand(t, "notify", "push", "--all-users", "Leaving")
+	if err == nil || !strings.Contains(err.Error(), "Ann") {
+		t.Errorf("expected Ann's failure to be reported, got %v", err)
+	}
+	if !strings.Contains(out, "sent to Bob") {
+		t.Errorf("expected Bob to get the message:\n%s", out)
+	}
+}
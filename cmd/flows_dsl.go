@@ -0,0 +1,674 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/fishfisher/homeyctl/internal/flowdsl"
+)
+
+// Logic cards used for droptoken comparisons
+var compareCards = map[string]string{
+	"<":  "homey:manager:logic:lt",
+	">":  "homey:manager:logic:gt",
+	"==": "homey:manager:logic:eq",
+}
+
+// flowNameResolver maps device and user names to IDs and back
+type flowNameResolver struct {
+	devices map[string]Device
+	users   map[string]User
+}
+
+func newFlowNameResolver() (*flowNameResolver, error) {
+	data, err := apiClient.GetDevices()
+	if err != nil {
+		return nil, err
+	}
+	var devices map[string]Device
+	if err := json.Unmarshal(data, &devices); err != nil {
+		return nil, fmt.Errorf("failed to parse devices: %w", err)
+	}
+
+	data, err = apiClient.GetUsers()
+	if err != nil {
+		return nil, err
+	}
+	var users map[string]User
+	if err := json.Unmarshal(data, &users); err != nil {
+		return nil, fmt.Errorf("failed to parse users: %w", err)
+	}
+
+	return &flowNameResolver{devices: devices, users: users}, nil
+}
+
+// deviceID resolves a device name or ID, rejecting ambiguous names
+func (r *flowNameResolver) deviceID(nameOrID string) (string, error) {
+	if _, ok := r.devices[nameOrID]; ok {
+		return nameOrID, nil
+	}
+	var matches []string
+	for id, d := range r.devices {
+		if strings.EqualFold(d.Name, nameOrID) {
+			matches = append(matches, id)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("device not found: %s", nameOrID)
+	case 1:
+		return matches[0], nil
+	}
+	return "", fmt.Errorf("device name %q is ambiguous, use the device ID", nameOrID)
+}
+
+// deviceName returns the name of a device if it uniquely identifies it
+func (r *flowNameResolver) deviceName(id string) (string, bool) {
+	d, ok := r.devices[id]
+	if !ok {
+		return "", false
+	}
+	if resolved, err := r.deviceID(d.Name); err != nil || resolved != id {
+		return "", false
+	}
+	return d.Name, true
+}
+
+func (r *flowNameResolver) user(nameOrID string) (*User, error) {
+	for _, u := range r.users {
+		if u.ID == nameOrID || strings.EqualFold(u.Name, nameOrID) {
+			return &u, nil
+		}
+	}
+	return nil, fmt.Errorf("user not found: %s", nameOrID)
+}
+
+// cardID builds the Homey card ID for a DSL reference
+func (r *flowNameResolver) cardID(ref flowdsl.Ref) (string, error) {
+	switch ref.Kind {
+	case flowdsl.RefManager:
+		return fmt.Sprintf("homey:manager:%s:%s", ref.Owner, ref.Name), nil
+	case flowdsl.RefDevice:
+		id, err := r.deviceID(ref.Owner)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("homey:device:%s:%s", id, ref.Name), nil
+	}
+	return ref.Name, nil
+}
+
+// droptoken builds a droptoken for a DSL comparison
+func (r *flowNameResolver) droptoken(ref flowdsl.Ref) (string, error) {
+	if ref.Kind != flowdsl.RefDevice {
+		return ref.Name, nil
+	}
+	id, err := r.deviceID(ref.Owner)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("homey:device:%s|%s", id, ref.Name), nil
+}
+
+// args copies card arguments, expanding user names to user objects
+func (r *flowNameResolver) args(args map[string]interface{}) (map[string]interface{}, error) {
+	out := map[string]interface{}{}
+	for k, v := range args {
+		if name, ok := v.(string); ok && k == "user" {
+			u, err := r.user(name)
+			if err != nil {
+				return nil, err
+			}
+			v = map[string]interface{}{"id": u.ID, "name": u.Name}
+		}
+		out[k] = v
+	}
+	return out, nil
+}
+
+// compiledCard is a card in the shape shared by simple and advanced flows
+type compiledCard struct {
+	ID        string
+	Args      map[string]interface{}
+	Droptoken string
+	Inverted  bool
+}
+
+func (r *flowNameResolver) compileCard(c flowdsl.Card) (*compiledCard, error) {
+	id, err := r.cardID(c.Ref)
+	if err != nil {
+		return nil, err
+	}
+	args, err := r.args(c.Args)
+	if err != nil {
+		return nil, err
+	}
+	return &compiledCard{ID: id, Args: args}, nil
+}
+
+func (r *flowNameResolver) compileCondition(c flowdsl.Condition) (*compiledCard, error) {
+	if c.Compare == nil {
+		card, err := r.compileCard(*c.Card)
+		if err != nil {
+			return nil, err
+		}
+		card.Inverted = c.Not
+		return card, nil
+	}
+	token, err := r.droptoken(c.Compare.Token)
+	if err != nil {
+		return nil, err
+	}
+	return &compiledCard{
+		ID:        compareCards[c.Compare.Op],
+		Args:      map[string]interface{}{"comparator": c.Compare.Value},
+		Droptoken: token,
+		Inverted:  c.Not,
+	}, nil
+}
+
+// compileFlowDSL turns a parsed DSL flow into simple or advanced flow JSON
+func compileFlowDSL(f *flowdsl.Flow, r *flowNameResolver) (map[string]interface{}, error) {
+	if f.Advanced {
+		return compileAdvancedFlowDSL(f, r)
+	}
+	if len(f.Rules) != 1 {
+		return nil, fmt.Errorf("simple flows have exactly one 'when'; add 'advanced' for more")
+	}
+	rule := f.Rules[0]
+
+	trigger, err := r.compileCard(rule.Trigger)
+	if err != nil {
+		return nil, err
+	}
+
+	conditions := []interface{}{}
+	for gi, group := range rule.Conditions {
+		for _, c := range group {
+			card, err := r.compileCondition(c)
+			if err != nil {
+				return nil, err
+			}
+			cond := map[string]interface{}{
+				"id":       card.ID,
+				"args":     card.Args,
+				"group":    fmt.Sprintf("group%d", gi+1),
+				"inverted": card.Inverted,
+			}
+			if card.Droptoken != "" {
+				cond["droptoken"] = card.Droptoken
+			}
+			conditions = append(conditions, cond)
+		}
+	}
+
+	actions := []interface{}{}
+	for _, list := range []struct {
+		group string
+		cards []flowdsl.Card
+	}{{"then", rule.Then}, {"else", rule.Else}} {
+		for _, c := range list.cards {
+			card, err := r.compileCard(c)
+			if err != nil {
+				return nil, err
+			}
+			actions = append(actions, map[string]interface{}{
+				"id":    card.ID,
+				"args":  card.Args,
+				"group": list.group,
+			})
+		}
+	}
+
+	return map[string]interface{}{
+		"name":       f.Name,
+		"enabled":    !f.Disabled,
+		"trigger":    map[string]interface{}{"id": trigger.ID, "args": trigger.Args},
+		"conditions": conditions,
+		"actions":    actions,
+	}, nil
+}
+
+// compileAdvancedFlowDSL chains each rule's trigger, conditions and actions
+// on the canvas: conditions continue on outputTrue, else actions start at
+// the last condition's outputFalse, and actions run in sequence.
+func compileAdvancedFlowDSL(f *flowdsl.Flow, r *flowNameResolver) (map[string]interface{}, error) {
+	cards := map[string]interface{}{}
+
+	// Seed y so the layout keeps rules, and then before else, in source order
+	row := 0
+	add := func(cardType string, c *compiledCard) (string, map[string]interface{}) {
+		id := newCardID()
+		card := newAdvancedCard(cardType, c.ID, c.Args)
+		card["y"] = row
+		if cardType == "condition" {
+			card["inverted"] = c.Inverted
+			if c.Droptoken != "" {
+				card["droptoken"] = c.Droptoken
+			}
+		}
+		cards[id] = card
+		return id, card
+	}
+	chain := func(from map[string]interface{}, output string, actions []flowdsl.Card) error {
+		for _, a := range actions {
+			c, err := r.compileCard(a)
+			if err != nil {
+				return err
+			}
+			id, card := add("action", c)
+			linkCards(from, output, id)
+			from, output = card, "outputSuccess"
+		}
+		return nil
+	}
+
+	for i, rule := range f.Rules {
+		row = 2 * i
+		if len(rule.Conditions) > 1 {
+			return nil, fmt.Errorf("'or' is only supported in simple flows")
+		}
+		if len(rule.Conditions) == 0 && len(rule.Else) > 0 {
+			return nil, fmt.Errorf("'else' needs at least one condition")
+		}
+
+		t, err := r.compileCard(rule.Trigger)
+		if err != nil {
+			return nil, err
+		}
+		_, from := add("trigger", t)
+		output := "outputSuccess"
+
+		if len(rule.Conditions) == 1 {
+			for _, c := range rule.Conditions[0] {
+				compiled, err := r.compileCondition(c)
+				if err != nil {
+					return nil, err
+				}
+				id, card := add("condition", compiled)
+				linkCards(from, output, id)
+				from, output = card, "outputTrue"
+			}
+		}
+
+		if err := chain(from, output, rule.Then); err != nil {
+			return nil, err
+		}
+		row++
+		if err := chain(from, "outputFalse", rule.Else); err != nil {
+			return nil, err
+		}
+	}
+
+	layoutAdvancedFlowCards(cards)
+	return map[string]interface{}{
+		"name":    f.Name,
+		"enabled": !f.Disabled,
+		"cards":   cards,
+	}, nil
+}
+
+// ref turns a card ID back into a DSL reference, using device names where
+// they are unique and falling back to the full ID.
+func (r *flowNameResolver) ref(cardID string) flowdsl.Ref {
+	if rest, ok := strings.CutPrefix(cardID, "homey:manager:"); ok {
+		if owner, name, ok := strings.Cut(rest, ":"); ok && flowdsl.IsIdent(owner) {
+			return flowdsl.Ref{Kind: flowdsl.RefManager, Owner: owner, Name: name}
+		}
+	}
+	if rest, ok := strings.CutPrefix(cardID, "homey:device:"); ok {
+		if id, name, ok := strings.Cut(rest, ":"); ok {
+			if deviceName, ok := r.deviceName(id); ok {
+				return flowdsl.Ref{Kind: flowdsl.RefDevice, Owner: deviceName, Name: name}
+			}
+		}
+	}
+	return flowdsl.Ref{Kind: flowdsl.RefRaw, Name: cardID}
+}
+
+func (r *flowNameResolver) tokenRef(droptoken string) flowdsl.Ref {
+	if rest, ok := strings.CutPrefix(droptoken, "homey:device:"); ok {
+		if id, capability, ok := strings.Cut(rest, "|"); ok {
+			if deviceName, ok := r.deviceName(id); ok {
+				return flowdsl.Ref{Kind: flowdsl.RefDevice, Owner: deviceName, Name: capability}
+			}
+		}
+	}
+	return flowdsl.Ref{Kind: flowdsl.RefRaw, Name: droptoken}
+}
+
+// decompileArgs collapses user objects back to user names
+func (r *flowNameResolver) decompileArgs(args map[string]interface{}) map[string]interface{} {
+	if len(args) == 0 {
+		return nil
+	}
+	out := map[string]interface{}{}
+	for k, v := range args {
+		if obj, ok := v.(map[string]interface{}); ok && k == "user" {
+			if id, ok := obj["id"].(string); ok {
+				if u, ok := r.users[id]; ok {
+					v = u.Name
+				}
+			}
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// flowCardJSON is a card as stored in simple and advanced flows
+type flowCardJSON struct {
+	ID        string                 `json:"id"`
+	Type      string                 `json:"type"`
+	Args      map[string]interface{} `json:"args"`
+	Group     string                 `json:"group"`
+	Inverted  bool                   `json:"inverted"`
+	Droptoken string                 `json:"droptoken"`
+}
+
+func (r *flowNameResolver) decompileCard(c flowCardJSON) (flowdsl.Card, error) {
+	if c.Droptoken != "" {
+		return flowdsl.Card{}, fmt.Errorf("card %s uses a droptoken the DSL cannot express", c.ID)
+	}
+	return flowdsl.Card{Ref: r.ref(c.ID), Args: r.decompileArgs(c.Args)}, nil
+}
+
+func (r *flowNameResolver) decompileCondition(c flowCardJSON) (flowdsl.Condition, error) {
+	if c.Droptoken != "" {
+		for op, id := range compareCards {
+			if c.ID != id || len(c.Args) != 1 {
+				continue
+			}
+			if value, ok := c.Args["comparator"]; ok {
+				return flowdsl.Condition{Not: c.Inverted, Compare: &flowdsl.Comparison{
+					Token: r.tokenRef(c.Droptoken), Op: op, Value: value,
+				}}, nil
+			}
+		}
+	}
+	card, err := r.decompileCard(c)
+	if err != nil {
+		return flowdsl.Condition{}, err
+	}
+	return flowdsl.Condition{Not: c.Inverted, Card: &card}, nil
+}
+
+// conditionGroupNumber is the n of a "group<n>" condition group, or -1 for
+// other names
+func conditionGroupNumber(group string) int {
+	n, err := strconv.Atoi(strings.TrimPrefix(group, "group"))
+	if err != nil || !strings.HasPrefix(group, "group") {
+		return -1
+	}
+	return n
+}
+
+// decompileFlow converts flow JSON into the DSL
+func decompileFlow(raw json.RawMessage, advanced bool, r *flowNameResolver) (*flowdsl.Flow, error) {
+	if advanced {
+		return decompileAdvancedFlow(raw, r)
+	}
+
+	var flow struct {
+		Name       string         `json:"name"`
+		Enabled    *bool          `json:"enabled"`
+		Trigger    flowCardJSON   `json:"trigger"`
+		Conditions []flowCardJSON `json:"conditions"`
+		Actions    []flowCardJSON `json:"actions"`
+	}
+	if err := json.Unmarshal(raw, &flow); err != nil {
+		return nil, fmt.Errorf("failed to parse flow: %w", err)
+	}
+
+	f := &flowdsl.Flow{Name: flow.Name, Disabled: flow.Enabled != nil && !*flow.Enabled}
+	trigger, err := r.decompileCard(flow.Trigger)
+	if err != nil {
+		return nil, err
+	}
+	rule := flowdsl.Rule{Trigger: trigger}
+
+	groups := map[string][]flowdsl.Condition{}
+	var groupNames []string
+	for _, c := range flow.Conditions {
+		cond, err := r.decompileCondition(c)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := groups[c.Group]; !ok {
+			groupNames = append(groupNames, c.Group)
+		}
+		groups[c.Group] = append(groups[c.Group], cond)
+	}
+	// group10 comes after group2
+	sort.Slice(groupNames, func(i, j int) bool {
+		a, b := conditionGroupNumber(groupNames[i]), conditionGroupNumber(groupNames[j])
+		if a != b {
+			return a < b
+		}
+		return groupNames[i] < groupNames[j]
+	})
+	for _, g := range groupNames {
+		rule.Conditions = append(rule.Conditions, groups[g])
+	}
+
+	for _, a := range flow.Actions {
+		card, err := r.decompileCard(a)
+		if err != nil {
+			return nil, err
+		}
+		if a.Group == "else" {
+			rule.Else = append(rule.Else, card)
+		} else {
+			rule.Then = append(rule.Then, card)
+		}
+	}
+
+	f.Rules = []flowdsl.Rule{rule}
+	return f, nil
+}
+
+// advancedCardJSON adds the canvas links to a flow card
+type advancedCardJSON struct {
+	flowCardJSON
+	X             float64  `json:"x"`
+	Y             float64  `json:"y"`
+	OutputSuccess []string `json:"outputSuccess"`
+	OutputTrue    []string `json:"outputTrue"`
+	OutputFalse   []string `json:"outputFalse"`
+	OutputError   []string `json:"outputError"`
+}
+
+// decompileAdvancedFlow accepts canvases made of linear chains: each trigger
+// leads to a chain of conditions, then a chain of actions, with an optional
+// else chain from the last condition.
+func decompileAdvancedFlow(raw json.RawMessage, r *flowNameResolver) (*flowdsl.Flow, error) {
+	var flow struct {
+		Name    string                      `json:"name"`
+		Enabled *bool                       `json:"enabled"`
+		Cards   map[string]advancedCardJSON `json:"cards"`
+	}
+	if err := json.Unmarshal(raw, &flow); err != nil {
+		return nil, fmt.Errorf("failed to parse advanced flow: %w", err)
+	}
+
+	f := &flowdsl.Flow{Name: flow.Name, Advanced: true, Disabled: flow.Enabled != nil && !*flow.Enabled}
+	visited := map[string]bool{}
+
+	single := func(key string, links []string) (string, error) {
+		if len(links) > 1 {
+			return "", fmt.Errorf("card %s branches to %d cards; the DSL only supports chains", key, len(links))
+		}
+		if len(links) == 0 {
+			return "", nil
+		}
+		if _, ok := flow.Cards[links[0]]; !ok {
+			return "", fmt.Errorf("card %s links to missing card %s", key, links[0])
+		}
+		if visited[links[0]] {
+			return "", fmt.Errorf("card %s is reached from more than one card; the DSL only supports chains", links[0])
+		}
+		visited[links[0]] = true
+		return links[0], nil
+	}
+	actions := func(key string) ([]flowdsl.Card, error) {
+		var cards []flowdsl.Card
+		for key != "" {
+			c := flow.Cards[key]
+			if c.Type != "action" {
+				return nil, fmt.Errorf("card %s: expected an action, got %s", key, c.Type)
+			}
+			if len(c.OutputError) > 0 {
+				return nil, fmt.Errorf("card %s: error outputs are not supported by the DSL", key)
+			}
+			card, err := r.decompileCard(c.flowCardJSON)
+			if err != nil {
+				return nil, err
+			}
+			cards = append(cards, card)
+			next, err := single(key, c.OutputSuccess)
+			if err != nil {
+				return nil, err
+			}
+			key = next
+		}
+		return cards, nil
+	}
+
+	var triggers []string
+	for key, c := range flow.Cards {
+		if c.Type == "trigger" {
+			triggers = append(triggers, key)
+		}
+	}
+	sort.Slice(triggers, func(i, j int) bool {
+		a, b := flow.Cards[triggers[i]], flow.Cards[triggers[j]]
+		if a.Y != b.Y {
+			return a.Y < b.Y
+		}
+		return triggers[i] < triggers[j]
+	})
+
+	for _, key := range triggers {
+		visited[key] = true
+		t := flow.Cards[key]
+		trigger, err := r.decompileCard(t.flowCardJSON)
+		if err != nil {
+			return nil, err
+		}
+		rule := flowdsl.Rule{Trigger: trigger}
+
+		next, err := single(key, t.OutputSuccess)
+		if err != nil {
+			return nil, err
+		}
+		var elseKey string
+		for next != "" && flow.Cards[next].Type == "condition" {
+			if elseKey != "" {
+				return nil, fmt.Errorf("card %s: only the last condition can have a false output", elseKey)
+			}
+			c := flow.Cards[next]
+			cond, err := r.decompileCondition(c.flowCardJSON)
+			if err != nil {
+				return nil, err
+			}
+			if len(rule.Conditions) == 0 {
+				rule.Conditions = [][]flowdsl.Condition{nil}
+			}
+			rule.Conditions[0] = append(rule.Conditions[0], cond)
+
+			if len(c.OutputFalse) > 0 {
+				elseKey = next
+			}
+			key := next
+			if next, err = single(key, c.OutputTrue); err != nil {
+				return nil, err
+			}
+		}
+
+		if rule.Then, err = actions(next); err != nil {
+			return nil, err
+		}
+		if elseKey != "" {
+			first, err := single(elseKey, flow.Cards[elseKey].OutputFalse)
+			if err != nil {
+				return nil, err
+			}
+			if rule.Else, err = actions(first); err != nil {
+				return nil, err
+			}
+		}
+		f.Rules = append(f.Rules, rule)
+	}
+
+	for key, c := range flow.Cards {
+		if !visited[key] {
+			return nil, fmt.Errorf("the DSL cannot express %s card %s", c.Type, key)
+		}
+	}
+	if len(f.Rules) == 0 {
+		return nil, fmt.Errorf("advanced flow has no trigger cards")
+	}
+	return f, nil
+}
+
+// readFlowDSL parses a DSL file, using the YAML form for .yaml/.yml files.
+// The flow name defaults to the file name.
+func readFlowDSL(path string, data []byte) (*flowdsl.Flow, error) {
+	var f *flowdsl.Flow
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		f, err = flowdsl.ParseYAML(data)
+	default:
+		f, err = flowdsl.Parse(string(data))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if f.Name == "" && path != "-" {
+		f.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return f, nil
+}
+
+var flowsDecompileCmd = &cobra.Command{
+	Use:   "decompile <name-or-id>",
+	Short: "Print a flow in the flow DSL",
+	Long: `Print a flow in the text DSL accepted by 'flows create --dsl'.
+
+Device and user IDs are replaced with names where the name is unique.
+Advanced flows are supported when each trigger leads to a chain of
+conditions and actions.
+
+Examples:
+  homeyctl flows decompile "Welcome home"
+  homeyctl flows decompile "Welcome home" > welcome.flow
+  homeyctl flows create --dsl welcome.flow`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		found, err := findFlow(args[0])
+		if err != nil {
+			return err
+		}
+		resolver, err := newFlowNameResolver()
+		if err != nil {
+			return err
+		}
+		f, err := decompileFlow(found.Raw, found.Advanced, resolver)
+		if err != nil {
+			return fmt.Errorf("cannot decompile %s: %w", found.Name, err)
+		}
+		fmt.Print(flowdsl.Format(f))
+		return nil
+	},
+}
+
+func init() {
+	flowsCmd.AddCommand(flowsDecompileCmd)
+}
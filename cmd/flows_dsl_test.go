@@ -0,0 +1,245 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/fishfisher/homeyctl/internal/flowdsl"
+)
+
+func testFlowResolver() *flowNameResolver {
+	return &flowNameResolver{
+		devices: map[string]Device{
+			"sensor-1": {ID: "sensor-1", Name: "Hall sensor"},
+			"heater-1": {ID: "heater-1", Name: "Hall heater"},
+			"lamp-1":   {ID: "lamp-1", Name: "Lamp"},
+			"lamp-2":   {ID: "lamp-2", Name: "Lamp"},
+		},
+		users: map[string]User{
+			"user-1": {ID: "user-1", Name: "Anna"},
+		},
+	}
+}
+
+// roundTrip compiles DSL source, passes it through JSON like the API would,
+// and decompiles it again.
+func roundTrip(t *testing.T, src string) string {
+	t.Helper()
+	r := testFlowResolver()
+
+	f, err := flowdsl.Parse(src)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	flow, err := compileFlowDSL(f, r)
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	if err := validateFlow(flow, f.Advanced); err != nil {
+		t.Fatalf("compiled flow is invalid: %v", err)
+	}
+	raw, _ := json.Marshal(flow)
+
+	back, err := decompileFlow(raw, f.Advanced, r)
+	if err != nil {
+		t.Fatalf("decompile: %v", err)
+	}
+	return flowdsl.Format(back)
+}
+
+func TestCompileFlowDSL_Simple(t *testing.T) {
+	f, err := flowdsl.Parse(`flow "Welcome home"
+when presence.user_enter(user: "Anna")
+if "Hall sensor".measure_temperature < 20
+or not "Hall heater".on
+then "Hall heater".on
+else "homey:device:lamp-2:off"`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	flow, err := compileFlowDSL(f, testFlowResolver())
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+
+	trigger := flow["trigger"].(map[string]interface{})
+	if trigger["id"] != "homey:manager:presence:user_enter" {
+		t.Errorf("unexpected trigger id: %v", trigger["id"])
+	}
+	user := trigger["args"].(map[string]interface{})["user"].(map[string]interface{})
+	if user["id"] != "user-1" || user["name"] != "Anna" {
+		t.Errorf("expected user resolved to object, got %v", user)
+	}
+
+	conditions := flow["conditions"].([]interface{})
+	cmp := conditions[0].(map[string]interface{})
+	if cmp["id"] != "homey:manager:logic:lt" || cmp["droptoken"] != "homey:device:sensor-1|measure_temperature" || cmp["group"] != "group1" {
+		t.Errorf("unexpected comparison condition: %v", cmp)
+	}
+	onCond := conditions[1].(map[string]interface{})
+	if onCond["id"] != "homey:device:heater-1:on" || onCond["inverted"] != true || onCond["group"] != "group2" {
+		t.Errorf("unexpected inverted condition: %v", onCond)
+	}
+
+	actions := flow["actions"].([]interface{})
+	if a := actions[1].(map[string]interface{}); a["id"] != "homey:device:lamp-2:off" || a["group"] != "else" {
+		t.Errorf("unexpected else action: %v", a)
+	}
+}
+
+func TestCompileFlowDSL_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"unknown device", `when "Nope".on then a.b`, "device not found"},
+		{"ambiguous device", `when a.b then "Lamp".on`, "ambiguous"},
+		{"unknown user", `when presence.user_enter(user: "Bob") then a.b`, "user not found"},
+		{"two rules in simple flow", "when a.b\nwhen c.d", "exactly one"},
+		{"or in advanced flow", "advanced\nwhen a.b if c.d or e.f", "'or'"},
+		{"else without condition", "advanced\nwhen a.b else c.d", "'else'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := flowdsl.Parse(tt.src)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			_, err = compileFlowDSL(f, testFlowResolver())
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestCompileFlowDSL_AdvancedLinksAndLayout(t *testing.T) {
+	f, err := flowdsl.Parse(`advanced
+flow "Heat"
+when presence.user_enter(user: "Anna")
+if "Hall sensor".measure_temperature < 20
+then "Hall heater".on
+then "Hall heater".target_temperature_set(target_temperature: 22)
+else "Hall heater".off`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	flow, err := compileFlowDSL(f, testFlowResolver())
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+
+	cards := flow["cards"].(map[string]interface{})
+	if len(cards) != 5 {
+		t.Fatalf("expected 5 cards, got %d", len(cards))
+	}
+	var trigger, condition map[string]interface{}
+	for _, raw := range cards {
+		card := raw.(map[string]interface{})
+		switch card["type"] {
+		case "trigger":
+			trigger = card
+		case "condition":
+			condition = card
+		}
+		if card["ownerUri"] == nil {
+			t.Errorf("card %v has no ownerUri", card["id"])
+		}
+	}
+
+	links := cardLinks(trigger)
+	if len(links) != 1 || cards[links[0]].(map[string]interface{})["type"] != "condition" {
+		t.Errorf("expected trigger linked to condition, got %v", links)
+	}
+	if len(condition["outputTrue"].([]interface{})) != 1 || len(condition["outputFalse"].([]interface{})) != 1 {
+		t.Errorf("expected condition with true and false links: %v", condition)
+	}
+	if cardCoord(condition, "x") <= cardCoord(trigger, "x") {
+		t.Error("expected condition to be placed right of the trigger")
+	}
+}
+
+func TestFlowDSL_RoundTrip(t *testing.T) {
+	tests := []string{
+		`flow "Welcome home"
+
+when presence.user_enter(user: "Anna")
+if "Hall sensor".measure_temperature < 20
+and not "Hall heater".on
+or "homey:device:lamp-1|onoff" == true
+then "Hall heater".on
+then "Hall heater".target_temperature_set(target_temperature: 22)
+else "homey:device:lamp-2:off"
+`,
+		`flow "Heat"
+advanced
+disabled
+
+when presence.user_enter(user: "Anna")
+if "Hall sensor".measure_temperature > 25
+and "Hall heater".on
+then "Hall heater".off
+then notifications.create_notification(text: "Too warm")
+else "Hall heater".on
+
+when cron.time_exactly(time: "07:00")
+then "Hall heater".on
+`,
+	}
+	for _, src := range tests {
+		if got := roundTrip(t, src); got != src {
+			t.Errorf("round trip mismatch:\n--- got\n%s\n--- want\n%s", got, src)
+		}
+	}
+}
+
+func TestFlowDSL_RoundTripManyGroups(t *testing.T) {
+	var src strings.Builder
+	src.WriteString("flow \"Groups\"\n\nwhen presence.user_enter(user: \"Anna\")\n")
+	for i := 1; i <= 11; i++ {
+		word := "or"
+		if i == 1 {
+			word = "if"
+		}
+		fmt.Fprintf(&src, "%s \"Hall sensor\".measure_temperature > %d\n", word, i)
+	}
+	src.WriteString("then \"Hall heater\".off\n")
+
+	// group10 and group11 must stay after group9
+	if got := roundTrip(t, src.String()); got != src.String() {
+		t.Errorf("round trip mismatch:\n--- got\n%s\n--- want\n%s", got, src.String())
+	}
+}
+
+func TestDecompileAdvancedFlow_RejectsBranches(t *testing.T) {
+	raw := json.RawMessage(`{"name":"x","cards":{
+		"t":{"type":"trigger","id":"homey:manager:cron:time_exactly","outputSuccess":["a","b"]},
+		"a":{"type":"action","id":"homey:device:lamp-1:on"},
+		"b":{"type":"action","id":"homey:device:lamp-2:on"}
+	}}`)
+	_, err := decompileFlow(raw, true, testFlowResolver())
+	if err == nil || !strings.Contains(err.Error(), "branches") {
+		t.Errorf("expected branch error, got %v", err)
+	}
+
+	raw = json.RawMessage(`{"name":"x","cards":{
+		"t":{"type":"trigger","id":"homey:manager:cron:time_exactly"},
+		"n":{"type":"note"}
+	}}`)
+	_, err = decompileFlow(raw, true, testFlowResolver())
+	if err == nil || !strings.Contains(err.Error(), "note") {
+		t.Errorf("expected unsupported card error, got %v", err)
+	}
+}
+
+func TestFlowsDecompileCommand_Exists(t *testing.T) {
+	if _, _, err := flowsCmd.Find([]string{"decompile"}); err != nil {
+		t.Errorf("decompile command not found: %v", err)
+	}
+	if flowsCreateCmd.Flags().Lookup("dsl") == nil {
+		t.Error("expected --dsl flag on flows create")
+	}
+}
@@ -0,0 +1,528 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/fishfisher/homeyctl/internal/flowdsl"
+	"github.com/fishfisher/homeyctl/internal/jsonpatch"
+)
+
+// Edge labels for the advanced flow outputs
+var flowOutputLabels = map[string]string{
+	"outputSuccess": "",
+	"outputTrue":    "true",
+	"outputFalse":   "false",
+	"outputError":   "error",
+}
+
+// flowGraphNode is one card on an advanced flow canvas
+type flowGraphNode struct {
+	Key   string
+	Type  string
+	Label string
+}
+
+// flowGraphEdge links a card output to another card
+type flowGraphEdge struct {
+	From  string
+	To    string
+	Label string
+}
+
+// flowGraph is the card graph of an advanced flow
+type flowGraph struct {
+	Name  string
+	Nodes []flowGraphNode
+	Edges []flowGraphEdge
+}
+
+// buildFlowGraph reads the cards of an advanced flow, ordering nodes by
+// canvas position so the output is stable.
+func buildFlowGraph(raw json.RawMessage, r *flowNameResolver) (*flowGraph, error) {
+	var flow struct {
+		Name  string                      `json:"name"`
+		Cards map[string]advancedCardJSON `json:"cards"`
+	}
+	if err := json.Unmarshal(raw, &flow); err != nil {
+		return nil, fmt.Errorf("failed to parse advanced flow: %w", err)
+	}
+
+	keys := make([]string, 0, len(flow.Cards))
+	for k := range flow.Cards {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := flow.Cards[keys[i]], flow.Cards[keys[j]]
+		if a.X != b.X {
+			return a.X < b.X
+		}
+		if a.Y != b.Y {
+			return a.Y < b.Y
+		}
+		return keys[i] < keys[j]
+	})
+
+	g := &flowGraph{Name: flow.Name}
+	for _, k := range keys {
+		c := flow.Cards[k]
+		g.Nodes = append(g.Nodes, flowGraphNode{Key: k, Type: c.Type, Label: flowCardLabel(c.flowCardJSON, r)})
+		for _, output := range advancedCardOutputs {
+			var links []string
+			switch output {
+			case "outputSuccess":
+				links = c.OutputSuccess
+			case "outputTrue":
+				links = c.OutputTrue
+			case "outputFalse":
+				links = c.OutputFalse
+			case "outputError":
+				links = c.OutputError
+			}
+			for _, to := range links {
+				g.Edges = append(g.Edges, flowGraphEdge{From: k, To: to, Label: flowOutputLabels[output]})
+			}
+		}
+	}
+	return g, nil
+}
+
+// flowCardLabel describes a card in DSL notation, or by type for cards
+// without a card ID (notes, delays, any/all blocks).
+func flowCardLabel(c flowCardJSON, r *flowNameResolver) string {
+	if c.ID == "" {
+		if len(c.Args) > 0 {
+			return c.Type + " " + flowdsl.FormatValue(c.Args)
+		}
+		return c.Type
+	}
+	if c.Type == "condition" {
+		if cond, err := r.decompileCondition(c); err == nil {
+			return flowdsl.FormatCondition(cond)
+		}
+	}
+	return flowdsl.FormatCard(flowdsl.Card{Ref: r.ref(c.ID), Args: r.decompileArgs(c.Args)})
+}
+
+func renderFlowDOT(g *flowGraph) string {
+	shapes := map[string]string{"trigger": "ellipse", "condition": "diamond", "action": "box"}
+	quote := func(s string) string { return "\"" + strings.NewReplacer("\\", "\\\\", "\"", "\\\"").Replace(s) + "\"" }
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %s {\n", quote(g.Name))
+	b.WriteString("  rankdir=LR;\n")
+	for _, n := range g.Nodes {
+		shape, ok := shapes[n.Type]
+		if !ok {
+			shape = "note"
+		}
+		fmt.Fprintf(&b, "  %s [shape=%s, label=%s];\n", quote(n.Key), shape, quote(n.Label))
+	}
+	for _, e := range g.Edges {
+		if e.Label != "" {
+			fmt.Fprintf(&b, "  %s -> %s [label=%s];\n", quote(e.From), quote(e.To), quote(e.Label))
+		} else {
+			fmt.Fprintf(&b, "  %s -> %s;\n", quote(e.From), quote(e.To))
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func renderFlowMermaid(g *flowGraph) string {
+	ids := make(map[string]string)
+	for i, n := range g.Nodes {
+		ids[n.Key] = fmt.Sprintf("n%d", i)
+	}
+	label := func(s string) string { return "\"" + strings.ReplaceAll(s, "\"", "#quot;") + "\"" }
+
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	for _, n := range g.Nodes {
+		switch n.Type {
+		case "trigger":
+			fmt.Fprintf(&b, "  %s([%s])\n", ids[n.Key], label(n.Label))
+		case "condition":
+			fmt.Fprintf(&b, "  %s{%s}\n", ids[n.Key], label(n.Label))
+		default:
+			fmt.Fprintf(&b, "  %s[%s]\n", ids[n.Key], label(n.Label))
+		}
+	}
+	for _, e := range g.Edges {
+		to, ok := ids[e.To]
+		if !ok {
+			continue
+		}
+		if e.Label != "" {
+			fmt.Fprintf(&b, "  %s -->|%s| %s\n", ids[e.From], e.Label, to)
+		} else {
+			fmt.Fprintf(&b, "  %s --> %s\n", ids[e.From], to)
+		}
+	}
+	return b.String()
+}
+
+// renderFlowASCII prints the graph as a tree from each card without
+// incoming links. Cards reached again are shown as references.
+func renderFlowASCII(g *flowGraph) string {
+	nodes := make(map[string]flowGraphNode)
+	incoming := make(map[string]bool)
+	out := make(map[string][]flowGraphEdge)
+	for _, n := range g.Nodes {
+		nodes[n.Key] = n
+	}
+	for _, e := range g.Edges {
+		if _, ok := nodes[e.To]; ok {
+			incoming[e.To] = true
+			out[e.From] = append(out[e.From], e)
+		}
+	}
+
+	var b strings.Builder
+	visited := make(map[string]bool)
+	var walk func(key, prefix, branch, edge string)
+	walk = func(key, prefix, branch, edge string) {
+		n := nodes[key]
+		if edge != "" {
+			edge = "(" + edge + ") "
+		}
+		if visited[key] {
+			fmt.Fprintf(&b, "%s%s%s↺ %s\n", prefix, branch, edge, n.Label)
+			return
+		}
+		visited[key] = true
+		fmt.Fprintf(&b, "%s%s%s[%s] %s\n", prefix, branch, edge, n.Type, n.Label)
+
+		switch branch {
+		case "├── ":
+			prefix += "│   "
+		case "└── ":
+			prefix += "    "
+		}
+		edges := out[key]
+		for i, e := range edges {
+			next := "├── "
+			if i == len(edges)-1 {
+				next = "└── "
+			}
+			walk(e.To, prefix, next, e.Label)
+		}
+	}
+
+	for _, n := range g.Nodes {
+		if !incoming[n.Key] && !visited[n.Key] {
+			walk(n.Key, "", "", "")
+		}
+	}
+	// Cards only reachable through a cycle
+	for _, n := range g.Nodes {
+		if !visited[n.Key] {
+			walk(n.Key, "", "", "")
+		}
+	}
+	return b.String()
+}
+
+var flowsGraphCmd = &cobra.Command{
+	Use:   "graph <name-or-id>",
+	Short: "Render an advanced flow as a graph",
+	Long: `Render the cards of an advanced flow as a graph.
+
+Formats:
+  ascii    Tree view in the terminal (default)
+  dot      Graphviz DOT
+  mermaid  Mermaid flowchart
+
+Cards are labelled in the flow DSL notation, with device names in place
+of device IDs.
+
+Examples:
+  homeyctl flows graph "Welcome home"
+  homeyctl flows graph "Welcome home" --format dot | dot -Tsvg > flow.svg
+  homeyctl flows graph "Welcome home" --format mermaid`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("format")
+
+		found, err := findFlow(args[0])
+		if err != nil {
+			return err
+		}
+		if !found.Advanced {
+			return fmt.Errorf("%s is a simple flow; graphs are only available for advanced flows", found.Name)
+		}
+		resolver, err := newFlowNameResolver()
+		if err != nil {
+			return err
+		}
+		g, err := buildFlowGraph(found.Raw, resolver)
+		if err != nil {
+			return err
+		}
+
+		switch format {
+		case "ascii":
+			fmt.Print(renderFlowASCII(g))
+		case "dot":
+			fmt.Print(renderFlowDOT(g))
+		case "mermaid":
+			fmt.Print(renderFlowMermaid(g))
+		default:
+			return fmt.Errorf("invalid format: %s (use: ascii, dot, mermaid)", format)
+		}
+		return nil
+	},
+}
+
+// Card fields Homey needs on every card; patches may change but not remove them
+var requiredCardFields = map[string]bool{"id": true, "type": true, "ownerUri": true, "x": true, "y": true}
+
+// splitAssignment splits "path=value" at the first = outside brackets
+func splitAssignment(s string) (string, string, error) {
+	depth := 0
+	for i, c := range s {
+		switch c {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case '=':
+			if depth == 0 {
+				return s[:i], s[i+1:], nil
+			}
+		}
+	}
+	return "", "", fmt.Errorf("invalid assignment %q (use path=value)", s)
+}
+
+// patchPointers resolves a JSONPath ($...) or JSON Pointer (/...) expression
+func patchPointers(doc interface{}, path string) ([]string, error) {
+	if strings.HasPrefix(path, "/") {
+		return []string{path}, nil
+	}
+	pointers, err := jsonpatch.Resolve(doc, path)
+	if err != nil {
+		return nil, err
+	}
+	if len(pointers) == 0 {
+		return nil, fmt.Errorf("%s matches nothing", path)
+	}
+	return pointers, nil
+}
+
+// patchCardKey checks that a pointer addresses a field inside an existing
+// card, or a new card being added, and returns the card key.
+func patchCardKey(doc map[string]interface{}, pointer, op string) (string, error) {
+	tokens, err := jsonpatch.ParsePointer(pointer)
+	if err != nil {
+		return "", err
+	}
+	cards, _ := doc["cards"].(map[string]interface{})
+	if len(tokens) == 2 && tokens[0] == "cards" && op == "add" {
+		if _, ok := cards[tokens[1]]; ok {
+			return "", fmt.Errorf("%s: card %s already exists", pointer, tokens[1])
+		}
+		return tokens[1], nil
+	}
+	if len(tokens) < 3 || tokens[0] != "cards" {
+		return "", fmt.Errorf("%s: patches must target fields inside a card (/cards/<card>/...), or add a new card", pointer)
+	}
+	if _, ok := cards[tokens[1]]; !ok {
+		return "", fmt.Errorf("%s: card %s not found", pointer, tokens[1])
+	}
+	if len(tokens) == 3 && requiredCardFields[tokens[2]] && (op == "remove" || op == "move") {
+		return "", fmt.Errorf("%s: cannot remove required card field %q", pointer, tokens[2])
+	}
+	return tokens[1], nil
+}
+
+// patchAdvancedFlow applies a patch to the full fetched flow and returns an
+// update holding the complete patched cards map, since Homey replaces the
+// cards as a whole (see client.MergeUpdate). The keys are the cards that
+// changed.
+func patchAdvancedFlow(doc map[string]interface{}, ops []jsonpatch.Operation, autoLayout bool) (map[string]interface{}, []string, error) {
+	touched := make(map[string]bool)
+	for _, op := range ops {
+		key, err := patchCardKey(doc, op.Path, op.Op)
+		if err != nil {
+			return nil, nil, err
+		}
+		touched[key] = true
+		if op.Op == "move" || op.Op == "copy" {
+			if _, err := patchCardKey(doc, op.From, op.Op); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	before, _ := doc["cards"].(map[string]interface{})
+	patched, err := jsonpatch.Apply(doc, ops)
+	if err != nil {
+		return nil, nil, err
+	}
+	cards, _ := patched.(map[string]interface{})["cards"].(map[string]interface{})
+
+	for key := range touched {
+		card, ok := cards[key].(map[string]interface{})
+		if !ok {
+			return nil, nil, fmt.Errorf("card %s must be an object", key)
+		}
+		old, _ := before[key].(map[string]interface{})
+		// Keep ownerUri in step when a patch swaps the card ID
+		if id, _ := card["id"].(string); id != old["id"] && card["ownerUri"] == old["ownerUri"] {
+			card["ownerUri"] = cardOwnerURI(id)
+		}
+	}
+
+	if autoLayout {
+		layoutAdvancedFlowCards(cards)
+		for key := range cards {
+			touched[key] = true
+		}
+	}
+
+	var keys []string
+	for key := range touched {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return map[string]interface{}{"cards": cards}, keys, nil
+}
+
+var flowsPatchCmd = &cobra.Command{
+	Use:   "patch <name-or-id> [patch-file]",
+	Short: "Patch cards of an advanced flow",
+	Long: `Apply JSON Patch or JSONPath edits to cards in an advanced flow.
+
+Homey replaces the whole cards map when an advanced flow is updated, so a
+partial card wipes its id, type, ownerUri and position, and cards left out
+are deleted. This command applies edits to the full fetched flow and sends
+every card back.
+
+A patch file holds an RFC 6902 JSON Patch array. Paths must point inside
+a card (/cards/<card>/...), or add a whole new card at /cards/<new-card>. --set and --unset take a JSONPath or JSON
+Pointer; --set values are parsed as JSON, falling back to a string.
+
+JSONPath supports member access, [*] and filters like
+[?(@.id=='homey:device:<id>:on')] or [?(@.type=='action')].
+
+Examples:
+  # Change a notification text
+  homeyctl flows patch "Welcome" --set '$.cards[?(@.id=="homey:manager:notifications:create_notification")].args.text=Welcome home'
+
+  # Set dim level on every action card that has one
+  homeyctl flows patch "Evening" --set '$.cards[?(@.type=="action")].args.dim=0.3'
+
+  # JSON Patch file
+  homeyctl flows patch "Evening" patch.json
+
+  # Re-position all cards
+  homeyctl flows patch "Evening" --auto-layout`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sets, _ := cmd.Flags().GetStringArray("set")
+		unsets, _ := cmd.Flags().GetStringArray("unset")
+		autoLayout, _ := cmd.Flags().GetBool("auto-layout")
+		backup, _ := cmd.Flags().GetBool("backup")
+
+		found, err := findFlow(args[0])
+		if err != nil {
+			return err
+		}
+		if !found.Advanced {
+			return fmt.Errorf("%s is a simple flow; use 'homeyctl flows update' instead", found.Name)
+		}
+
+		var doc map[string]interface{}
+		if err := json.Unmarshal(found.Raw, &doc); err != nil {
+			return fmt.Errorf("failed to parse advanced flow: %w", err)
+		}
+
+		var ops []jsonpatch.Operation
+		if len(args) == 2 {
+			var data []byte
+			if args[1] == "-" {
+				data, err = os.ReadFile("/dev/stdin")
+			} else {
+				data, err = os.ReadFile(args[1])
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read patch: %w", err)
+			}
+			if err := json.Unmarshal(data, &ops); err != nil {
+				return fmt.Errorf("invalid JSON Patch: %w", err)
+			}
+		}
+		for _, s := range sets {
+			path, raw, err := splitAssignment(s)
+			if err != nil {
+				return err
+			}
+			var value interface{}
+			if err := json.Unmarshal([]byte(raw), &value); err != nil {
+				value = raw
+			}
+			pointers, err := patchPointers(doc, path)
+			if err != nil {
+				return err
+			}
+			for _, p := range pointers {
+				ops = append(ops, jsonpatch.Operation{Op: "add", Path: p, Value: value})
+			}
+		}
+		for _, path := range unsets {
+			pointers, err := patchPointers(doc, path)
+			if err != nil {
+				return err
+			}
+			for _, p := range pointers {
+				if _, err := jsonpatch.Get(doc, p); err == nil {
+					ops = append(ops, jsonpatch.Operation{Op: "remove", Path: p})
+				}
+			}
+		}
+		if len(ops) == 0 && !autoLayout {
+			return fmt.Errorf("nothing to patch: provide a patch file, --set, --unset or --auto-layout")
+		}
+
+		update, keys, err := patchAdvancedFlow(doc, ops, autoLayout)
+		if err != nil {
+			return err
+		}
+		if err := validateAdvancedFlowUpdate(update); err != nil {
+			return err
+		}
+
+		if backup {
+			path, err := backupFlow(found.Name, found.Raw)
+			if err != nil {
+				return fmt.Errorf("backup failed: %w", err)
+			}
+			color.Yellow("Backed up to: %s\n", path)
+		}
+
+		recordFlowHistory(found, "patch")
+		if _, err := apiClient.UpdateAdvancedFlow(found.ID, update); err != nil {
+			return err
+		}
+		color.Green("Patched advanced flow: %s (%d card(s) updated)\n", found.Name, len(keys))
+		return nil
+	},
+}
+
+func init() {
+	flowsCmd.AddCommand(flowsGraphCmd)
+	flowsGraphCmd.Flags().String("format", "ascii", "Output format: ascii, dot, mermaid")
+
+	flowsCmd.AddCommand(flowsPatchCmd)
+	flowsPatchCmd.Flags().StringArray("set", nil, "Set a value: <jsonpath|pointer>=<json> (repeatable)")
+	flowsPatchCmd.Flags().StringArray("unset", nil, "Remove a value: <jsonpath|pointer> (repeatable)")
+	flowsPatchCmd.Flags().Bool("auto-layout", false, "Re-position all cards")
+	flowsPatchCmd.Flags().Bool("backup", false, "Save current flow state before patching")
+}
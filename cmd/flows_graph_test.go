@@ -0,0 +1,202 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/fishfisher/homeyctl/internal/client"
+	"github.com/fishfisher/homeyctl/internal/fakehomey"
+	"github.com/fishfisher/homeyctl/internal/jsonpatch"
+)
+
+const testAdvancedFlow = `{"id":"f1","name":"Heat","cards":{
+	"t":{"type":"trigger","id":"homey:manager:presence:user_enter","ownerUri":"homey:manager:presence","args":{"user":{"id":"user-1","name":"Anna"}},"x":0,"y":0,"outputSuccess":["c"]},
+	"c":{"type":"condition","id":"homey:manager:logic:lt","ownerUri":"homey:manager:logic","droptoken":"homey:device:sensor-1|measure_temperature","args":{"comparator":20},"x":420,"y":0,"outputTrue":["on"],"outputFalse":["off"]},
+	"on":{"type":"action","id":"homey:device:heater-1:on","ownerUri":"homey:device:heater-1","args":{},"x":840,"y":0,"outputSuccess":["c"]},
+	"off":{"type":"action","id":"homey:device:heater-1:off","ownerUri":"homey:device:heater-1","args":{},"x":840,"y":160},
+	"n":{"type":"note","args":{"text":"hi"},"x":0,"y":300}
+}}`
+
+func TestBuildFlowGraph(t *testing.T) {
+	g, err := buildFlowGraph(json.RawMessage(testAdvancedFlow), testFlowResolver())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(g.Nodes) != 5 || len(g.Edges) != 4 {
+		t.Fatalf("expected 5 nodes and 4 edges, got %d and %d", len(g.Nodes), len(g.Edges))
+	}
+	if g.Nodes[0].Key != "t" || g.Nodes[0].Label != `presence.user_enter(user: "Anna")` {
+		t.Errorf("unexpected first node: %+v", g.Nodes[0])
+	}
+	labels := map[string]string{}
+	for _, n := range g.Nodes {
+		labels[n.Key] = n.Label
+	}
+	if labels["c"] != `"Hall sensor".measure_temperature < 20` {
+		t.Errorf("unexpected condition label: %s", labels["c"])
+	}
+	if labels["n"] != `note {text: "hi"}` {
+		t.Errorf("unexpected note label: %s", labels["n"])
+	}
+}
+
+func TestRenderFlowGraph(t *testing.T) {
+	g, _ := buildFlowGraph(json.RawMessage(testAdvancedFlow), testFlowResolver())
+
+	dot := renderFlowDOT(g)
+	for _, want := range []string{`digraph "Heat" {`, `"c" [shape=diamond, label="\"Hall sensor\".measure_temperature < 20"];`, `"c" -> "off" [label="false"];`, `"t" -> "c";`} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("DOT output missing %q:\n%s", want, dot)
+		}
+	}
+
+	mermaid := renderFlowMermaid(g)
+	for _, want := range []string{"flowchart LR", `n0(["presence.user_enter(user: #quot;Anna#quot;)"])`, "n2 -->|true| n3"} {
+		if !strings.Contains(mermaid, want) {
+			t.Errorf("Mermaid output missing %q:\n%s", want, mermaid)
+		}
+	}
+
+	ascii := renderFlowASCII(g)
+	want := `[trigger] presence.user_enter(user: "Anna")
+└── [condition] "Hall sensor".measure_temperature < 20
+    ├── (true) [action] "Hall heater".on
+    │   └── ↺ "Hall sensor".measure_temperature < 20
+    └── (false) [action] "Hall heater".off
+[note] note {text: "hi"}
+`
+	if ascii != want {
+		t.Errorf("unexpected ASCII output:\n%s\nwant:\n%s", ascii, want)
+	}
+}
+
+func TestSplitAssignment(t *testing.T) {
+	path, value, err := splitAssignment(`$.cards[?(@.id=="x")].args.text=a=b`)
+	if err != nil || path != `$.cards[?(@.id=="x")].args.text` || value != "a=b" {
+		t.Errorf("got %q %q %v", path, value, err)
+	}
+	if _, _, err := splitAssignment("$.cards.x"); err == nil {
+		t.Error("expected error without =")
+	}
+}
+
+func TestPatchAdvancedFlow(t *testing.T) {
+	var doc map[string]interface{}
+	json.Unmarshal([]byte(testAdvancedFlow), &doc)
+
+	ops := []jsonpatch.Operation{
+		{Op: "add", Path: "/cards/on/args/dim", Value: 0.5},
+		{Op: "replace", Path: "/cards/off/id", Value: "homey:device:lamp-1:off"},
+	}
+	update, keys, err := patchAdvancedFlow(doc, ops, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(keys, ",") != "off,on" {
+		t.Errorf("unexpected changed cards: %v", keys)
+	}
+	if err := validateAdvancedFlowUpdate(update); err != nil {
+		t.Errorf("patched update is invalid: %v", err)
+	}
+
+	cards := update["cards"].(map[string]interface{})
+	on := cards["on"].(map[string]interface{})
+	if on["args"].(map[string]interface{})["dim"] != 0.5 || on["type"] != "action" || on["x"] != 840.0 {
+		t.Errorf("expected full card with new arg, got %v", on)
+	}
+	if off := cards["off"].(map[string]interface{}); off["ownerUri"] != "homey:device:lamp-1" {
+		t.Errorf("expected ownerUri to follow new ID, got %v", off["ownerUri"])
+	}
+	if _, ok := doc["cards"].(map[string]interface{})["on"].(map[string]interface{})["args"].(map[string]interface{})["dim"]; ok {
+		t.Error("original document was modified")
+	}
+}
+
+func TestPatchAdvancedFlow_Rejects(t *testing.T) {
+	var doc map[string]interface{}
+	json.Unmarshal([]byte(testAdvancedFlow), &doc)
+
+	tests := []struct {
+		name string
+		op   jsonpatch.Operation
+		want string
+	}{
+		{"whole card", jsonpatch.Operation{Op: "remove", Path: "/cards/on"}, "inside a card"},
+		{"top level", jsonpatch.Operation{Op: "replace", Path: "/name", Value: "x"}, "inside a card"},
+		{"unknown card", jsonpatch.Operation{Op: "add", Path: "/cards/zz/args/a", Value: 1}, "not found"},
+		{"required field", jsonpatch.Operation{Op: "remove", Path: "/cards/on/ownerUri"}, "required card field"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := patchAdvancedFlow(doc, []jsonpatch.Operation{tt.op}, false)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestPatchAdvancedFlow_AutoLayout(t *testing.T) {
+	var doc map[string]interface{}
+	json.Unmarshal([]byte(testAdvancedFlow), &doc)
+
+	update, keys, err := patchAdvancedFlow(doc, nil, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(keys) != 5 {
+		t.Errorf("expected all cards in update, got %v", keys)
+	}
+	cards := update["cards"].(map[string]interface{})
+	if cardCoord(cards["c"], "x") <= cardCoord(cards["t"], "x") {
+		t.Error("expected condition right of trigger after layout")
+	}
+}
+
+func TestFlowsGraphAndPatchCommands_Exist(t *testing.T) {
+	for _, name := range []string{"graph", "patch"} {
+		if _, _, err := flowsCmd.Find([]string{name}); err != nil {
+			t.Errorf("%s command not found: %v", name, err)
+		}
+	}
+	for _, flag := range []string{"set", "unset", "auto-layout", "backup"} {
+		if flowsPatchCmd.Flags().Lookup(flag) == nil {
+			t.Errorf("expected --%s flag on flows patch", flag)
+		}
+	}
+}
+
+func TestFlowsPatch_AddCardKeepsExistingCards(t *testing.T) {
+	h := useFakeHomey(t, &fakehomey.Seed{
+		AdvancedFlows: fakehomey.Objects{
+			"f1": {"id": "f1", "name": "Morning", "cards": map[string]interface{}{
+				"t1": map[string]interface{}{"type": "trigger", "id": "homey:manager:cron:time_exactly", "ownerUri": "homey:manager:cron", "args": map[string]interface{}{"time": "07:00"}, "x": 0, "y": 0},
+			}},
+		},
+	})
+	patch := writeFile(t, t.TempDir(), "patch.json", `[
+		{"op": "add", "path": "/cards/a1", "value": {"type": "action", "id": "homey:manager:notifications:create_notification", "args": {"text": "Good morning"}, "x": 420, "y": 0}}
+	]`)
+
+	out, err := runCommand(t, "flows", "patch", "Morning", patch, "--dry-run")
+	if !errors.Is(err, client.ErrDryRun) {
+		t.Fatalf("expected dry run, got %v", err)
+	}
+	if strings.Contains(out, "- /cards/t1") || !strings.Contains(out, "+ /cards/a1") {
+		t.Errorf("dry run should only add a1:\n%s", out)
+	}
+
+	if _, err := runCommand(t, "flows", "patch", "Morning", patch); err != nil {
+		t.Fatal(err)
+	}
+	cards := h.Object(fakehomey.AdvancedFlows, "f1")["cards"].(map[string]interface{})
+	t1, _ := cards["t1"].(map[string]interface{})
+	if t1 == nil || t1["id"] != "homey:manager:cron:time_exactly" {
+		t.Fatalf("existing trigger was lost: %v", cards)
+	}
+	if a1, _ := cards["a1"].(map[string]interface{}); a1 == nil || a1["ownerUri"] != "homey:manager:notifications" {
+		t.Errorf("expected the new card with its ownerUri, got %v", cards["a1"])
+	}
+}
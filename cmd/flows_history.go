@@ -0,0 +1,586 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/fishfisher/homeyctl/internal/config"
+	"github.com/fishfisher/homeyctl/internal/jsondiff"
+	"github.com/fishfisher/homeyctl/internal/jsonpatch"
+	"github.com/rodaine/table"
+	"github.com/spf13/cobra"
+)
+
+// flowVersion is one recorded state of a flow, taken before a change
+type flowVersion struct {
+	Version  int             `json:"version"`
+	FlowID   string          `json:"flowId"`
+	Name     string          `json:"name"`
+	Advanced bool            `json:"advanced"`
+	Action   string          `json:"action"`
+	Time     time.Time       `json:"time"`
+	Flow     json.RawMessage `json:"flow,omitempty"`
+}
+
+// flowHistoryRoot returns the directory holding the history of all flows.
+func flowHistoryRoot() (string, error) {
+	configDir, err := config.Dir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find config dir: %w", err)
+	}
+	return filepath.Join(configDir, "history", "flows"), nil
+}
+
+func flowHistoryDir(flowID string) (string, error) {
+	root, err := flowHistoryRoot()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, strings.NewReplacer("/", "_", "\\", "_").Replace(flowID)), nil
+}
+
+// loadFlowHistory reads all recorded versions of a flow, oldest first.
+func loadFlowHistory(flowID string) ([]flowVersion, error) {
+	dir, err := flowHistoryDir(flowID)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read flow history: %w", err)
+	}
+
+	var versions []flowVersion
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasPrefix(e.Name(), "v") || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read flow history: %w", err)
+		}
+		var v flowVersion
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, fmt.Errorf("invalid history file %s: %w", e.Name(), err)
+		}
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Version < versions[j].Version })
+	return versions, nil
+}
+
+// recordFlowVersion stores the flow's current state as the next version in
+// its history. A state identical to the latest version is not stored again.
+func recordFlowVersion(f *foundFlow, action string) (*flowVersion, error) {
+	versions, err := loadFlowHistory(f.ID)
+	if err != nil {
+		return nil, err
+	}
+	if n := len(versions); n > 0 && jsonpatch.Equal(versions[n-1].Flow, f.Raw) {
+		return &versions[n-1], nil
+	}
+
+	v := flowVersion{
+		Version:  1,
+		FlowID:   f.ID,
+		Name:     f.Name,
+		Advanced: f.Advanced,
+		Action:   action,
+		Time:     time.Now(),
+		Flow:     f.Raw,
+	}
+	if n := len(versions); n > 0 {
+		v.Version = versions[n-1].Version + 1
+	}
+
+	dir, err := flowHistoryDir(f.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create history dir: %w", err)
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("v%04d.json", v.Version))
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write flow history: %w", err)
+	}
+	return &v, nil
+}
+
+// recordFlowHistory records a version before a change. Failing to record
+// only warns, so the history never blocks the change itself. Nothing is
+// recorded in a dry run, since the change won't happen.
+func recordFlowHistory(f *foundFlow, action string) {
+	if dryRunFlag {
+		return
+	}
+	if _, err := recordFlowVersion(f, action); err != nil {
+		color.Yellow("Warning: could not record flow history: %v\n", err)
+	}
+}
+
+// findFlowHistory looks up a flow live and falls back to the history store,
+// so deleted flows can still be inspected and restored. live is nil when
+// the flow no longer exists.
+func findFlowHistory(nameOrID string) (live *foundFlow, flowID string, versions []flowVersion, err error) {
+	live, findErr := findFlow(nameOrID)
+	if findErr == nil {
+		versions, err = loadFlowHistory(live.ID)
+		return live, live.ID, versions, err
+	}
+
+	root, err := flowHistoryRoot()
+	if err != nil {
+		return nil, "", nil, err
+	}
+	entries, _ := os.ReadDir(root)
+	var latest *flowVersion
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		vs, err := loadFlowHistory(e.Name())
+		if err != nil || len(vs) == 0 {
+			continue
+		}
+		last := vs[len(vs)-1]
+		if last.FlowID != nameOrID && !strings.EqualFold(last.Name, nameOrID) {
+			continue
+		}
+		if latest == nil || last.Time.After(latest.Time) {
+			latest, versions = &last, vs
+		}
+	}
+	if latest == nil {
+		return nil, "", nil, findErr
+	}
+	return nil, latest.FlowID, versions, nil
+}
+
+// pickFlowVersion resolves "v3" or "3" to a recorded version.
+func pickFlowVersion(versions []flowVersion, spec string) (*flowVersion, error) {
+	n, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(spec), "v"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid version %q (use v3 or 3)", spec)
+	}
+	for i := range versions {
+		if versions[i].Version == n {
+			return &versions[i], nil
+		}
+	}
+	return nil, fmt.Errorf("version v%d not found", n)
+}
+
+// flowCardChange is one card-level difference between two flow states.
+// Card is empty for changes to the flow's own fields.
+type flowCardChange struct {
+	Card   string            `json:"card,omitempty"`
+	Label  string            `json:"label,omitempty"`
+	Kind   string            `json:"kind"`
+	Fields []jsondiff.Change `json:"fields,omitempty"`
+}
+
+// flowDiffCards splits a flow into its own fields and its cards keyed for
+// matching. Advanced flow cards keep their keys; simple flow cards are
+// keyed by kind and card ID, so reordering or regrouping shows as a change
+// to the card rather than as unrelated removals and additions.
+func flowDiffCards(raw json.RawMessage, advanced bool) (map[string]interface{}, map[string]interface{}, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse flow: %w", err)
+	}
+	cards := map[string]interface{}{}
+	if advanced {
+		if m, ok := doc["cards"].(map[string]interface{}); ok {
+			cards = m
+		}
+		delete(doc, "cards")
+	} else {
+		if t, ok := doc["trigger"]; ok && t != nil {
+			cards["trigger"] = t
+		}
+		for _, kind := range []string{"conditions", "actions"} {
+			list, _ := doc[kind].([]interface{})
+			seen := map[string]int{}
+			for _, item := range list {
+				card, _ := item.(map[string]interface{})
+				id, _ := card["id"].(string)
+				key := strings.TrimSuffix(kind, "s") + " " + id
+				seen[key]++
+				if seen[key] > 1 {
+					key = fmt.Sprintf("%s #%d", key, seen[key])
+				}
+				cards[key] = item
+			}
+		}
+		delete(doc, "trigger")
+		delete(doc, "conditions")
+		delete(doc, "actions")
+	}
+	delete(doc, "id")
+	return doc, cards, nil
+}
+
+func diffCardLabel(card interface{}, r *flowNameResolver) string {
+	data, _ := json.Marshal(card)
+	var c flowCardJSON
+	if json.Unmarshal(data, &c) != nil {
+		return ""
+	}
+	return flowCardLabel(c, r)
+}
+
+// diffFlowVersions compares two states of the same flow card by card.
+func diffFlowVersions(a, b json.RawMessage, advanced bool, r *flowNameResolver) ([]flowCardChange, error) {
+	fieldsA, cardsA, err := flowDiffCards(a, advanced)
+	if err != nil {
+		return nil, err
+	}
+	fieldsB, cardsB, err := flowDiffCards(b, advanced)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []flowCardChange
+	if fields := jsondiff.Diff(fieldsA, fieldsB); len(fields) > 0 {
+		changes = append(changes, flowCardChange{Kind: jsondiff.Changed, Fields: fields})
+	}
+
+	keys := map[string]bool{}
+	for k := range cardsA {
+		keys[k] = true
+	}
+	for k := range cardsB {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	for _, k := range sorted {
+		x, inA := cardsA[k]
+		y, inB := cardsB[k]
+		switch {
+		case !inA:
+			changes = append(changes, flowCardChange{Card: k, Label: diffCardLabel(y, r), Kind: jsondiff.Added})
+		case !inB:
+			changes = append(changes, flowCardChange{Card: k, Label: diffCardLabel(x, r), Kind: jsondiff.Removed})
+		default:
+			if fields := jsondiff.Diff(x, y); len(fields) > 0 {
+				changes = append(changes, flowCardChange{Card: k, Label: diffCardLabel(y, r), Kind: jsondiff.Changed, Fields: fields})
+			}
+		}
+	}
+	return changes, nil
+}
+
+func formatFlowDiff(changes []flowCardChange) string {
+	var b strings.Builder
+	for _, c := range changes {
+		if c.Card == "" {
+			for _, f := range c.Fields {
+				fmt.Fprintf(&b, "%s\n", f)
+			}
+			continue
+		}
+		symbol := map[string]string{jsondiff.Added: "+", jsondiff.Removed: "-"}[c.Kind]
+		if symbol == "" {
+			symbol = "~"
+		}
+		fmt.Fprintf(&b, "%s card %s: %s\n", symbol, c.Card, c.Label)
+		for _, f := range c.Fields {
+			fmt.Fprintf(&b, "    %s\n", f)
+		}
+	}
+	return b.String()
+}
+
+// restoreFlowFields are the flow fields a rollback writes back
+var restoreFlowFields = map[bool][]string{
+	false: {"name", "enabled", "folder", "trigger", "conditions", "actions"},
+	true:  {"name", "enabled", "folder", "cards"},
+}
+
+// restoreFlowBody builds the create/update body that restores a snapshot.
+// Read-only fields like id and broken are left out; folder is always sent so
+// a flow moved since the snapshot goes back to its old folder.
+func restoreFlowBody(v *flowVersion) (map[string]interface{}, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(v.Flow, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse version v%d: %w", v.Version, err)
+	}
+	body := map[string]interface{}{}
+	for _, field := range restoreFlowFields[v.Advanced] {
+		if value, ok := doc[field]; ok {
+			body[field] = value
+		}
+	}
+	if _, ok := body["folder"]; !ok {
+		body["folder"] = nil
+	}
+	return body, nil
+}
+
+var flowsHistoryCmd = &cobra.Command{
+	Use:   "history <name-or-id>",
+	Short: "List recorded versions of a flow",
+	Long: `List the versions recorded for a flow.
+
+A version is recorded automatically before every change made with homeyctl:
+flows update, patch, move, delete and rollback. Versions are stored in the
+homeyctl config directory under history/flows/. Deleted flows can still be
+looked up by name or ID.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		live, flowID, versions, err := findFlowHistory(args[0])
+		if err != nil {
+			return err
+		}
+
+		if isJSON() {
+			list := make([]flowVersion, len(versions))
+			for i, v := range versions {
+				v.Flow = nil
+				list[i] = v
+			}
+			out, _ := json.MarshalIndent(list, "", "  ")
+			fmt.Println(string(out))
+			return nil
+		}
+
+		if len(versions) == 0 {
+			fmt.Println("No versions recorded.")
+			return nil
+		}
+
+		headerFmt := color.New(color.FgCyan, color.Underline).SprintfFunc()
+		tbl := table.New("Version", "Recorded", "Before", "Name")
+		tbl.WithHeaderFormatter(headerFmt)
+		for _, v := range versions {
+			tbl.AddRow(fmt.Sprintf("v%d", v.Version), v.Time.Local().Format("2006-01-02 15:04:05"), v.Action, v.Name)
+		}
+		tbl.Print()
+
+		if live == nil {
+			color.Yellow("\nFlow %s no longer exists; restore it with: homeyctl flows rollback %s --to v%d\n", flowID, flowID, versions[len(versions)-1].Version)
+		}
+		return nil
+	},
+}
+
+var flowsDiffCmd = &cobra.Command{
+	Use:   "diff <name-or-id> [v1] [v2]",
+	Short: "Show card-level changes between flow versions",
+	Long: `Compare two states of a flow card by card.
+
+With no versions, compares the latest recorded version with the live flow.
+With one version, compares it with the live flow. Use "current" to name the
+live flow explicitly.
+
+Examples:
+  homeyctl flows diff "Evening lights"
+  homeyctl flows diff "Evening lights" v2
+  homeyctl flows diff "Evening lights" v2 v4`,
+	Args: cobra.RangeArgs(1, 3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		live, _, versions, err := findFlowHistory(args[0])
+		if err != nil {
+			return err
+		}
+		if len(versions) == 0 {
+			return fmt.Errorf("no versions recorded for flow %s", args[0])
+		}
+
+		specs := args[1:]
+		if len(specs) == 0 {
+			specs = []string{fmt.Sprintf("v%d", versions[len(versions)-1].Version)}
+		}
+		if len(specs) == 1 {
+			specs = append(specs, "current")
+		}
+
+		var states [2]json.RawMessage
+		var names [2]string
+		advanced := versions[0].Advanced
+		for i, spec := range specs {
+			if strings.EqualFold(spec, "current") {
+				if live == nil {
+					return fmt.Errorf("flow no longer exists; compare two recorded versions instead")
+				}
+				states[i], names[i] = live.Raw, "current"
+				continue
+			}
+			v, err := pickFlowVersion(versions, spec)
+			if err != nil {
+				return err
+			}
+			states[i], names[i] = v.Flow, fmt.Sprintf("v%d", v.Version)
+		}
+
+		r, err := newFlowNameResolver()
+		if err != nil {
+			r = &flowNameResolver{}
+		}
+		changes, err := diffFlowVersions(states[0], states[1], advanced, r)
+		if err != nil {
+			return err
+		}
+
+		if isJSON() {
+			out, _ := json.MarshalIndent(map[string]interface{}{"from": names[0], "to": names[1], "changes": changes}, "", "  ")
+			fmt.Println(string(out))
+			return nil
+		}
+		if len(changes) == 0 {
+			fmt.Printf("No changes between %s and %s.\n", names[0], names[1])
+			return nil
+		}
+		fmt.Printf("%s → %s\n", names[0], names[1])
+		fmt.Print(formatFlowDiff(changes))
+		return nil
+	},
+}
+
+var flowsRollbackCmd = &cobra.Command{
+	Use:   "rollback <name-or-id>",
+	Short: "Restore a flow to a recorded version",
+	Long: `Restore a flow to a recorded version.
+
+The current state is recorded first, so a rollback can itself be undone.
+If the flow was deleted, it is created again from the version; the new flow
+gets a new ID and keeps its history.
+
+Examples:
+  homeyctl flows rollback "Evening lights" --to v3`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		to, _ := cmd.Flags().GetString("to")
+		if to == "" {
+			return fmt.Errorf("--to is required (see flows history)")
+		}
+
+		live, flowID, versions, err := findFlowHistory(args[0])
+		if err != nil {
+			return err
+		}
+		v, err := pickFlowVersion(versions, to)
+		if err != nil {
+			return err
+		}
+		body, err := restoreFlowBody(v)
+		if err != nil {
+			return err
+		}
+		if err := validateFlow(body, v.Advanced); err != nil {
+			return fmt.Errorf("version v%d cannot be restored: %w", v.Version, err)
+		}
+
+		if live != nil {
+			recordFlowHistory(live, "rollback")
+			if v.Advanced {
+				_, err = apiClient.UpdateAdvancedFlow(live.ID, body)
+			} else {
+				_, err = apiClient.UpdateFlow(live.ID, body)
+			}
+			if err != nil {
+				return err
+			}
+			color.Green("Rolled back flow %s to v%d\n", live.Name, v.Version)
+			return nil
+		}
+
+		var result json.RawMessage
+		if v.Advanced {
+			result, err = apiClient.CreateAdvancedFlow(body)
+		} else {
+			result, err = apiClient.CreateFlow(body)
+		}
+		if err != nil {
+			return err
+		}
+		var created struct {
+			ID string `json:"id"`
+		}
+		json.Unmarshal(result, &created)
+
+		if created.ID != "" && created.ID != flowID {
+			oldDir, _ := flowHistoryDir(flowID)
+			newDir, _ := flowHistoryDir(created.ID)
+			if err := os.Rename(oldDir, newDir); err != nil {
+				color.Yellow("Warning: could not move flow history: %v\n", err)
+			}
+		}
+		color.Green("Restored deleted flow %s from v%d (ID: %s)\n", v.Name, v.Version, created.ID)
+		return nil
+	},
+}
+
+var flowsMoveCmd = &cobra.Command{
+	Use:   "move <name-or-id> [folder]",
+	Short: "Move a flow to a folder",
+	Long: `Move a flow to a flow folder, or out of any folder with --root.
+
+Examples:
+  homeyctl flows move "Evening lights" "Lighting"
+  homeyctl flows move "Evening lights" --root`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		root, _ := cmd.Flags().GetBool("root")
+		if root == (len(args) == 2) {
+			return fmt.Errorf("provide a folder or --root")
+		}
+
+		f, err := findFlow(args[0])
+		if err != nil {
+			return err
+		}
+
+		update := map[string]interface{}{"folder": nil}
+		target := "top level"
+		if !root {
+			folder, err := findFlowFolder(args[1])
+			if err != nil {
+				return err
+			}
+			update["folder"] = folder.ID
+			target = folder.Name
+		}
+
+		recordFlowHistory(f, "move")
+		if f.Advanced {
+			_, err = apiClient.UpdateAdvancedFlow(f.ID, update)
+		} else {
+			_, err = apiClient.UpdateFlow(f.ID, update)
+		}
+		if err != nil {
+			return err
+		}
+		color.Green("Moved flow %s to %s\n", f.Name, target)
+		return nil
+	},
+}
+
+func init() {
+	flowsCmd.AddCommand(flowsHistoryCmd)
+	flowsCmd.AddCommand(flowsDiffCmd)
+	flowsCmd.AddCommand(flowsRollbackCmd)
+	flowsRollbackCmd.Flags().String("to", "", "Version to restore, e.g. v3")
+	flowsCmd.AddCommand(flowsMoveCmd)
+	flowsMoveCmd.Flags().Bool("root", false, "Move the flow out of any folder")
+}
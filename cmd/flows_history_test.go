@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/fishfisher/homeyctl/internal/config"
+	"github.com/fishfisher/homeyctl/internal/fakehomey"
+)
+
+func TestRecordFlowVersion(t *testing.T) {
+	t.Setenv(config.DirEnv, t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	f := &foundFlow{ID: "f1", Name: "Heat", Advanced: true, Raw: json.RawMessage(testAdvancedFlow)}
+	v, err := recordFlowVersion(f, "update")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v.Version != 1 {
+		t.Errorf("expected v1, got v%d", v.Version)
+	}
+
+	// An unchanged state is not recorded twice
+	if v, _ := recordFlowVersion(f, "update"); v.Version != 1 {
+		t.Errorf("expected duplicate state to reuse v1, got v%d", v.Version)
+	}
+
+	f.Raw = json.RawMessage(strings.Replace(testAdvancedFlow, `"comparator":20`, `"comparator":21`, 1))
+	if v, _ := recordFlowVersion(f, "patch"); v.Version != 2 {
+		t.Errorf("expected v2, got v%d", v.Version)
+	}
+
+	versions, err := loadFlowHistory("f1")
+	if err != nil || len(versions) != 2 {
+		t.Fatalf("expected 2 versions, got %d (%v)", len(versions), err)
+	}
+	if versions[1].Action != "patch" || !versions[1].Advanced {
+		t.Errorf("unexpected version: %+v", versions[1])
+	}
+	if _, err := pickFlowVersion(versions, "v2"); err != nil {
+		t.Errorf("expected v2 to resolve: %v", err)
+	}
+	if _, err := pickFlowVersion(versions, "7"); err == nil {
+		t.Error("expected error for unknown version")
+	}
+}
+
+func TestDiffFlowVersions_Advanced(t *testing.T) {
+	before := json.RawMessage(testAdvancedFlow)
+	var doc map[string]interface{}
+	json.Unmarshal(before, &doc)
+	cards := doc["cards"].(map[string]interface{})
+	cards["c"].(map[string]interface{})["args"] = map[string]interface{}{"comparator": 18}
+	delete(cards, "n")
+	cards["dim"] = map[string]interface{}{"type": "action", "id": "homey:device:lamp-1:dim", "args": map[string]interface{}{"dim": 0.5}}
+	doc["name"] = "Heating"
+	after, _ := json.Marshal(doc)
+
+	changes, err := diffFlowVersions(before, after, true, testFlowResolver())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := formatFlowDiff(changes)
+	want := `~ /name: "Heat" → "Heating"
+~ card c: "Hall sensor".measure_temperature < 18
+    ~ /args/comparator: 20 → 18
++ card dim: "homey:device:lamp-1:dim"(dim: 0.5)
+- card n: note {text: "hi"}
+`
+	if got != want {
+		t.Errorf("unexpected diff:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestDiffFlowVersions_SimpleMatchesCardsByID(t *testing.T) {
+	before := json.RawMessage(`{"id":"s1","name":"Door","trigger":{"id":"homey:manager:presence:user_enter"},
+		"actions":[{"id":"homey:device:lamp-1:on","group":"then","args":{}},{"id":"homey:device:heater-1:on","group":"then","args":{}}]}`)
+	after := json.RawMessage(`{"id":"s1","name":"Door","trigger":{"id":"homey:manager:presence:user_enter"},
+		"actions":[{"id":"homey:device:heater-1:on","group":"else","args":{}},{"id":"homey:device:lamp-1:on","group":"then","args":{}}]}`)
+
+	changes, err := diffFlowVersions(before, after, false, testFlowResolver())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Card != "action homey:device:heater-1:on" || changes[0].Fields[0].Path != "/group" {
+		t.Errorf("expected only the regrouped heater action, got %+v", changes)
+	}
+}
+
+func TestRestoreFlowBody(t *testing.T) {
+	v := &flowVersion{Version: 3, Advanced: true, Flow: json.RawMessage(testAdvancedFlow)}
+	body, err := restoreFlowBody(v)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := body["id"]; ok {
+		t.Error("expected id to be stripped")
+	}
+	if folder, ok := body["folder"]; !ok || folder != nil {
+		t.Errorf("expected folder to be reset to top level, got %v", folder)
+	}
+	if err := validateFlow(body, true); err != nil {
+		t.Errorf("restore body is invalid: %v", err)
+	}
+}
+
+func TestFlowsHistoryCommands_Exist(t *testing.T) {
+	for _, name := range []string{"history", "diff", "rollback", "move"} {
+		if _, _, err := flowsCmd.Find([]string{name}); err != nil {
+			t.Errorf("%s command not found: %v", name, err)
+		}
+	}
+	if flowsRollbackCmd.Flags().Lookup("to") == nil {
+		t.Error("expected --to flag on flows rollback")
+	}
+}
+
+func TestFlowsRollback_RemovesCardsAddedLater(t *testing.T) {
+	trigger := map[string]interface{}{"type": "trigger", "id": "homey:manager:cron:time_exactly", "ownerUri": "homey:manager:cron", "args": map[string]interface{}{"time": "07:00"}, "x": 0, "y": 0}
+	h := useFakeHomey(t, &fakehomey.Seed{
+		AdvancedFlows: fakehomey.Objects{
+			"f1": {"id": "f1", "name": "Morning", "cards": map[string]interface{}{"t1": trigger}},
+		},
+	})
+
+	// v1 is the flow before this update adds a1
+	update, _ := json.Marshal(map[string]interface{}{"cards": map[string]interface{}{
+		"t1": trigger,
+		"a1": map[string]interface{}{"type": "action", "id": "homey:manager:notifications:create_notification", "ownerUri": "homey:manager:notifications", "args": map[string]interface{}{"text": "Hi"}, "x": 420, "y": 0},
+	}})
+	if _, err := runCommand(t, "flows", "update", "Morning", "--data", string(update)); err != nil {
+		t.Fatal(err)
+	}
+	if cards := h.Object(fakehomey.AdvancedFlows, "f1")["cards"].(map[string]interface{}); len(cards) != 2 {
+		t.Fatalf("expected the update to add a1, got %v", cards)
+	}
+
+	if _, err := runCommand(t, "flows", "rollback", "Morning", "--to", "v1"); err != nil {
+		t.Fatal(err)
+	}
+	cards := h.Object(fakehomey.AdvancedFlows, "f1")["cards"].(map[string]interface{})
+	if _, ok := cards["a1"]; ok || cards["t1"] == nil || len(cards) != 1 {
+		t.Errorf("expected only t1 after rolling back to v1, got %v", cards)
+	}
+}
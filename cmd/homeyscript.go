@@ -23,7 +23,9 @@ var homeyscriptCmd = &cobra.Command{
 	Use:     "homeyscript",
 	Aliases: []string{"hs"},
 	Short:   "Manage HomeyScript scripts",
-	Long:    `List, view, create, update, delete, and run HomeyScript scripts.`,
+	Long: `List, view, create, update, delete, and run HomeyScript scripts.
+
+Use pull, push and watch to edit scripts as local .js files.`,
 }
 
 func findHomeyScript(nameOrID string) (*HomeyScript, error) {
@@ -217,7 +219,15 @@ var homeyscriptDeleteCmd = &cobra.Command{
 var homeyscriptRunCmd = &cobra.Command{
 	Use:   "run <name-or-id> [args...]",
 	Short: "Run a script",
-	Args:  cobra.MinimumNArgs(1),
+	Long: `Run a script and show its console output, return value and error.
+
+The command exits with a non-zero status when the script throws, so it can
+be used in shell scripts and CI. With --json the raw response is printed.
+
+Examples:
+  homeyctl homeyscript run "Morning report"
+  homeyctl homeyscript run sonos_enqueue_play "192.168.68.129|playlist-uri"`,
+	Args: cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		script, err := findHomeyScript(args[0])
 		if err != nil {
@@ -234,17 +244,127 @@ var homeyscriptRunCmd = &cobra.Command{
 			return err
 		}
 
+		result := parseHomeyScriptRun(data)
 		if isJSON() {
 			outputJSON(data)
-			return nil
+		} else {
+			printHomeyScriptRun(result)
+		}
+		// The error is already in the output; only the exit status is left
+		if !result.Success {
+			cmd.SilenceUsage = true
+			return errFalse
 		}
-
-		// Print the run result
-		fmt.Println(string(data))
 		return nil
 	},
 }
 
+// homeyScriptRun is the outcome of running a script. HomeyScript versions
+// differ in how they name the fields, so they are picked out of the raw
+// response by parseHomeyScriptRun.
+type homeyScriptRun struct {
+	Success bool
+	Console []string
+	Returns json.RawMessage
+	Message string
+	Stack   string
+}
+
+func parseHomeyScriptRun(data json.RawMessage) *homeyScriptRun {
+	run := &homeyScriptRun{Success: true}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		run.Returns = data
+		return run
+	}
+
+	if raw, ok := fields["success"]; ok {
+		json.Unmarshal(raw, &run.Success)
+	}
+	for _, key := range []string{"returns", "returnValue", "result"} {
+		if raw, ok := fields[key]; ok {
+			run.Returns = raw
+			break
+		}
+	}
+	for _, key := range []string{"console", "logs", "stdout"} {
+		raw, ok := fields[key]
+		if !ok {
+			continue
+		}
+		var lines []string
+		var text string
+		if json.Unmarshal(raw, &lines) == nil {
+			run.Console = lines
+		} else if json.Unmarshal(raw, &text) == nil && text != "" {
+			run.Console = strings.Split(strings.TrimRight(text, "\n"), "\n")
+		}
+		break
+	}
+
+	// Errors come either as a separate field or, when success is false, as
+	// the returned value
+	errRaw, hasErr := fields["error"]
+	if !hasErr && !run.Success {
+		errRaw, hasErr = run.Returns, true
+		run.Returns = nil
+	}
+	if hasErr && string(errRaw) != "null" {
+		run.Success = false
+		var errObj struct {
+			Message string `json:"message"`
+			Stack   string `json:"stack"`
+		}
+		var text string
+		if json.Unmarshal(errRaw, &text) == nil {
+			run.Message = text
+		} else if json.Unmarshal(errRaw, &errObj) == nil {
+			run.Message, run.Stack = errObj.Message, errObj.Stack
+		}
+		if run.Message == "" {
+			run.Message = strings.TrimSpace(string(errRaw))
+		}
+	}
+	return run
+}
+
+func printHomeyScriptRun(run *homeyScriptRun) {
+	section := color.New(color.FgCyan, color.Bold)
+	if len(run.Console) > 0 {
+		section.Println("Console")
+		for _, line := range run.Console {
+			fmt.Println("  " + line)
+		}
+	}
+
+	if len(run.Returns) > 0 && string(run.Returns) != "null" {
+		section.Println("Returned")
+		var v interface{}
+		if json.Unmarshal(run.Returns, &v) == nil {
+			if s, ok := v.(string); ok {
+				fmt.Println("  " + s)
+			} else {
+				out, _ := json.MarshalIndent(v, "  ", "  ")
+				fmt.Println("  " + string(out))
+			}
+		}
+	}
+
+	if !run.Success {
+		color.New(color.FgRed, color.Bold).Println("Error")
+		color.Red("  %s\n", run.Message)
+		if stack := strings.TrimSpace(run.Stack); stack != "" {
+			for _, line := range strings.Split(stack, "\n") {
+				color.New(color.Faint).Println("  " + strings.TrimSpace(line))
+			}
+		}
+		return
+	}
+	if len(run.Console) == 0 && (len(run.Returns) == 0 || string(run.Returns) == "null") {
+		color.Green("Script finished without output\n")
+	}
+}
+
 var homeyscriptCreateFlowCmd = &cobra.Command{
 	Use:   "create-flow <script-name-or-id>",
 	Short: "Create a simple flow that runs a HomeyScript",
@@ -0,0 +1,236 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/fishfisher/homeyctl/internal/fakehomey"
+	"github.com/fishfisher/homeyctl/internal/homeyscript"
+	"github.com/fishfisher/homeyctl/internal/jsondiff"
+	"github.com/spf13/cobra"
+)
+
+// scriptExpectations are the parts of a test run a script author wants to
+// pin down. Only the fields present in the file are checked, and the file
+// has the same shape as the --json output of homeyscript test.
+type scriptExpectations struct {
+	Logs    *[]string           `json:"logs"`
+	Returns json.RawMessage     `json:"returns"`
+	Error   *string             `json:"error"`
+	Calls   *[]homeyscript.Call `json:"calls"`
+	Tags    *[]homeyscript.Call `json:"tags"`
+	Changes *[]string           `json:"changes"`
+}
+
+type expectationResult struct {
+	Field string
+	Diff  []jsondiff.Change
+}
+
+func checkScriptExpectations(exp *scriptExpectations, result *homeyscript.Result) []expectationResult {
+	var results []expectationResult
+	check := func(field string, present bool, want, got interface{}) {
+		if present {
+			results = append(results, expectationResult{Field: field, Diff: jsondiff.Diff(want, got)})
+		}
+	}
+	check("logs", exp.Logs != nil, exp.Logs, result.Logs)
+	check("returns", exp.Returns != nil, exp.Returns, result.Returns)
+	check("error", exp.Error != nil, exp.Error, result.Error)
+	check("calls", exp.Calls != nil, exp.Calls, result.Calls)
+	check("tags", exp.Tags != nil, exp.Tags, result.Tags)
+	check("changes", exp.Changes != nil, exp.Changes, result.Changes)
+	return results
+}
+
+// liveScriptSeed reads the state scripts usually look at from the Homey.
+// Only GET requests are made.
+func liveScriptSeed() (*fakehomey.Seed, error) {
+	seed := &fakehomey.Seed{}
+	sources := []struct {
+		name  string
+		fetch func() (json.RawMessage, error)
+		into  *fakehomey.Objects
+	}{
+		{"devices", apiClient.GetDevices, &seed.Devices},
+		{"zones", apiClient.GetZones, &seed.Zones},
+		{"flows", apiClient.GetFlows, &seed.Flows},
+		{"advanced flows", apiClient.GetAdvancedFlows, &seed.AdvancedFlows},
+		{"variables", apiClient.GetVariables, &seed.Variables},
+		{"notifications", apiClient.GetNotifications, &seed.Notifications},
+	}
+	for _, src := range sources {
+		data, err := src.fetch()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s: %w", src.name, err)
+		}
+		if err := json.Unmarshal(data, src.into); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", src.name, err)
+		}
+	}
+	return seed, nil
+}
+
+var homeyscriptTestCmd = &cobra.Command{
+	Use:   "test <file.js>",
+	Short: "Run a script locally against a mocked Homey",
+	Long: `Run a script locally in an embedded JavaScript engine, without uploading it.
+
+The Homey global is served by an in-memory fake Homey. Its state comes from
+a fixture file (--fixture, in the format of 'homeyctl dev fake-homey' seeds
+or 'homeyctl snapshot --json --include-flows') or from a read-only snapshot
+of your Homey (--live). Scripts can read devices, zones, flows, variables
+and notifications; calls that would change something (setCapabilityValue,
+updateVariable, triggerFlow, runFlowCardAction, createNotification, say)
+are applied to the fake Homey only and listed afterwards, together with the
+resulting state changes.
+
+Supported: Homey.devices, Homey.zones, Homey.flow, Homey.logic and
+Homey.notifications, plus log, console, args, tag, say, wait and global.
+wait() resolves immediately.
+
+With --expect, the run is compared to a JSON file with any of the keys
+logs, returns, error, calls, tags and changes. Save the --json output of a
+good run to get started. The command exits non-zero when the script throws
+(unless an expected error matches) or an expectation does not hold.
+
+Examples:
+  homeyctl homeyscript test cold.js --fixture house.json
+  homeyctl homeyscript test cold.js --live --arg hall
+  homeyctl homeyscript test cold.js --fixture house.json --json > cold.expect.json
+  homeyctl homeyscript test cold.js --fixture house.json --expect cold.expect.json`,
+	Args: cobra.ExactArgs(1),
+	// Fixtures need no Homey, so only load config for --live
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if live, _ := cmd.Flags().GetBool("live"); live {
+			return rootCmd.PersistentPreRunE(cmd, args)
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fixture, _ := cmd.Flags().GetString("fixture")
+		live, _ := cmd.Flags().GetBool("live")
+		expectPath, _ := cmd.Flags().GetString("expect")
+		scriptArgs, _ := cmd.Flags().GetStringArray("arg")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+
+		if (fixture == "") == !live {
+			return fmt.Errorf("use either --fixture <file> or --live")
+		}
+
+		code, err := os.ReadFile(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to read script: %w", err)
+		}
+
+		var expectations *scriptExpectations
+		if expectPath != "" {
+			data, err := os.ReadFile(expectPath)
+			if err != nil {
+				return fmt.Errorf("failed to read expectations: %w", err)
+			}
+			expectations = &scriptExpectations{}
+			if err := json.Unmarshal(data, expectations); err != nil {
+				return fmt.Errorf("invalid expectations %s: %w", expectPath, err)
+			}
+		}
+
+		var seed *fakehomey.Seed
+		if live {
+			if seed, err = liveScriptSeed(); err != nil {
+				return err
+			}
+		} else if seed, err = fakehomey.LoadSeed(fixture); err != nil {
+			return err
+		}
+
+		result, err := homeyscript.Run(filepath.Base(args[0]), string(code), seed, homeyscript.Options{Args: scriptArgs, Timeout: timeout})
+		if err != nil {
+			return err
+		}
+
+		var checks []expectationResult
+		if expectations != nil {
+			checks = checkScriptExpectations(expectations, result)
+		}
+		failed := 0
+		for _, c := range checks {
+			if len(c.Diff) > 0 {
+				failed++
+			}
+		}
+
+		if isJSON() {
+			out, _ := json.MarshalIndent(result, "", "  ")
+			fmt.Println(string(out))
+		} else {
+			printScriptTestResult(result, checks)
+		}
+
+		expectedError := expectations != nil && expectations.Error != nil
+		switch {
+		case failed > 0:
+			return fmt.Errorf("%d expectation(s) not met", failed)
+		case result.Failed() && !expectedError:
+			return fmt.Errorf("script failed: %s", result.Error)
+		}
+		return nil
+	},
+}
+
+func printScriptTestResult(result *homeyscript.Result, checks []expectationResult) {
+	run := &homeyScriptRun{Success: !result.Failed(), Console: result.Logs, Message: result.Error, Stack: result.Stack}
+	if result.Returns != nil {
+		run.Returns, _ = json.Marshal(result.Returns)
+	}
+	printHomeyScriptRun(run)
+
+	section := color.New(color.FgCyan, color.Bold)
+	if len(result.Calls) > 0 {
+		section.Printf("Calls (%d)\n", len(result.Calls))
+		for _, c := range result.Calls {
+			fmt.Println("  " + c.String())
+		}
+	}
+	if len(result.Tags) > 0 {
+		section.Println("Tags")
+		for _, c := range result.Tags {
+			out, _ := json.Marshal(c.Args)
+			fmt.Printf("  %s = %s\n", c.API, out)
+		}
+	}
+	if len(result.Changes) > 0 {
+		section.Println("State changes")
+		for _, c := range result.Changes {
+			fmt.Println("  " + c)
+		}
+	}
+
+	if len(checks) > 0 {
+		section.Println("Expectations")
+		for _, c := range checks {
+			if len(c.Diff) == 0 {
+				color.Green("  ✓ %s\n", c.Field)
+				continue
+			}
+			color.Red("  ✗ %s\n", c.Field)
+			for _, d := range c.Diff {
+				fmt.Println("      " + d.String())
+			}
+		}
+	}
+	fmt.Printf("\nFinished in %s\n", result.Elapsed.Round(time.Millisecond))
+}
+
+func init() {
+	homeyscriptCmd.AddCommand(homeyscriptTestCmd)
+	homeyscriptTestCmd.Flags().String("fixture", "", "JSON file with the house to run against")
+	homeyscriptTestCmd.Flags().Bool("live", false, "Run against a read-only snapshot of your Homey")
+	homeyscriptTestCmd.Flags().String("expect", "", "JSON file with expected logs, returns, error, calls, tags or changes")
+	homeyscriptTestCmd.Flags().StringArray("arg", nil, "Argument passed to the script in args (repeatable)")
+	homeyscriptTestCmd.Flags().Duration("timeout", 10*time.Second, "Stop scripts that run longer than this")
+}
@@ -0,0 +1,491 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cobra"
+)
+
+// homeyScriptManifestFile is kept in a synced directory and maps local
+// files to scripts on the Homey
+const homeyScriptManifestFile = ".homeyscript.json"
+
+// homeyScriptEntry is one synced script. Version is the script version the
+// local file is based on; Hash is the SHA-256 of the code at the last pull
+// or push, so local edits can be told apart from untouched files.
+type homeyScriptEntry struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	File    string `json:"file"`
+	Version int    `json:"version"`
+	Hash    string `json:"hash"`
+}
+
+type homeyScriptManifest struct {
+	Scripts []homeyScriptEntry `json:"scripts"`
+}
+
+func loadHomeyScriptManifest(dir string) (*homeyScriptManifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, homeyScriptManifestFile))
+	if os.IsNotExist(err) {
+		return &homeyScriptManifest{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	var m homeyScriptManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", filepath.Join(dir, homeyScriptManifestFile), err)
+	}
+	return &m, nil
+}
+
+func (m *homeyScriptManifest) save(dir string) error {
+	sort.Slice(m.Scripts, func(i, j int) bool { return m.Scripts[i].File < m.Scripts[j].File })
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, homeyScriptManifestFile), append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+func (m *homeyScriptManifest) byFile(file string) *homeyScriptEntry {
+	for i := range m.Scripts {
+		if m.Scripts[i].File == file {
+			return &m.Scripts[i]
+		}
+	}
+	return nil
+}
+
+func (m *homeyScriptManifest) byID(id string) *homeyScriptEntry {
+	for i := range m.Scripts {
+		if m.Scripts[i].ID == id {
+			return &m.Scripts[i]
+		}
+	}
+	return nil
+}
+
+func hashScript(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// scriptFileName turns a script name into a file name that is safe on all
+// platforms
+func scriptFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|':
+			return '_'
+		}
+		if r < 32 {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	if name == "" || name == "." || name == ".." {
+		name = "script"
+	}
+	return name + ".js"
+}
+
+func fetchHomeyScripts() ([]HomeyScript, error) {
+	data, err := apiClient.GetHomeyScripts()
+	if err != nil {
+		return nil, err
+	}
+	var byID map[string]HomeyScript
+	if err := json.Unmarshal(data, &byID); err != nil {
+		return nil, fmt.Errorf("failed to parse scripts: %w", err)
+	}
+	scripts := make([]HomeyScript, 0, len(byID))
+	for _, s := range byID {
+		scripts = append(scripts, s)
+	}
+	sort.Slice(scripts, func(i, j int) bool { return scripts[i].Name < scripts[j].Name })
+	return scripts, nil
+}
+
+func fetchHomeyScript(id string) (*HomeyScript, error) {
+	data, err := apiClient.GetHomeyScript(id)
+	if err != nil {
+		return nil, err
+	}
+	var s HomeyScript
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse script: %w", err)
+	}
+	return &s, nil
+}
+
+// pullHomeyScripts writes every script to dir. Files with local changes
+// that were never pushed are left alone unless force is set.
+func pullHomeyScripts(dir string, force bool) (pulled, skipped int, err error) {
+	scripts, err := fetchHomeyScripts()
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return 0, 0, fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	manifest, err := loadHomeyScriptManifest(dir)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	remote := map[string]bool{}
+	taken := map[string]string{}
+	for _, e := range manifest.Scripts {
+		taken[e.File] = e.ID
+	}
+
+	for _, s := range scripts {
+		remote[s.ID] = true
+		entry := manifest.byID(s.ID)
+		if entry == nil {
+			file := scriptFileName(s.Name)
+			if owner, ok := taken[file]; ok && owner != s.ID {
+				file = strings.TrimSuffix(file, ".js") + "-" + shortID(s.ID) + ".js"
+			}
+			taken[file] = s.ID
+			manifest.Scripts = append(manifest.Scripts, homeyScriptEntry{ID: s.ID, File: file})
+			entry = &manifest.Scripts[len(manifest.Scripts)-1]
+		}
+
+		path := filepath.Join(dir, entry.File)
+		if local, err := os.ReadFile(path); err == nil && !force {
+			localHash := hashScript(string(local))
+			if localHash == hashScript(s.Code) {
+				entry.Name, entry.Version, entry.Hash = s.Name, s.Version, localHash
+				continue
+			}
+			if entry.Hash == "" || localHash != entry.Hash {
+				color.Yellow("Skipped %s: it has local changes (push them, or pull with --force to overwrite)\n", entry.File)
+				skipped++
+				continue
+			}
+		}
+
+		if err := os.WriteFile(path, []byte(s.Code), 0o644); err != nil {
+			return pulled, skipped, fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		entry.Name, entry.Version, entry.Hash = s.Name, s.Version, hashScript(s.Code)
+		fmt.Printf("Pulled %s (v%d)\n", entry.File, s.Version)
+		pulled++
+	}
+
+	kept := manifest.Scripts[:0]
+	for _, e := range manifest.Scripts {
+		if remote[e.ID] {
+			kept = append(kept, e)
+		} else {
+			color.Yellow("%s was deleted on the Homey; the local file is kept but no longer synced\n", e.File)
+		}
+	}
+	manifest.Scripts = kept
+
+	return pulled, skipped, manifest.save(dir)
+}
+
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+type pushResult int
+
+const (
+	pushUnchanged pushResult = iota
+	pushUpdated
+	pushCreated
+	pushConflict
+)
+
+// pushHomeyScript uploads one file from dir. A file is only uploaded when it
+// changed since the last sync, and only if the script on the Homey is still
+// at the version the file was based on; force skips that version check but
+// never re-uploads an unchanged file. New files create new scripts named
+// after the file. The manifest is only updated once the upload succeeded.
+func pushHomeyScript(dir string, manifest *homeyScriptManifest, file string, force bool) (pushResult, error) {
+	code, err := os.ReadFile(filepath.Join(dir, file))
+	if err != nil {
+		return pushUnchanged, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	hash := hashScript(string(code))
+
+	entry := manifest.byFile(file)
+	if entry == nil {
+		name := strings.TrimSuffix(file, ".js")
+		data, err := apiClient.CreateHomeyScript(name, string(code))
+		if err != nil {
+			return pushUnchanged, fmt.Errorf("failed to create %s: %w", name, err)
+		}
+		var created HomeyScript
+		if err := json.Unmarshal(data, &created); err != nil {
+			return pushUnchanged, fmt.Errorf("failed to parse response: %w", err)
+		}
+		manifest.Scripts = append(manifest.Scripts, homeyScriptEntry{ID: created.ID, Name: created.Name, File: file, Version: created.Version, Hash: hash})
+		return pushCreated, nil
+	}
+
+	if entry.Hash == hash {
+		return pushUnchanged, nil
+	}
+
+	remote, err := fetchHomeyScript(entry.ID)
+	if err != nil {
+		return pushUnchanged, err
+	}
+	version := entry.Version
+	if remote.Version != entry.Version {
+		if !force {
+			return pushConflict, fmt.Errorf("%s: the script on the Homey is at v%d but %s is based on v%d", entry.Name, remote.Version, file, entry.Version)
+		}
+		version = remote.Version
+	}
+
+	data, err := apiClient.UpdateHomeyScript(entry.ID, entry.Name, string(code), version)
+	if err != nil {
+		return pushUnchanged, fmt.Errorf("failed to update %s: %w", entry.Name, err)
+	}
+	var updated HomeyScript
+	if err := json.Unmarshal(data, &updated); err == nil && updated.Version > version {
+		entry.Version = updated.Version
+	} else {
+		entry.Version = version + 1
+	}
+	entry.Hash = hash
+	return pushUpdated, nil
+}
+
+func listScriptFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".js") {
+			files = append(files, e.Name())
+		}
+	}
+	return files, nil
+}
+
+func reportPush(file string, result pushResult, entry *homeyScriptEntry) {
+	switch result {
+	case pushCreated:
+		color.Green("Created %s (%s)\n", entry.Name, file)
+	case pushUpdated:
+		color.Green("Pushed %s (now v%d)\n", file, entry.Version)
+	}
+}
+
+var homeyscriptPullCmd = &cobra.Command{
+	Use:   "pull <dir>",
+	Short: "Download all scripts to a directory",
+	Long: `Download every script to a .js file in a directory.
+
+The directory gets a .homeyscript.json manifest that maps files to script
+IDs and records the version each file is based on, so push can detect when
+a script was changed on the Homey in the meantime. Files with local changes
+that were not pushed yet are skipped unless --force is given.
+
+Examples:
+  homeyctl homeyscript pull ./scripts
+  homeyctl homeyscript pull ./scripts --force`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		force, _ := cmd.Flags().GetBool("force")
+		pulled, skipped, err := pullHomeyScripts(args[0], force)
+		if err != nil {
+			return err
+		}
+		color.Green("Pulled %d script(s) to %s\n", pulled, args[0])
+		if skipped > 0 {
+			return fmt.Errorf("%d script(s) skipped because of local changes", skipped)
+		}
+		return nil
+	},
+}
+
+var homeyscriptPushCmd = &cobra.Command{
+	Use:   "push <dir>",
+	Short: "Upload changed scripts from a directory",
+	Long: `Upload the .js files in a directory that changed since the last pull or push.
+
+Files listed in the manifest update their script; new files create a script
+named after the file. If a script was changed on the Homey since the file
+was pulled, it is reported as a conflict and not uploaded; pull again to
+merge, or use --force to overwrite the Homey's version. --force only skips
+that check; unchanged files are never uploaded. A file that fails to upload
+is reported and its manifest entry is left as it was, so the next push
+tries it again.
+
+Examples:
+  homeyctl homeyscript push ./scripts
+  homeyctl homeyscript push ./scripts --force`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := args[0]
+		force, _ := cmd.Flags().GetBool("force")
+
+		manifest, err := loadHomeyScriptManifest(dir)
+		if err != nil {
+			return err
+		}
+		files, err := listScriptFiles(dir)
+		if err != nil {
+			return err
+		}
+
+		pushed, conflicts, failed := 0, 0, 0
+		for _, file := range files {
+			result, err := pushHomeyScript(dir, manifest, file, force)
+			if err != nil {
+				if result == pushConflict {
+					color.Red("Conflict: %v\n", err)
+					conflicts++
+				} else {
+					color.Red("Failed to push %s: %v\n", file, err)
+					failed++
+				}
+				continue
+			}
+			reportPush(file, result, manifest.byFile(file))
+			if result != pushUnchanged {
+				pushed++
+			}
+		}
+		if err := manifest.save(dir); err != nil {
+			return err
+		}
+
+		if pushed == 0 && conflicts == 0 && failed == 0 {
+			fmt.Println("Everything up to date")
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d script(s) failed to push", failed)
+		}
+		if conflicts > 0 {
+			return fmt.Errorf("%d script(s) not pushed because of conflicts", conflicts)
+		}
+		return nil
+	},
+}
+
+var homeyscriptWatchCmd = &cobra.Command{
+	Use:   "watch <dir>",
+	Short: "Upload scripts whenever they are saved",
+	Long: `Watch a directory and upload a script every time its file is saved.
+
+Uses the same manifest and conflict detection as push: if a script was
+changed on the Homey since it was pulled, the save is not uploaded and a
+conflict is reported instead. Stop with Ctrl+C.
+
+Examples:
+  homeyctl homeyscript pull ./scripts
+  homeyctl homeyscript watch ./scripts`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := args[0]
+		manifest, err := loadHomeyScriptManifest(dir)
+		if err != nil {
+			return err
+		}
+
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			return fmt.Errorf("failed to watch %s: %w", dir, err)
+		}
+		defer watcher.Close()
+		// Watch the directory rather than the files, since many editors
+		// save by writing a new file and renaming it over the old one
+		if err := watcher.Add(dir); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", dir, err)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		fmt.Printf("Watching %s for changes (Ctrl+C to stop)\n", dir)
+
+		// Editors often write a file in several steps; wait for a quiet
+		// moment before uploading
+		const settle = 200 * time.Millisecond
+		pending := map[string]bool{}
+		timer := time.NewTimer(settle)
+		timer.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return nil
+				}
+				color.Yellow("Warning: %v\n", err)
+			case ev, ok := <-watcher.Events:
+				if !ok {
+					return nil
+				}
+				name := filepath.Base(ev.Name)
+				if !strings.HasSuffix(name, ".js") || !ev.Has(fsnotify.Write|fsnotify.Create|fsnotify.Rename) {
+					continue
+				}
+				pending[name] = true
+				timer.Reset(settle)
+			case <-timer.C:
+				for file := range pending {
+					delete(pending, file)
+					if _, err := os.Stat(filepath.Join(dir, file)); err != nil {
+						continue
+					}
+					result, err := pushHomeyScript(dir, manifest, file, false)
+					stamp := time.Now().Format("15:04:05")
+					switch {
+					case result == pushConflict:
+						color.Red("%s Conflict: %v\n", stamp, err)
+					case err != nil:
+						color.Red("%s Failed to push %s: %v\n", stamp, file, err)
+					case result != pushUnchanged:
+						fmt.Print(stamp + " ")
+						reportPush(file, result, manifest.byFile(file))
+						if err := manifest.save(dir); err != nil {
+							color.Yellow("Warning: %v\n", err)
+						}
+					}
+				}
+			}
+		}
+	},
+}
+
+func init() {
+	homeyscriptCmd.AddCommand(homeyscriptPullCmd)
+	homeyscriptCmd.AddCommand(homeyscriptPushCmd)
+	homeyscriptCmd.AddCommand(homeyscriptWatchCmd)
+
+	homeyscriptPullCmd.Flags().Bool("force", false, "Overwrite local files that have unpushed changes")
+	homeyscriptPushCmd.Flags().Bool("force", false, "Upload even if the script changed on the Homey")
+}
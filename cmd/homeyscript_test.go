@@ -0,0 +1,304 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fishfisher/homeyctl/internal/fakehomey"
+)
+
+func scriptsSeed() *fakehomey.Seed {
+	return &fakehomey.Seed{
+		Scripts: fakehomey.Objects{
+			"s1": {"id": "s1", "name": "Morning report", "code": "log('morning');", "version": 2.0},
+			"s2": {"id": "s2", "name": "a/b", "code": "return 1;", "version": 1.0},
+			"s3": {"id": "s3", "name": "Broken", "code": "throw new Error('boom');", "version": 1.0,
+				"result": map[string]interface{}{"success": false, "returns": map[string]interface{}{"message": "boom", "stack": "Error: boom\n    at script.js:1:7"}}},
+		},
+	}
+}
+
+func TestHomeyScript_PullPush(t *testing.T) {
+	h := useFakeHomey(t, scriptsSeed())
+	dir := t.TempDir()
+
+	if _, err := runCommand(t, "homeyscript", "pull", dir); err != nil {
+		t.Fatalf("pull failed: %v", err)
+	}
+	code, err := os.ReadFile(filepath.Join(dir, "Morning report.js"))
+	if err != nil || string(code) != "log('morning');" {
+		t.Fatalf("expected pulled script, got %q (%v)", code, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a_b.js")); err != nil {
+		t.Errorf("expected unsafe name to be sanitized: %v", err)
+	}
+	manifest, _ := loadHomeyScriptManifest(dir)
+	if e := manifest.byID("s1"); e == nil || e.Version != 2 || e.File != "Morning report.js" {
+		t.Fatalf("unexpected manifest entry: %+v", e)
+	}
+
+	out, err := runCommand(t, "homeyscript", "push", dir)
+	if err != nil || !strings.Contains(out, "Everything up to date") {
+		t.Fatalf("expected nothing to push, got %q (%v)", out, err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "Morning report.js"), []byte("log('good morning');"), 0o644)
+	os.WriteFile(filepath.Join(dir, "New one.js"), []byte("return 42;"), 0o644)
+	out, err = runCommand(t, "homeyscript", "push", dir)
+	if err != nil {
+		t.Fatalf("push failed: %v", err)
+	}
+	if !strings.Contains(out, "Pushed Morning report.js (now v3)") || !strings.Contains(out, "Created New one") {
+		t.Errorf("unexpected push output:\n%s", out)
+	}
+	if s := h.Object(fakehomey.Scripts, "s1"); s["code"] != "log('good morning');" || s["version"] != 3.0 {
+		t.Errorf("expected updated script, got %v", s)
+	}
+	manifest, _ = loadHomeyScriptManifest(dir)
+	if e := manifest.byFile("New one.js"); e == nil || e.ID == "" {
+		t.Errorf("expected created script in manifest, got %+v", manifest.Scripts)
+	}
+}
+
+func TestHomeyScript_PushConflict(t *testing.T) {
+	h := useFakeHomey(t, scriptsSeed())
+	dir := t.TempDir()
+	if _, err := runCommand(t, "homeyscript", "pull", dir); err != nil {
+		t.Fatalf("pull failed: %v", err)
+	}
+
+	// Someone edits the script in the web editor
+	if _, err := apiClient.UpdateHomeyScript("s1", "Morning report", "log('web');", 2); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "Morning report.js"), []byte("log('local');"), 0o644)
+
+	out, err := runCommand(t, "homeyscript", "push", dir)
+	if err == nil || !strings.Contains(err.Error(), "1 script(s) not pushed") {
+		t.Fatalf("expected conflict error, got %v", err)
+	}
+	if !strings.Contains(out, "at v3 but Morning report.js is based on v2") {
+		t.Errorf("unexpected conflict output:\n%s", out)
+	}
+	if s := h.Object(fakehomey.Scripts, "s1"); s["code"] != "log('web');" {
+		t.Errorf("conflicting push overwrote the script: %v", s["code"])
+	}
+
+	// Pull keeps the local edit
+	if _, err := runCommand(t, "homeyscript", "pull", dir); err == nil {
+		t.Error("expected pull to report the skipped local change")
+	}
+	if code, _ := os.ReadFile(filepath.Join(dir, "Morning report.js")); string(code) != "log('local');" {
+		t.Errorf("pull overwrote local changes: %s", code)
+	}
+
+	if _, err := runCommand(t, "homeyscript", "push", dir, "--force"); err != nil {
+		t.Fatalf("push --force failed: %v", err)
+	}
+	if s := h.Object(fakehomey.Scripts, "s1"); s["code"] != "log('local');" {
+		t.Errorf("expected forced push, got %v", s["code"])
+	}
+}
+
+func TestHomeyScript_PushForceSkipsUnchangedFiles(t *testing.T) {
+	h := useFakeHomey(t, scriptsSeed())
+	dir := t.TempDir()
+	if _, err := runCommand(t, "homeyscript", "pull", dir); err != nil {
+		t.Fatalf("pull failed: %v", err)
+	}
+
+	out, err := runCommand(t, "homeyscript", "push", dir, "--force")
+	if err != nil || !strings.Contains(out, "Everything up to date") {
+		t.Fatalf("expected nothing to push, got %q (%v)", out, err)
+	}
+	for _, c := range h.Calls() {
+		if c.Method == "PUT" {
+			t.Errorf("expected no uploads, got %s %s", c.Method, c.Path)
+		}
+	}
+}
+
+func TestHomeyScript_PushKeepsManifestForFailedFiles(t *testing.T) {
+	h := fakehomey.New(scriptsSeed())
+	h.SetToken("test-token")
+	// Uploads of s1 fail; s2 still goes through
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut && strings.HasSuffix(r.URL.Path, "/s1") {
+			http.Error(w, `{"error":"storage full"}`, http.StatusInternalServerError)
+			return
+		}
+		h.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("HOMEY_MODE", "local")
+	t.Setenv("HOMEY_LOCAL_ADDRESS", server.URL)
+	t.Setenv("HOMEY_LOCAL_TOKEN", "test-token")
+
+	dir := t.TempDir()
+	if _, err := runCommand(t, "homeyscript", "pull", dir); err != nil {
+		t.Fatalf("pull failed: %v", err)
+	}
+	before, _ := loadHomeyScriptManifest(dir)
+	os.WriteFile(filepath.Join(dir, "Morning report.js"), []byte("log('good morning');"), 0o644)
+	os.WriteFile(filepath.Join(dir, "a_b.js"), []byte("return 2;"), 0o644)
+
+	out, err := runCommand(t, "homeyscript", "push", dir)
+	if err == nil || !strings.Contains(err.Error(), "1 script(s) failed to push") {
+		t.Errorf("expected the failed push to be reported, got %v", err)
+	}
+	if !strings.Contains(out, "Pushed a_b.js") {
+		t.Errorf("expected a_b.js to be pushed:\n%s", out)
+	}
+	after, _ := loadHomeyScriptManifest(dir)
+	if got, want := *after.byID("s1"), *before.byID("s1"); got != want {
+		t.Errorf("expected the failed file's entry to be unchanged, got %+v, want %+v", got, want)
+	}
+	if after.byID("s2").Version != 2 {
+		t.Errorf("expected the pushed file's entry to be updated, got %+v", after.byID("s2"))
+	}
+}
+
+func TestHomeyScript_RunExitCode(t *testing.T) {
+	useFakeHomey(t, scriptsSeed())
+
+	out, err := runCommand(t, "homeyscript", "run", "Broken")
+	if !errors.Is(err, errFalse) {
+		t.Fatalf("expected a silent failure, got %v", err)
+	}
+	if !strings.Contains(out, "boom") || !strings.Contains(out, "at script.js:1:7") {
+		t.Errorf("expected the error and stack in output:\n%s", out)
+	}
+
+	if _, err := runCommand(t, "homeyscript", "run", "Morning report"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestParseHomeyScriptRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		success bool
+		returns string
+		console []string
+		message string
+	}{
+		{"returns", `{"success":true,"returns":{"ok":1}}`, true, `{"ok":1}`, nil, ""},
+		{"returnValue with logs", `{"success":true,"returnValue":"done","console":"a\nb\n"}`, true, `"done"`, []string{"a", "b"}, ""},
+		{"log list", `{"success":true,"logs":["x"]}`, true, "", []string{"x"}, ""},
+		{"failed returns", `{"success":false,"returns":{"message":"boom","stack":"at x"}}`, false, "", nil, "boom"},
+		{"error string", `{"error":"timeout"}`, false, "", nil, "timeout"},
+		{"null error", `{"success":true,"error":null}`, true, "", nil, ""},
+		{"plain value", `42`, true, "42", nil, ""},
+	}
+	for _, tt := range tests {
+		run := parseHomeyScriptRun(json.RawMessage(tt.data))
+		if run.Success != tt.success || string(run.Returns) != tt.returns || run.Message != tt.message {
+			t.Errorf("%s: got %+v", tt.name, run)
+		}
+		if strings.Join(run.Console, "|") != strings.Join(tt.console, "|") {
+			t.Errorf("%s: console = %q, want %q", tt.name, run.Console, tt.console)
+		}
+	}
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const coldScript = `
+const devices = await Homey.devices.getDevices();
+if (devices.sensor.capabilitiesObj.measure_temperature.value < 18) {
+  await Homey.devices.setCapabilityValue({ deviceId: 'heater', capabilityId: 'onoff', value: true });
+  log('heating');
+}
+return 'ok';
+`
+
+const coldFixture = `{
+  "devices": [
+    {"id": "sensor", "name": "Hall sensor", "capabilitiesObj": {"measure_temperature": {"id": "measure_temperature", "value": 16}}},
+    {"id": "heater", "name": "Heater", "capabilitiesObj": {"onoff": {"id": "onoff", "value": false}}}
+  ]
+}`
+
+func TestHomeyScriptTest_Fixture(t *testing.T) {
+	dir := t.TempDir()
+	script := writeFile(t, dir, "cold.js", coldScript)
+	fixture := writeFile(t, dir, "house.json", coldFixture)
+
+	out, err := runCommand(t, "homeyscript", "test", script, "--fixture", fixture)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{"heating", `devices.setCapabilityValue({"capabilityId":"onoff","deviceId":"heater","value":true})`, "~ /devices/heater/capabilitiesObj/onoff/value: false → true"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	expect := writeFile(t, dir, "cold.expect.json", `{"returns": "ok", "calls": [{"api": "devices.setCapabilityValue", "args": {"deviceId": "heater", "capabilityId": "onoff", "value": false}}]}`)
+	out, err = runCommand(t, "homeyscript", "test", script, "--fixture", fixture, "--expect", expect)
+	if err == nil || !strings.Contains(err.Error(), "1 expectation(s) not met") {
+		t.Fatalf("expected failed expectation, got %v", err)
+	}
+	if !strings.Contains(out, "✓ returns") || !strings.Contains(out, "✗ calls") || !strings.Contains(out, "~ /0/args/value: false → true") {
+		t.Errorf("unexpected expectation output:\n%s", out)
+	}
+
+	// The --json output of a run works as an expectations file
+	out, err = runCommand(t, "homeyscript", "test", script, "--fixture", fixture, "--json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect = writeFile(t, dir, "saved.json", out)
+	if _, err := runCommand(t, "homeyscript", "test", script, "--fixture", fixture, "--expect", expect); err != nil {
+		t.Errorf("expected saved run to match: %v", err)
+	}
+}
+
+func TestHomeyScriptTest_ErrorsAndLive(t *testing.T) {
+	dir := t.TempDir()
+	script := writeFile(t, dir, "bad.js", "throw new Error('nope');")
+	fixture := writeFile(t, dir, "house.json", coldFixture)
+
+	if _, err := runCommand(t, "homeyscript", "test", script, "--fixture", fixture); err == nil || !strings.Contains(err.Error(), "script failed: nope") {
+		t.Errorf("expected script failure, got %v", err)
+	}
+	expect := writeFile(t, dir, "bad.expect.json", `{"error": "nope"}`)
+	if _, err := runCommand(t, "homeyscript", "test", script, "--fixture", fixture, "--expect", expect); err != nil {
+		t.Errorf("expected error to be accepted, got %v", err)
+	}
+	if _, err := runCommand(t, "homeyscript", "test", script); err == nil || !strings.Contains(err.Error(), "--fixture <file> or --live") {
+		t.Errorf("expected source error, got %v", err)
+	}
+
+	var seed fakehomey.Seed
+	json.Unmarshal([]byte(coldFixture), &seed)
+	h := useFakeHomey(t, &seed)
+	script = writeFile(t, dir, "cold.js", coldScript)
+	out, err := runCommand(t, "homeyscript", "test", script, "--live")
+	if err != nil {
+		t.Fatalf("live test failed: %v", err)
+	}
+	if !strings.Contains(out, "heating") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+	if v, _ := h.CapabilityValue("heater", "onoff"); v != false {
+		t.Error("live test changed the real Homey")
+	}
+	for _, c := range h.Calls() {
+		t.Errorf("live test made a mutating request: %+v", c)
+	}
+}
@@ -11,33 +11,18 @@ import (
 
 // Notification represents a Homey notification
 type Notification struct {
-	ID       string `json:"id"`
-	Excerpt  string `json:"excerpt"`
-	OwnerUri string `json:"ownerUri"`
-	Date     string `json:"date"`
+	ID          string `json:"id"`
+	Excerpt     string `json:"excerpt"`
+	OwnerUri    string `json:"ownerUri"`
+	Date        string `json:"date"`
+	DateCreated string `json:"dateCreated"`
 }
 
 var notifyCmd = &cobra.Command{
 	Use:     "notify",
 	Aliases: []string{"notifications"},
 	Short:   "Manage notifications",
-	Long:    `Send and view Homey timeline notifications.`,
-}
-
-var notifySendCmd = &cobra.Command{
-	Use:   "send <message>",
-	Short: "Send a notification to the timeline",
-	Args:  cobra.ExactArgs(1),
-	RunE: func(cmd *cobra.Command, args []string) error {
-		message := args[0]
-
-		if err := apiClient.SendNotification(message); err != nil {
-			return err
-		}
-
-		color.Green("Notification sent: %s\n", message)
-		return nil
-	},
+	Long:    `Send and view Homey timeline notifications, and route them to other channels.`,
 }
 
 var notifyListCmd = &cobra.Command{
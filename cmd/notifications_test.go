@@ -1,7 +1,16 @@
 package cmd
 
 import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
 	"testing"
+
+	"github.com/fishfisher/homeyctl/internal/config"
+	"github.com/fishfisher/homeyctl/internal/fakehomey"
+	"github.com/fishfisher/homeyctl/internal/notify"
 )
 
 func TestNotifySendCommand_Exists(t *testing.T) {
@@ -89,3 +98,76 @@ func TestNotifyOwnersCommand_Exists(t *testing.T) {
 		t.Errorf("expected command name 'owners', got '%s'", cmd.Name())
 	}
 }
+
+func TestNotifyChannelsAndForward(t *testing.T) {
+	t.Setenv(config.DirEnv, t.TempDir())
+	h := useFakeHomey(t, &fakehomey.Seed{
+		Notifications: fakehomey.Objects{
+			"old": {"id": "old", "excerpt": "Already seen", "ownerUri": "homey:manager:flow", "dateCreated": "2026-03-01T08:00:00Z"},
+		},
+	})
+	if _, err := runCommand(t, "notify", "list"); err != nil {
+		t.Fatal(err)
+	}
+
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+	}))
+	defer server.Close()
+	cfg.Notify = config.NotifyConfig{Channels: map[string]config.NotifyChannel{
+		"hook": {Type: "webhook", URL: server.URL, Template: "{{.Title}}: {{.Message}}"},
+		"bad":  {Type: "ntfy"},
+	}}
+
+	if _, err := resolveChannels([]string{"pager"}); err == nil || !strings.Contains(err.Error(), "unknown channel: pager") {
+		t.Errorf("expected unknown channel error, got %v", err)
+	}
+	if _, err := resolveChannels([]string{"bad"}); err == nil || !strings.Contains(err.Error(), "needs url") {
+		t.Errorf("expected invalid channel error, got %v", err)
+	}
+	channels, err := resolveChannels([]string{"timeline", "hook"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed := sendToChannels(channels, notify.Message{Title: "Test", Message: "Hello"}); len(failed) != 0 {
+		t.Fatalf("unexpected failures: %v", failed)
+	}
+	if len(bodies) != 1 || bodies[0] != "Test: Hello" || len(h.IDs(fakehomey.Notifications)) != 2 {
+		t.Fatalf("expected webhook and timeline delivery, got %v and %v", bodies, h.IDs(fakehomey.Notifications))
+	}
+
+	// The first poll only remembers what is already on the timeline
+	bodies = nil
+	f := &notificationForwarder{channels: []string{"hook"}, send: sendToChannel}
+	if n, err := f.poll(); err != nil || n != 0 || len(f.seen) != 2 {
+		t.Fatalf("first poll: n=%d err=%v seen=%v", n, err, f.seen)
+	}
+	if err := apiClient.SendNotification("Smoke detected"); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := f.poll(); err != nil || n != 1 {
+		t.Fatalf("second poll: n=%d err=%v", n, err)
+	}
+	if n, _ := f.poll(); n != 0 {
+		t.Errorf("expected notifications to be forwarded once, got %d", n)
+	}
+	if len(bodies) != 1 || bodies[0] != "Homey: Smoke detected" {
+		t.Errorf("unexpected forwarded bodies: %v", bodies)
+	}
+
+	f.match = regexp.MustCompile("(?i)smoke")
+	apiClient.SendNotification("Washing machine done")
+	if n, _ := f.poll(); n != 0 || len(bodies) != 1 {
+		t.Errorf("expected --match to skip, got %d forwarded", n)
+	}
+
+	if err := saveForwardState(f.seen); err != nil {
+		t.Fatal(err)
+	}
+	seen, err := loadForwardState()
+	if err != nil || len(seen) != 4 || !seen["old"] {
+		t.Errorf("unexpected saved state: %v (%v)", seen, err)
+	}
+}
@@ -0,0 +1,409 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/fishfisher/homeyctl/internal/config"
+	"github.com/fishfisher/homeyctl/internal/notify"
+	"github.com/spf13/cobra"
+)
+
+// Built-in channels: the Homey timeline, and push notifications to
+// everyone with the Homey app
+const (
+	timelineChannel = "timeline"
+	pushChannel     = "push"
+)
+
+const notifyExample = `# Notification channels for 'homeyctl notify send --to' and 'notify forward'
+# Add to config.toml. Channel names are yours to choose; timeline and push
+# (the Homey app, to every user) are built in.
+
+[notify]
+# Channels 'notify forward' uses when --to is not given
+forward = ["push", "email", "ntfy"]
+
+[notify.channels.email]
+type = "smtp"
+host = "smtp.example.com"
+port = 587                        # 587 uses STARTTLS, 465 implicit TLS
+username = "homey@example.com"
+password = "app-password"
+from = "Homey <homey@example.com>"
+to = ["ann@example.com", "bob@example.com"]
+subject = "[Homey] {{.Title}}"
+
+[notify.channels.ntfy]
+type = "ntfy"
+url = "https://ntfy.sh/my-homey-alarms"
+priority = "high"
+tags = ["house"]
+
+[notify.channels.slack]
+type = "webhook"
+url = "https://hooks.slack.com/services/T000/B000/XXXX"
+template = '{"text": {{json (print "*" .Title "*: " .Message)}}}'
+
+[notify.channels.webhook]
+type = "webhook"
+url = "https://example.com/hooks/homey"
+token = "secret"                  # Sent as a Bearer token
+# Without a template the message is posted as JSON with
+# title, message, source and time
+`
+
+// resolveChannels checks that each channel name is the timeline or a
+// configured channel
+func resolveChannels(names []string) ([]string, error) {
+	var resolved []string
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if name != timelineChannel && name != pushChannel {
+			ch, ok := cfg.Notify.Channels[strings.ToLower(name)]
+			if !ok {
+				return nil, fmt.Errorf("unknown channel: %s (configure [notify.channels.%s] or run 'homeyctl notify example')", name, name)
+			}
+			if err := notify.Validate(name, ch); err != nil {
+				return nil, err
+			}
+		}
+		resolved = append(resolved, name)
+	}
+	if len(resolved) == 0 {
+		return nil, fmt.Errorf("no channels given")
+	}
+	return resolved, nil
+}
+
+// sendToChannel delivers a message to one resolved channel
+func sendToChannel(name string, msg notify.Message) error {
+	switch name {
+	case timelineChannel:
+		return ignoreDryRun(apiClient.SendNotification(msg.Message))
+	case pushChannel:
+		_, _, err := sendPush("", msg.Message, "")
+		return ignoreDryRun(err)
+	}
+	ch := cfg.Notify.Channels[strings.ToLower(name)]
+	if dryRunFlag {
+		fmt.Printf("Would send to %s (%s): %s\n", name, ch.Type, msg.Message)
+		return nil
+	}
+	return notify.Send(ch, msg)
+}
+
+// sendToChannels delivers a message to every channel and returns the
+// names of those that failed
+func sendToChannels(names []string, msg notify.Message) []string {
+	var failed []string
+	for _, name := range names {
+		if err := sendToChannel(name, msg); err != nil {
+			color.Red("Error: %s: %v\n", name, err)
+			failed = append(failed, name)
+		}
+	}
+	return failed
+}
+
+var notifySendCmd = &cobra.Command{
+	Use:   "send <message>",
+	Short: "Send a notification to the timeline or other channels",
+	Long: `Send a notification to the Homey timeline, or with --to to any mix of the
+timeline, push (the Homey app, to every user) and channels configured in
+config.toml (email over SMTP, ntfy and webhooks with a body template).
+
+Run 'homeyctl notify example' for a sample channel configuration.
+
+Examples:
+  homeyctl notify send "Washing machine done"
+  homeyctl notify send "Smoke detected" --to timeline,push,email,ntfy
+  homeyctl notify send "Door left open" --to slack --title "Front door"`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		message := args[0]
+		to, _ := cmd.Flags().GetStringSlice("to")
+		title, _ := cmd.Flags().GetString("title")
+
+		if len(to) == 0 {
+			if err := apiClient.SendNotification(message); err != nil {
+				return err
+			}
+			color.Green("Notification sent: %s\n", message)
+			return nil
+		}
+
+		channels, err := resolveChannels(to)
+		if err != nil {
+			return err
+		}
+		msg := notify.Message{Title: title, Message: message, Source: "homeyctl", Time: time.Now()}
+		failed := sendToChannels(channels, msg)
+		if len(failed) > 0 {
+			return fmt.Errorf("failed to send to %d of %d channel(s): %s", len(failed), len(channels), strings.Join(failed, ", "))
+		}
+		if !dryRunFlag {
+			color.Green("Notification sent to %s: %s\n", strings.Join(channels, ", "), message)
+		}
+		return nil
+	},
+}
+
+func forwardStatePath() (string, error) {
+	configDir, err := config.Dir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find config dir: %w", err)
+	}
+	return filepath.Join(configDir, "history", "notifications-forwarded.json"), nil
+}
+
+// loadForwardState returns the IDs already forwarded, or nil when
+// forwarding has never run
+func loadForwardState() (map[string]bool, error) {
+	path, err := forwardStatePath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	if err := json.Unmarshal(data, &ids); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	seen := map[string]bool{}
+	for _, id := range ids {
+		seen[id] = true
+	}
+	return seen, nil
+}
+
+func saveForwardState(seen map[string]bool) error {
+	path, err := forwardStatePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create history dir: %w", err)
+	}
+	ids := make([]string, 0, len(seen))
+	for id := range seen {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	data, _ := json.MarshalIndent(ids, "", "  ")
+	return os.WriteFile(path, data, 0o644)
+}
+
+// notificationForwarder sends new timeline notifications to channels
+type notificationForwarder struct {
+	channels []string
+	match    *regexp.Regexp
+	seen     map[string]bool // nil until the first poll
+	send     func(name string, msg notify.Message) error
+}
+
+// poll fetches the timeline and forwards notifications not seen before,
+// oldest first. The first poll without saved state only marks what is
+// already there as seen. It returns the number forwarded.
+func (f *notificationForwarder) poll() (int, error) {
+	data, err := apiClient.GetNotifications()
+	if err != nil {
+		return 0, err
+	}
+	var notifications map[string]Notification
+	if err := json.Unmarshal(data, &notifications); err != nil {
+		return 0, fmt.Errorf("failed to parse notifications: %w", err)
+	}
+
+	var fresh []Notification
+	current := map[string]bool{}
+	for id, n := range notifications {
+		if n.ID == "" {
+			n.ID = id
+		}
+		current[n.ID] = true
+		if f.seen != nil && !f.seen[n.ID] {
+			fresh = append(fresh, n)
+		}
+	}
+	sort.Slice(fresh, func(i, j int) bool {
+		if fresh[i].DateCreated != fresh[j].DateCreated {
+			return fresh[i].DateCreated < fresh[j].DateCreated
+		}
+		return fresh[i].ID < fresh[j].ID
+	})
+
+	forwarded := 0
+	for _, n := range fresh {
+		if f.match != nil && !f.match.MatchString(n.Excerpt) {
+			continue
+		}
+		msg := notify.Message{ID: n.ID, Title: "Homey", Message: n.Excerpt, Source: n.OwnerUri, Time: time.Now()}
+		if t, err := time.Parse(time.RFC3339, n.DateCreated); err == nil {
+			msg.Time = t
+		}
+		var failed []string
+		for _, name := range f.channels {
+			if err := f.send(name, msg); err != nil {
+				color.Red("Error: %s: %v\n", name, err)
+				failed = append(failed, name)
+			}
+		}
+		if len(failed) == len(f.channels) {
+			// Leave it unseen so the next poll tries again
+			delete(current, n.ID)
+			continue
+		}
+		forwarded++
+		logForwarded(msg, f.channels, failed)
+	}
+
+	// Only remember IDs still on the timeline, so the state stays small
+	f.seen = current
+	return forwarded, nil
+}
+
+func logForwarded(msg notify.Message, channels, failed []string) {
+	if isJSON() {
+		out, _ := json.Marshal(map[string]interface{}{"time": msg.Time, "id": msg.ID, "message": msg.Message, "channels": channels, "failed": failed})
+		fmt.Println(string(out))
+		return
+	}
+	fmt.Printf("%s forwarded %q\n", msg.Time.Local().Format("15:04:05"), msg.Message)
+}
+
+var notifyForwardCmd = &cobra.Command{
+	Use:   "forward",
+	Short: "Forward new timeline notifications to channels",
+	Long: `Poll the Homey timeline and forward each new notification to channels
+configured in config.toml, so alarms reach people without the Homey app.
+
+Notifications are de-duplicated by ID, and the IDs already forwarded are
+kept between runs. The first run only remembers what is on the timeline,
+so old notifications are not forwarded. A notification that could not be
+delivered to any channel is retried on the next poll.
+
+Channels default to [notify] forward in config.toml.
+
+Examples:
+  homeyctl notify forward
+  homeyctl notify forward --to email,ntfy --interval 10s
+  homeyctl notify forward --match "(?i)smoke|water|alarm"
+  homeyctl notify forward --once     # for cron`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		to, _ := cmd.Flags().GetStringSlice("to")
+		interval, _ := cmd.Flags().GetDuration("interval")
+		once, _ := cmd.Flags().GetBool("once")
+		match, _ := cmd.Flags().GetString("match")
+
+		if len(to) == 0 {
+			to = cfg.Notify.Forward
+		}
+		if len(to) == 0 {
+			return fmt.Errorf("no channels: use --to or set [notify] forward in config.toml")
+		}
+		channels, err := resolveChannels(to)
+		if err != nil {
+			return err
+		}
+		for _, name := range channels {
+			if name == timelineChannel {
+				return fmt.Errorf("cannot forward the timeline to itself")
+			}
+		}
+		if interval <= 0 {
+			return fmt.Errorf("--interval must be positive")
+		}
+
+		f := &notificationForwarder{channels: channels, send: sendToChannel}
+		if match != "" {
+			if f.match, err = regexp.Compile(match); err != nil {
+				return fmt.Errorf("invalid --match: %w", err)
+			}
+		}
+		if f.seen, err = loadForwardState(); err != nil {
+			return err
+		}
+
+		run := func() error {
+			n, err := f.poll()
+			if err != nil {
+				return err
+			}
+			if dryRunFlag {
+				return nil
+			}
+			if err := saveForwardState(f.seen); err != nil {
+				return err
+			}
+			if once && !isJSON() {
+				fmt.Printf("Forwarded %d notification(s)\n", n)
+			}
+			return nil
+		}
+
+		if once {
+			return run()
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		if !isJSON() {
+			fmt.Printf("Forwarding notifications to %s every %s (Ctrl+C to stop)\n", strings.Join(channels, ", "), interval)
+		}
+		if err := run(); err != nil {
+			return err
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+				if err := run(); err != nil {
+					color.Red("Error: %v\n", err)
+				}
+			}
+		}
+	},
+}
+
+var notifyExampleCmd = &cobra.Command{
+	Use:   "example",
+	Short: "Print an example channel configuration",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fmt.Print(notifyExample)
+		return nil
+	},
+}
+
+func init() {
+	notifyCmd.AddCommand(notifyForwardCmd)
+	notifyCmd.AddCommand(notifyExampleCmd)
+	notifySendCmd.Flags().StringSlice("to", nil, "Channels to send to: timeline, push or names from [notify.channels]")
+	notifySendCmd.Flags().String("title", "Homey", "Title for channels that have one")
+	notifyForwardCmd.Flags().StringSlice("to", nil, "Channels to forward to (default [notify] forward)")
+	notifyForwardCmd.Flags().Duration("interval", 30*time.Second, "How often to poll the timeline")
+	notifyForwardCmd.Flags().Bool("once", false, "Poll once and exit")
+	notifyForwardCmd.Flags().String("match", "", "Only forward notifications matching this regular expression")
+}
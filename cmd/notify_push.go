@@ -0,0 +1,222 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// pushCardURI owns the Homey app's push notification flow cards
+const pushCardURI = "homey:manager:mobile"
+
+// findPushCard picks the push notification card, one with an image
+// argument when an image is wanted
+func findPushCard(cards []flowCardDef, withImage bool) (*flowCardDef, error) {
+	var found *flowCardDef
+	for i, c := range cards {
+		if c.OwnerURI() != pushCardURI || c.Arg("user") == nil || c.Arg("text") == nil {
+			continue
+		}
+		hasImage := c.Arg("image") != nil
+		if withImage && !hasImage {
+			continue
+		}
+		// Prefer the plain text card when there is no image
+		if found == nil || (!withImage && !hasImage) {
+			found = &cards[i]
+		}
+	}
+	if found == nil {
+		if withImage {
+			return nil, fmt.Errorf("no push notification card with an image found on this Homey")
+		}
+		return nil, fmt.Errorf("no push notification card found on this Homey")
+	}
+	return found, nil
+}
+
+// findPushImage resolves --image to one of the Homey's registered images,
+// by image ID or by the device that owns it (e.g. a camera). Image card
+// arguments take the image object, which is Homey's image token.
+func findPushImage(ref string) (map[string]interface{}, error) {
+	data, err := apiClient.GetImages()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %w", err)
+	}
+	var images map[string]map[string]interface{}
+	if err := json.Unmarshal(data, &images); err != nil {
+		return nil, fmt.Errorf("failed to parse images: %w", err)
+	}
+	if img, ok := images[ref]; ok {
+		return img, nil
+	}
+
+	device, err := findDevice(ref)
+	if err != nil {
+		return nil, fmt.Errorf("no image or device %s found (images come from apps and devices such as cameras)", ref)
+	}
+	var ids []string
+	for id, img := range images {
+		if img["ownerUri"] == "homey:device:"+device.ID {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("device %s has no image", device.Name)
+	}
+	sort.Strings(ids)
+	return images[ids[0]], nil
+}
+
+// pushRecipients returns the card's user choices, which are what the
+// card takes as its user argument
+func pushRecipients(card *flowCardDef) ([]map[string]interface{}, error) {
+	data, err := apiClient.GetFlowCardAutocomplete("flowcardaction", card.OwnerURI(), card.ID, "user", "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list push recipients: %w", err)
+	}
+	var items []map[string]interface{}
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("failed to parse push recipients: %w", err)
+	}
+	return items, nil
+}
+
+// pushRecipientFor finds a user among the card's user choices
+func pushRecipientFor(items []map[string]interface{}, u *User) map[string]interface{} {
+	for _, item := range items {
+		id, _ := item["id"].(string)
+		athomID, _ := item["athomId"].(string)
+		name, _ := item["name"].(string)
+		if id == u.ID || (u.AthomID != "" && (id == u.AthomID || athomID == u.AthomID)) {
+			return item
+		}
+		if strings.EqualFold(name, u.Name) {
+			return item
+		}
+	}
+	return nil
+}
+
+// sendPush sends a push notification to a user, or to everyone who can
+// receive them when user is empty, with an optional image. It returns the
+// card used and the names of the recipients it reached; a failed recipient
+// does not stop the others, and the failures are joined in the error.
+func sendPush(user, message, image string) (*flowCardDef, []string, error) {
+	cards, err := fetchFlowCards("action")
+	if err != nil {
+		return nil, nil, err
+	}
+	card, err := findPushCard(cards, image != "")
+	if err != nil {
+		return nil, nil, err
+	}
+	var imageArg map[string]interface{}
+	if image != "" {
+		if imageArg, err = findPushImage(image); err != nil {
+			return nil, nil, err
+		}
+	}
+	items, err := pushRecipients(card)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var recipients []map[string]interface{}
+	if user == "" {
+		recipients = items
+		if len(recipients) == 0 {
+			return nil, nil, fmt.Errorf("no users can receive push notifications")
+		}
+	} else {
+		u, err := findUser(user)
+		if err != nil {
+			return nil, nil, err
+		}
+		item := pushRecipientFor(items, u)
+		if item == nil {
+			return nil, nil, fmt.Errorf("%s cannot receive push notifications (signed in to the Homey app?)", u.Name)
+		}
+		recipients = append(recipients, item)
+	}
+
+	var sent []string
+	var errs []error
+	for _, r := range recipients {
+		name, _ := r["name"].(string)
+		cardArgs := map[string]interface{}{"user": r, "text": message}
+		if imageArg != nil {
+			cardArgs["image"] = imageArg
+		}
+		if _, err := apiClient.RunFlowCardAction(card.OwnerURI(), card.ID, cardArgs); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		sent = append(sent, name)
+	}
+	return card, sent, errors.Join(errs...)
+}
+
+var notifyPushCmd = &cobra.Command{
+	Use:   "push [user] <message>",
+	Short: "Send a push notification to the Homey app",
+	Long: `Send a push notification to a user's phone through the Homey app, using
+the "send a push notification" flow card.
+
+The user is a Homey user name or ID. With --all-users, the message goes to
+everyone who can receive push notifications and no user is given; if some
+users cannot be reached, the others still get the message.
+
+--image attaches an image the Homey already has, such as a camera
+snapshot, given by image ID or by the device that owns it.
+
+Examples:
+  homeyctl notify push Ann "Dinner is ready"
+  homeyctl notify push --all-users "Leaving in 5 minutes"
+  homeyctl notify push Ann "Someone at the door" --image "Front door camera"`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		allUsers, _ := cmd.Flags().GetBool("all-users")
+		image, _ := cmd.Flags().GetString("image")
+
+		if allUsers && len(args) != 1 {
+			return fmt.Errorf("--all-users takes only a message")
+		}
+		if !allUsers && len(args) != 2 {
+			return fmt.Errorf("requires a user and a message, or --all-users and a message")
+		}
+		message := args[len(args)-1]
+		user := ""
+		if !allUsers {
+			user = args[0]
+		}
+
+		card, sent, err := sendPush(user, message, image)
+		if card == nil || len(sent) == 0 {
+			return err
+		}
+
+		if isJSON() {
+			out, _ := json.MarshalIndent(map[string]interface{}{"card": card.ID, "users": sent, "message": message}, "", "  ")
+			fmt.Println(string(out))
+		} else {
+			color.Green("Push notification sent to %s: %s\n", strings.Join(sent, ", "), message)
+		}
+		if err != nil {
+			cmd.SilenceUsage = true
+			return fmt.Errorf("not sent to every user:\n%w", err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	notifyCmd.AddCommand(notifyPushCmd)
+	notifyPushCmd.Flags().Bool("all-users", false, "Send to every user who can receive push notifications")
+	notifyPushCmd.Flags().String("image", "", "Attach a Homey image by image ID or owning device")
+}
@@ -0,0 +1,510 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/fishfisher/homeyctl/internal/config"
+	"github.com/rodaine/table"
+	"github.com/spf13/cobra"
+)
+
+// Presence log events
+const (
+	presenceStart   = "start" // First record of a user; their state, not a change
+	presenceArrived = "arrived"
+	presenceLeft    = "left"
+	presenceAsleep  = "asleep"
+	presenceAwake   = "awake"
+)
+
+// presenceRecord is one presence or sleep change, as kept in the log.
+// Present and Asleep are the state after the change.
+type presenceRecord struct {
+	Time    time.Time `json:"time"`
+	UserID  string    `json:"userId"`
+	User    string    `json:"user"`
+	Event   string    `json:"event"`
+	Present bool      `json:"present"`
+	Asleep  bool      `json:"asleep"`
+}
+
+func presenceLogPath() (string, error) {
+	configDir, err := config.Dir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find config dir: %w", err)
+	}
+	return filepath.Join(configDir, "history", "presence.jsonl"), nil
+}
+
+// appendPresenceLog adds records to the log, one JSON object per line
+func appendPresenceLog(records []presenceRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	path, err := presenceLogPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create history dir: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open presence log: %w", err)
+	}
+	defer f.Close()
+
+	for _, r := range records {
+		line, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(append(line, '\n')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadPresenceLog reads the log, oldest first. Lines that cannot be parsed
+// are skipped.
+func loadPresenceLog() ([]presenceRecord, error) {
+	path, err := presenceLogPath()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open presence log: %w", err)
+	}
+	defer f.Close()
+
+	var records []presenceRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var r presenceRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err == nil {
+			records = append(records, r)
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Time.Before(records[j].Time) })
+	return records, scanner.Err()
+}
+
+// latestPresence returns the last record of each user, by user ID
+func latestPresence(records []presenceRecord) map[string]presenceRecord {
+	latest := map[string]presenceRecord{}
+	for _, r := range records {
+		latest[r.UserID] = r
+	}
+	return latest
+}
+
+// presenceTransitions compares users to their last logged state and returns
+// records for what changed. Users not in the log yet get a start record.
+func presenceTransitions(latest map[string]presenceRecord, users []User, now time.Time) []presenceRecord {
+	var records []presenceRecord
+	for _, u := range users {
+		rec := presenceRecord{Time: now, UserID: u.ID, User: u.Name, Present: u.Present, Asleep: u.Asleep}
+		last, ok := latest[u.ID]
+		if !ok {
+			rec.Event = presenceStart
+			records = append(records, rec)
+			continue
+		}
+		if u.Present != last.Present {
+			rec.Event = presenceLeft
+			if u.Present {
+				rec.Event = presenceArrived
+			}
+			// Keep the old sleep state until its own record
+			rec.Asleep = last.Asleep
+			records = append(records, rec)
+			rec.Asleep = u.Asleep
+		}
+		if u.Asleep != last.Asleep {
+			rec.Event = presenceAwake
+			if u.Asleep {
+				rec.Event = presenceAsleep
+			}
+			records = append(records, rec)
+		}
+	}
+	return records
+}
+
+// timeAtHome sums how long a user was logged as present within [from, to).
+// Time before their first record is unknown and not counted.
+func timeAtHome(records []presenceRecord, userID string, from, to time.Time) time.Duration {
+	var total time.Duration
+	var since time.Time
+	home := false
+	add := func(end time.Time) {
+		if !home {
+			return
+		}
+		start := since
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if end.After(start) {
+			total += end.Sub(start)
+		}
+	}
+	for _, r := range records {
+		if r.UserID != userID {
+			continue
+		}
+		add(r.Time)
+		since, home = r.Time, r.Present
+	}
+	add(to)
+	return total
+}
+
+// weekStart returns the Monday 00:00 local time of the week containing t
+func weekStart(t time.Time) time.Time {
+	day := startOfDay(t)
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+type presenceWeek struct {
+	Week      string    `json:"week"`
+	Start     time.Time `json:"start"`
+	HoursHome float64   `json:"hoursHome"`
+}
+
+type presenceUserReport struct {
+	User          string         `json:"user"`
+	UserID        string         `json:"userId"`
+	Present       bool           `json:"present"`
+	Asleep        bool           `json:"asleep"`
+	LastArrival   *time.Time     `json:"lastArrival"`
+	LastDeparture *time.Time     `json:"lastDeparture"`
+	Weeks         []presenceWeek `json:"weeks"`
+}
+
+type presenceReport struct {
+	Since       *time.Time           `json:"since"`
+	Users       []presenceUserReport `json:"users"`
+	Transitions []presenceRecord     `json:"transitions"`
+}
+
+// buildPresenceReport summarizes the log: current state, last arrival and
+// departure, and time at home for each of the last weeks, oldest first
+func buildPresenceReport(records []presenceRecord, now time.Time, weeks, limit int) presenceReport {
+	report := presenceReport{Users: []presenceUserReport{}, Transitions: []presenceRecord{}}
+	if len(records) > 0 {
+		report.Since = &records[0].Time
+	}
+
+	latest := latestPresence(records)
+	for id, last := range latest {
+		u := presenceUserReport{User: last.User, UserID: id, Present: last.Present, Asleep: last.Asleep, Weeks: []presenceWeek{}}
+		for _, r := range records {
+			if r.UserID != id {
+				continue
+			}
+			t := r.Time
+			switch r.Event {
+			case presenceArrived:
+				u.LastArrival = &t
+			case presenceLeft:
+				u.LastDeparture = &t
+			}
+		}
+		current := weekStart(now)
+		for i := weeks - 1; i >= 0; i-- {
+			start := current.AddDate(0, 0, -7*i)
+			end := start.AddDate(0, 0, 7)
+			if end.After(now) {
+				end = now
+			}
+			year, week := start.ISOWeek()
+			hours := timeAtHome(records, id, start, end).Hours()
+			u.Weeks = append(u.Weeks, presenceWeek{
+				Week:      fmt.Sprintf("%d-W%02d", year, week),
+				Start:     start,
+				HoursHome: math.Round(hours*10) / 10,
+			})
+		}
+		report.Users = append(report.Users, u)
+	}
+	sort.Slice(report.Users, func(i, j int) bool {
+		return strings.ToLower(report.Users[i].User) < strings.ToLower(report.Users[j].User)
+	})
+
+	for i := len(records) - 1; i >= 0 && len(report.Transitions) < limit; i-- {
+		if records[i].Event != presenceStart {
+			report.Transitions = append(report.Transitions, records[i])
+		}
+	}
+	return report
+}
+
+// fetchUsers lists users sorted by name
+func fetchUsers() ([]User, error) {
+	data, err := apiClient.GetUsers()
+	if err != nil {
+		return nil, err
+	}
+	var users map[string]User
+	if err := json.Unmarshal(data, &users); err != nil {
+		return nil, fmt.Errorf("failed to parse users: %w", err)
+	}
+	list := make([]User, 0, len(users))
+	for _, u := range users {
+		list = append(list, u)
+	}
+	sort.Slice(list, func(i, j int) bool { return strings.ToLower(list[i].Name) < strings.ToLower(list[j].Name) })
+	return list, nil
+}
+
+// recordPresence polls users once and logs what changed since the last poll
+func recordPresence(now time.Time) ([]presenceRecord, error) {
+	users, err := fetchUsers()
+	if err != nil {
+		return nil, err
+	}
+	records, err := loadPresenceLog()
+	if err != nil {
+		return nil, err
+	}
+	changes := presenceTransitions(latestPresence(records), users, now)
+	if err := appendPresenceLog(changes); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+func describePresenceRecord(r presenceRecord) string {
+	if r.Event != presenceStart {
+		return r.Event
+	}
+	state := "away"
+	if r.Present {
+		state = "home"
+	}
+	if r.Asleep {
+		state += ", asleep"
+	}
+	return state + " (first record)"
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+var presenceStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show presence and sleep status of all users",
+	Long: `Show whether each user is home or away and asleep or awake.
+
+The Since column shows the last arrival or departure when 'homeyctl
+presence log' has recorded one.
+
+Examples:
+  homeyctl presence status
+  homeyctl presence status --json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		users, err := fetchUsers()
+		if err != nil {
+			return err
+		}
+
+		if isJSON() {
+			type userStatus struct {
+				ID      string `json:"id"`
+				Name    string `json:"name"`
+				Present bool   `json:"present"`
+				Asleep  bool   `json:"asleep"`
+			}
+			list := make([]userStatus, 0, len(users))
+			for _, u := range users {
+				list = append(list, userStatus{u.ID, u.Name, u.Present, u.Asleep})
+			}
+			out, _ := json.MarshalIndent(list, "", "  ")
+			fmt.Println(string(out))
+			return nil
+		}
+
+		records, err := loadPresenceLog()
+		if err != nil {
+			return err
+		}
+		since := map[string]*time.Time{}
+		for _, r := range records {
+			if r.Event == presenceArrived || r.Event == presenceLeft {
+				t := r.Time
+				since[r.UserID] = &t
+			}
+		}
+
+		headerFmt := color.New(color.FgCyan, color.Underline).SprintfFunc()
+		tbl := table.New("User", "Presence", "Sleep", "Since")
+		tbl.WithHeaderFormatter(headerFmt)
+		home := 0
+		for _, u := range users {
+			presence := color.YellowString("away")
+			if u.Present {
+				presence = color.GreenString("home")
+				home++
+			}
+			sleep := "awake"
+			if u.Asleep {
+				sleep = "asleep"
+			}
+			tbl.AddRow(u.Name, presence, sleep, formatOptionalTime(since[u.ID]))
+		}
+		tbl.Print()
+		fmt.Printf("\n%d of %d home\n", home, len(users))
+		return nil
+	},
+}
+
+var presenceLogCmd = &cobra.Command{
+	Use:   "log",
+	Short: "Record presence changes and report time at home",
+	Long: `Record presence and sleep changes in a local log and report on them.
+
+Each run polls users once and logs whoever arrived, left, fell asleep or
+woke up since the previous run, then reports the last arrival and
+departure and the hours at home per week. Run it from cron, or keep it
+running with --watch to poll every --interval.
+
+The log is kept in the homeyctl config directory
+(history/presence.jsonl). Time before a user's first record is not
+counted.
+
+Examples:
+  homeyctl presence log
+  homeyctl presence log --weeks 8 --json
+  homeyctl presence log --watch --interval 1m`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		watch, _ := cmd.Flags().GetBool("watch")
+		interval, _ := cmd.Flags().GetDuration("interval")
+		weeks, _ := cmd.Flags().GetInt("weeks")
+		limit, _ := cmd.Flags().GetInt("limit")
+
+		if watch {
+			if interval < time.Second {
+				return fmt.Errorf("--interval must be at least 1s")
+			}
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+			if !isJSON() {
+				fmt.Printf("Recording presence every %s (Ctrl+C to stop)\n", interval)
+			}
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			now := time.Now()
+			for {
+				changes, err := recordPresence(now)
+				if err != nil {
+					color.Yellow("Warning: %v\n", err)
+				}
+				for _, r := range changes {
+					if isJSON() {
+						out, _ := json.Marshal(r)
+						fmt.Println(string(out))
+					} else {
+						fmt.Printf("%s %s %s\n", r.Time.Local().Format("2006-01-02 15:04:05"), r.User, describePresenceRecord(r))
+					}
+				}
+				select {
+				case <-ctx.Done():
+					return nil
+				case now = <-ticker.C:
+				}
+			}
+		}
+
+		if weeks < 1 {
+			return fmt.Errorf("--weeks must be at least 1")
+		}
+		now := time.Now()
+		if _, err := recordPresence(now); err != nil {
+			return err
+		}
+		records, err := loadPresenceLog()
+		if err != nil {
+			return err
+		}
+		report := buildPresenceReport(records, now, weeks, limit)
+
+		if isJSON() {
+			out, _ := json.MarshalIndent(report, "", "  ")
+			fmt.Println(string(out))
+			return nil
+		}
+
+		headerFmt := color.New(color.FgCyan, color.Underline).SprintfFunc()
+		columns := []interface{}{"User", "Now", "Last Arrival", "Last Departure"}
+		if len(report.Users) > 0 {
+			for _, w := range report.Users[0].Weeks {
+				columns = append(columns, w.Week)
+			}
+		}
+		tbl := table.New(columns...)
+		tbl.WithHeaderFormatter(headerFmt)
+		for _, u := range report.Users {
+			state := "away"
+			if u.Present {
+				state = "home"
+			}
+			if u.Asleep {
+				state += ", asleep"
+			}
+			row := []interface{}{u.User, state, formatOptionalTime(u.LastArrival), formatOptionalTime(u.LastDeparture)}
+			for _, w := range u.Weeks {
+				row = append(row, fmt.Sprintf("%.1fh", w.HoursHome))
+			}
+			tbl.AddRow(row...)
+		}
+		tbl.Print()
+
+		if len(report.Transitions) > 0 {
+			color.New(color.Bold).Println("\nRecent changes")
+			for _, r := range report.Transitions {
+				fmt.Printf("  %s  %s %s\n", r.Time.Local().Format("2006-01-02 15:04"), r.User, r.Event)
+			}
+		}
+		if report.Since != nil {
+			fmt.Printf("\nRecording since %s\n", report.Since.Local().Format("2006-01-02 15:04"))
+		}
+		return nil
+	},
+}
+
+func init() {
+	presenceCmd.AddCommand(presenceStatusCmd)
+	presenceCmd.AddCommand(presenceLogCmd)
+	presenceLogCmd.Flags().Bool("watch", false, "Keep polling and print changes as they are recorded")
+	presenceLogCmd.Flags().Duration("interval", time.Minute, "Time between polls with --watch")
+	presenceLogCmd.Flags().Int("weeks", 4, "Number of weeks of time at home to report")
+	presenceLogCmd.Flags().Int("limit", 10, "Number of recent changes to show")
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fishfisher/homeyctl/internal/config"
+	"github.com/fishfisher/homeyctl/internal/fakehomey"
+)
+
+func TestPresenceTransitions(t *testing.T) {
+	t0 := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	users := []User{{ID: "u1", Name: "Ann", Present: true}, {ID: "u2", Name: "Bob"}}
+
+	records := presenceTransitions(nil, users, t0)
+	if len(records) != 2 || records[0].Event != presenceStart || records[1].Event != presenceStart {
+		t.Fatalf("expected start records, got %+v", records)
+	}
+	latest := latestPresence(records)
+	if got := presenceTransitions(latest, users, t0.Add(time.Minute)); len(got) != 0 {
+		t.Errorf("expected no changes, got %+v", got)
+	}
+
+	users[0].Present, users[0].Asleep = false, true
+	got := presenceTransitions(latest, users, t0.Add(time.Hour))
+	if len(got) != 2 || got[0].Event != presenceLeft || got[0].Asleep || got[1].Event != presenceAsleep || !got[1].Asleep {
+		t.Errorf("expected left then asleep, got %+v", got)
+	}
+}
+
+func TestTimeAtHomeAndReport(t *testing.T) {
+	// Monday 2 March 2026 is the start of ISO week 10
+	day := func(d, h int) time.Time { return time.Date(2026, 3, d, h, 0, 0, 0, time.Local) }
+	records := []presenceRecord{
+		{Time: day(1, 20), UserID: "u1", User: "Ann", Event: presenceStart, Present: true},
+		{Time: day(2, 8), UserID: "u1", User: "Ann", Event: presenceLeft},
+		{Time: day(2, 17), UserID: "u1", User: "Ann", Event: presenceArrived, Present: true},
+		{Time: day(3, 8), UserID: "u1", User: "Ann", Event: presenceLeft},
+		{Time: day(2, 12), UserID: "u2", User: "Bob", Event: presenceStart, Present: true},
+	}
+	now := day(3, 12)
+
+	if got := timeAtHome(records, "u1", day(2, 0), now); got != 23*time.Hour {
+		t.Errorf("Ann this week = %v, want 23h", got)
+	}
+	if got := timeAtHome(records, "u1", day(1, 0), day(2, 0)); got != 4*time.Hour {
+		t.Errorf("Ann last week = %v, want 4h", got)
+	}
+	if got := timeAtHome(records, "u2", day(2, 0), now); got != 24*time.Hour {
+		t.Errorf("Bob this week = %v, want 24h", got)
+	}
+
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Time.Before(records[j].Time) })
+	report := buildPresenceReport(records, now, 2, 10)
+	if len(report.Users) != 2 || report.Users[0].User != "Ann" {
+		t.Fatalf("unexpected users: %+v", report.Users)
+	}
+	ann := report.Users[0]
+	if ann.Present || !ann.LastDeparture.Equal(day(3, 8)) || !ann.LastArrival.Equal(day(2, 17)) {
+		t.Errorf("unexpected Ann report: %+v", ann)
+	}
+	if len(ann.Weeks) != 2 || ann.Weeks[0].Week != "2026-W09" || ann.Weeks[0].HoursHome != 4 || ann.Weeks[1].HoursHome != 23 {
+		t.Errorf("unexpected weeks: %+v", ann.Weeks)
+	}
+	if len(report.Transitions) != 3 || report.Transitions[0].Event != presenceLeft {
+		t.Errorf("expected newest transitions first, got %+v", report.Transitions)
+	}
+}
+
+func TestPresenceStatusAndLog(t *testing.T) {
+	t.Setenv(config.DirEnv, t.TempDir())
+	h := useFakeHomey(t, &fakehomey.Seed{
+		Users: fakehomey.Objects{
+			"u1": {"id": "u1", "name": "Ann", "present": true, "asleep": false},
+			"u2": {"id": "u2", "name": "Bob", "present": false, "asleep": true},
+		},
+	})
+
+	out, err := runCommand(t, "presence", "status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "Ann") || !strings.Contains(out, "asleep") || !strings.Contains(out, "1 of 2 home") {
+		t.Errorf("unexpected status output:\n%s", out)
+	}
+
+	if _, err := runCommand(t, "presence", "log"); err != nil {
+		t.Fatal(err)
+	}
+	if err := apiClient.SetPresent("u2", true); err != nil {
+		t.Fatal(err)
+	}
+	out, err = runCommand(t, "presence", "log", "--json", "--weeks", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report presenceReport
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, out)
+	}
+	if len(report.Users) != 2 || !report.Users[1].Present || report.Users[1].LastArrival == nil || len(report.Users[1].Weeks) != 1 {
+		t.Errorf("unexpected report: %+v", report.Users)
+	}
+	if len(report.Transitions) != 1 || report.Transitions[0].User != "Bob" || report.Transitions[0].Event != presenceArrived {
+		t.Errorf("unexpected transitions: %+v", report.Transitions)
+	}
+	if u := h.Object(fakehomey.Users, "u2"); u["present"] != true {
+		t.Errorf("expected fake Homey to have Bob home, got %v", u)
+	}
+
+	out, err = runCommand(t, "presence", "status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "2 of 2 home") || !strings.Contains(out, time.Now().Format("2006-01-02")) {
+		t.Errorf("expected Since from the log:\n%s", out)
+	}
+}
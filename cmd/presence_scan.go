@@ -0,0 +1,489 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/fishfisher/homeyctl/internal/config"
+	"github.com/rodaine/table"
+	"github.com/spf13/cobra"
+)
+
+// arpTablePath is the kernel ARP table on Linux. Elsewhere 'arp -an' is used.
+var arpTablePath = "/proc/net/arp"
+
+// pingHost reports whether ip answers one ping within a second
+var pingHost = func(ip string) bool {
+	args := []string{"-c", "1", "-W", "1", ip}
+	switch runtime.GOOS {
+	case "darwin", "freebsd":
+		args = []string{"-c", "1", "-t", "1", ip}
+	case "windows":
+		args = []string{"-n", "1", "-w", "1000", ip}
+	}
+	return exec.Command("ping", args...).Run() == nil
+}
+
+// normalizeMAC lowercases a MAC address and pads octets, since macOS
+// prints 0a:1:... as a:1:...
+func normalizeMAC(mac string) string {
+	parts := strings.FieldsFunc(strings.ToLower(mac), func(r rune) bool { return r == ':' || r == '-' })
+	if len(parts) != 6 {
+		return strings.ToLower(mac)
+	}
+	for i, p := range parts {
+		if len(p) == 1 {
+			parts[i] = "0" + p
+		}
+	}
+	return strings.Join(parts, ":")
+}
+
+// parseProcARP reads /proc/net/arp into IP → MAC, skipping incomplete entries
+func parseProcARP(data string) map[string]string {
+	table := map[string]string{}
+	for i, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if i == 0 || len(fields) < 4 || fields[2] == "0x0" || fields[3] == "00:00:00:00:00:00" {
+			continue
+		}
+		table[fields[0]] = normalizeMAC(fields[3])
+	}
+	return table
+}
+
+var arpLine = regexp.MustCompile(`\(([0-9.]+)\) at ([0-9a-fA-F:-]+)`)
+
+// parseArpCommand reads 'arp -an' output into IP → MAC
+func parseArpCommand(data string) map[string]string {
+	table := map[string]string{}
+	for _, m := range arpLine.FindAllStringSubmatch(data, -1) {
+		table[m[1]] = normalizeMAC(m[2])
+	}
+	return table
+}
+
+func readARPTable() (map[string]string, error) {
+	if data, err := os.ReadFile(arpTablePath); err == nil {
+		return parseProcARP(string(data)), nil
+	}
+	out, err := exec.Command("arp", "-an").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ARP table: %w", err)
+	}
+	return parseArpCommand(string(out)), nil
+}
+
+// dhcpLease is one active DHCP lease
+type dhcpLease struct {
+	IP       string
+	MAC      string
+	Hostname string
+}
+
+// parseLeases reads dnsmasq and ISC dhcpd lease files, keeping leases that
+// have not expired at now
+func parseLeases(data string, now time.Time) []dhcpLease {
+	if strings.Contains(data, "lease ") && strings.Contains(data, "{") {
+		return parseDhcpdLeases(data, now)
+	}
+	// dnsmasq: <expiry> <mac> <ip> <hostname> <client id>
+	var leases []dhcpLease
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		expiry, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil || (expiry != 0 && time.Unix(expiry, 0).Before(now)) {
+			continue
+		}
+		l := dhcpLease{MAC: normalizeMAC(fields[1]), IP: fields[2]}
+		if fields[3] != "*" {
+			l.Hostname = fields[3]
+		}
+		leases = append(leases, l)
+	}
+	return leases
+}
+
+func parseDhcpdLeases(data string, now time.Time) []dhcpLease {
+	// Later entries for the same address replace earlier ones
+	byIP := map[string]dhcpLease{}
+	var order []string
+	var cur *dhcpLease
+	active := false
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSuffix(strings.TrimSpace(scanner.Text()), ";")
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) >= 2 && fields[0] == "lease":
+			cur, active = &dhcpLease{IP: fields[1]}, true
+		case cur == nil:
+		case line == "}":
+			if active {
+				if _, seen := byIP[cur.IP]; !seen {
+					order = append(order, cur.IP)
+				}
+				byIP[cur.IP] = *cur
+			} else {
+				delete(byIP, cur.IP)
+			}
+			cur = nil
+		case len(fields) >= 3 && fields[0] == "hardware" && fields[1] == "ethernet":
+			cur.MAC = normalizeMAC(fields[2])
+		case len(fields) >= 2 && fields[0] == "client-hostname":
+			cur.Hostname = strings.Trim(fields[1], `"`)
+		case len(fields) >= 3 && fields[0] == "binding" && fields[1] == "state":
+			active = active && fields[2] == "active"
+		case len(fields) >= 4 && fields[0] == "ends":
+			// ends <weekday> <yyyy/mm/dd> <hh:mm:ss>, in UTC
+			if end, err := time.Parse("2006/01/02 15:04:05", fields[2]+" "+fields[3]); err == nil && end.Before(now) {
+				active = false
+			}
+		}
+	}
+	var leases []dhcpLease
+	for _, ip := range order {
+		if l, ok := byIP[ip]; ok {
+			leases = append(leases, l)
+		}
+	}
+	return leases
+}
+
+// presenceSighting is the result of scanning for one person
+type presenceSighting struct {
+	User string `json:"user"`
+	Seen bool   `json:"seen"`
+	Via  string `json:"via,omitempty"`
+}
+
+// scanPresence looks for each person's phone with the given methods: ping
+// of known addresses, the ARP table, and active DHCP leases. Pinging first
+// also fills the ARP table for phones that do not answer pings.
+func scanPresence(people []config.PresencePerson, methods map[string]bool, leaseFiles []string, now time.Time) ([]presenceSighting, error) {
+	var leases []dhcpLease
+	if methods["leases"] || methods["ping"] || methods["arp"] {
+		for _, path := range leaseFiles {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				if methods["leases"] {
+					return nil, fmt.Errorf("failed to read leases: %w", err)
+				}
+				continue
+			}
+			leases = append(leases, parseLeases(string(data), now)...)
+		}
+	}
+
+	sightings := make([]presenceSighting, len(people))
+	addresses := make([][]string, len(people))
+	for i, p := range people {
+		sightings[i].User = p.User
+		addresses[i] = append(addresses[i], p.IPs...)
+		for _, l := range leases {
+			if personHasLease(p, l) {
+				addresses[i] = append(addresses[i], l.IP)
+				if methods["leases"] && !sightings[i].Seen {
+					sightings[i] = presenceSighting{User: p.User, Seen: true, Via: "lease " + leaseLabel(l)}
+				}
+			}
+		}
+	}
+
+	if methods["ping"] {
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		for i := range people {
+			for _, ip := range addresses[i] {
+				wg.Add(1)
+				go func(i int, ip string) {
+					defer wg.Done()
+					if pingHost(ip) {
+						mu.Lock()
+						defer mu.Unlock()
+						if !sightings[i].Seen || !strings.HasPrefix(sightings[i].Via, "ping") {
+							sightings[i].Seen, sightings[i].Via = true, "ping "+ip
+						}
+					}
+				}(i, ip)
+			}
+		}
+		wg.Wait()
+	}
+
+	if methods["arp"] {
+		arp, err := readARPTable()
+		if err != nil {
+			return nil, err
+		}
+		for i, p := range people {
+			if sightings[i].Seen {
+				continue
+			}
+			for ip, mac := range arp {
+				if containsFold(addresses[i], ip) || containsFold(normalizeMACs(p.MACs), mac) {
+					sightings[i].Seen, sightings[i].Via = true, "arp "+ip
+					break
+				}
+			}
+		}
+	}
+	return sightings, nil
+}
+
+func personHasLease(p config.PresencePerson, l dhcpLease) bool {
+	return (l.MAC != "" && containsFold(normalizeMACs(p.MACs), l.MAC)) ||
+		(l.Hostname != "" && containsFold(p.Hostnames, l.Hostname)) ||
+		containsFold(p.IPs, l.IP)
+}
+
+func leaseLabel(l dhcpLease) string {
+	if l.Hostname != "" {
+		return l.Hostname
+	}
+	return l.IP
+}
+
+func normalizeMACs(macs []string) []string {
+	out := make([]string, len(macs))
+	for i, m := range macs {
+		out[i] = normalizeMAC(m)
+	}
+	return out
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// presenceTracker applies arrival and departure hysteresis to scans.
+// Phones drop off Wi-Fi to save power, so someone only leaves after not
+// being seen for awayAfter, and arrives after being seen for homeAfter.
+type presenceTracker struct {
+	homeAfter, awayAfter time.Duration
+	people               map[string]*trackedPresence
+}
+
+type trackedPresence struct {
+	present   bool
+	lastSeen  time.Time
+	seenSince time.Time
+}
+
+func newPresenceTracker(homeAfter, awayAfter time.Duration) *presenceTracker {
+	return &presenceTracker{homeAfter: homeAfter, awayAfter: awayAfter, people: map[string]*trackedPresence{}}
+}
+
+// start sets the presence Homey has now. Someone at home counts as seen
+// at now, so a first scan that misses them does not send them away.
+func (t *presenceTracker) start(user string, present bool, now time.Time) {
+	p := &trackedPresence{present: present}
+	if present {
+		p.lastSeen = now
+	}
+	t.people[user] = p
+}
+
+// observe records a scan and returns the presence to set when it should
+// change. The change only sticks once it is committed, so a failed update
+// is tried again on the next scan.
+func (t *presenceTracker) observe(user string, seen bool, now time.Time) (present, changed bool) {
+	p, ok := t.people[user]
+	if !ok {
+		p = &trackedPresence{}
+		t.people[user] = p
+	}
+	if seen {
+		p.lastSeen = now
+		if p.seenSince.IsZero() {
+			p.seenSince = now
+		}
+		if !p.present && now.Sub(p.seenSince) >= t.homeAfter {
+			return true, true
+		}
+		return p.present, false
+	}
+	p.seenSince = time.Time{}
+	if p.present && now.Sub(p.lastSeen) >= t.awayAfter {
+		return false, true
+	}
+	return p.present, false
+}
+
+// commit records that Homey now has the presence observe asked for
+func (t *presenceTracker) commit(user string, present bool) {
+	if p, ok := t.people[user]; ok {
+		p.present = present
+	}
+}
+
+func parseScanMethods(s string) (map[string]bool, error) {
+	methods := map[string]bool{}
+	for _, m := range strings.Split(s, ",") {
+		m = strings.TrimSpace(m)
+		switch m {
+		case "arp", "leases", "ping":
+			methods[m] = true
+		default:
+			return nil, fmt.Errorf("invalid method %q (use: arp, leases, ping)", m)
+		}
+	}
+	return methods, nil
+}
+
+var presenceScanCmd = &cobra.Command{
+	Use:   "scan",
+	Short: "Set presence from phones seen on the network",
+	Long: `Detect who is home by looking for their phones on the local network,
+and set Homey presence when it changes.
+
+Phones are found by:
+  ping     pinging their IPs (and the IPs of their DHCP leases)
+  arp      their IP or MAC in the ARP table
+  leases   an active DHCP lease for their MAC or host name
+
+Phones and lease files are configured under [presence] in config.toml; run
+'homeyctl presence example' for a sample. Phones sleep and drop off Wi-Fi,
+so someone is only set away after not being seen for away_after (default
+10m), and home after being seen for home_after (default right away). A
+DHCP lease stays active until it expires, so use short lease times or
+leave out leases for quick departures.
+
+Use --once to scan a single time and show who was seen, without changing
+presence.
+
+Examples:
+  homeyctl presence scan --once
+  homeyctl presence scan
+  homeyctl presence scan --interval 1m --methods ping,arp`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		interval, _ := cmd.Flags().GetDuration("interval")
+		once, _ := cmd.Flags().GetBool("once")
+		methodsStr, _ := cmd.Flags().GetString("methods")
+		methods, err := parseScanMethods(methodsStr)
+		if err != nil {
+			return fmt.Errorf("--methods: %w", err)
+		}
+
+		pc := cfg.Presence
+		if len(pc.People) == 0 {
+			return fmt.Errorf("no [[presence.people]] configured; run 'homeyctl presence example'")
+		}
+
+		if once {
+			sightings, err := scanPresence(pc.People, methods, pc.Leases, time.Now())
+			if err != nil {
+				return err
+			}
+			if isJSON() {
+				out, _ := json.MarshalIndent(sightings, "", "  ")
+				fmt.Println(string(out))
+				return nil
+			}
+			headerFmt := color.New(color.FgCyan, color.Underline).SprintfFunc()
+			tbl := table.New("User", "Seen", "Via")
+			tbl.WithHeaderFormatter(headerFmt)
+			for _, s := range sightings {
+				seen := color.YellowString("no")
+				if s.Seen {
+					seen = color.GreenString("yes")
+				}
+				tbl.AddRow(s.User, seen, s.Via)
+			}
+			tbl.Print()
+			return nil
+		}
+
+		if interval < time.Second {
+			return fmt.Errorf("--interval must be at least 1s")
+		}
+		users, err := presenceUsers(pc.People)
+		if err != nil {
+			return err
+		}
+		awayAfter := pc.AwayAfter
+		if awayAfter == 0 {
+			awayAfter = 10 * time.Minute
+		}
+		tracker := newPresenceTracker(pc.HomeAfter, awayAfter)
+		now := time.Now()
+		names := make([]string, 0, len(users))
+		for name, u := range users {
+			tracker.start(name, u.Present, now)
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		scan := func(now time.Time) {
+			sightings, err := scanPresence(pc.People, methods, pc.Leases, now)
+			if err != nil {
+				color.Yellow("Warning: scan failed: %v\n", err)
+				return
+			}
+			for _, s := range sightings {
+				present, changed := tracker.observe(s.User, s.Seen, now)
+				if !changed {
+					continue
+				}
+				u := users[s.User]
+				if err := setUserPresent(u, present); err != nil {
+					color.Red("Error: failed to set presence for %s: %v\n", u.Name, err)
+					continue
+				}
+				tracker.commit(s.User, present)
+				reason := "seen via " + s.Via
+				if !present {
+					reason = "not seen for " + awayAfter.String()
+				}
+				logPresenceChange(now, u.Name, present, reason)
+			}
+		}
+
+		if !isJSON() {
+			fmt.Printf("Scanning for %s every %s (Ctrl+C to stop)\n", strings.Join(names, ", "), interval)
+		}
+		scan(now)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case now := <-ticker.C:
+				scan(now)
+			}
+		}
+	},
+}
+
+func init() {
+	presenceCmd.AddCommand(presenceScanCmd)
+	presenceScanCmd.Flags().Duration("interval", 30*time.Second, "Time between scans")
+	presenceScanCmd.Flags().Bool("once", false, "Scan once and show who was seen, without changing presence")
+	presenceScanCmd.Flags().String("methods", "ping,arp,leases", "Comma-separated detection methods: ping, arp, leases")
+}
@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fishfisher/homeyctl/internal/config"
+)
+
+func TestParseARPTables(t *testing.T) {
+	proc := `IP address       HW type     Flags       HW address            Mask     Device
+192.168.1.23     0x1         0x2         AA:BB:CC:DD:EE:FF     *        wlan0
+192.168.1.40     0x1         0x0         00:00:00:00:00:00     *        wlan0
+`
+	if got := parseProcARP(proc); len(got) != 1 || got["192.168.1.23"] != "aa:bb:cc:dd:ee:ff" {
+		t.Errorf("parseProcARP = %v", got)
+	}
+
+	arp := `? (192.168.1.23) at a:bb:c:dd:ee:f on en0 ifscope [ethernet]
+? (192.168.1.40) at (incomplete) on en0 ifscope [ethernet]
+`
+	if got := parseArpCommand(arp); len(got) != 1 || got["192.168.1.23"] != "0a:bb:0c:dd:ee:0f" {
+		t.Errorf("parseArpCommand = %v", got)
+	}
+}
+
+func TestParseLeases(t *testing.T) {
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+
+	dnsmasq := strings.Join([]string{
+		"1772456400 aa:bb:cc:dd:ee:ff 192.168.1.23 anns-iphone 01:aa:bb:cc:dd:ee:ff",
+		"1600000000 11:22:33:44:55:66 192.168.1.24 old-phone *",
+		"0 22:33:44:55:66:77 192.168.1.25 * *",
+	}, "\n")
+	leases := parseLeases(dnsmasq, now)
+	if len(leases) != 2 || leases[0].Hostname != "anns-iphone" || leases[1].Hostname != "" {
+		t.Errorf("dnsmasq leases = %+v", leases)
+	}
+
+	dhcpd := `
+lease 192.168.1.23 {
+  starts 1 2026/03/02 10:00:00;
+  ends 1 2026/03/02 14:00:00;
+  binding state active;
+  hardware ethernet aa:bb:cc:dd:ee:ff;
+  client-hostname "anns-iphone";
+}
+lease 192.168.1.24 {
+  ends 1 2026/03/02 11:00:00;
+  binding state active;
+  hardware ethernet 11:22:33:44:55:66;
+}
+lease 192.168.1.25 {
+  ends 1 2026/03/02 14:00:00;
+  binding state free;
+  hardware ethernet 22:33:44:55:66:77;
+}
+`
+	leases = parseLeases(dhcpd, now)
+	if len(leases) != 1 || leases[0].IP != "192.168.1.23" || leases[0].MAC != "aa:bb:cc:dd:ee:ff" || leases[0].Hostname != "anns-iphone" {
+		t.Errorf("dhcpd leases = %+v", leases)
+	}
+}
+
+func TestScanPresence(t *testing.T) {
+	dir := t.TempDir()
+	arpTablePath = writeFile(t, dir, "arp", "IP address HW type Flags HW address Mask Device\n192.168.1.30 0x1 0x2 11:22:33:44:55:66 * wlan0\n")
+	t.Cleanup(func() { arpTablePath = "/proc/net/arp" })
+	leases := writeFile(t, dir, "leases", "0 aa:bb:cc:dd:ee:ff 192.168.1.23 anns-iphone *\n")
+
+	var mu sync.Mutex
+	var pinged []string
+	origPing := pingHost
+	pingHost = func(ip string) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		pinged = append(pinged, ip)
+		return ip == "192.168.1.23"
+	}
+	t.Cleanup(func() { pingHost = origPing })
+
+	people := []config.PresencePerson{
+		{User: "Ann", Hostnames: []string{"anns-iphone"}},
+		{User: "Bob", MACs: []string{"11:22:33:44:55:66"}},
+		{User: "Cat", IPs: []string{"192.168.1.50"}},
+	}
+	methods, _ := parseScanMethods("ping,arp")
+	sightings, err := scanPresence(people, methods, []string{leases}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []presenceSighting{
+		{User: "Ann", Seen: true, Via: "ping 192.168.1.23"},
+		{User: "Bob", Seen: true, Via: "arp 192.168.1.30"},
+		{User: "Cat"},
+	}
+	for i, s := range sightings {
+		if s != want[i] {
+			t.Errorf("sighting %d = %+v, want %+v", i, s, want[i])
+		}
+	}
+	if len(pinged) != 2 {
+		t.Errorf("expected the lease and configured IPs to be pinged, got %v", pinged)
+	}
+
+	methods, _ = parseScanMethods("leases")
+	sightings, _ = scanPresence(people, methods, []string{leases}, time.Now())
+	if !sightings[0].Seen || sightings[0].Via != "lease anns-iphone" || sightings[1].Seen {
+		t.Errorf("unexpected lease sightings: %+v", sightings)
+	}
+
+	if _, err := parseScanMethods("ping,wifi"); err == nil {
+		t.Error("expected invalid method error")
+	}
+}
+
+func TestPresenceTracker(t *testing.T) {
+	start := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	at := func(m int) time.Time { return start.Add(time.Duration(m) * time.Minute) }
+	tracker := newPresenceTracker(2*time.Minute, 10*time.Minute)
+	tracker.start("Ann", true, start)
+	tracker.start("Bob", false, start)
+
+	steps := []struct {
+		minute  int
+		user    string
+		seen    bool
+		present bool
+		changed bool
+	}{
+		{0, "Ann", false, true, false},  // a missed scan does not send Ann away
+		{5, "Ann", true, true, false},   // seen again
+		{14, "Ann", false, true, false}, // 9 minutes unseen
+		{15, "Ann", false, false, true}, // 10 minutes unseen
+		{0, "Bob", true, false, false},  // seen, waiting for home_after
+		{1, "Bob", false, false, false}, // a gap restarts the wait
+		{2, "Bob", true, false, false},
+		{4, "Bob", true, true, true},
+		{5, "Bob", true, true, false},
+	}
+	for _, s := range steps {
+		present, changed := tracker.observe(s.user, s.seen, at(s.minute))
+		if present != s.present || changed != s.changed {
+			t.Errorf("%s at +%dm seen=%v: got present=%v changed=%v, want %v %v", s.user, s.minute, s.seen, present, changed, s.present, s.changed)
+		}
+		if changed {
+			tracker.commit(s.user, present)
+		}
+	}
+
+	// Without a commit, as when setting presence fails, the change is
+	// asked for again on the next scan
+	tracker.start("Cat", false, start)
+	tracker.observe("Cat", true, at(0))
+	if present, changed := tracker.observe("Cat", true, at(2)); !present || !changed {
+		t.Fatalf("expected Cat to arrive, got %v %v", present, changed)
+	}
+	if present, changed := tracker.observe("Cat", true, at(3)); !present || !changed {
+		t.Errorf("expected the uncommitted arrival to be retried, got %v %v", present, changed)
+	}
+	tracker.commit("Cat", true)
+	if _, changed := tracker.observe("Cat", true, at(4)); changed {
+		t.Error("expected no change after the commit")
+	}
+}
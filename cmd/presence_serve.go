@@ -0,0 +1,358 @@
+package cmd
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/fishfisher/homeyctl/internal/config"
+	"github.com/spf13/cobra"
+)
+
+const presenceExample = `# Presence detection for 'homeyctl presence serve' and 'presence scan'
+# Add to config.toml
+
+[presence]
+# Required in webhook URLs (?secret=...), or as the HTTP basic auth password
+secret = "change-me-to-something-long"
+# Only geofence events for this region count; leave empty to accept any
+home_region = "Home"
+# presence scan: seen this long before arriving, unseen this long before leaving
+home_after = "0s"
+away_after = "10m"
+# DHCP lease files (dnsmasq or ISC dhcpd)
+leases = ["/var/lib/misc/dnsmasq.leases"]
+
+[[presence.people]]
+user = "Ann"                      # Homey user name or ID
+ids = ["ann", "ann/iphone"]       # OwnTracks user, device or user/device, Locative device ID
+ips = ["192.168.1.23"]
+macs = ["aa:bb:cc:dd:ee:ff"]
+hostnames = ["anns-iphone"]
+`
+
+// presenceUsers resolves the configured people to Homey users
+func presenceUsers(people []config.PresencePerson) (map[string]*User, error) {
+	if len(people) == 0 {
+		return nil, fmt.Errorf("no [[presence.people]] configured; run 'homeyctl presence example'")
+	}
+	users, err := fetchUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := map[string]*User{}
+	for _, p := range people {
+		var found *User
+		for i, u := range users {
+			if u.ID == p.User || strings.EqualFold(u.Name, p.User) {
+				found = &users[i]
+				break
+			}
+		}
+		if found == nil {
+			return nil, fmt.Errorf("presence config: user not found: %s", p.User)
+		}
+		resolved[p.User] = found
+	}
+	return resolved, nil
+}
+
+// setUserPresent sets a user home or away, and lets a dry run carry on
+func setUserPresent(u *User, present bool) error {
+	return ignoreDryRun(apiClient.SetPresent(u.ID, present))
+}
+
+// logPresenceChange prints one presence change as a log line
+func logPresenceChange(now time.Time, user string, present bool, reason string) {
+	if isJSON() {
+		out, _ := json.Marshal(map[string]interface{}{"time": now, "user": user, "present": present, "reason": reason})
+		fmt.Println(string(out))
+		return
+	}
+	status := color.GreenString("home")
+	if !present {
+		status = color.YellowString("away")
+	}
+	fmt.Printf("%s %s %s (%s)\n", now.Format("15:04:05"), user, status, reason)
+}
+
+// geofenceEvent is an enter or leave event from a geofence app
+type geofenceEvent struct {
+	IDs     []string // Identifiers of the sender, matched against presence.people ids
+	Present bool
+	Region  string
+	Ignore  bool // Not a presence change, like an ordinary location update
+}
+
+// parseOwnTracks reads an OwnTracks HTTP mode message. Transitions give
+// enter and leave; with a home region, location updates also tell whether
+// the phone is inside it.
+func parseOwnTracks(r *http.Request, homeRegion string) (*geofenceEvent, error) {
+	var msg struct {
+		Type      string    `json:"_type"`
+		Event     string    `json:"event"`
+		Desc      string    `json:"desc"`
+		TID       string    `json:"tid"`
+		Topic     string    `json:"topic"`
+		InRegions *[]string `json:"inregions"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&msg); err != nil {
+		return nil, fmt.Errorf("invalid OwnTracks message: %w", err)
+	}
+
+	ev := &geofenceEvent{}
+	user, device := r.Header.Get("X-Limit-U"), r.Header.Get("X-Limit-D")
+	if u, _, ok := r.BasicAuth(); ok && user == "" {
+		user = u
+	}
+	// Topics look like owntracks/<user>/<device>
+	if parts := strings.Split(msg.Topic, "/"); len(parts) == 3 {
+		if user == "" {
+			user = parts[1]
+		}
+		if device == "" {
+			device = parts[2]
+		}
+	}
+	for _, id := range []string{user, device, msg.TID} {
+		if id != "" {
+			ev.IDs = append(ev.IDs, id)
+		}
+	}
+	if user != "" && device != "" {
+		ev.IDs = append(ev.IDs, user+"/"+device)
+	}
+
+	switch msg.Type {
+	case "transition":
+		ev.Region = msg.Desc
+		ev.Present = msg.Event == "enter"
+		ev.Ignore = homeRegion != "" && !strings.EqualFold(msg.Desc, homeRegion)
+	case "location":
+		ev.Ignore = homeRegion == ""
+		ev.Region = homeRegion
+		if msg.InRegions != nil {
+			for _, region := range *msg.InRegions {
+				if strings.EqualFold(region, homeRegion) {
+					ev.Present = true
+				}
+			}
+		}
+	default:
+		ev.Ignore = true
+	}
+	return ev, nil
+}
+
+// parseLocative reads a Locative webhook, sent as a form or as JSON
+func parseLocative(r *http.Request, homeRegion string) (*geofenceEvent, error) {
+	var msg struct {
+		Trigger string `json:"trigger"`
+		Device  string `json:"device"`
+		ID      string `json:"id"`
+	}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&msg); err != nil {
+			return nil, fmt.Errorf("invalid Locative message: %w", err)
+		}
+	} else {
+		if err := r.ParseForm(); err != nil {
+			return nil, fmt.Errorf("invalid Locative message: %w", err)
+		}
+		msg.Trigger, msg.Device, msg.ID = r.Form.Get("trigger"), r.Form.Get("device"), r.Form.Get("id")
+	}
+	if msg.Device == "" {
+		return nil, fmt.Errorf("invalid Locative message: no device")
+	}
+
+	ev := &geofenceEvent{IDs: []string{msg.Device}, Region: msg.ID}
+	switch msg.Trigger {
+	case "enter":
+		ev.Present = true
+	case "exit":
+	default:
+		ev.Ignore = true
+	}
+	if homeRegion != "" && !strings.EqualFold(msg.ID, homeRegion) {
+		ev.Ignore = true
+	}
+	return ev, nil
+}
+
+// presenceWebhook receives geofence webhooks and sets Homey presence
+type presenceWebhook struct {
+	cfg   config.PresenceConfig
+	users map[string]*User
+	set   func(u *User, present bool) error
+}
+
+func (h *presenceWebhook) personFor(ids []string) *config.PresencePerson {
+	for i, p := range h.cfg.People {
+		for _, want := range p.IDs {
+			for _, id := range ids {
+				if strings.EqualFold(id, want) {
+					return &h.cfg.People[i]
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (h *presenceWebhook) authorized(r *http.Request) bool {
+	if h.cfg.Secret == "" {
+		return true
+	}
+	given := r.URL.Query().Get("secret")
+	if _, pass, ok := r.BasicAuth(); ok {
+		given = pass
+	} else if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		given = token
+	}
+	return subtle.ConstantTimeCompare([]byte(given), []byte(h.cfg.Secret)) == 1
+}
+
+func (h *presenceWebhook) handler(source string, parse func(*http.Request, string) (*geofenceEvent, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !h.authorized(r) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		ev, err := parse(r, h.cfg.HomeRegion)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// OwnTracks expects a JSON array of messages to deliver back
+		reply := func() {
+			if source == "owntracks" {
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprint(w, "[]")
+				return
+			}
+			fmt.Fprint(w, "OK")
+		}
+		if ev.Ignore {
+			reply()
+			return
+		}
+
+		person := h.personFor(ev.IDs)
+		if person == nil {
+			// Answer with success so the app does not keep retrying
+			color.Yellow("Warning: %s event from unknown id %s\n", source, strings.Join(ev.IDs, ", "))
+			reply()
+			return
+		}
+		u := h.users[person.User]
+		if err := h.set(u, ev.Present); err != nil {
+			color.Red("Error: failed to set presence for %s: %v\n", u.Name, err)
+			http.Error(w, "failed to update Homey", http.StatusBadGateway)
+			return
+		}
+		verb := "enter"
+		if !ev.Present {
+			verb = "leave"
+		}
+		reason := source + " " + verb
+		if ev.Region != "" {
+			reason += " " + ev.Region
+		}
+		logPresenceChange(time.Now(), u.Name, ev.Present, reason)
+		reply()
+	}
+}
+
+func (h *presenceWebhook) mux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/owntracks", h.handler("owntracks", parseOwnTracks))
+	mux.HandleFunc("/locative", h.handler("locative", parseLocative))
+	return mux
+}
+
+var presenceServeCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Receive geofence webhooks and set presence",
+	Long: `Run a webhook server that sets Homey presence from geofence apps.
+
+Endpoints:
+  POST /owntracks   OwnTracks in HTTP mode (transition and location messages)
+  POST /locative    Locative, as a form or JSON
+
+Senders are matched to Homey users with [[presence.people]] ids in
+config.toml: the OwnTracks user, device, user/device or tracker ID, or the
+Locative device ID. Set home_region to only react to your home geofence.
+
+When [presence] secret is set, requests must carry it as ?secret=..., as
+the HTTP basic auth password, or as a Bearer token. Put the server behind
+HTTPS (a reverse proxy or tunnel) before exposing it to the internet.
+
+Run 'homeyctl presence example' for a sample configuration.
+
+Examples:
+  homeyctl presence serve
+  homeyctl presence serve --listen :8080
+  # OwnTracks URL: https://example.com/owntracks?secret=...`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		listen, _ := cmd.Flags().GetString("listen")
+
+		users, err := presenceUsers(cfg.Presence.People)
+		if err != nil {
+			return err
+		}
+		hook := &presenceWebhook{cfg: cfg.Presence, users: users, set: setUserPresent}
+		if cfg.Presence.Secret == "" {
+			color.Yellow("Warning: no [presence] secret set; anyone who can reach the server can change presence\n")
+		}
+
+		server := &http.Server{Addr: listen, Handler: hook.mux(), ReadHeaderTimeout: 10 * time.Second}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		go func() {
+			<-ctx.Done()
+			shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			server.Shutdown(shutdown)
+		}()
+
+		if !isJSON() {
+			fmt.Printf("Listening on %s for /owntracks and /locative (Ctrl+C to stop)\n", listen)
+		}
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	},
+}
+
+var presenceExampleCmd = &cobra.Command{
+	Use:   "example",
+	Short: "Print an example presence configuration",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fmt.Print(presenceExample)
+		return nil
+	},
+}
+
+func init() {
+	presenceCmd.AddCommand(presenceServeCmd)
+	presenceCmd.AddCommand(presenceExampleCmd)
+	presenceServeCmd.Flags().String("listen", ":8765", "Address to listen on")
+}
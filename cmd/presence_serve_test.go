@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/fishfisher/homeyctl/internal/config"
+	"github.com/fishfisher/homeyctl/internal/fakehomey"
+)
+
+func TestPresenceWebhook(t *testing.T) {
+	type call struct {
+		user    string
+		present bool
+	}
+	var calls []call
+	hook := &presenceWebhook{
+		cfg: config.PresenceConfig{
+			Secret:     "s3cret",
+			HomeRegion: "Home",
+			People: []config.PresencePerson{
+				{User: "Ann", IDs: []string{"ann/iphone"}},
+				{User: "Bob", IDs: []string{"B0B-UUID"}},
+			},
+		},
+		users: map[string]*User{"Ann": {ID: "u1", Name: "Ann"}, "Bob": {ID: "u2", Name: "Bob"}},
+		set: func(u *User, present bool) error {
+			calls = append(calls, call{u.Name, present})
+			return nil
+		},
+	}
+	server := httptest.NewServer(hook.mux())
+	defer server.Close()
+
+	post := func(path, contentType, body string, header http.Header) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest("POST", server.URL+path, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	owntracks := http.Header{"X-Limit-U": {"ann"}, "X-Limit-D": {"iphone"}}
+
+	if resp := post("/owntracks", "application/json", `{"_type":"transition","event":"enter","desc":"Home"}`, owntracks); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected 401 without secret, got %d", resp.StatusCode)
+	}
+
+	steps := []struct {
+		path, contentType, body string
+		header                  http.Header
+	}{
+		{"/owntracks?secret=s3cret", "application/json", `{"_type":"transition","event":"enter","desc":"Home"}`, owntracks},
+		{"/owntracks?secret=s3cret", "application/json", `{"_type":"transition","event":"leave","desc":"Work"}`, owntracks},
+		{"/owntracks?secret=s3cret", "application/json", `{"_type":"location","lat":1,"lon":2}`, owntracks},
+		{"/owntracks?secret=s3cret", "application/json", `{"_type":"location","topic":"owntracks/ann/iphone","inregions":["home"]}`, nil},
+		{"/locative", "application/x-www-form-urlencoded", url.Values{"trigger": {"exit"}, "device": {"b0b-uuid"}, "id": {"Home"}}.Encode(), http.Header{"Authorization": {"Bearer s3cret"}}},
+		{"/locative?secret=s3cret", "application/json", `{"trigger":"enter","device":"unknown","id":"Home"}`, nil},
+	}
+	for _, s := range steps {
+		if resp := post(s.path, s.contentType, s.body, s.header); resp.StatusCode != http.StatusOK {
+			t.Errorf("POST %s %s: status %d", s.path, s.body, resp.StatusCode)
+		}
+	}
+	want := []call{{"Ann", true}, {"Ann", false}, {"Ann", true}, {"Bob", false}}
+	if len(calls) != len(want) {
+		t.Fatalf("calls = %+v, want %+v", calls, want)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Errorf("call %d = %+v, want %+v", i, calls[i], want[i])
+		}
+	}
+
+	if resp := post("/locative?secret=s3cret", "application/json", `{"trigger":"enter"}`, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for a message without device, got %d", resp.StatusCode)
+	}
+}
+
+func TestPresenceUsers(t *testing.T) {
+	useFakeHomey(t, &fakehomey.Seed{
+		Users: fakehomey.Objects{
+			"u1": {"id": "u1", "name": "Ann", "present": true},
+		},
+	})
+	if _, err := runCommand(t, "users", "list"); err != nil {
+		t.Fatal(err)
+	}
+
+	users, err := presenceUsers([]config.PresencePerson{{User: "ann"}, {User: "u1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if users["ann"].ID != "u1" || !users["u1"].Present {
+		t.Errorf("unexpected users: %+v", users)
+	}
+	if _, err := presenceUsers([]config.PresencePerson{{User: "Zed"}}); err == nil || !strings.Contains(err.Error(), "user not found: Zed") {
+		t.Errorf("expected unknown user error, got %v", err)
+	}
+	if _, err := presenceUsers(nil); err == nil || !strings.Contains(err.Error(), "presence example") {
+		t.Errorf("expected missing config error, got %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/rodaine/table"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/fishfisher/homeyctl/internal/client"
+	"github.com/fishfisher/homeyctl/internal/config"
+)
+
+// runReplay executes a whole command against the recorded fixtures in
+// testdata/replay/<scenario> and returns what it printed to stdout.
+func runReplay(t *testing.T, scenario string, args ...string) (string, error) {
+	t.Helper()
+	dir, err := filepath.Abs(filepath.Join("testdata", "replay", scenario))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOMEYCTL_REPLAY", dir)
+	return runCommand(t, args...)
+}
+
+// runCommand executes a whole command and returns what it printed to stdout
+func runCommand(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	if os.Getenv(config.DirEnv) == "" {
+		t.Setenv(config.DirEnv, t.TempDir())
+	}
+
+	// Flags bound to package variables keep their values between runs
+	jsonFlag, dryRunFlag, verboseFlag, traceFlag, recordDir = false, false, false, false, ""
+	devicesMatchFilter = ""
+	resetFlags(rootCmd)
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, colorOut, tableOut := os.Stdout, color.Output, table.DefaultWriter
+	os.Stdout, color.Output, table.DefaultWriter = w, w, w
+
+	done := make(chan string)
+	go func() {
+		out, _ := io.ReadAll(r)
+		done <- string(out)
+	}()
+
+	// Keep cobra's usage output on errors out of the test log
+	rootCmd.SetOut(io.Discard)
+	rootCmd.SetErr(io.Discard)
+	defer rootCmd.SetOut(nil)
+	defer rootCmd.SetErr(nil)
+
+	rootCmd.SetArgs(args)
+	runErr := rootCmd.Execute()
+
+	w.Close()
+	os.Stdout, color.Output, table.DefaultWriter = stdout, colorOut, tableOut
+	return <-done, runErr
+}
+
+// resetFlags puts flags changed by an earlier run back to their defaults
+func resetFlags(cmd *cobra.Command) {
+	reset := func(f *pflag.Flag) {
+		if !f.Changed {
+			return
+		}
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			sv.Replace(nil)
+		} else {
+			f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	}
+	cmd.Flags().VisitAll(reset)
+	cmd.PersistentFlags().VisitAll(reset)
+	for _, sub := range cmd.Commands() {
+		resetFlags(sub)
+	}
+}
+
+func TestReplay_DevicesList(t *testing.T) {
+	out, err := runReplay(t, "home", "devices", "list")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{"Kitchen lamp", "Hall sensor", "light", "d2"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	out, err = runReplay(t, "home", "devices", "list", "--json", "--match", "hall")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out, `"name": "Hall sensor"`) || strings.Contains(out, "Kitchen lamp") {
+		t.Errorf("unexpected JSON output:\n%s", out)
+	}
+}
+
+func TestReplay_DryRunDoesNotMutate(t *testing.T) {
+	// The replay has no PUT for z1, so sending it would fail
+	out, err := runReplay(t, "home", "zones", "rename", "Kitchen", "Cookhouse", "--dry-run")
+	if !errors.Is(err, client.ErrDryRun) {
+		t.Fatalf("expected dry run to stop the command, got %v", err)
+	}
+	for _, want := range []string{"DRY RUN: PUT /api/manager/zones/zone/z1", `~ /name: "Kitchen" → "Cookhouse"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "Renamed zone") {
+		t.Errorf("command reported success in a dry run:\n%s", out)
+	}
+}
+
+func TestReplay_ErrorResponse(t *testing.T) {
+	_, err := runReplay(t, "home", "zones", "delete", "Hall")
+	if err == nil || !strings.Contains(err.Error(), "status 409: Zone is not empty") {
+		t.Errorf("expected recorded 409, got %v", err)
+	}
+}
+
+func TestReplay_FlowUpdateRecordsHistory(t *testing.T) {
+	t.Setenv(config.DirEnv, t.TempDir())
+
+	out, err := runReplay(t, "home", "flows", "update", "Door", "--data", `{"name":"Front door","trigger":{"id":"homey:manager:presence:user_enter"}}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out, "Updated flow: Door") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+
+	out, err = runReplay(t, "home", "flows", "history", "Door")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out, "v1") || !strings.Contains(out, "update") {
+		t.Errorf("expected recorded v1 in history:\n%s", out)
+	}
+}
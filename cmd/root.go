@@ -2,7 +2,9 @@ package cmd
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"runtime/debug"
 	"strings"
@@ -17,7 +19,11 @@ var (
 	cfg       *config.Config
 	apiClient *client.Client
 
-	jsonFlag bool
+	jsonFlag    bool
+	dryRunFlag  bool
+	verboseFlag bool
+	traceFlag   bool
+	recordDir   string
 
 	versionInfo struct {
 		Version string
@@ -99,8 +105,9 @@ var rootCmd = &cobra.Command{
 			cmd.Name() == "set-host" || cmd.Name() == "show" ||
 			cmd.Name() == "completion" || cmd.Name() == "install-skill" ||
 			cmd.Name() == "auth" || cmd.Name() == "login" || cmd.Name() == "api-key" ||
-			cmd.Name() == "status" || cmd.Name() == "scopes" ||
+			cmd.Name() == "scopes" ||
 			strings.HasPrefix(cmdPath, "homeyctl auth") ||
+			cmdPath == "homeyctl dev" || strings.HasPrefix(cmdPath, "homeyctl dev ") ||
 			cmdPath == "homeyctl" {
 			return nil
 		}
@@ -111,23 +118,90 @@ var rootCmd = &cobra.Command{
 			return fmt.Errorf("failed to load config: %w", err)
 		}
 
-		if cfg.Token == "" {
+		replayDir := os.Getenv("HOMEYCTL_REPLAY")
+		if cfg.EffectiveToken() == "" && replayDir == "" {
 			return fmt.Errorf("no API token configured. Run: homeyctl auth")
 		}
 
 		apiClient = client.New(cfg)
+		if err := setupTransports(replayDir); err != nil {
+			return err
+		}
+		if dryRunFlag {
+			cmd.SilenceUsage = true
+		}
 		return nil
 	},
 }
 
+// setupTransports layers replay, recording, tracing and dry-run over the
+// client's transport, innermost first. Tracing sits below dry-run so only
+// requests that are actually sent get logged.
+func setupTransports(replayDir string) error {
+	if replayDir != "" {
+		replay, err := client.NewReplayTransport(replayDir)
+		if err != nil {
+			return fmt.Errorf("HOMEYCTL_REPLAY: %w", err)
+		}
+		apiClient.WrapTransport(func(http.RoundTripper) http.RoundTripper { return replay })
+	}
+	if recordDir != "" {
+		apiClient.WrapTransport(func(next http.RoundTripper) http.RoundTripper {
+			return client.NewRecordTransport(next, recordDir)
+		})
+	}
+	if verboseFlag || traceFlag {
+		apiClient.WrapTransport(func(next http.RoundTripper) http.RoundTripper {
+			return client.NewTraceTransport(next, os.Stderr, traceFlag)
+		})
+	}
+	if dryRunFlag {
+		apiClient.WrapTransport(func(next http.RoundTripper) http.RoundTripper {
+			return client.NewDryRunTransport(next, os.Stdout)
+		})
+	}
+	return nil
+}
+
+// errFalse ends a command with exit status 1 and no error message, for
+// checks that scripts test like test(1)
+var errFalse = errors.New("false")
+
+// exitCodeError ends a command with a specific exit status, so scripts can
+// tell a failed check (errFalse) from a failure to check at all
+type exitCodeError struct {
+	code int
+	err  error
+}
+
+func (e *exitCodeError) Error() string { return e.err.Error() }
+func (e *exitCodeError) Unwrap() error { return e.err }
+
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
+		// A dry run stops at the first mutating request, which is success
+		if errors.Is(err, client.ErrDryRun) {
+			return
+		}
+		if errors.Is(err, errFalse) {
+			os.Exit(1)
+		}
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		var exitErr *exitCodeError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.code)
+		}
 		os.Exit(1)
 	}
 }
 
 func init() {
 	rootCmd.PersistentFlags().BoolVar(&jsonFlag, "json", false, "Output in JSON format")
+	rootCmd.PersistentFlags().BoolVar(&dryRunFlag, "dry-run", false, "Print mutating requests instead of sending them")
+	rootCmd.PersistentFlags().BoolVar(&verboseFlag, "verbose", false, "Log each request with status and timing to stderr")
+	rootCmd.PersistentFlags().BoolVar(&traceFlag, "trace", false, "Like --verbose, with headers and bodies (Authorization redacted)")
+	rootCmd.PersistentFlags().StringVar(&recordDir, "record", "", "Save request/response pairs as fixtures in this directory (credentials are redacted; review before sharing)")
+	rootCmd.SilenceErrors = true
 	rootCmd.Flags().BoolP("version", "v", false, "Print version")
 }
 
@@ -22,6 +22,7 @@ func TestCommandSkipsConfigLoading(t *testing.T) {
 		{"completion command", "homeyctl completion", "completion", true},
 		{"install-skill command", "homeyctl install-skill", "install-skill", true},
 		{"root command", "homeyctl", "homeyctl", true},
+		{"dev fake-homey", "homeyctl dev fake-homey", "fake-homey", true},
 
 		// Auth commands that should skip config loading
 		{"auth command", "homeyctl auth", "auth", true},
@@ -48,6 +49,7 @@ func TestCommandSkipsConfigLoading(t *testing.T) {
 		{"devices get command", "homeyctl devices get", "get", false},
 		{"devices set command", "homeyctl devices set", "set", false},
 		{"zones list command", "homeyctl zones list", "list", false},
+		{"zones status command", "homeyctl zones status", "status", false},
 	}
 
 	for _, tc := range skipCommands {
@@ -84,11 +86,21 @@ func shouldSkipConfigLoading(cmdPath, cmdName string) bool {
 		cmdName == "set-host" || cmdName == "show" ||
 		cmdName == "completion" || cmdName == "install-skill" ||
 		cmdName == "auth" || cmdName == "login" || cmdName == "api-key" ||
-		cmdName == "status" || cmdName == "scopes" ||
+		cmdName == "scopes" ||
 		strings.HasPrefix(cmdPath, "homeyctl auth") ||
+		cmdPath == "homeyctl dev" || strings.HasPrefix(cmdPath, "homeyctl dev ") ||
 		cmdPath == "homeyctl" {
 		return true
 	}
 
 	return false
 }
+
+func TestDryRunFlagIsGlobal(t *testing.T) {
+	if rootCmd.PersistentFlags().Lookup("dry-run") == nil {
+		t.Fatal("expected persistent --dry-run flag on root command")
+	}
+	if _, _, err := rootCmd.Find([]string{"zones", "delete"}); err != nil {
+		t.Fatalf("zones delete not found: %v", err)
+	}
+}
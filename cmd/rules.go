@@ -0,0 +1,487 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/fishfisher/homeyctl/internal/client"
+	"github.com/fishfisher/homeyctl/internal/rules"
+	"github.com/rodaine/table"
+	"github.com/spf13/cobra"
+)
+
+var rulesCmd = &cobra.Command{
+	Use:   "rules",
+	Short: "Run local automation rules",
+	Long: `Run automation rules from a YAML file on this machine, next to Homey's own flows.
+
+A rules file lists rules, each with triggers (when), optional conditions
+(if) and actions (then):
+
+  rules:
+    - name: Hall light off
+      when:
+        device: Hall sensor
+        capability: alarm_motion
+        becomes: false
+        for: 20m
+      if: time.hour >= 7 && user.ann.present
+      then:
+        - set: { device: Hall light, capability: onoff, value: false }
+
+    - name: Expensive power
+      when: { price: now, above: 2.5, hysteresis: 0.3 }
+      debounce: 1h
+      then:
+        - mood: Power saver
+        - notify: "Power costs {{ round(price * 100) / 100 }} now"
+
+    - name: Morning
+      when: { time: "06:30", days: [mon, tue, wed, thu, fri] }
+      then: [{ flow: Good morning }]
+
+Triggers watch one of:
+  device + capability    a device capability, by device name
+  variable               a logic variable, by name
+  presence               a user, with becomes: home, away, asleep or awake
+  price: now             the current electricity price
+  time: "HH:MM"          a time of day, optionally on some days
+
+and fire when the value changes, or with becomes, above or below, when that
+starts to hold. hysteresis keeps above/below on until the value has moved
+back past the threshold by that margin. for waits until the condition has
+held that long, so "becomes: false, for: 20m" on alarm_motion means no
+motion for 20 minutes. debounce on a rule skips it while it ran recently.
+
+Conditions and {{ }} in notifications are expressions over the current state:
+  device.<device>.<capability>   vars.<variable>   user.<user>.present
+  user.<user>.asleep   price   time.hour   time.minute   time.weekday
+  value and previous             the triggering value and the one before
+Names are lowercased with spaces and punctuation turned into _, so
+"Hall sensor" is device.hall_sensor.
+
+Actions: set (with value, or expr for a computed value), flow, mood and
+notify (a Homey timeline notification).`,
+}
+
+// homeyRuleActions carries out rule actions on the Homey
+type homeyRuleActions struct{}
+
+// ignoreDryRun lets a dry-run daemon carry on after printing a request
+func ignoreDryRun(err error) error {
+	if errors.Is(err, client.ErrDryRun) {
+		return nil
+	}
+	return err
+}
+
+func (homeyRuleActions) SetCapability(device, capability string, value interface{}) error {
+	d, err := findDevice(device)
+	if err != nil {
+		return err
+	}
+	return ignoreDryRun(apiClient.SetCapability(d.ID, capability, value))
+}
+
+func (homeyRuleActions) TriggerFlow(name string) error {
+	f, err := findFlow(name)
+	if err != nil {
+		return err
+	}
+	if f.Advanced {
+		return ignoreDryRun(apiClient.TriggerAdvancedFlow(f.ID))
+	}
+	return ignoreDryRun(apiClient.TriggerFlow(f.ID))
+}
+
+func (homeyRuleActions) SetMood(name string) error {
+	m, err := findMood(name)
+	if err != nil {
+		return err
+	}
+	return ignoreDryRun(apiClient.SetMood(m.ID))
+}
+
+func (homeyRuleActions) SendNotification(text string) error {
+	return ignoreDryRun(apiClient.SendNotification(text))
+}
+
+// replayRuleActions stands in for the Homey when replaying an event log.
+// The firings already list what would have been done.
+type replayRuleActions struct{}
+
+func (replayRuleActions) SetCapability(string, string, interface{}) error { return nil }
+func (replayRuleActions) TriggerFlow(string) error                        { return nil }
+func (replayRuleActions) SetMood(string) error                            { return nil }
+func (replayRuleActions) SendNotification(string) error                   { return nil }
+
+// rulesPoller turns polled Homey state into rule events. Only values that
+// changed since the previous poll become events.
+type rulesPoller struct {
+	engine *rules.Engine
+	last   map[string]interface{}
+	prices *priceSchedule
+}
+
+func newRulesPoller(engine *rules.Engine) *rulesPoller {
+	return &rulesPoller{engine: engine, last: map[string]interface{}{}}
+}
+
+// ruleEventAdder records a value seen by the poller as an event when it
+// changed
+type ruleEventAdder func(kind, name, property string, value interface{})
+
+func (p *rulesPoller) poll(now time.Time) ([]rules.Event, error) {
+	var events []rules.Event
+	add := func(kind, name, property string, value interface{}) {
+		key := kind + "\x00" + strings.ToLower(name) + "\x00" + property
+		if old, ok := p.last[key]; ok && reflect.DeepEqual(old, value) {
+			return
+		}
+		p.last[key] = value
+		events = append(events, rules.Event{Time: now, Kind: kind, Name: name, Property: property, Value: value})
+	}
+
+	// A failing source is reported without dropping the events of the others
+	var errs []error
+	for _, source := range []struct {
+		kind string
+		poll func(time.Time, ruleEventAdder) error
+	}{
+		{rules.KindCapability, p.pollDevices},
+		{rules.KindVariable, p.pollVariables},
+		{rules.KindPresence, p.pollUsers},
+		{rules.KindPrice, p.pollPrice},
+	} {
+		if !p.engine.Watches(source.kind) {
+			continue
+		}
+		if err := source.poll(now, add); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", source.kind, err))
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		a, b := events[i], events[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Property < b.Property
+	})
+	return events, errors.Join(errs...)
+}
+
+func (p *rulesPoller) pollDevices(now time.Time, add ruleEventAdder) error {
+	data, err := apiClient.GetDevices()
+	if err != nil {
+		return err
+	}
+	var devices map[string]Device
+	if err := json.Unmarshal(data, &devices); err != nil {
+		return fmt.Errorf("failed to parse devices: %w", err)
+	}
+	for _, d := range devices {
+		for id, c := range d.CapabilitiesObj {
+			add(rules.KindCapability, d.Name, id, c.Value)
+		}
+	}
+	return nil
+}
+
+func (p *rulesPoller) pollVariables(now time.Time, add ruleEventAdder) error {
+	data, err := apiClient.GetVariables()
+	if err != nil {
+		return err
+	}
+	var variables map[string]Variable
+	if err := json.Unmarshal(data, &variables); err != nil {
+		return fmt.Errorf("failed to parse variables: %w", err)
+	}
+	for _, v := range variables {
+		add(rules.KindVariable, v.Name, "", v.Value)
+	}
+	return nil
+}
+
+func (p *rulesPoller) pollUsers(now time.Time, add ruleEventAdder) error {
+	data, err := apiClient.GetUsers()
+	if err != nil {
+		return err
+	}
+	var users map[string]User
+	if err := json.Unmarshal(data, &users); err != nil {
+		return fmt.Errorf("failed to parse users: %w", err)
+	}
+	for _, u := range users {
+		add(rules.KindPresence, u.Name, "present", u.Present)
+		add(rules.KindPresence, u.Name, "asleep", u.Asleep)
+	}
+	return nil
+}
+
+func (p *rulesPoller) pollPrice(now time.Time, add ruleEventAdder) error {
+	price, ok := 0.0, false
+	if p.prices != nil {
+		price, ok = p.prices.At(now)
+	}
+	// Dynamic prices are published a day at a time
+	if !ok {
+		schedule, err := loadPriceSchedule(now, now.Add(time.Hour))
+		if err != nil {
+			return err
+		}
+		p.prices = schedule
+		price, ok = schedule.At(now)
+	}
+	if ok {
+		add(rules.KindPrice, "", "", price)
+	}
+	return nil
+}
+
+// printFiring shows one rule firing as a log line
+func printFiring(f rules.Firing) {
+	if isJSON() {
+		out, _ := json.Marshal(f)
+		fmt.Println(string(out))
+		return
+	}
+	stamp := f.Time.Local().Format("2006-01-02 15:04:05")
+	if f.Skipped != "" {
+		color.Yellow("%s %s: skipped, %s\n", stamp, f.Rule, f.Skipped)
+	} else {
+		color.Green("%s %s (%s)\n", stamp, f.Rule, f.Trigger)
+		for _, a := range f.Actions {
+			fmt.Printf("    %s\n", a)
+		}
+	}
+	for _, e := range f.Errors {
+		color.Red("    Error: %s\n", e)
+	}
+}
+
+var rulesRunCmd = &cobra.Command{
+	Use:   "run <rules.yaml>",
+	Short: "Run rules as a daemon",
+	Long: `Run rules until interrupted, polling the Homey for changes.
+
+Devices, variables, users and the electricity price are polled every
+--interval, but only what the rules refer to. Time triggers and "for"
+delays are checked every second. The first poll sets the starting state
+and does not fire rules.
+
+Use --save-events to record what was seen, for replaying later with
+'homeyctl rules test'. With --dry-run, actions print the requests they
+would send.
+
+Examples:
+  homeyctl rules run rules.yaml
+  homeyctl rules run rules.yaml --interval 5s --save-events events.jsonl
+  homeyctl rules run rules.yaml --dry-run`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		interval, _ := cmd.Flags().GetDuration("interval")
+		saveEvents, _ := cmd.Flags().GetString("save-events")
+		if interval < time.Second {
+			return fmt.Errorf("--interval must be at least 1s")
+		}
+
+		file, err := rules.Load(args[0])
+		if err != nil {
+			return err
+		}
+		engine := rules.New(file, homeyRuleActions{})
+		poller := newRulesPoller(engine)
+
+		var log *os.File
+		if saveEvents != "" {
+			log, err = os.OpenFile(saveEvents, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+			if err != nil {
+				return fmt.Errorf("failed to open event log: %w", err)
+			}
+			defer log.Close()
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		pollOnce := func(now time.Time) {
+			events, err := poller.poll(now)
+			if err != nil {
+				color.Yellow("Warning: poll failed: %v\n", err)
+			}
+			for _, ev := range events {
+				if log != nil {
+					line, _ := json.Marshal(ev)
+					fmt.Fprintln(log, string(line))
+				}
+				for _, f := range engine.Handle(ev) {
+					printFiring(f)
+				}
+			}
+		}
+
+		if !isJSON() {
+			fmt.Printf("Running %d rule(s) from %s (Ctrl+C to stop)\n", len(file.Rules), args[0])
+		}
+		pollOnce(time.Now())
+
+		pollTicker := time.NewTicker(interval)
+		defer pollTicker.Stop()
+		clock := time.NewTicker(time.Second)
+		defer clock.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case now := <-pollTicker.C:
+				pollOnce(now)
+			case now := <-clock.C:
+				for _, f := range engine.Tick(now) {
+					printFiring(f)
+				}
+			}
+		}
+	},
+}
+
+// loadRuleEvents reads a JSON-lines event log, as written by
+// 'rules run --save-events'
+func loadRuleEvents(path string) ([]rules.Event, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read events: %w", err)
+	}
+	defer f.Close()
+
+	var events []rules.Event
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		var ev rules.Event
+		if err := json.Unmarshal([]byte(text), &ev); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if ev.Time.IsZero() {
+			return nil, fmt.Errorf("%s:%d: event has no time", path, line)
+		}
+		events = append(events, ev)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read events: %w", err)
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time.Before(events[j].Time) })
+	return events, nil
+}
+
+var rulesTestCmd = &cobra.Command{
+	Use:   "test <rules.yaml>",
+	Short: "Replay an event log through rules",
+	Long: `Replay recorded events through rules and show which rules would fire.
+
+Nothing is sent to the Homey. The event log has one JSON event per line:
+
+  {"time":"2026-03-02T07:00:00+01:00","kind":"capability","name":"Hall sensor","property":"alarm_motion","value":true}
+  {"time":"2026-03-02T07:01:00+01:00","kind":"variable","name":"Guest mode","value":false}
+  {"time":"2026-03-02T07:02:00+01:00","kind":"presence","name":"Ann","property":"present","value":true}
+  {"time":"2026-03-02T07:03:00+01:00","kind":"price","value":2.7}
+  {"time":"2026-03-02T08:00:00+01:00","kind":"tick"}
+
+Record one with 'homeyctl rules run --save-events', or write it by hand.
+Time moves from event to event; use --until to keep the clock running
+after the last event so pending "for" delays and time triggers can fire.
+
+Examples:
+  homeyctl rules test rules.yaml --events events.jsonl
+  homeyctl rules test rules.yaml --events events.jsonl --until 30m --json`,
+	Args: cobra.ExactArgs(1),
+	// Replays need no Homey
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error { return nil },
+	RunE: func(cmd *cobra.Command, args []string) error {
+		eventsPath, _ := cmd.Flags().GetString("events")
+		until, _ := cmd.Flags().GetDuration("until")
+		if eventsPath == "" {
+			return fmt.Errorf("--events <file> is required")
+		}
+
+		file, err := rules.Load(args[0])
+		if err != nil {
+			return err
+		}
+		events, err := loadRuleEvents(eventsPath)
+		if err != nil {
+			return err
+		}
+
+		engine := rules.New(file, replayRuleActions{})
+		firings := []rules.Firing{}
+		for _, ev := range events {
+			firings = append(firings, engine.Handle(ev)...)
+		}
+		if len(events) > 0 && until > 0 {
+			firings = append(firings, engine.Tick(events[len(events)-1].Time.Add(until))...)
+		}
+
+		if isJSON() {
+			out, _ := json.MarshalIndent(firings, "", "  ")
+			fmt.Println(string(out))
+			return nil
+		}
+
+		if len(firings) == 0 {
+			fmt.Printf("No rules fired for %d event(s)\n", len(events))
+			return nil
+		}
+		headerFmt := color.New(color.FgCyan, color.Underline).SprintfFunc()
+		tbl := table.New("Time", "Rule", "Trigger", "Result")
+		tbl.WithHeaderFormatter(headerFmt)
+		ran := 0
+		for _, f := range firings {
+			result := strings.Join(f.Actions, "; ")
+			if f.Skipped != "" {
+				result = "skipped: " + f.Skipped
+			} else {
+				ran++
+			}
+			if len(f.Errors) > 0 {
+				result += " (" + strings.Join(f.Errors, "; ") + ")"
+			}
+			tbl.AddRow(f.Time.Local().Format("2006-01-02 15:04:05"), f.Rule, f.Trigger, result)
+		}
+		tbl.Print()
+		fmt.Printf("\n%d event(s), %d rule run(s), %d skipped\n", len(events), ran, len(firings)-ran)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(rulesCmd)
+	rulesCmd.AddCommand(rulesRunCmd)
+	rulesCmd.AddCommand(rulesTestCmd)
+
+	rulesRunCmd.Flags().Duration("interval", 10*time.Second, "How often to poll the Homey for changes")
+	rulesRunCmd.Flags().String("save-events", "", "Append the events seen to this JSON-lines file")
+
+	rulesTestCmd.Flags().String("events", "", "JSON-lines event log to replay (required)")
+	rulesTestCmd.Flags().Duration("until", 0, "Keep the clock running this long after the last event")
+}
@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fishfisher/homeyctl/internal/fakehomey"
+	"github.com/fishfisher/homeyctl/internal/rules"
+)
+
+const hallRules = `
+rules:
+  - name: Hall light off
+    when: { device: Hall sensor, capability: alarm_motion, becomes: false, for: 20m }
+    then:
+      - set: { device: Hall light, capability: onoff, value: false }
+  - name: Guest arrives
+    when: { variable: Guest mode, becomes: true }
+    then:
+      - mood: Welcome
+      - notify: "Guest mode on, hall light is {{ device.hall_light.onoff ? 'on' : 'off' }}"
+`
+
+const hallEvents = `{"time":"2026-03-02T07:00:00Z","kind":"capability","name":"Hall light","property":"onoff","value":true}
+{"time":"2026-03-02T07:00:00Z","kind":"capability","name":"Hall sensor","property":"alarm_motion","value":true}
+{"time":"2026-03-02T07:00:00Z","kind":"variable","name":"Guest mode","value":false}
+# motion stops
+{"time":"2026-03-02T07:05:00Z","kind":"capability","name":"Hall sensor","property":"alarm_motion","value":false}
+{"time":"2026-03-02T07:10:00Z","kind":"variable","name":"Guest mode","value":true}
+`
+
+func TestRulesTest_Replay(t *testing.T) {
+	dir := t.TempDir()
+	rulesFile := writeFile(t, dir, "rules.yaml", hallRules)
+	events := writeFile(t, dir, "events.jsonl", hallEvents)
+
+	out, err := runCommand(t, "rules", "test", rulesFile, "--events", events, "--json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var firings []rules.Firing
+	if err := json.Unmarshal([]byte(out), &firings); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, out)
+	}
+	if len(firings) != 1 || firings[0].Rule != "Guest arrives" {
+		t.Fatalf("expected only the guest rule before --until, got %+v", firings)
+	}
+	if want := `notify "Guest mode on, hall light is on"`; firings[0].Actions[1] != want {
+		t.Errorf("actions = %v, want %s", firings[0].Actions, want)
+	}
+
+	out, err = runCommand(t, "rules", "test", rulesFile, "--events", events, "--until", "30m")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out, "set Hall light.onoff = false") || !strings.Contains(out, "5 event(s), 2 rule run(s), 0 skipped") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+
+	bad := writeFile(t, dir, "bad.jsonl", `{"kind":"tick"}`)
+	if _, err := runCommand(t, "rules", "test", rulesFile, "--events", bad); err == nil || !strings.Contains(err.Error(), "bad.jsonl:1: event has no time") {
+		t.Errorf("expected missing time error, got %v", err)
+	}
+	if _, err := runCommand(t, "rules", "test", rulesFile); err == nil || !strings.Contains(err.Error(), "--events") {
+		t.Errorf("expected --events error, got %v", err)
+	}
+}
+
+func TestRulesPollerAndActions(t *testing.T) {
+	h := useFakeHomey(t, &fakehomey.Seed{
+		Devices: fakehomey.Objects{
+			"light":  {"id": "light", "name": "Hall light", "capabilitiesObj": map[string]interface{}{"onoff": map[string]interface{}{"id": "onoff", "value": true}}},
+			"sensor": {"id": "sensor", "name": "Hall sensor", "capabilitiesObj": map[string]interface{}{"alarm_motion": map[string]interface{}{"id": "alarm_motion", "value": false}}},
+		},
+		Variables: fakehomey.Objects{
+			"v1": {"id": "v1", "name": "Guest mode", "type": "boolean", "value": false},
+		},
+		Moods: fakehomey.Objects{
+			"m1": {"id": "m1", "name": "Welcome"},
+		},
+	})
+	// Load config and the client the same way commands do
+	if _, err := runCommand(t, "devices", "list"); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := rules.Parse([]byte(hallRules))
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := rules.New(file, homeyRuleActions{})
+	poller := newRulesPoller(engine)
+
+	handle := func(events []rules.Event) {
+		for _, ev := range events {
+			for _, f := range engine.Handle(ev) {
+				if len(f.Errors) > 0 {
+					t.Errorf("unexpected errors: %v", f.Errors)
+				}
+			}
+		}
+	}
+
+	now := time.Now()
+	events, err := poller.poll(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("expected devices and variables on first poll, got %+v", events)
+	}
+	handle(events)
+	if events, _ := poller.poll(now.Add(time.Second)); len(events) != 0 {
+		t.Errorf("expected no events without changes, got %+v", events)
+	}
+
+	if err := apiClient.SetVariable("v1", true); err != nil {
+		t.Fatal(err)
+	}
+	events, _ = poller.poll(now.Add(2 * time.Second))
+	if len(events) != 1 || events[0].Name != "Guest mode" || events[0].Value != true {
+		t.Fatalf("expected the variable change, got %+v", events)
+	}
+	handle(events)
+
+	// The sensor has reported no motion since the first poll
+	for _, f := range engine.Tick(now.Add(21 * time.Minute)) {
+		if len(f.Errors) > 0 {
+			t.Errorf("unexpected errors: %v", f.Errors)
+		}
+	}
+
+	if v, _ := h.CapabilityValue("light", "onoff"); v != false {
+		t.Errorf("expected the hall light to be switched off, got %v", v)
+	}
+	if m := h.Object(fakehomey.Moods, "m1"); m["active"] != true {
+		t.Errorf("expected mood to be set, got %v", m)
+	}
+	var notified bool
+	for _, id := range h.IDs(fakehomey.Notifications) {
+		if strings.Contains(h.Object(fakehomey.Notifications, id)["excerpt"].(string), "Guest mode on") {
+			notified = true
+		}
+	}
+	if !notified {
+		t.Error("expected a timeline notification")
+	}
+}
+
+func TestRulesPollerKeepsEventsWhenASourceFails(t *testing.T) {
+	useFakeHomey(t, &fakehomey.Seed{
+		Devices: fakehomey.Objects{
+			"light": {"id": "light", "name": "Hall light", "capabilitiesObj": map[string]interface{}{"onoff": map[string]interface{}{"id": "onoff", "value": true}}},
+		},
+	})
+	if _, err := runCommand(t, "devices", "list"); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := rules.Parse([]byte(`
+rules:
+  - name: Cheap
+    when: { price: now, below: 1 }
+    then: [{ flow: x }]
+  - name: Light
+    when: { device: Hall light, capability: onoff }
+    then: [{ flow: x }]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	poller := newRulesPoller(rules.New(file, replayRuleActions{}))
+
+	// The fake Homey has no prices
+	events, err := poller.poll(time.Now())
+	if err == nil || !strings.Contains(err.Error(), "price") {
+		t.Errorf("expected the price source to fail, got %v", err)
+	}
+	if len(events) != 1 || events[0].Name != "Hall light" {
+		t.Errorf("expected the device event despite the failure, got %+v", events)
+	}
+}
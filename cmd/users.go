@@ -11,6 +11,7 @@ import (
 
 type User struct {
 	ID      string `json:"id"`
+	AthomID string `json:"athomId"`
 	Name    string `json:"name"`
 	Email   string `json:"email"`
 	Role    string `json:"role"`
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/fatih/color"
+	"github.com/fishfisher/homeyctl/internal/expr"
 	"github.com/rodaine/table"
 	"github.com/spf13/cobra"
 )
@@ -17,6 +18,43 @@ type Variable struct {
 	Value interface{} `json:"value"`
 }
 
+// findVariable finds a variable by name or ID
+func findVariable(nameOrID string) (*Variable, error) {
+	data, err := apiClient.GetVariables()
+	if err != nil {
+		return nil, err
+	}
+
+	var vars map[string]Variable
+	if err := json.Unmarshal(data, &vars); err != nil {
+		return nil, fmt.Errorf("failed to parse variables: %w", err)
+	}
+
+	for _, v := range vars {
+		if v.ID == nameOrID || strings.EqualFold(v.Name, nameOrID) {
+			return &v, nil
+		}
+	}
+
+	return nil, fmt.Errorf("variable not found: %s", nameOrID)
+}
+
+// parseVariableValue parses a command-line value according to a variable type
+func parseVariableValue(varType, valueStr string) (interface{}, error) {
+	switch varType {
+	case "boolean":
+		return valueStr == "true" || valueStr == "1" || valueStr == "yes", nil
+	case "number":
+		var num float64
+		if _, err := fmt.Sscanf(valueStr, "%f", &num); err != nil {
+			return nil, fmt.Errorf("invalid number: %s", valueStr)
+		}
+		return num, nil
+	default:
+		return valueStr, nil
+	}
+}
+
 var varsCmd = &cobra.Command{
 	Use:     "variables",
 	Aliases: []string{"vars", "var"},
@@ -61,29 +99,11 @@ var varsGetCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		nameOrID := args[0]
 
-		data, err := apiClient.GetVariables()
+		variable, err := findVariable(nameOrID)
 		if err != nil {
 			return err
 		}
 
-		var vars map[string]Variable
-		if err := json.Unmarshal(data, &vars); err != nil {
-			return fmt.Errorf("failed to parse variables: %w", err)
-		}
-
-		// Find variable by name or ID
-		var variable *Variable
-		for _, v := range vars {
-			if v.ID == nameOrID || strings.EqualFold(v.Name, nameOrID) {
-				variable = &v
-				break
-			}
-		}
-
-		if variable == nil {
-			return fmt.Errorf("variable not found: %s", nameOrID)
-		}
-
 		if isJSON() {
 			out, _ := json.MarshalIndent(variable, "", "  ")
 			fmt.Println(string(out))
@@ -101,54 +121,56 @@ var varsGetCmd = &cobra.Command{
 var varsSetCmd = &cobra.Command{
 	Use:   "set <name-or-id> <value>",
 	Short: "Set variable value",
-	Args:  cobra.ExactArgs(2),
-	RunE: func(cmd *cobra.Command, args []string) error {
-		nameOrID := args[0]
-		valueStr := args[1]
+	Long: `Set a variable to a value, parsed according to the variable's type.
 
-		data, err := apiClient.GetVariables()
-		if err != nil {
-			return err
-		}
+With --expr the new value is computed from the current one, which is
+available as "value". The variable is read again just before writing, and
+the expression is re-evaluated if something else changed it in between.
 
-		var vars map[string]Variable
-		if err := json.Unmarshal(data, &vars); err != nil {
-			return fmt.Errorf("failed to parse variables: %w", err)
-		}
+Examples:
+  homeyctl variables set counter 42
+  homeyctl variables set counter --expr 'value * 2 + 1'
+  homeyctl variables set target --expr 'clamp(value + 0.5, 16, 23)'
+  homeyctl variables set label --expr '"Mode " + value'`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if expr, _ := cmd.Flags().GetString("expr"); expr != "" {
+			return cobra.ExactArgs(1)(cmd, args)
+		}
+		return cobra.ExactArgs(2)(cmd, args)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		nameOrID := args[0]
+		exprSrc, _ := cmd.Flags().GetString("expr")
 
-		// Find variable by name or ID
-		var variable *Variable
-		for _, v := range vars {
-			if v.ID == nameOrID || strings.EqualFold(v.Name, nameOrID) {
-				variable = &v
-				break
+		var compute func(v *Variable) (interface{}, error)
+		if exprSrc != "" {
+			e, err := expr.Parse(exprSrc)
+			if err != nil {
+				return fmt.Errorf("invalid expression: %w", err)
 			}
-		}
-
-		if variable == nil {
-			return fmt.Errorf("variable not found: %s", nameOrID)
-		}
-
-		// Parse value based on variable type
-		var value interface{}
-		switch variable.Type {
-		case "boolean":
-			value = valueStr == "true" || valueStr == "1" || valueStr == "yes"
-		case "number":
-			var num float64
-			if _, err := fmt.Sscanf(valueStr, "%f", &num); err != nil {
-				return fmt.Errorf("invalid number: %s", valueStr)
+			compute = func(v *Variable) (interface{}, error) {
+				result, err := e.Eval(expr.Env{"value": v.Value})
+				if err != nil {
+					return nil, fmt.Errorf("failed to evaluate %q: %w", exprSrc, err)
+				}
+				return coerceVariableValue(v, result)
+			}
+		} else {
+			valueStr := args[1]
+			compute = func(v *Variable) (interface{}, error) {
+				return parseVariableValue(v.Type, valueStr)
 			}
-			value = num
-		default:
-			value = valueStr
 		}
 
-		if err := apiClient.SetVariable(variable.ID, value); err != nil {
+		source := "set"
+		if exprSrc != "" {
+			source = "expr " + exprSrc
+		}
+		change, err := updateVariable(nameOrID, source, compute)
+		if err != nil {
 			return err
 		}
-
-		color.Green("Set %s = %v\n", variable.Name, value)
+		printVariableChange(change)
 		return nil
 	},
 }
@@ -178,19 +200,9 @@ Examples:
 			return fmt.Errorf("invalid type: %s (use: string, number, boolean)", varType)
 		}
 
-		// Parse value based on type
-		var value interface{}
-		switch varType {
-		case "boolean":
-			value = valueStr == "true" || valueStr == "1" || valueStr == "yes"
-		case "number":
-			var num float64
-			if _, err := fmt.Sscanf(valueStr, "%f", &num); err != nil {
-				return fmt.Errorf("invalid number: %s", valueStr)
-			}
-			value = num
-		default:
-			value = valueStr
+		value, err := parseVariableValue(varType, valueStr)
+		if err != nil {
+			return err
 		}
 
 		result, err := apiClient.CreateVariable(name, varType, value)
@@ -215,29 +227,11 @@ var varsDeleteCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		nameOrID := args[0]
 
-		data, err := apiClient.GetVariables()
+		variable, err := findVariable(nameOrID)
 		if err != nil {
 			return err
 		}
 
-		var vars map[string]Variable
-		if err := json.Unmarshal(data, &vars); err != nil {
-			return fmt.Errorf("failed to parse variables: %w", err)
-		}
-
-		// Find variable by name or ID
-		var variable *Variable
-		for _, v := range vars {
-			if v.ID == nameOrID || strings.EqualFold(v.Name, nameOrID) {
-				variable = &v
-				break
-			}
-		}
-
-		if variable == nil {
-			return fmt.Errorf("variable not found: %s", nameOrID)
-		}
-
 		if err := apiClient.DeleteVariable(variable.ID); err != nil {
 			return err
 		}
@@ -254,4 +248,6 @@ func init() {
 	varsCmd.AddCommand(varsSetCmd)
 	varsCmd.AddCommand(varsCreateCmd)
 	varsCmd.AddCommand(varsDeleteCmd)
+
+	varsSetCmd.Flags().String("expr", "", "Compute the new value from the current one (available as 'value')")
 }
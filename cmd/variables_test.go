@@ -0,0 +1,202 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fishfisher/homeyctl/internal/config"
+	"github.com/fishfisher/homeyctl/internal/fakehomey"
+)
+
+func variablesSeed() *fakehomey.Seed {
+	return &fakehomey.Seed{
+		Variables: fakehomey.Objects{
+			"v1": {"id": "v1", "name": "counter", "type": "number", "value": 3.0},
+			"v2": {"id": "v2", "name": "guests", "type": "boolean", "value": false},
+			"v3": {"id": "v3", "name": "label", "type": "string", "value": "day"},
+			"v4": {"id": "v4", "name": "outdoor", "type": "number", "value": 0.0},
+		},
+		Devices: fakehomey.Objects{
+			"s1": {"id": "s1", "name": "Garden sensor", "capabilitiesObj": map[string]interface{}{
+				"measure_temperature": map[string]interface{}{"id": "measure_temperature", "value": 12.5},
+			}},
+		},
+	}
+}
+
+func variableValue(h *fakehomey.Homey, id string) interface{} {
+	return h.Object(fakehomey.Variables, id)["value"]
+}
+
+func TestVariables_IncrDecrToggle(t *testing.T) {
+	t.Setenv(config.DirEnv, t.TempDir())
+	h := useFakeHomey(t, variablesSeed())
+
+	out, err := runCommand(t, "variables", "incr", "counter")
+	if err != nil {
+		t.Fatalf("incr failed: %v", err)
+	}
+	if !strings.Contains(out, "Set counter = 4 (was 3)") {
+		t.Errorf("unexpected output: %s", out)
+	}
+	if _, err := runCommand(t, "variables", "decr", "counter", "1.5"); err != nil {
+		t.Fatalf("decr failed: %v", err)
+	}
+	if v := variableValue(h, "v1"); v != 2.5 {
+		t.Errorf("expected counter 2.5, got %v", v)
+	}
+
+	if _, err := runCommand(t, "variables", "toggle", "guests"); err != nil {
+		t.Fatalf("toggle failed: %v", err)
+	}
+	if v := variableValue(h, "v2"); v != true {
+		t.Errorf("expected guests true, got %v", v)
+	}
+
+	if _, err := runCommand(t, "variables", "incr", "label"); err == nil || !strings.Contains(err.Error(), "not a number") {
+		t.Errorf("expected type error for incr on a string, got %v", err)
+	}
+	if _, err := runCommand(t, "variables", "toggle", "counter"); err == nil || !strings.Contains(err.Error(), "not a boolean") {
+		t.Errorf("expected type error for toggle on a number, got %v", err)
+	}
+}
+
+func TestVariables_SetExpr(t *testing.T) {
+	t.Setenv(config.DirEnv, t.TempDir())
+	h := useFakeHomey(t, variablesSeed())
+
+	if _, err := runCommand(t, "variables", "set", "counter", "--expr", "value * 2 + 1"); err != nil {
+		t.Fatalf("set --expr failed: %v", err)
+	}
+	if v := variableValue(h, "v1"); v != 7.0 {
+		t.Errorf("expected counter 7, got %v", v)
+	}
+
+	if _, err := runCommand(t, "variables", "set", "label", "--expr", `"Mode " + value`); err != nil {
+		t.Fatalf("set --expr on string failed: %v", err)
+	}
+	if v := variableValue(h, "v3"); v != "Mode day" {
+		t.Errorf("expected label 'Mode day', got %v", v)
+	}
+
+	if _, err := runCommand(t, "variables", "set", "guests", "--expr", "value + 'x'"); err == nil || !strings.Contains(err.Error(), "boolean variable") {
+		t.Errorf("expected type error, got %v", err)
+	}
+	if _, err := runCommand(t, "variables", "set", "counter", "--expr", "value *"); err == nil || !strings.Contains(err.Error(), "invalid expression") {
+		t.Errorf("expected parse error, got %v", err)
+	}
+	if _, err := runCommand(t, "variables", "set", "counter"); err == nil {
+		t.Error("expected error for set without value or --expr")
+	}
+}
+
+func TestVariables_SetFrom(t *testing.T) {
+	t.Setenv(config.DirEnv, t.TempDir())
+	h := useFakeHomey(t, variablesSeed())
+
+	if _, err := runCommand(t, "variables", "set-from", "outdoor", "Garden sensor", "measure_temperature"); err != nil {
+		t.Fatalf("set-from failed: %v", err)
+	}
+	if v := variableValue(h, "v4"); v != 12.5 {
+		t.Errorf("expected outdoor 12.5, got %v", v)
+	}
+
+	if _, err := runCommand(t, "variables", "set-from", "label", "Garden sensor", "measure_temperature"); err != nil {
+		t.Fatalf("set-from into string failed: %v", err)
+	}
+	if v := variableValue(h, "v3"); v != "12.5" {
+		t.Errorf("expected label \"12.5\", got %v", v)
+	}
+
+	if _, err := runCommand(t, "variables", "set-from", "outdoor", "Garden sensor", "onoff"); err == nil || !strings.Contains(err.Error(), "no capability onoff") {
+		t.Errorf("expected missing capability error, got %v", err)
+	}
+}
+
+func TestVariables_UpdateRetriesOnConflict(t *testing.T) {
+	t.Setenv(config.DirEnv, t.TempDir())
+	h := useFakeHomey(t, variablesSeed())
+
+	// Any command sets up apiClient against the fake Homey
+	if _, err := runCommand(t, "variables", "list"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a flow bumping the counter between our read and write
+	calls := 0
+	change, err := updateVariable("counter", "incr 1", func(v *Variable) (interface{}, error) {
+		calls++
+		if calls == 1 {
+			if err := apiClient.SetVariable("v1", 10.0); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return v.Value.(float64) + 1, nil
+	})
+	if err != nil {
+		t.Fatalf("updateVariable failed: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected the update to be recomputed once, got %d computations", calls)
+	}
+	if v := variableValue(h, "v1"); v != 11.0 || change.Old != 10.0 {
+		t.Errorf("expected 10 -> 11, got %v (old %v)", v, change.Old)
+	}
+
+	// A variable that changes on every read is given up on
+	_, err = updateVariable("counter", "incr 1", func(v *Variable) (interface{}, error) {
+		calls++
+		apiClient.SetVariable("v1", float64(calls))
+		return 0.0, nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "gave up after 5 attempts") {
+		t.Errorf("expected retries to be bounded, got %v", err)
+	}
+}
+
+func TestVariables_History(t *testing.T) {
+	t.Setenv(config.DirEnv, t.TempDir())
+	useFakeHomey(t, variablesSeed())
+
+	runCommand(t, "variables", "set", "counter", "5")
+	runCommand(t, "variables", "incr", "counter", "2")
+	runCommand(t, "variables", "toggle", "guests")
+
+	out, err := runCommand(t, "variables", "history", "counter")
+	if err != nil {
+		t.Fatalf("history failed: %v", err)
+	}
+	for _, want := range []string{"incr 2", "set", "counter"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("history missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "guests") {
+		t.Errorf("history not filtered by variable:\n%s", out)
+	}
+	if strings.Index(out, "incr 2") > strings.Index(out, " set") {
+		t.Errorf("expected newest first:\n%s", out)
+	}
+
+	out, err = runCommand(t, "variables", "history", "--limit", "1", "--json")
+	if err != nil {
+		t.Fatalf("history --json failed: %v", err)
+	}
+	if !strings.Contains(out, `"source": "toggle"`) || strings.Contains(out, "incr") {
+		t.Errorf("expected only the latest change:\n%s", out)
+	}
+}
+
+func TestVariables_DryRunNotJournaled(t *testing.T) {
+	t.Setenv(config.DirEnv, t.TempDir())
+	h := useFakeHomey(t, variablesSeed())
+
+	runCommand(t, "variables", "incr", "counter", "--dry-run")
+	if v := variableValue(h, "v1"); v != 3.0 {
+		t.Errorf("dry run changed the variable to %v", v)
+	}
+	changes, err := loadVariableJournal()
+	if err != nil || len(changes) != 0 {
+		t.Errorf("expected no journal entries after a dry run, got %v (%v)", changes, err)
+	}
+}
@@ -0,0 +1,410 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/fishfisher/homeyctl/internal/config"
+	"github.com/rodaine/table"
+	"github.com/spf13/cobra"
+)
+
+// variableUpdateAttempts bounds how often a read-modify-write is retried
+// when the variable keeps changing underneath it
+const variableUpdateAttempts = 5
+
+// variableChange is one change made to a variable, as kept in the journal
+type variableChange struct {
+	Time   time.Time   `json:"time"`
+	ID     string      `json:"id"`
+	Name   string      `json:"name"`
+	Type   string      `json:"type"`
+	Old    interface{} `json:"old"`
+	New    interface{} `json:"new"`
+	Source string      `json:"source"`
+}
+
+func variableJournalPath() (string, error) {
+	configDir, err := config.Dir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find config dir: %w", err)
+	}
+	return filepath.Join(configDir, "history", "variables.jsonl"), nil
+}
+
+// appendVariableJournal adds a change to the journal, one JSON object per line
+func appendVariableJournal(change *variableChange) error {
+	path, err := variableJournalPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create history dir: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open variable journal: %w", err)
+	}
+	defer f.Close()
+
+	line, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// loadVariableJournal reads the journal, oldest first. Lines that cannot be
+// parsed are skipped so one bad write does not hide the rest.
+func loadVariableJournal() ([]variableChange, error) {
+	path, err := variableJournalPath()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open variable journal: %w", err)
+	}
+	defer f.Close()
+
+	var changes []variableChange
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var c variableChange
+		if err := json.Unmarshal(scanner.Bytes(), &c); err == nil {
+			changes = append(changes, c)
+		}
+	}
+	return changes, scanner.Err()
+}
+
+func getVariableByID(id string) (*Variable, error) {
+	data, err := apiClient.GetVariable(id)
+	if err != nil {
+		return nil, err
+	}
+	var v Variable
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("failed to parse variable: %w", err)
+	}
+	return &v, nil
+}
+
+// updateVariable reads a variable, computes its new value and writes it
+// back. Homey has no compare-and-set, so the variable is read again right
+// before the write; if another writer changed it in between, the new value
+// is computed again from the fresh one. Successful changes are journaled.
+func updateVariable(nameOrID, source string, compute func(v *Variable) (interface{}, error)) (*variableChange, error) {
+	found, err := findVariable(nameOrID)
+	if err != nil {
+		return nil, err
+	}
+
+	current := found
+	for attempt := 1; attempt <= variableUpdateAttempts; attempt++ {
+		value, err := compute(current)
+		if err != nil {
+			return nil, err
+		}
+
+		latest, err := getVariableByID(current.ID)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(latest.Value, current.Value) {
+			if verboseFlag {
+				fmt.Fprintf(os.Stderr, "%s changed from %v to %v while updating, retrying\n", current.Name, current.Value, latest.Value)
+			}
+			current = latest
+			continue
+		}
+
+		if err := apiClient.SetVariable(current.ID, value); err != nil {
+			return nil, err
+		}
+
+		change := &variableChange{
+			Time:   time.Now(),
+			ID:     current.ID,
+			Name:   current.Name,
+			Type:   current.Type,
+			Old:    current.Value,
+			New:    value,
+			Source: source,
+		}
+		if err := appendVariableJournal(change); err != nil {
+			color.Yellow("Warning: failed to record variable history: %v\n", err)
+		}
+		return change, nil
+	}
+	return nil, fmt.Errorf("%s kept changing while updating it; gave up after %d attempts", found.Name, variableUpdateAttempts)
+}
+
+// coerceVariableValue converts a computed value to the variable's type
+func coerceVariableValue(v *Variable, value interface{}) (interface{}, error) {
+	switch v.Type {
+	case "number":
+		if n, ok := toFloat(value); ok {
+			return n, nil
+		}
+		if s, ok := value.(string); ok {
+			if n, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err == nil {
+				return n, nil
+			}
+		}
+		return nil, fmt.Errorf("%s is a number variable, got %v", v.Name, formatVariableValue(value))
+	case "boolean":
+		switch x := value.(type) {
+		case bool:
+			return x, nil
+		case string:
+			switch strings.ToLower(strings.TrimSpace(x)) {
+			case "true", "1", "yes", "on":
+				return true, nil
+			case "false", "0", "no", "off":
+				return false, nil
+			}
+		}
+		if n, ok := toFloat(value); ok {
+			return n != 0, nil
+		}
+		return nil, fmt.Errorf("%s is a boolean variable, got %v", v.Name, formatVariableValue(value))
+	default:
+		return formatVariableValue(value), nil
+	}
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// formatVariableValue prints numbers without a trailing ".000000"
+func formatVariableValue(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return "null"
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case string:
+		return x
+	}
+	return fmt.Sprint(v)
+}
+
+func printVariableChange(change *variableChange) {
+	if isJSON() {
+		out, _ := json.MarshalIndent(change, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+	color.Green("Set %s = %s (was %s)\n", change.Name, formatVariableValue(change.New), formatVariableValue(change.Old))
+}
+
+func addToVariable(nameOrID string, delta float64, source string) error {
+	change, err := updateVariable(nameOrID, source, func(v *Variable) (interface{}, error) {
+		if v.Type != "number" {
+			return nil, fmt.Errorf("%s is a %s variable, not a number", v.Name, v.Type)
+		}
+		current, ok := toFloat(v.Value)
+		if !ok {
+			current = 0
+		}
+		return current + delta, nil
+	})
+	if err != nil {
+		return err
+	}
+	printVariableChange(change)
+	return nil
+}
+
+func parseStep(args []string) (float64, error) {
+	if len(args) < 2 {
+		return 1, nil
+	}
+	n, err := strconv.ParseFloat(args[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number: %s", args[1])
+	}
+	return n, nil
+}
+
+var varsIncrCmd = &cobra.Command{
+	Use:   "incr <name-or-id> [n]",
+	Short: "Increase a number variable",
+	Long: `Increase a number variable by n (default 1).
+
+The current value is read, increased and written back; if something else
+changes the variable in between, the increment is redone on the new value.
+
+Examples:
+  homeyctl variables incr visitors
+  homeyctl variables incr energy_kwh 0.25`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		n, err := parseStep(args)
+		if err != nil {
+			return err
+		}
+		return addToVariable(args[0], n, "incr "+formatVariableValue(n))
+	},
+}
+
+var varsDecrCmd = &cobra.Command{
+	Use:   "decr <name-or-id> [n]",
+	Short: "Decrease a number variable",
+	Long: `Decrease a number variable by n (default 1).
+
+Examples:
+  homeyctl variables decr visitors
+  homeyctl variables decr budget 12.5`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		n, err := parseStep(args)
+		if err != nil {
+			return err
+		}
+		return addToVariable(args[0], -n, "decr "+formatVariableValue(n))
+	},
+}
+
+var varsToggleCmd = &cobra.Command{
+	Use:   "toggle <name-or-id>",
+	Short: "Flip a boolean variable",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		change, err := updateVariable(args[0], "toggle", func(v *Variable) (interface{}, error) {
+			if v.Type != "boolean" {
+				return nil, fmt.Errorf("%s is a %s variable, not a boolean", v.Name, v.Type)
+			}
+			current, _ := v.Value.(bool)
+			return !current, nil
+		})
+		if err != nil {
+			return err
+		}
+		printVariableChange(change)
+		return nil
+	},
+}
+
+var varsSetFromCmd = &cobra.Command{
+	Use:   "set-from <name-or-id> <device> <capability>",
+	Short: "Copy a device capability value into a variable",
+	Long: `Copy the current value of a device capability into a variable.
+
+The value is converted to the variable's type: numbers are parsed from
+strings, booleans from numbers (non-zero is true) and strings are formatted.
+
+Examples:
+  homeyctl variables set-from outdoor_temp "Garden sensor" measure_temperature
+  homeyctl variables set-from tv_on "Living room TV" onoff`,
+	Args: cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		deviceName, capability := args[1], args[2]
+
+		source := fmt.Sprintf("from %s.%s", deviceName, capability)
+		change, err := updateVariable(args[0], source, func(v *Variable) (interface{}, error) {
+			device, err := findDevice(deviceName)
+			if err != nil {
+				return nil, err
+			}
+			capObj, ok := device.CapabilitiesObj[capability]
+			if !ok {
+				return nil, fmt.Errorf("device %s has no capability %s", device.Name, capability)
+			}
+			if capObj.Value == nil {
+				return nil, fmt.Errorf("%s.%s has no value yet", device.Name, capability)
+			}
+			return coerceVariableValue(v, capObj.Value)
+		})
+		if err != nil {
+			return err
+		}
+		printVariableChange(change)
+		return nil
+	},
+}
+
+var varsHistoryCmd = &cobra.Command{
+	Use:   "history [name-or-id]",
+	Short: "Show changes made to variables",
+	Long: `Show the changes homeyctl has made to variables, newest first.
+
+Every set, incr, decr, toggle and set-from is recorded in a local journal
+in the homeyctl config directory (history/variables.jsonl). Changes made
+by flows or the Homey app are not included.
+
+Examples:
+  homeyctl variables history
+  homeyctl variables history counter --limit 5`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		limit, _ := cmd.Flags().GetInt("limit")
+
+		changes, err := loadVariableJournal()
+		if err != nil {
+			return err
+		}
+
+		var filtered []variableChange
+		for i := len(changes) - 1; i >= 0; i-- {
+			c := changes[i]
+			if len(args) == 1 && c.ID != args[0] && !strings.EqualFold(c.Name, args[0]) {
+				continue
+			}
+			filtered = append(filtered, c)
+			if limit > 0 && len(filtered) == limit {
+				break
+			}
+		}
+
+		if isJSON() {
+			out, _ := json.MarshalIndent(filtered, "", "  ")
+			fmt.Println(string(out))
+			return nil
+		}
+
+		if len(filtered) == 0 {
+			fmt.Println("No variable changes recorded")
+			return nil
+		}
+
+		headerFmt := color.New(color.FgCyan, color.Underline).SprintfFunc()
+		tbl := table.New("Time", "Variable", "Old", "New", "Source")
+		tbl.WithHeaderFormatter(headerFmt)
+		for _, c := range filtered {
+			tbl.AddRow(c.Time.Local().Format("2006-01-02 15:04:05"), c.Name, formatVariableValue(c.Old), formatVariableValue(c.New), c.Source)
+		}
+		tbl.Print()
+		return nil
+	},
+}
+
+func init() {
+	varsCmd.AddCommand(varsIncrCmd)
+	varsCmd.AddCommand(varsDecrCmd)
+	varsCmd.AddCommand(varsToggleCmd)
+	varsCmd.AddCommand(varsSetFromCmd)
+	varsCmd.AddCommand(varsHistoryCmd)
+	varsHistoryCmd.Flags().Int("limit", 20, "Maximum number of changes to show (0 for all)")
+}
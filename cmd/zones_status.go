@@ -0,0 +1,384 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/rodaine/table"
+	"github.com/spf13/cobra"
+)
+
+// ZoneNode is a zone with its children and device counts, used by 'zones tree'
+type ZoneNode struct {
+	ID           string      `json:"id"`
+	Name         string      `json:"name"`
+	Icon         string      `json:"icon"`
+	Devices      int         `json:"devices"`
+	TotalDevices int         `json:"totalDevices"`
+	Children     []*ZoneNode `json:"children"`
+}
+
+// ZoneStatus holds aggregated device state for a zone and its descendants
+type ZoneStatus struct {
+	Zone           string   `json:"zone"`
+	ZoneID         string   `json:"zoneId"`
+	Zones          int      `json:"zones"`
+	Devices        int      `json:"devices"`
+	LightsOn       int      `json:"lightsOn"`
+	LightsTotal    int      `json:"lightsTotal"`
+	Temperature    *float64 `json:"temperature"`
+	Humidity       *float64 `json:"humidity"`
+	OpenContacts   []string `json:"openContacts"`
+	ActiveAlarms   []string `json:"activeAlarms"`
+	Motion         []string `json:"motion"`
+	PowerW         *float64 `json:"powerW"`
+	PoweredDevices int      `json:"poweredDevices"`
+}
+
+// buildZoneTree arranges zones into a forest of root nodes sorted by name.
+// Device counts include devices in descendant zones.
+func buildZoneTree(zones map[string]Zone, devices map[string]Device) []*ZoneNode {
+	nodes := make(map[string]*ZoneNode, len(zones))
+	for _, z := range zones {
+		nodes[z.ID] = &ZoneNode{ID: z.ID, Name: z.Name, Icon: z.Icon, Children: []*ZoneNode{}}
+	}
+	for _, d := range devices {
+		if n, ok := nodes[d.Zone]; ok {
+			n.Devices++
+		}
+	}
+
+	var roots []*ZoneNode
+	for _, z := range zones {
+		n := nodes[z.ID]
+		if parent, ok := nodes[z.Parent]; ok && z.Parent != z.ID {
+			parent.Children = append(parent.Children, n)
+		} else {
+			roots = append(roots, n)
+		}
+	}
+
+	var finish func(n *ZoneNode) int
+	finish = func(n *ZoneNode) int {
+		sort.Slice(n.Children, func(i, j int) bool { return n.Children[i].Name < n.Children[j].Name })
+		n.TotalDevices = n.Devices
+		for _, c := range n.Children {
+			n.TotalDevices += finish(c)
+		}
+		return n.TotalDevices
+	}
+	sort.Slice(roots, func(i, j int) bool { return roots[i].Name < roots[j].Name })
+	for _, r := range roots {
+		finish(r)
+	}
+	return roots
+}
+
+// zoneDescendants returns the IDs of a zone and all zones below it
+func zoneDescendants(zones map[string]Zone, rootID string) map[string]bool {
+	children := make(map[string][]string)
+	for _, z := range zones {
+		children[z.Parent] = append(children[z.Parent], z.ID)
+	}
+
+	ids := map[string]bool{rootID: true}
+	queue := []string{rootID}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, c := range children[id] {
+			if !ids[c] {
+				ids[c] = true
+				queue = append(queue, c)
+			}
+		}
+	}
+	return ids
+}
+
+// capabilityFloat returns a numeric capability value if present
+func capabilityFloat(d Device, capability string) (float64, bool) {
+	c, ok := d.CapabilitiesObj[capability]
+	if !ok {
+		return 0, false
+	}
+	v, ok := c.Value.(float64)
+	return v, ok
+}
+
+// capabilityBool returns a boolean capability value if present
+func capabilityBool(d Device, capability string) (bool, bool) {
+	c, ok := d.CapabilitiesObj[capability]
+	if !ok {
+		return false, false
+	}
+	v, ok := c.Value.(bool)
+	return v, ok
+}
+
+// aggregateZoneStatus summarizes the devices located in the given zones.
+// livePower maps device IDs to their current consumption in watts.
+func aggregateZoneStatus(zoneIDs map[string]bool, devices map[string]Device, livePower map[string]float64) ZoneStatus {
+	status := ZoneStatus{
+		Zones:        len(zoneIDs),
+		OpenContacts: []string{},
+		ActiveAlarms: []string{},
+		Motion:       []string{},
+	}
+
+	var tempSum, humSum float64
+	var tempCount, humCount int
+	var power float64
+
+	for _, d := range devices {
+		if !zoneIDs[d.Zone] {
+			continue
+		}
+		status.Devices++
+
+		if d.Class == "light" {
+			status.LightsTotal++
+			if on, ok := capabilityBool(d, "onoff"); ok && on {
+				status.LightsOn++
+			}
+		}
+		if v, ok := capabilityFloat(d, "measure_temperature"); ok {
+			tempSum += v
+			tempCount++
+		}
+		if v, ok := capabilityFloat(d, "measure_humidity"); ok {
+			humSum += v
+			humCount++
+		}
+		if w, ok := livePower[d.ID]; ok {
+			power += w
+			status.PoweredDevices++
+		}
+
+		for capID := range d.CapabilitiesObj {
+			if !strings.HasPrefix(capID, "alarm_") {
+				continue
+			}
+			active, ok := capabilityBool(d, capID)
+			if !ok || !active {
+				continue
+			}
+			switch capID {
+			case "alarm_contact":
+				status.OpenContacts = append(status.OpenContacts, d.Name)
+			case "alarm_motion":
+				status.Motion = append(status.Motion, d.Name)
+			default:
+				status.ActiveAlarms = append(status.ActiveAlarms, fmt.Sprintf("%s (%s)", d.Name, capID))
+			}
+		}
+	}
+
+	if tempCount > 0 {
+		avg := tempSum / float64(tempCount)
+		status.Temperature = &avg
+	}
+	if humCount > 0 {
+		avg := humSum / float64(humCount)
+		status.Humidity = &avg
+	}
+	if status.PoweredDevices > 0 {
+		status.PowerW = &power
+	}
+
+	sort.Strings(status.OpenContacts)
+	sort.Strings(status.ActiveAlarms)
+	sort.Strings(status.Motion)
+	return status
+}
+
+// fetchZonesAndDevices loads all zones and devices
+func fetchZonesAndDevices() (map[string]Zone, map[string]Device, error) {
+	zonesData, err := apiClient.GetZones()
+	if err != nil {
+		return nil, nil, err
+	}
+	var zones map[string]Zone
+	if err := json.Unmarshal(zonesData, &zones); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse zones: %w", err)
+	}
+
+	devicesData, err := apiClient.GetDevices()
+	if err != nil {
+		return nil, nil, err
+	}
+	var devices map[string]Device
+	if err := json.Unmarshal(devicesData, &devices); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse devices: %w", err)
+	}
+
+	return zones, devices, nil
+}
+
+// fetchLivePower returns current power per device from the energy manager
+func fetchLivePower() (map[string]float64, error) {
+	data, err := apiClient.GetEnergyLive()
+	if err != nil {
+		return nil, err
+	}
+
+	var report struct {
+		Items []struct {
+			Type   string `json:"type"`
+			ID     string `json:"id"`
+			Values struct {
+				W *float64 `json:"W"`
+			} `json:"values"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to parse energy data: %w", err)
+	}
+
+	power := make(map[string]float64)
+	for _, item := range report.Items {
+		if item.Type == "device" && item.Values.W != nil {
+			power[item.ID] = *item.Values.W
+		}
+	}
+	return power, nil
+}
+
+// printZoneTree writes the tree with box-drawing connectors
+func printZoneTree(nodes []*ZoneNode, prefix string) {
+	for i, n := range nodes {
+		connector, childPrefix := "├── ", "│   "
+		if i == len(nodes)-1 {
+			connector, childPrefix = "└── ", "    "
+		}
+		fmt.Printf("%s%s%s %s\n", prefix, connector, n.Name, zoneDeviceCount(n))
+		printZoneTree(n.Children, prefix+childPrefix)
+	}
+}
+
+func zoneDeviceCount(n *ZoneNode) string {
+	if n.TotalDevices == n.Devices {
+		return color.New(color.Faint).Sprintf("(%d)", n.Devices)
+	}
+	return color.New(color.Faint).Sprintf("(%d, %d total)", n.Devices, n.TotalDevices)
+}
+
+var zonesTreeCmd = &cobra.Command{
+	Use:   "tree",
+	Short: "Show the zone hierarchy",
+	Long: `Show zones as a tree with device counts.
+
+Each zone shows the number of devices directly in it, and the total
+including all zones below it.
+
+Examples:
+  homeyctl zones tree
+  homeyctl zones tree --json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		zones, devices, err := fetchZonesAndDevices()
+		if err != nil {
+			return err
+		}
+
+		roots := buildZoneTree(zones, devices)
+
+		if isJSON() {
+			out, _ := json.MarshalIndent(roots, "", "  ")
+			fmt.Println(string(out))
+			return nil
+		}
+
+		for _, r := range roots {
+			color.New(color.Bold).Printf("%s ", r.Name)
+			fmt.Println(zoneDeviceCount(r))
+			printZoneTree(r.Children, "")
+		}
+		return nil
+	},
+}
+
+var zonesStatusCmd = &cobra.Command{
+	Use:   "status <zone>",
+	Short: "Show aggregated status for a zone",
+	Long: `Show aggregated device state for a zone and all zones below it.
+
+Includes lights on, average temperature and humidity, open contacts,
+active alarms, motion, and total power consumption.
+
+Examples:
+  homeyctl zones status "Home"
+  homeyctl zones status "First Floor" --json`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		zones, devices, err := fetchZonesAndDevices()
+		if err != nil {
+			return err
+		}
+
+		var zone *Zone
+		for _, z := range zones {
+			if z.ID == args[0] || strings.EqualFold(z.Name, args[0]) {
+				zone = &z
+				break
+			}
+		}
+		if zone == nil {
+			return fmt.Errorf("zone not found: %s", args[0])
+		}
+
+		power, err := fetchLivePower()
+		if err != nil {
+			return err
+		}
+
+		status := aggregateZoneStatus(zoneDescendants(zones, zone.ID), devices, power)
+		status.Zone = zone.Name
+		status.ZoneID = zone.ID
+
+		if isJSON() {
+			out, _ := json.MarshalIndent(status, "", "  ")
+			fmt.Println(string(out))
+			return nil
+		}
+
+		color.New(color.Bold).Println(status.Zone)
+		fmt.Printf("  Zones:       %d\n", status.Zones)
+		fmt.Printf("  Devices:     %d\n", status.Devices)
+		fmt.Printf("  Lights on:   %d/%d\n", status.LightsOn, status.LightsTotal)
+		if status.Temperature != nil {
+			fmt.Printf("  Temperature: %.1f °C\n", *status.Temperature)
+		}
+		if status.Humidity != nil {
+			fmt.Printf("  Humidity:    %.0f %%\n", *status.Humidity)
+		}
+		if status.PowerW != nil {
+			fmt.Printf("  Power:       %.1f W (%d devices)\n", *status.PowerW, status.PoweredDevices)
+		}
+
+		headerFmt := color.New(color.FgCyan, color.Underline).SprintfFunc()
+		if len(status.OpenContacts)+len(status.ActiveAlarms)+len(status.Motion) > 0 {
+			fmt.Println()
+			tbl := table.New("State", "Device")
+			tbl.WithHeaderFormatter(headerFmt)
+			for _, name := range status.ActiveAlarms {
+				tbl.AddRow(color.RedString("alarm"), name)
+			}
+			for _, name := range status.OpenContacts {
+				tbl.AddRow("open", name)
+			}
+			for _, name := range status.Motion {
+				tbl.AddRow("motion", name)
+			}
+			tbl.Print()
+		}
+		return nil
+	},
+}
+
+func init() {
+	zonesCmd.AddCommand(zonesTreeCmd)
+	zonesCmd.AddCommand(zonesStatusCmd)
+}
@@ -140,3 +140,133 @@ func TestKnownZoneIcons_NotEmpty(t *testing.T) {
 		}
 	}
 }
+
+func TestZonesTreeCommand_Exists(t *testing.T) {
+	cmd, _, err := zonesCmd.Find([]string{"tree"})
+	if err != nil {
+		t.Fatalf("tree command not found: %v", err)
+	}
+	if cmd.Name() != "tree" {
+		t.Errorf("expected command name 'tree', got '%s'", cmd.Name())
+	}
+}
+
+func TestZonesStatusCommand_RequiresOneArg(t *testing.T) {
+	cmd, _, err := zonesCmd.Find([]string{"status"})
+	if err != nil {
+		t.Fatalf("status command not found: %v", err)
+	}
+
+	if err := cmd.Args(cmd, []string{}); err == nil {
+		t.Error("expected error with 0 args")
+	}
+	if err := cmd.Args(cmd, []string{"Home"}); err != nil {
+		t.Errorf("expected no error with 1 arg, got: %v", err)
+	}
+}
+
+func testZones() map[string]Zone {
+	return map[string]Zone{
+		"home":    {ID: "home", Name: "Home"},
+		"floor1":  {ID: "floor1", Name: "First Floor", Parent: "home"},
+		"kitchen": {ID: "kitchen", Name: "Kitchen", Parent: "floor1"},
+		"bath":    {ID: "bath", Name: "Bathroom", Parent: "floor1"},
+		"garden":  {ID: "garden", Name: "Garden", Parent: "home"},
+	}
+}
+
+func TestBuildZoneTree(t *testing.T) {
+	devices := map[string]Device{
+		"d1": {ID: "d1", Zone: "kitchen"},
+		"d2": {ID: "d2", Zone: "kitchen"},
+		"d3": {ID: "d3", Zone: "bath"},
+		"d4": {ID: "d4", Zone: "home"},
+	}
+
+	roots := buildZoneTree(testZones(), devices)
+	if len(roots) != 1 || roots[0].ID != "home" {
+		t.Fatalf("expected single root 'home', got %+v", roots)
+	}
+
+	home := roots[0]
+	if home.Devices != 1 || home.TotalDevices != 4 {
+		t.Errorf("expected home 1/4 devices, got %d/%d", home.Devices, home.TotalDevices)
+	}
+	if len(home.Children) != 2 || home.Children[0].Name != "First Floor" || home.Children[1].Name != "Garden" {
+		t.Fatalf("expected children sorted by name, got %+v", home.Children)
+	}
+
+	floor := home.Children[0]
+	if floor.TotalDevices != 3 {
+		t.Errorf("expected first floor total 3, got %d", floor.TotalDevices)
+	}
+	if floor.Children[0].Name != "Bathroom" || floor.Children[1].Name != "Kitchen" {
+		t.Errorf("expected Bathroom before Kitchen, got %s, %s", floor.Children[0].Name, floor.Children[1].Name)
+	}
+}
+
+func TestZoneDescendants(t *testing.T) {
+	ids := zoneDescendants(testZones(), "floor1")
+
+	for _, id := range []string{"floor1", "kitchen", "bath"} {
+		if !ids[id] {
+			t.Errorf("expected %s to be included", id)
+		}
+	}
+	if ids["home"] || ids["garden"] {
+		t.Errorf("expected parent and sibling zones to be excluded, got %v", ids)
+	}
+}
+
+func TestAggregateZoneStatus(t *testing.T) {
+	devices := map[string]Device{
+		"lamp1": {ID: "lamp1", Name: "Lamp 1", Class: "light", Zone: "kitchen", CapabilitiesObj: map[string]Capability{
+			"onoff": {ID: "onoff", Value: true},
+		}},
+		"lamp2": {ID: "lamp2", Name: "Lamp 2", Class: "light", Zone: "bath", CapabilitiesObj: map[string]Capability{
+			"onoff": {ID: "onoff", Value: false},
+		}},
+		"sensor": {ID: "sensor", Name: "Sensor", Class: "sensor", Zone: "bath", CapabilitiesObj: map[string]Capability{
+			"measure_temperature": {ID: "measure_temperature", Value: 20.0},
+			"measure_humidity":    {ID: "measure_humidity", Value: 60.0},
+			"alarm_motion":        {ID: "alarm_motion", Value: true},
+			"alarm_water":         {ID: "alarm_water", Value: true},
+		}},
+		"door": {ID: "door", Name: "Door", Class: "sensor", Zone: "kitchen", CapabilitiesObj: map[string]Capability{
+			"measure_temperature": {ID: "measure_temperature", Value: 22.0},
+			"alarm_contact":       {ID: "alarm_contact", Value: true},
+			"alarm_tamper":        {ID: "alarm_tamper", Value: false},
+		}},
+		"outside": {ID: "outside", Name: "Outside", Class: "light", Zone: "garden", CapabilitiesObj: map[string]Capability{
+			"onoff": {ID: "onoff", Value: true},
+		}},
+	}
+	power := map[string]float64{"lamp1": 7.5, "outside": 40}
+
+	status := aggregateZoneStatus(zoneDescendants(testZones(), "floor1"), devices, power)
+
+	if status.Devices != 4 {
+		t.Errorf("expected 4 devices, got %d", status.Devices)
+	}
+	if status.LightsOn != 1 || status.LightsTotal != 2 {
+		t.Errorf("expected 1/2 lights on, got %d/%d", status.LightsOn, status.LightsTotal)
+	}
+	if status.Temperature == nil || *status.Temperature != 21 {
+		t.Errorf("expected average temperature 21, got %v", status.Temperature)
+	}
+	if status.Humidity == nil || *status.Humidity != 60 {
+		t.Errorf("expected humidity 60, got %v", status.Humidity)
+	}
+	if len(status.OpenContacts) != 1 || status.OpenContacts[0] != "Door" {
+		t.Errorf("expected open contact 'Door', got %v", status.OpenContacts)
+	}
+	if len(status.Motion) != 1 || status.Motion[0] != "Sensor" {
+		t.Errorf("expected motion on 'Sensor', got %v", status.Motion)
+	}
+	if len(status.ActiveAlarms) != 1 || status.ActiveAlarms[0] != "Sensor (alarm_water)" {
+		t.Errorf("expected water alarm only, got %v", status.ActiveAlarms)
+	}
+	if status.PowerW == nil || *status.PowerW != 7.5 {
+		t.Errorf("expected 7.5 W, got %v", status.PowerW)
+	}
+}
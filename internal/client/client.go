@@ -160,6 +160,23 @@ func (c *Client) DeleteFlow(id string) error {
 	return err
 }
 
+// MergeUpdate returns current with update applied the way Homey applies a
+// PUT: every top-level field in update replaces the current one whole, and
+// fields left out keep their value. Nested objects are not merged, so an
+// advanced flow update with "cards" must hold every card the flow keeps;
+// cards missing from it are deleted.
+func MergeUpdate(current, update map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(current)+len(update))
+	for k, v := range current {
+		merged[k] = v
+	}
+	for k, v := range update {
+		merged[k] = v
+	}
+	return merged
+}
+
+// UpdateAdvancedFlow sends an update merged as described at MergeUpdate
 func (c *Client) UpdateAdvancedFlow(id string, flow map[string]interface{}) (json.RawMessage, error) {
 	return c.doRequest("PUT", "/api/manager/flow/advancedflow/"+id, flow)
 }
@@ -245,6 +262,14 @@ func (c *Client) RunFlowCardAction(uri, id string, args map[string]interface{})
 	return c.doRequest("POST", fmt.Sprintf("/api/manager/flow/flowcardaction/%s/%s/run", uri, id), body)
 }
 
+// RunFlowCardCondition evaluates any flow card condition
+func (c *Client) RunFlowCardCondition(uri, id string, args map[string]interface{}) (json.RawMessage, error) {
+	body := map[string]interface{}{
+		"args": args,
+	}
+	return c.doRequest("POST", fmt.Sprintf("/api/manager/flow/flowcardcondition/%s/%s/run", uri, id), body)
+}
+
 // Logic variables
 
 func (c *Client) GetVariables() (json.RawMessage, error) {
@@ -521,6 +546,14 @@ func (c *Client) SetMood(id string) error {
 	return err
 }
 
+// Images
+
+// GetImages lists the images apps and devices have registered, such as
+// camera snapshots, which image flow card arguments take
+func (c *Client) GetImages() (json.RawMessage, error) {
+	return c.doRequest("GET", "/api/manager/images/image/", nil)
+}
+
 // Dashboards
 
 func (c *Client) GetDashboards() (json.RawMessage, error) {
@@ -760,3 +760,22 @@ func TestDisableApp(t *testing.T) {
 		t.Errorf("expected path %s, got %s", expectedPath, receivedPath)
 	}
 }
+
+func TestMergeUpdate(t *testing.T) {
+	current := map[string]interface{}{
+		"name":  "Morning",
+		"cards": map[string]interface{}{"t1": map[string]interface{}{"type": "trigger"}, "a1": map[string]interface{}{"type": "action"}},
+	}
+	update := map[string]interface{}{"cards": map[string]interface{}{"t1": map[string]interface{}{"type": "trigger"}}}
+
+	merged := MergeUpdate(current, update)
+	if merged["name"] != "Morning" {
+		t.Errorf("expected fields left out to be kept, got %v", merged)
+	}
+	if cards := merged["cards"].(map[string]interface{}); len(cards) != 1 || cards["a1"] != nil {
+		t.Errorf("expected cards to be replaced whole, got %v", cards)
+	}
+	if len(current["cards"].(map[string]interface{})) != 2 {
+		t.Error("current was modified")
+	}
+}
@@ -0,0 +1,264 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// fixture is one recorded request/response pair. Headers are not recorded,
+// and values that look like credentials are redacted from the bodies, but
+// fixtures can still hold personal data such as names and addresses.
+type fixture struct {
+	Request struct {
+		Method string          `json:"method"`
+		Path   string          `json:"path"`
+		Body   json.RawMessage `json:"body,omitempty"`
+	} `json:"request"`
+	Response struct {
+		Status int             `json:"status"`
+		Body   json.RawMessage `json:"body,omitempty"`
+		Text   string          `json:"text,omitempty"` // non-JSON body
+	} `json:"response"`
+}
+
+func (f *fixture) key() string {
+	return f.Request.Method + " " + f.Request.Path
+}
+
+var fixtureNameUnsafe = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// recordTransport writes every request/response pair to a directory
+type recordTransport struct {
+	next http.RoundTripper
+	dir  string
+
+	mu  sync.Mutex
+	seq int
+}
+
+// NewRecordTransport returns a transport that saves each request and its
+// response as a numbered JSON fixture in dir. Numbering continues after any
+// fixtures already in dir, so several commands can be recorded in a row.
+func NewRecordTransport(next http.RoundTripper, dir string) http.RoundTripper {
+	existing, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	return &recordTransport{next: next, dir: dir, seq: len(existing)}
+}
+
+func (t *recordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var f fixture
+	f.Request.Method = req.Method
+	f.Request.Path = req.URL.RequestURI()
+	if req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		if json.Valid(body) {
+			f.Request.Body = redactBody(body, req.URL.Path)
+		}
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	f.Response.Status = resp.StatusCode
+	if json.Valid(body) {
+		f.Response.Body = redactBody(body, req.URL.Path)
+	} else {
+		f.Response.Text = string(body)
+	}
+
+	t.mu.Lock()
+	t.seq++
+	seq := t.seq
+	t.mu.Unlock()
+
+	slug := strings.Trim(fixtureNameUnsafe.ReplaceAllString(strings.TrimPrefix(req.URL.Path, "/api/manager/"), "_"), "_")
+	if len(slug) > 60 {
+		slug = slug[:60]
+	}
+	name := fmt.Sprintf("%04d-%s-%s.json", seq, req.Method, slug)
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(t.dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create record dir: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(t.dir, name), data, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to record fixture: %w", err)
+	}
+	return resp, nil
+}
+
+// secretKeyWords mark object keys and setting names whose values are
+// credentials, e.g. "token", "api_key" or "smtpPassword"
+var secretKeyWords = []string{"token", "secret", "password", "passwd", "apikey", "credential"}
+
+func isSecretKey(key string) bool {
+	key = strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(key))
+	for _, w := range secretKeyWords {
+		if strings.Contains(key, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactBody hides credentials in a recorded JSON body: string values of
+// secret-looking keys, like the token of a new PAT or an app's API key
+// setting, and the whole body when the path itself names a secret setting,
+// as in PUT .../setting/token. The body is returned unchanged when there
+// is nothing to hide.
+func redactBody(body []byte, path string) []byte {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return body
+	}
+	var changed bool
+	if isSecretKey(path[strings.LastIndex(path, "/")+1:]) {
+		v = redactAll(v, &changed)
+	} else {
+		v = redactSecrets(v, &changed)
+	}
+	if !changed {
+		return body
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return body
+	}
+	return out
+}
+
+func redactSecrets(v interface{}, changed *bool) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for k, item := range x {
+			if isSecretKey(k) {
+				x[k] = redactAll(item, changed)
+			} else {
+				x[k] = redactSecrets(item, changed)
+			}
+		}
+	case []interface{}:
+		for i, item := range x {
+			x[i] = redactSecrets(item, changed)
+		}
+	}
+	return v
+}
+
+// redactAll replaces every non-empty string in v
+func redactAll(v interface{}, changed *bool) interface{} {
+	switch x := v.(type) {
+	case string:
+		if x != "" {
+			*changed = true
+			return "[REDACTED]"
+		}
+	case map[string]interface{}:
+		for k, item := range x {
+			x[k] = redactAll(item, changed)
+		}
+	case []interface{}:
+		for i, item := range x {
+			x[i] = redactAll(item, changed)
+		}
+	}
+	return v
+}
+
+// replayTransport serves recorded fixtures instead of talking to Homey
+type replayTransport struct {
+	mu        sync.Mutex
+	responses map[string][]*fixture
+}
+
+// NewReplayTransport returns a transport that answers requests from the
+// fixtures in dir, matched on method and path. Fixtures for the same
+// request are served in file order; the last one keeps being served once
+// the others are used up. Unmatched requests fail.
+func NewReplayTransport(dir string) (http.RoundTripper, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no fixtures found in %s", dir)
+	}
+	sort.Strings(files)
+
+	t := &replayTransport{responses: make(map[string][]*fixture)}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fixture: %w", err)
+		}
+		f := &fixture{}
+		if err := json.Unmarshal(data, f); err != nil {
+			return nil, fmt.Errorf("invalid fixture %s: %w", filepath.Base(file), err)
+		}
+		if f.Response.Status == 0 {
+			f.Response.Status = http.StatusOK
+		}
+		t.responses[f.key()] = append(t.responses[f.key()], f)
+	}
+	return t, nil
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	key := req.Method + " " + req.URL.RequestURI()
+
+	t.mu.Lock()
+	queue := t.responses[key]
+	if len(queue) == 0 {
+		t.mu.Unlock()
+		return nil, fmt.Errorf("replay: no recorded response for %s", key)
+	}
+	f := queue[0]
+	if len(queue) > 1 {
+		t.responses[key] = queue[1:]
+	}
+	t.mu.Unlock()
+
+	body := []byte(f.Response.Text)
+	if len(f.Response.Body) > 0 {
+		body = f.Response.Body
+	}
+	return &http.Response{
+		StatusCode:    f.Response.Status,
+		Status:        fmt.Sprintf("%d %s", f.Response.Status, http.StatusText(f.Response.Status)),
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,101 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// traceTransport logs requests and responses with timings
+type traceTransport struct {
+	next   http.RoundTripper
+	out    io.Writer
+	bodies bool
+}
+
+// NewTraceTransport returns a transport that logs each request line,
+// response status, size and duration to out. With bodies set it also logs
+// headers and bodies; the Authorization header is always redacted.
+func NewTraceTransport(next http.RoundTripper, out io.Writer, bodies bool) http.RoundTripper {
+	return &traceTransport{next: next, out: out, bodies: bodies}
+}
+
+func (t *traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	fmt.Fprintf(t.out, "→ %s %s\n", req.Method, req.URL.RequestURI())
+	if t.bodies {
+		t.writeHeaders(req.Header)
+		if req.Body != nil {
+			body, err := io.ReadAll(req.Body)
+			req.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			t.writeBody(body)
+		}
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		fmt.Fprintf(t.out, "✗ %s %s failed after %s: %v\n", req.Method, req.URL.RequestURI(), elapsed, err)
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	fmt.Fprintf(t.out, "← %s (%s, %s)\n", resp.Status, elapsed, formatSize(len(body)))
+	if t.bodies {
+		t.writeHeaders(resp.Header)
+		t.writeBody(body)
+	}
+	return resp, nil
+}
+
+func (t *traceTransport) writeHeaders(h http.Header) {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, v := range h[name] {
+			if http.CanonicalHeaderKey(name) == "Authorization" || http.CanonicalHeaderKey(name) == "Cookie" {
+				v = redact(v)
+			}
+			fmt.Fprintf(t.out, "  %s: %s\n", name, v)
+		}
+	}
+}
+
+func (t *traceTransport) writeBody(body []byte) {
+	if len(body) == 0 {
+		return
+	}
+	fmt.Fprintf(t.out, "  %s\n", bytes.TrimSpace(body))
+}
+
+// redact keeps the auth scheme and hides the credentials
+func redact(v string) string {
+	if i := strings.IndexByte(v, ' '); i > 0 {
+		return v[:i] + " [REDACTED]"
+	}
+	return "[REDACTED]"
+}
+
+func formatSize(n int) string {
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+	return fmt.Sprintf("%.1f kB", float64(n)/1024)
+}
@@ -0,0 +1,123 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/fishfisher/homeyctl/internal/jsondiff"
+)
+
+// ErrDryRun is returned for a request the dry-run transport intercepted
+var ErrDryRun = errors.New("dry run: request not sent")
+
+// WrapTransport layers a transport over the client's current one. Layers
+// added later see requests first.
+func (c *Client) WrapTransport(wrap func(http.RoundTripper) http.RoundTripper) {
+	base := c.httpClient.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	c.httpClient.Transport = wrap(base)
+}
+
+// dryRunTransport lets GET requests through and prints every other request
+// instead of sending it
+type dryRunTransport struct {
+	next http.RoundTripper
+	out  io.Writer
+}
+
+// NewDryRunTransport returns a transport that prints the method, path and
+// JSON body of mutating requests to out and fails them with ErrDryRun.
+// Condition cards are still evaluated. For
+// PUT requests the current object is fetched and the would-be changes are
+// shown as a diff.
+func NewDryRunTransport(next http.RoundTripper, out io.Writer) http.RoundTripper {
+	return &dryRunTransport{next: next, out: out}
+}
+
+// evaluatesCondition reports whether a request runs a condition card, which
+// only reads state, so a dry run still sends it
+func evaluatesCondition(req *http.Request) bool {
+	return req.Method == http.MethodPost &&
+		strings.HasPrefix(req.URL.Path, "/api/manager/flow/flowcardcondition/") &&
+		strings.HasSuffix(req.URL.Path, "/run")
+}
+
+func (t *dryRunTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == http.MethodGet || req.Method == http.MethodHead || evaluatesCondition(req) {
+		return t.next.RoundTrip(req)
+	}
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	fmt.Fprintf(t.out, "DRY RUN: %s %s\n", req.Method, req.URL.RequestURI())
+	if len(body) > 0 {
+		var pretty bytes.Buffer
+		if json.Indent(&pretty, body, "", "  ") == nil {
+			fmt.Fprintln(t.out, pretty.String())
+		} else {
+			fmt.Fprintln(t.out, string(body))
+		}
+	}
+
+	if req.Method == http.MethodPut && len(body) > 0 {
+		if changes, ok := t.diffCurrent(req, body); ok {
+			if len(changes) == 0 {
+				fmt.Fprintln(t.out, "No changes.")
+			} else {
+				fmt.Fprintln(t.out, "Changes:")
+				for _, c := range changes {
+					fmt.Fprintf(t.out, "  %s\n", c)
+				}
+			}
+		}
+	}
+
+	return nil, ErrDryRun
+}
+
+// diffCurrent fetches the object at the request path and diffs it against
+// the object with the update merged in by MergeUpdate. ok is false when the
+// current object can't be read as a JSON object.
+func (t *dryRunTransport) diffCurrent(req *http.Request, body []byte) ([]jsondiff.Change, bool) {
+	var update map[string]interface{}
+	if json.Unmarshal(body, &update) != nil {
+		return nil, false
+	}
+
+	get, err := http.NewRequestWithContext(req.Context(), http.MethodGet, req.URL.String(), nil)
+	if err != nil {
+		return nil, false
+	}
+	get.Header = req.Header.Clone()
+	get.Header.Del("Content-Type")
+
+	resp, err := t.next.RoundTrip(get)
+	if err != nil {
+		return nil, false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, false
+	}
+
+	var current map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&current); err != nil {
+		return nil, false
+	}
+	return jsondiff.Diff(current, MergeUpdate(current, update)), true
+}
@@ -0,0 +1,228 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDryRunTransport(t *testing.T) {
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			t.Errorf("expected Authorization on GET, got %q", r.Header.Get("Authorization"))
+		}
+		w.Write([]byte(`{"id":"f1","name":"Old","enabled":true}`))
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	client := &Client{
+		baseURL:    server.URL,
+		token:      "test-token",
+		httpClient: server.Client(),
+	}
+	client.WrapTransport(func(next http.RoundTripper) http.RoundTripper {
+		return NewDryRunTransport(next, &out)
+	})
+
+	_, err := client.UpdateFlow("f1", map[string]interface{}{"name": "New", "enabled": true})
+	if !errors.Is(err, ErrDryRun) {
+		t.Fatalf("expected ErrDryRun, got %v", err)
+	}
+	if len(methods) != 1 || methods[0] != "GET" {
+		t.Errorf("expected only the GET for the diff to reach the server, got %v", methods)
+	}
+
+	got := out.String()
+	for _, want := range []string{"DRY RUN: PUT /api/manager/flow/flow/f1", `"name": "New"`, `~ /name: "Old" → "New"`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "/enabled") {
+		t.Errorf("unchanged field shown in diff:\n%s", got)
+	}
+
+	// Reads still go through, and so do condition cards, which only read
+	if _, err := client.GetFlows(); err != nil {
+		t.Errorf("expected GET to pass through, got %v", err)
+	}
+	if _, err := client.RunFlowCardCondition("homey:manager:logic", "homey:manager:logic:lt", nil); err != nil {
+		t.Errorf("expected condition to be evaluated, got %v", err)
+	}
+	if _, err := client.RunFlowCardAction("homey:manager:logic", "homey:manager:logic:set", nil); !errors.Is(err, ErrDryRun) {
+		t.Errorf("expected action to be intercepted, got %v", err)
+	}
+}
+
+func TestDryRunTransport_DeleteWithoutBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected %s request to server", r.Method)
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	client := &Client{
+		baseURL:    server.URL,
+		token:      "test-token",
+		httpClient: server.Client(),
+	}
+	client.WrapTransport(func(next http.RoundTripper) http.RoundTripper {
+		return NewDryRunTransport(next, &out)
+	})
+
+	if err := client.DeleteZone("z1"); !errors.Is(err, ErrDryRun) {
+		t.Fatalf("expected ErrDryRun, got %v", err)
+	}
+	if out.String() != "DRY RUN: DELETE /api/manager/zones/zone/z1\n" {
+		t.Errorf("unexpected output: %q", out.String())
+	}
+}
+
+func TestTraceTransport_RedactsAuthorization(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	client := &Client{
+		baseURL:    server.URL,
+		token:      "secret-token",
+		httpClient: server.Client(),
+	}
+	client.WrapTransport(func(next http.RoundTripper) http.RoundTripper {
+		return NewTraceTransport(next, &out, true)
+	})
+
+	if err := client.UpdateZone("z1", map[string]interface{}{"name": "Hall"}); err != nil {
+		t.Fatalf("UpdateZone failed: %v", err)
+	}
+
+	got := out.String()
+	if strings.Contains(got, "secret-token") {
+		t.Errorf("token leaked into trace:\n%s", got)
+	}
+	for _, want := range []string{"→ PUT /api/manager/zones/zone/z1", "Authorization: Bearer [REDACTED]", `{"name":"Hall"}`, "← 200 OK (", `{"ok":true}`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("trace missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "DELETE" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("zone not found"))
+			return
+		}
+		w.Write([]byte(`{"z1":{"id":"z1","name":"Hall"}}`))
+	}))
+
+	recording := &Client{
+		baseURL:    server.URL,
+		token:      "secret-token",
+		httpClient: server.Client(),
+	}
+	recording.WrapTransport(func(next http.RoundTripper) http.RoundTripper {
+		return NewRecordTransport(next, dir)
+	})
+	want, err := recording.GetZones()
+	if err != nil {
+		t.Fatalf("GetZones failed: %v", err)
+	}
+	recording.DeleteZone("z1")
+	server.Close()
+
+	replay, err := NewReplayTransport(dir)
+	if err != nil {
+		t.Fatalf("NewReplayTransport failed: %v", err)
+	}
+	replaying := &Client{
+		baseURL:    "http://homey.invalid",
+		httpClient: &http.Client{Transport: replay},
+	}
+
+	got, err := replaying.GetZones()
+	if err != nil {
+		t.Fatalf("replayed GetZones failed: %v", err)
+	}
+	var compacted bytes.Buffer
+	json.Compact(&compacted, got)
+	if compacted.String() != string(want) {
+		t.Errorf("replayed %s, want %s", got, want)
+	}
+	if err := replaying.DeleteZone("z1"); err == nil || !strings.Contains(err.Error(), "404: zone not found") {
+		t.Errorf("expected replayed 404, got %v", err)
+	}
+	if _, err := replaying.GetDevices(); err == nil || !strings.Contains(err.Error(), "no recorded response") {
+		t.Errorf("expected unmatched request to fail, got %v", err)
+	}
+}
+
+func TestRecordTransport_RedactsSecrets(t *testing.T) {
+	dir := t.TempDir()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/users/pat"):
+			w.Write([]byte(`{"id":"p1","name":"ci","token":"pat-secret","scopes":["homey"]}`))
+		case strings.HasSuffix(r.URL.Path, "/setting"):
+			w.Write([]byte(`{"api_key":"app-secret","interval":60,"accounts":[{"user":"ann","password":"hunter2"}]}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	c := &Client{
+		baseURL:    server.URL,
+		token:      "secret-token",
+		httpClient: server.Client(),
+	}
+	c.WrapTransport(func(next http.RoundTripper) http.RoundTripper {
+		return NewRecordTransport(next, dir)
+	})
+	created, err := c.CreatePAT("ci", []string{"homey"})
+	if err != nil {
+		t.Fatalf("CreatePAT failed: %v", err)
+	}
+	if !strings.Contains(string(created), "pat-secret") {
+		t.Errorf("redaction must not change the live response, got %s", created)
+	}
+	if _, err := c.GetAppSettings("com.tibber"); err != nil {
+		t.Fatalf("GetAppSettings failed: %v", err)
+	}
+	if err := c.SetAppSetting("com.tibber", "token", "new-secret"); err != nil {
+		t.Fatalf("SetAppSetting failed: %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 3 {
+		t.Fatalf("expected 3 fixtures, got %v", files)
+	}
+	var all strings.Builder
+	for _, file := range files {
+		data, _ := os.ReadFile(file)
+		all.Write(data)
+	}
+	for _, secret := range []string{"pat-secret", "app-secret", "hunter2", "new-secret", "secret-token"} {
+		if strings.Contains(all.String(), secret) {
+			t.Errorf("fixtures contain %q:\n%s", secret, all.String())
+		}
+	}
+	for _, kept := range []string{`"name": "ci"`, `"interval": 60`, `"user": "ann"`} {
+		if !strings.Contains(all.String(), kept) {
+			t.Errorf("fixtures lost %s:\n%s", kept, all.String())
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -19,6 +20,83 @@ type CloudConfig struct {
 	Token string `mapstructure:"token"` // Cloud token/PAT
 }
 
+// TariffConfig describes grid fees, taxes, and subsidies added on top of
+// the electricity price. Amounts are per kWh excluding VAT.
+type TariffConfig struct {
+	VAT              float64        `mapstructure:"vat"`               // e.g. 0.25 for 25%
+	SpotIncludesVAT  bool           `mapstructure:"spot_includes_vat"` // Homey prices already include VAT
+	Markup           float64        `mapstructure:"markup"`            // Supplier markup
+	EnergyTax        float64        `mapstructure:"energy_tax"`        // Consumption tax
+	SubsidyThreshold float64        `mapstructure:"subsidy_threshold"` // Spot price where the subsidy starts
+	SubsidyRate      float64        `mapstructure:"subsidy_rate"`      // Share above the threshold covered, e.g. 0.9
+	GridFees         []GridFee      `mapstructure:"grid_fees"`
+	CapacityTiers    []CapacityTier `mapstructure:"capacity_tiers"`
+}
+
+// GridFee is a time-of-use grid energy fee
+type GridFee struct {
+	Hours string  `mapstructure:"hours"` // Clock range like "06-22", empty for all day
+	Days  string  `mapstructure:"days"`  // all, weekdays, weekends (default: all)
+	Price float64 `mapstructure:"price"`
+}
+
+// CapacityTier is a monthly capacity (effect) tariff step
+type CapacityTier struct {
+	MaxKW   float64 `mapstructure:"max_kw" json:"max_kw"` // Upper bound of the average of the top 3 peaks
+	Monthly float64 `mapstructure:"monthly" json:"monthly"`
+}
+
+// PresenceConfig maps geofence apps and phones on the LAN to Homey users
+type PresenceConfig struct {
+	Secret     string           `mapstructure:"secret"`      // Required in webhook requests when set
+	HomeRegion string           `mapstructure:"home_region"` // Geofence region that means home; empty accepts any
+	HomeAfter  time.Duration    `mapstructure:"home_after"`  // Seen this long before arriving
+	AwayAfter  time.Duration    `mapstructure:"away_after"`  // Unseen this long before leaving
+	Leases     []string         `mapstructure:"leases"`      // DHCP lease files
+	People     []PresencePerson `mapstructure:"people"`
+}
+
+// PresencePerson is one Homey user and the ways to detect them
+type PresencePerson struct {
+	User      string   `mapstructure:"user"`      // Homey user name or ID
+	IDs       []string `mapstructure:"ids"`       // Geofence app user, device or tracker IDs
+	IPs       []string `mapstructure:"ips"`       // Phone IP addresses
+	MACs      []string `mapstructure:"macs"`      // Phone MAC addresses
+	Hostnames []string `mapstructure:"hostnames"` // Phone DHCP host names
+}
+
+// NotifyConfig holds the external channels 'notify send --to' and
+// 'notify forward' deliver to, by name
+type NotifyConfig struct {
+	Channels map[string]NotifyChannel `mapstructure:"channels"`
+	Forward  []string                 `mapstructure:"forward"` // Default channels for 'notify forward'
+}
+
+// NotifyChannel is one notification backend. Type selects which of the
+// other fields apply: smtp, ntfy or webhook.
+type NotifyChannel struct {
+	Type string `mapstructure:"type"`
+
+	// smtp
+	Host     string   `mapstructure:"host"`
+	Port     int      `mapstructure:"port"` // 587 uses STARTTLS, 465 implicit TLS
+	Username string   `mapstructure:"username"`
+	Password string   `mapstructure:"password"`
+	From     string   `mapstructure:"from"`
+	To       []string `mapstructure:"to"`
+	Subject  string   `mapstructure:"subject"` // Template, default "{{.Title}}"
+
+	// ntfy and webhook
+	URL         string            `mapstructure:"url"`
+	Token       string            `mapstructure:"token"`    // Sent as a Bearer token
+	Priority    string            `mapstructure:"priority"` // ntfy priority: min, low, default, high, urgent
+	Tags        []string          `mapstructure:"tags"`     // ntfy tags
+	Method      string            `mapstructure:"method"`   // webhook method, default POST
+	ContentType string            `mapstructure:"content_type"`
+	Template    string            `mapstructure:"template"` // webhook body template
+	Headers     map[string]string `mapstructure:"headers"`
+}
+
 type Config struct {
 	// Legacy fields (still supported for backwards compatibility)
 	Host   string `mapstructure:"host"`
@@ -31,6 +109,15 @@ type Config struct {
 	Mode  string      `mapstructure:"mode"` // auto, local, cloud
 	Local LocalConfig `mapstructure:"local"`
 	Cloud CloudConfig `mapstructure:"cloud"`
+
+	// Local energy tariff model
+	Tariff TariffConfig `mapstructure:"tariff"`
+
+	// Presence detection for 'presence serve' and 'presence scan'
+	Presence PresenceConfig `mapstructure:"presence"`
+
+	// External notification channels
+	Notify NotifyConfig `mapstructure:"notify"`
 }
 
 // BaseURL returns the API base URL based on current mode
@@ -96,8 +183,28 @@ func (c *Config) EffectiveToken() string {
 	return c.Token
 }
 
+// DirEnv overrides the homeyctl config directory, e.g. for tests or to
+// keep several setups apart
+const DirEnv = "HOMEYCTL_CONFIG_DIR"
+
+// Dir returns the homeyctl config directory: $HOMEYCTL_CONFIG_DIR when set,
+// otherwise homeyctl in the user config directory
+func Dir() (string, error) {
+	if dir := os.Getenv(DirEnv); dir != "" {
+		return dir, nil
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "homeyctl"), nil
+}
+
 // CheckLegacyConfig checks if the old homey-cli config exists and prints migration instructions
 func CheckLegacyConfig() {
+	if os.Getenv(DirEnv) != "" {
+		return
+	}
 	configDir, err := os.UserConfigDir()
 	if err != nil {
 		return
@@ -127,9 +234,8 @@ func Load() (*Config, error) {
 	viper.SetConfigType("toml")
 
 	// Config locations
-	configDir, err := os.UserConfigDir()
-	if err == nil {
-		viper.AddConfigPath(filepath.Join(configDir, "homeyctl"))
+	if dir, err := Dir(); err == nil {
+		viper.AddConfigPath(dir)
 	}
 	viper.AddConfigPath(".")
 
@@ -143,9 +249,10 @@ func Load() (*Config, error) {
 	_ = viper.BindEnv("port")
 	_ = viper.BindEnv("format")
 	_ = viper.BindEnv("mode")
-	_ = viper.BindEnv("address")       // HOMEY_ADDRESS for local mode
-	_ = viper.BindEnv("local.token")   // HOMEY_LOCAL_TOKEN
-	_ = viper.BindEnv("local.address") // HOMEY_LOCAL_ADDRESS
+	_ = viper.BindEnv("address") // HOMEY_ADDRESS for local mode
+	// Nested keys need explicit names, or viper looks for HOMEY_LOCAL.TOKEN
+	_ = viper.BindEnv("local.token", "HOMEY_LOCAL_TOKEN")
+	_ = viper.BindEnv("local.address", "HOMEY_LOCAL_ADDRESS")
 
 	// Defaults
 	viper.SetDefault("host", "localhost")
@@ -169,12 +276,11 @@ func Load() (*Config, error) {
 }
 
 func Save(cfg *Config) error {
-	configDir, err := os.UserConfigDir()
+	dir, err := Dir()
 	if err != nil {
 		return fmt.Errorf("failed to get config dir: %w", err)
 	}
 
-	dir := filepath.Join(configDir, "homeyctl")
 	if err := os.MkdirAll(dir, 0o755); err != nil {
 		return fmt.Errorf("failed to create config dir: %w", err)
 	}
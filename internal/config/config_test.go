@@ -1,7 +1,12 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/spf13/viper"
 )
 
 func TestBaseURL(t *testing.T) {
@@ -197,3 +202,152 @@ func TestBaseURLLocalFallsBackToLegacy(t *testing.T) {
 		t.Errorf("BaseURL() = %q, want %q", got, expected)
 	}
 }
+
+func TestLoadTariff(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(DirEnv, dir)
+	t.Setenv("HOME", dir)
+	t.Chdir(dir)
+	toml := `
+[tariff]
+vat = 0.25
+energy_tax = 0.0979
+subsidy_threshold = 0.75
+subsidy_rate = 0.9
+
+[[tariff.grid_fees]]
+hours = "06-22"
+days = "weekdays"
+price = 0.40
+
+[[tariff.grid_fees]]
+price = 0.30
+
+[[tariff.capacity_tiers]]
+max_kw = 2
+monthly = 130
+`
+	if err := os.WriteFile(filepath.Join(dir, "config.toml"), []byte(toml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	viper.Reset()
+	defer viper.Reset()
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	if cfg.Tariff.VAT != 0.25 || cfg.Tariff.SubsidyRate != 0.9 {
+		t.Errorf("unexpected tariff: %+v", cfg.Tariff)
+	}
+	if len(cfg.Tariff.GridFees) != 2 || cfg.Tariff.GridFees[0].Hours != "06-22" || cfg.Tariff.GridFees[1].Price != 0.30 {
+		t.Errorf("unexpected grid fees: %+v", cfg.Tariff.GridFees)
+	}
+	if len(cfg.Tariff.CapacityTiers) != 1 || cfg.Tariff.CapacityTiers[0].MaxKW != 2 {
+		t.Errorf("unexpected capacity tiers: %+v", cfg.Tariff.CapacityTiers)
+	}
+}
+
+func TestLoadPresence(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(DirEnv, dir)
+	t.Setenv("HOME", dir)
+	t.Chdir(dir)
+	toml := `
+[presence]
+secret = "s3cret"
+away_after = "10m"
+leases = ["/var/lib/misc/dnsmasq.leases"]
+
+[[presence.people]]
+user = "Ann"
+ids = ["ann", "ann/phone"]
+ips = ["192.168.1.23"]
+macs = ["aa:bb:cc:dd:ee:ff"]
+`
+	if err := os.WriteFile(filepath.Join(dir, "config.toml"), []byte(toml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	viper.Reset()
+	defer viper.Reset()
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	p := cfg.Presence
+	if p.Secret != "s3cret" || p.AwayAfter != 10*time.Minute || len(p.Leases) != 1 {
+		t.Errorf("unexpected presence config: %+v", p)
+	}
+	if len(p.People) != 1 || p.People[0].User != "Ann" || len(p.People[0].IDs) != 2 || p.People[0].MACs[0] != "aa:bb:cc:dd:ee:ff" {
+		t.Errorf("unexpected people: %+v", p.People)
+	}
+}
+
+func TestLoadNotify(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(DirEnv, dir)
+	t.Setenv("HOME", dir)
+	t.Chdir(dir)
+	toml := `
+[notify]
+forward = ["ntfy", "slack"]
+
+[notify.channels.ntfy]
+type = "ntfy"
+url = "https://ntfy.sh/homey"
+priority = "high"
+
+[notify.channels.slack]
+type = "webhook"
+url = "https://hooks.slack.com/services/x"
+template = '{"text": {{json .Message}}}'
+headers = { "X-Source" = "homeyctl" }
+`
+	if err := os.WriteFile(filepath.Join(dir, "config.toml"), []byte(toml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	viper.Reset()
+	defer viper.Reset()
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	n := cfg.Notify
+	if len(n.Forward) != 2 || len(n.Channels) != 2 {
+		t.Fatalf("unexpected notify config: %+v", n)
+	}
+	if c := n.Channels["ntfy"]; c.Type != "ntfy" || c.Priority != "high" {
+		t.Errorf("unexpected ntfy channel: %+v", c)
+	}
+	if c := n.Channels["slack"]; c.Template != `{"text": {{json .Message}}}` || c.Headers["x-source"] != "homeyctl" {
+		t.Errorf("unexpected webhook channel: %+v", c)
+	}
+}
+
+func TestLoadLocalFromEnv(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(DirEnv, dir)
+	t.Setenv("HOME", dir)
+	t.Chdir(dir)
+	t.Setenv("HOMEY_LOCAL_ADDRESS", "http://192.168.1.50")
+	t.Setenv("HOMEY_LOCAL_TOKEN", "local-token")
+
+	viper.Reset()
+	defer viper.Reset()
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if cfg.Local.Address != "http://192.168.1.50" || cfg.EffectiveToken() != "local-token" {
+		t.Errorf("expected local settings from env, got %+v", cfg.Local)
+	}
+}
@@ -0,0 +1,404 @@
+// Package expr evaluates small arithmetic and boolean expressions such as
+// "value * 2 + 1" or "temperature < 18 && !away" against named values.
+//
+// Numbers are float64, strings are double or single quoted, and true, false
+// and null are literals. Operators, from lowest to highest precedence:
+//
+//	cond ? a : b
+//	|| (or)
+//	&& (and)
+//	== != < <= > >=
+//	+ -            (+ also joins strings)
+//	* / %
+//	! -            (unary)
+//
+// Names may use dots to reach into nested objects ("kitchen.temperature").
+// Functions: abs, min, max, round, floor, ceil, clamp.
+package expr
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Env holds the values names in an expression refer to
+type Env map[string]interface{}
+
+// Expr is a parsed expression
+type Expr struct {
+	src  string
+	root node
+}
+
+// Parse parses an expression
+func Parse(src string) (*Expr, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	root, err := p.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %s at position %d", t, t.pos+1)
+	}
+	return &Expr{src: src, root: root}, nil
+}
+
+// Eval parses and evaluates an expression in one step
+func Eval(src string, env Env) (interface{}, error) {
+	e, err := Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	return e.Eval(env)
+}
+
+// Eval evaluates the expression. The result is a float64, string, bool or
+// nil, or a value taken unchanged from env.
+func (e *Expr) Eval(env Env) (interface{}, error) {
+	return e.root.eval(env)
+}
+
+// Names returns the top-level names the expression refers to, in order of
+// first use
+func (e *Expr) Names() []string {
+	var names []string
+	seen := map[string]bool{}
+	e.root.walk(func(n node) {
+		if id, ok := n.(*identNode); ok {
+			name := strings.SplitN(id.name, ".", 2)[0]
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	})
+	return names
+}
+
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Truthy reports whether v counts as true in a condition: false, nil, 0 and
+// "" are false, everything else is true
+func Truthy(v interface{}) bool {
+	switch x := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return x
+	case string:
+		return x != ""
+	}
+	if n, ok := toNumber(v); ok {
+		return n != 0
+	}
+	return true
+}
+
+// toNumber converts the numeric types JSON decoding and callers produce
+func toNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+func describe(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	}
+	if _, ok := toNumber(v); ok {
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func formatValue(v interface{}) string {
+	if n, ok := toNumber(v); ok {
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	if v == nil {
+		return "null"
+	}
+	return fmt.Sprint(v)
+}
+
+type node interface {
+	eval(env Env) (interface{}, error)
+	walk(fn func(node))
+}
+
+type literalNode struct{ value interface{} }
+
+func (n *literalNode) eval(Env) (interface{}, error) { return n.value, nil }
+func (n *literalNode) walk(fn func(node))            { fn(n) }
+
+type identNode struct{ name string }
+
+func (n *identNode) eval(env Env) (interface{}, error) {
+	parts := strings.Split(n.name, ".")
+	v, ok := env[parts[0]]
+	if !ok {
+		return nil, fmt.Errorf("unknown name %q", parts[0])
+	}
+	for i, part := range parts[1:] {
+		obj, isObj := v.(map[string]interface{})
+		if !isObj {
+			return nil, fmt.Errorf("%s is not an object", strings.Join(parts[:i+1], "."))
+		}
+		if v, ok = obj[part]; !ok {
+			return nil, fmt.Errorf("unknown name %q", strings.Join(parts[:i+2], "."))
+		}
+	}
+	return v, nil
+}
+
+func (n *identNode) walk(fn func(node)) { fn(n) }
+
+type unaryNode struct {
+	op string
+	x  node
+}
+
+func (n *unaryNode) eval(env Env) (interface{}, error) {
+	v, err := n.x.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "!" {
+		return !Truthy(v), nil
+	}
+	num, ok := toNumber(v)
+	if !ok {
+		return nil, fmt.Errorf("cannot negate %s", describe(v))
+	}
+	return -num, nil
+}
+
+func (n *unaryNode) walk(fn func(node)) { fn(n); n.x.walk(fn) }
+
+type binaryNode struct {
+	op   string
+	l, r node
+}
+
+func (n *binaryNode) eval(env Env) (interface{}, error) {
+	l, err := n.l.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	// Short-circuit logic returns booleans, like a condition would
+	switch n.op {
+	case "&&":
+		if !Truthy(l) {
+			return false, nil
+		}
+		r, err := n.r.eval(env)
+		return Truthy(r), err
+	case "||":
+		if Truthy(l) {
+			return true, nil
+		}
+		r, err := n.r.eval(env)
+		return Truthy(r), err
+	}
+
+	r, err := n.r.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return equal(l, r), nil
+	case "!=":
+		return !equal(l, r), nil
+	}
+
+	ln, lok := toNumber(l)
+	rn, rok := toNumber(r)
+
+	if n.op == "+" && (!lok || !rok) {
+		ls, lstr := l.(string)
+		rs, rstr := r.(string)
+		if lstr || rstr {
+			if !lstr {
+				ls = formatValue(l)
+			}
+			if !rstr {
+				rs = formatValue(r)
+			}
+			return ls + rs, nil
+		}
+	}
+
+	switch n.op {
+	case "<", "<=", ">", ">=":
+		if ls, ok := l.(string); ok {
+			if rs, ok := r.(string); ok {
+				return compare(n.op, strings.Compare(ls, rs)), nil
+			}
+		}
+	}
+
+	if !lok || !rok {
+		return nil, fmt.Errorf("cannot apply %s to %s and %s", n.op, describe(l), describe(r))
+	}
+
+	switch n.op {
+	case "+":
+		return ln + rn, nil
+	case "-":
+		return ln - rn, nil
+	case "*":
+		return ln * rn, nil
+	case "/":
+		if rn == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return ln / rn, nil
+	case "%":
+		if rn == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return math.Mod(ln, rn), nil
+	case "<", "<=", ">", ">=":
+		c := 0
+		if ln < rn {
+			c = -1
+		} else if ln > rn {
+			c = 1
+		}
+		return compare(n.op, c), nil
+	}
+	return nil, fmt.Errorf("unknown operator %s", n.op)
+}
+
+func (n *binaryNode) walk(fn func(node)) { fn(n); n.l.walk(fn); n.r.walk(fn) }
+
+func compare(op string, c int) bool {
+	switch op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	}
+	return c >= 0
+}
+
+func equal(l, r interface{}) bool {
+	ln, lok := toNumber(l)
+	rn, rok := toNumber(r)
+	if lok && rok {
+		return ln == rn
+	}
+	switch l.(type) {
+	case nil, bool, string:
+		return l == r
+	}
+	return false
+}
+
+type ternaryNode struct{ cond, then, els node }
+
+func (n *ternaryNode) eval(env Env) (interface{}, error) {
+	c, err := n.cond.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if Truthy(c) {
+		return n.then.eval(env)
+	}
+	return n.els.eval(env)
+}
+
+func (n *ternaryNode) walk(fn func(node)) {
+	fn(n)
+	n.cond.walk(fn)
+	n.then.walk(fn)
+	n.els.walk(fn)
+}
+
+type callNode struct {
+	name string
+	args []node
+}
+
+var functions = map[string]struct {
+	min, max int
+	fn       func(args []float64) float64
+}{
+	"abs":   {1, 1, func(a []float64) float64 { return math.Abs(a[0]) }},
+	"floor": {1, 1, func(a []float64) float64 { return math.Floor(a[0]) }},
+	"ceil":  {1, 1, func(a []float64) float64 { return math.Ceil(a[0]) }},
+	"round": {1, 2, func(a []float64) float64 {
+		if len(a) == 1 {
+			return math.Round(a[0])
+		}
+		p := math.Pow(10, a[1])
+		return math.Round(a[0]*p) / p
+	}},
+	"min": {1, -1, func(a []float64) float64 {
+		m := a[0]
+		for _, x := range a[1:] {
+			m = math.Min(m, x)
+		}
+		return m
+	}},
+	"max": {1, -1, func(a []float64) float64 {
+		m := a[0]
+		for _, x := range a[1:] {
+			m = math.Max(m, x)
+		}
+		return m
+	}},
+	"clamp": {3, 3, func(a []float64) float64 { return math.Max(a[1], math.Min(a[2], a[0])) }},
+}
+
+func (n *callNode) eval(env Env) (interface{}, error) {
+	f := functions[n.name]
+	args := make([]float64, len(n.args))
+	for i, arg := range n.args {
+		v, err := arg.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		num, ok := toNumber(v)
+		if !ok {
+			return nil, fmt.Errorf("%s() expects numbers, got %s", n.name, describe(v))
+		}
+		args[i] = num
+	}
+	return f.fn(args), nil
+}
+
+func (n *callNode) walk(fn func(node)) {
+	fn(n)
+	for _, arg := range n.args {
+		arg.walk(fn)
+	}
+}
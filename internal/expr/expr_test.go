@@ -0,0 +1,112 @@
+package expr
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	env := Env{
+		"value":   20.0,
+		"count":   3,
+		"name":    "Hall",
+		"away":    false,
+		"kitchen": map[string]interface{}{"temperature": 17.5},
+	}
+	tests := []struct {
+		src  string
+		want interface{}
+	}{
+		{"value * 2 + 1", 41.0},
+		{"(value + 1) * 2", 42.0},
+		{"-value + count", -17.0},
+		{"10 % 4", 2.0},
+		{"value / 8", 2.5},
+		{"1.5e2", 150.0},
+		{"value > 18 && !away", true},
+		{"value > 18 and not away", true},
+		{"away || count == 3", true},
+		{"kitchen.temperature < 18", true},
+		{"name == 'Hall'", true},
+		{`name + " " + count`, "Hall 3"},
+		{"name < \"Kitchen\"", true},
+		{"value > 30 ? 'hot' : value > 15 ? 'warm' : 'cold'", "warm"},
+		{"min(value, 5, 8)", 5.0},
+		{"max(1, count)", 3.0},
+		{"round(2.345, 2)", 2.35},
+		{"clamp(value, 0, 10)", 10.0},
+		{"abs(-2) + floor(1.7) + ceil(0.2)", 4.0},
+		{"null == null", true},
+		{"count != 3", false},
+	}
+	for _, tt := range tests {
+		got, err := Eval(tt.src, env)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.src, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s = %#v, want %#v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"value *", "unexpected end of expression"},
+		{"(1 + 2", "expected ')'"},
+		{"missing + 1", `unknown name "missing"`},
+		{"kitchen.humidity", `unknown name "kitchen.humidity"`},
+		{"name.first", "name is not an object"},
+		{"1 / 0", "division by zero"},
+		{"name * 2", "cannot apply * to string and number"},
+		{"nope(1)", "unknown function nope()"},
+		{"clamp(1)", "clamp() takes 3 arguments, got 1"},
+		{"'open", "unterminated string"},
+		{"1 # 2", `unexpected '#' at position 3`},
+		{"1 2", "unexpected '2' at position 3"},
+	}
+	env := Env{"value": 1.0, "name": "x", "kitchen": map[string]interface{}{}}
+	for _, tt := range tests {
+		_, err := Eval(tt.src, env)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.src, tt.want, err)
+		}
+	}
+}
+
+func TestShortCircuit(t *testing.T) {
+	// The right-hand side refers to an unknown name and must not be evaluated
+	for _, src := range []string{"false && missing", "true || missing", "true ? 1 : missing"} {
+		if _, err := Eval(src, nil); err != nil {
+			t.Errorf("%s: unexpected error: %v", src, err)
+		}
+	}
+}
+
+func TestNames(t *testing.T) {
+	e, err := Parse("kitchen.temperature < target && !away || kitchen.motion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := e.Names(), []string{"kitchen", "target", "away"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Names() = %v, want %v", got, want)
+	}
+}
+
+func TestTruthy(t *testing.T) {
+	for _, v := range []interface{}{true, 1.0, -1, "x", map[string]interface{}{}} {
+		if !Truthy(v) {
+			t.Errorf("expected %#v to be truthy", v)
+		}
+	}
+	for _, v := range []interface{}{false, 0.0, 0, "", nil} {
+		if Truthy(v) {
+			t.Errorf("expected %#v to be falsy", v)
+		}
+	}
+}
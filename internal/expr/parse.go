@@ -0,0 +1,307 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of expression"
+	case tokString:
+		return strconv.Quote(t.text)
+	}
+	return "'" + t.text + "'"
+}
+
+// Longest operators first so "<=" wins over "<"
+var operators = []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "+", "-", "*", "/", "%", "!", "(", ")", ",", "?", ":"}
+
+func lex(src string) ([]token, error) {
+	var toks []token
+	runes := []rune(src)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			if i < len(runes) && (runes[i] == 'e' || runes[i] == 'E') {
+				i++
+				if i < len(runes) && (runes[i] == '+' || runes[i] == '-') {
+					i++
+				}
+				for i < len(runes) && unicode.IsDigit(runes[i]) {
+					i++
+				}
+			}
+			toks = append(toks, token{tokNumber, string(runes[start:i]), start})
+
+		case r == '_' || unicode.IsLetter(r):
+			start := i
+			for i < len(runes) && (runes[i] == '_' || runes[i] == '.' || unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])) {
+				i++
+			}
+			toks = append(toks, token{tokIdent, string(runes[start:i]), start})
+
+		case r == '"' || r == '\'':
+			start := i
+			var sb strings.Builder
+			i++
+			for ; i < len(runes) && runes[i] != r; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+					switch runes[i] {
+					case 'n':
+						sb.WriteRune('\n')
+					case 't':
+						sb.WriteRune('\t')
+					default:
+						sb.WriteRune(runes[i])
+					}
+					continue
+				}
+				sb.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("unterminated string at position %d", start+1)
+			}
+			i++
+			toks = append(toks, token{tokString, sb.String(), start})
+
+		default:
+			matched := false
+			rest := string(runes[i:])
+			for _, op := range operators {
+				if strings.HasPrefix(rest, op) {
+					toks = append(toks, token{tokOp, op, i})
+					i += len([]rune(op))
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected %q at position %d", r, i+1)
+			}
+		}
+	}
+	return append(toks, token{kind: tokEOF, pos: len(runes)}), nil
+}
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is one of ops. The words "and",
+// "or" and "not" are accepted as aliases.
+func (p *parser) accept(ops ...string) (string, bool) {
+	t := p.peek()
+	text := t.text
+	if t.kind == tokIdent {
+		switch text {
+		case "and":
+			text = "&&"
+		case "or":
+			text = "||"
+		case "not":
+			text = "!"
+		default:
+			return "", false
+		}
+	} else if t.kind != tokOp {
+		return "", false
+	}
+	for _, op := range ops {
+		if text == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) expect(op string) error {
+	if _, ok := p.accept(op); !ok {
+		t := p.peek()
+		return fmt.Errorf("expected '%s' at position %d, got %s", op, t.pos+1, t)
+	}
+	return nil
+}
+
+func (p *parser) parseTernary() (node, error) {
+	cond, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := p.accept("?"); !ok {
+		return cond, nil
+	}
+	then, err := p.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	els, err := p.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+	return &ternaryNode{cond, then, els}, nil
+}
+
+// Binary operators by precedence level, lowest first
+var levels = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!=", "<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *parser) parseBinary(level int) (node, error) {
+	if level == len(levels) {
+		return p.parseUnary()
+	}
+	l, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept(levels[level]...)
+		if !ok {
+			return l, nil
+		}
+		r, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryNode{op, l, r}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if op, ok := p.accept("!", "-"); ok {
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op, x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	if _, ok := p.accept("("); ok {
+		x, err := p.parseTernary()
+		if err != nil {
+			return nil, err
+		}
+		return x, p.expect(")")
+	}
+
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s at position %d", t.text, t.pos+1)
+		}
+		return &literalNode{n}, nil
+	case tokString:
+		return &literalNode{t.text}, nil
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return &literalNode{true}, nil
+		case "false":
+			return &literalNode{false}, nil
+		case "null":
+			return &literalNode{nil}, nil
+		}
+		if _, ok := p.accept("("); ok {
+			return p.parseCall(t)
+		}
+		if strings.HasSuffix(t.text, ".") || strings.Contains(t.text, "..") {
+			return nil, fmt.Errorf("invalid name %q at position %d", t.text, t.pos+1)
+		}
+		return &identNode{t.text}, nil
+	}
+	return nil, fmt.Errorf("unexpected %s at position %d", t, t.pos+1)
+}
+
+func (p *parser) parseCall(name token) (node, error) {
+	f, ok := functions[name.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %s() at position %d", name.text, name.pos+1)
+	}
+	var args []node
+	if _, ok := p.accept(")"); !ok {
+		for {
+			arg, err := p.parseTernary()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if _, ok := p.accept(","); !ok {
+				break
+			}
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+	}
+	if len(args) < f.min || (f.max >= 0 && len(args) > f.max) {
+		return nil, fmt.Errorf("%s() takes %s, got %d", name.text, arity(f.min, f.max), len(args))
+	}
+	return &callNode{name.text, args}, nil
+}
+
+func arity(min, max int) string {
+	switch {
+	case min == max && min == 1:
+		return "1 argument"
+	case min == max:
+		return fmt.Sprintf("%d arguments", min)
+	case max < 0:
+		return fmt.Sprintf("at least %d argument(s)", min)
+	}
+	return fmt.Sprintf("%d to %d arguments", min, max)
+}
@@ -0,0 +1,303 @@
+// Package fakehomey is an in-memory simulator of the Homey Web API manager
+// endpoints homeyctl uses. It serves devices with capability state, zones,
+// flows, variables, moods, insights, energy, notifications, users and
+// personal access tokens, so commands can run against a realistic house
+// without touching a real one.
+package fakehomey
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Objects is a set of API objects keyed by ID. In seed files it can be
+// written either as an object keyed by ID or as a list of objects with an
+// "id" field.
+type Objects map[string]map[string]interface{}
+
+func (o *Objects) UnmarshalJSON(data []byte) error {
+	var byID map[string]map[string]interface{}
+	if err := json.Unmarshal(data, &byID); err == nil {
+		for id, obj := range byID {
+			if _, ok := obj["id"]; !ok {
+				obj["id"] = id
+			}
+		}
+		*o = byID
+		return nil
+	}
+
+	var list []map[string]interface{}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("expected an object keyed by ID or a list of objects")
+	}
+	*o = make(Objects, len(list))
+	for i, obj := range list {
+		id, _ := obj["id"].(string)
+		if id == "" {
+			return fmt.Errorf("item %d has no id", i)
+		}
+		(*o)[id] = obj
+	}
+	return nil
+}
+
+// FlowCards holds the flow cards served by the card listing endpoints
+type FlowCards struct {
+	Trigger   []map[string]interface{} `json:"trigger"`
+	Condition []map[string]interface{} `json:"condition"`
+	Action    []map[string]interface{} `json:"action"`
+}
+
+// Seed is the initial state of a fake Homey, usually read from a JSON file
+type Seed struct {
+	Devices       Objects   `json:"devices"`
+	Groups        Objects   `json:"groups"`
+	Zones         Objects   `json:"zones"`
+	Flows         Objects   `json:"flows"`
+	AdvancedFlows Objects   `json:"advancedFlows"`
+	FlowFolders   Objects   `json:"flowFolders"`
+	FlowCards     FlowCards `json:"flowCards"`
+	Variables     Objects   `json:"variables"`
+	Moods         Objects   `json:"moods"`
+	Insights      Objects   `json:"insights"`
+	Notifications Objects   `json:"notifications"`
+	Users         Objects   `json:"users"`
+	PATs          Objects   `json:"pats"`
+	Apps          Objects   `json:"apps"`
+	Dashboards    Objects   `json:"dashboards"`
+	Scripts       Objects   `json:"scripts"`
+	Images        Objects   `json:"images"`
+
+	System map[string]interface{} `json:"system"`
+
+	// Values are served as-is at their path below /api/manager/, for
+	// read-mostly endpoints like "energy/live", "weather/weather" or
+	// "insights/log/<uri>/<id>/entry". A PUT to such a path replaces it.
+	Values map[string]json.RawMessage `json:"values"`
+}
+
+// LoadSeed reads a seed file
+func LoadSeed(path string) (*Seed, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read seed: %w", err)
+	}
+	var seed Seed
+	if err := json.Unmarshal(data, &seed); err != nil {
+		return nil, fmt.Errorf("invalid seed %s: %w", path, err)
+	}
+	return &seed, nil
+}
+
+// Call is a request that changed state or ran something, kept so tests can
+// assert on what a command did
+type Call struct {
+	Method string      `json:"method"`
+	Path   string      `json:"path"`
+	Body   interface{} `json:"body,omitempty"`
+}
+
+// Homey is the simulator state. It implements http.Handler.
+type Homey struct {
+	// Now returns the current time, used for capability timestamps and
+	// notification dates
+	Now func() time.Time
+
+	mu          sync.Mutex
+	token       string
+	collections map[string]Objects
+	cards       map[string][]map[string]interface{}
+	system      map[string]interface{}
+	values      map[string]json.RawMessage
+	nextID      int
+	calls       []Call
+}
+
+// Collection paths below /api/manager/, plus the HomeyScript app
+const (
+	Devices       = "devices/device"
+	Groups        = "devices/group"
+	Zones         = "zones/zone"
+	Flows         = "flow/flow"
+	AdvancedFlows = "flow/advancedflow"
+	FlowFolders   = "flow/flowfolder"
+	Variables     = "logic/variable"
+	Moods         = "moods/mood"
+	Insights      = "insights/log"
+	Notifications = "notifications/notification"
+	Users         = "users/user"
+	PATs          = "users/pat"
+	Apps          = "apps/app"
+	Dashboards    = "dashboards/dashboard"
+	Scripts       = "homeyscript/script"
+	Images        = "images/image"
+)
+
+// New creates a fake Homey from a seed, which may be nil for an empty
+// house. The seed is copied, so the caller may reuse it.
+func New(seed *Seed) *Homey {
+	if seed == nil {
+		seed = &Seed{}
+	}
+	seed = copyValue(seed).(*Seed)
+
+	h := &Homey{
+		Now: time.Now,
+		collections: map[string]Objects{
+			Devices:       seed.Devices,
+			Groups:        seed.Groups,
+			Zones:         seed.Zones,
+			Flows:         seed.Flows,
+			AdvancedFlows: seed.AdvancedFlows,
+			FlowFolders:   seed.FlowFolders,
+			Variables:     seed.Variables,
+			Moods:         seed.Moods,
+			Insights:      seed.Insights,
+			Notifications: seed.Notifications,
+			Users:         seed.Users,
+			PATs:          seed.PATs,
+			Apps:          seed.Apps,
+			Dashboards:    seed.Dashboards,
+			Scripts:       seed.Scripts,
+			Images:        seed.Images,
+		},
+		cards: map[string][]map[string]interface{}{
+			"trigger":   seed.FlowCards.Trigger,
+			"condition": seed.FlowCards.Condition,
+			"action":    seed.FlowCards.Action,
+		},
+		system: seed.System,
+		values: seed.Values,
+	}
+	for name, objs := range h.collections {
+		if objs == nil {
+			h.collections[name] = Objects{}
+		}
+	}
+	if h.system == nil {
+		h.system = map[string]interface{}{"name": "Fake Homey", "homeyVersion": "12.0.0"}
+	}
+	if h.values == nil {
+		h.values = map[string]json.RawMessage{}
+	}
+	return h
+}
+
+// SetToken requires requests to carry "Authorization: Bearer <token>".
+// An empty token accepts any request.
+func (h *Homey) SetToken(token string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.token = token
+}
+
+// Object returns a copy of an object in a collection, or nil
+func (h *Homey) Object(collection, id string) map[string]interface{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	obj, ok := h.collections[collection][id]
+	if !ok {
+		return nil
+	}
+	return copyValue(obj).(map[string]interface{})
+}
+
+// IDs lists the IDs in a collection, sorted
+func (h *Homey) IDs(collection string) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ids := make([]string, 0, len(h.collections[collection]))
+	for id := range h.collections[collection] {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// CapabilityValue returns the current value of a device capability
+func (h *Homey) CapabilityValue(deviceID, capability string) (interface{}, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	capObj, ok := capabilityObj(h.collections[Devices][deviceID], capability)
+	if !ok {
+		return nil, false
+	}
+	return capObj["value"], true
+}
+
+// Calls returns the state-changing requests served so far
+func (h *Homey) Calls() []Call {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]Call(nil), h.calls...)
+}
+
+// Snapshot returns the current state as a seed, e.g. to save it
+func (h *Homey) Snapshot() *Seed {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	seed := &Seed{
+		Devices:       h.collections[Devices],
+		Groups:        h.collections[Groups],
+		Zones:         h.collections[Zones],
+		Flows:         h.collections[Flows],
+		AdvancedFlows: h.collections[AdvancedFlows],
+		FlowFolders:   h.collections[FlowFolders],
+		FlowCards: FlowCards{
+			Trigger:   h.cards["trigger"],
+			Condition: h.cards["condition"],
+			Action:    h.cards["action"],
+		},
+		Variables:     h.collections[Variables],
+		Moods:         h.collections[Moods],
+		Insights:      h.collections[Insights],
+		Notifications: h.collections[Notifications],
+		Users:         h.collections[Users],
+		PATs:          h.collections[PATs],
+		Apps:          h.collections[Apps],
+		Dashboards:    h.collections[Dashboards],
+		Scripts:       h.collections[Scripts],
+		Images:        h.collections[Images],
+		System:        h.system,
+		Values:        h.values,
+	}
+	return copyValue(seed).(*Seed)
+}
+
+// newID returns a deterministic UUID-shaped ID
+func (h *Homey) newID() string {
+	h.nextID++
+	return fmt.Sprintf("00000000-0000-4000-8000-%012d", h.nextID)
+}
+
+func capabilityObj(device map[string]interface{}, capability string) (map[string]interface{}, bool) {
+	caps, _ := device["capabilitiesObj"].(map[string]interface{})
+	capObj, ok := caps[capability].(map[string]interface{})
+	return capObj, ok
+}
+
+// copyValue deep-copies a value through JSON
+func copyValue(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	switch v.(type) {
+	case *Seed:
+		var out Seed
+		json.Unmarshal(data, &out)
+		return &out
+	case map[string]interface{}:
+		var out map[string]interface{}
+		json.Unmarshal(data, &out)
+		return out
+	}
+	var out interface{}
+	json.Unmarshal(data, &out)
+	return out
+}
@@ -0,0 +1,167 @@
+package fakehomey
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fishfisher/homeyctl/internal/client"
+	"github.com/fishfisher/homeyctl/internal/config"
+)
+
+const testSeed = `{
+  "devices": [
+    {"id": "lamp", "name": "Kitchen lamp", "class": "light", "zone": "kitchen",
+     "capabilitiesObj": {"onoff": {"id": "onoff", "value": false}, "dim": {"id": "dim", "value": 1}}},
+    {"id": "sensor", "name": "Hall sensor", "class": "sensor", "zone": "hall",
+     "capabilitiesObj": {"measure_temperature": {"id": "measure_temperature", "value": 21.5, "setable": false}}}
+  ],
+  "zones": {"kitchen": {"name": "Kitchen"}, "hall": {"name": "Hall"}},
+  "users": [{"id": "anna", "name": "Anna", "role": "owner", "present": true}],
+  "moods": [{"id": "movie", "name": "Movie", "devices": {"lamp": {"onoff": true, "dim": 0.2}}}],
+  "flowCards": {"action": [{"id": "homey:device:lamp:on", "uri": "homey:device:lamp"}]},
+  "values": {"energy/live": {"W": 420}}
+}`
+
+// newTestServer starts a fake Homey for the test. Tests outside this
+// package use fakehomeytest.NewServer.
+func newTestServer(t *testing.T, seed *Seed) (*Homey, *httptest.Server) {
+	t.Helper()
+	h := New(seed)
+	server := httptest.NewServer(h)
+	t.Cleanup(server.Close)
+	return h, server
+}
+
+func newTestClient(t *testing.T) (*Homey, *client.Client) {
+	t.Helper()
+	var seed Seed
+	if err := json.Unmarshal([]byte(testSeed), &seed); err != nil {
+		t.Fatalf("invalid seed: %v", err)
+	}
+	h, server := newTestServer(t, &seed)
+	h.SetToken("secret")
+	h.Now = func() time.Time { return time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC) }
+
+	cfg := &config.Config{Mode: "local", Local: config.LocalConfig{Address: server.URL, Token: "secret"}}
+	return h, client.New(cfg)
+}
+
+func TestDevicesAndCapabilities(t *testing.T) {
+	h, c := newTestClient(t)
+
+	data, err := c.GetDevices()
+	if err != nil {
+		t.Fatalf("GetDevices failed: %v", err)
+	}
+	var devices map[string]map[string]interface{}
+	json.Unmarshal(data, &devices)
+	if len(devices) != 2 || devices["lamp"]["name"] != "Kitchen lamp" {
+		t.Errorf("unexpected devices: %v", devices)
+	}
+
+	if err := c.SetCapability("lamp", "onoff", true); err != nil {
+		t.Fatalf("SetCapability failed: %v", err)
+	}
+	if v, _ := h.CapabilityValue("lamp", "onoff"); v != true {
+		t.Errorf("expected onoff true, got %v", v)
+	}
+	caps := h.Object(Devices, "lamp")["capabilitiesObj"].(map[string]interface{})
+	if caps["onoff"].(map[string]interface{})["lastUpdated"] != "2026-01-01T12:00:00Z" {
+		t.Errorf("expected lastUpdated to be set, got %v", caps["onoff"])
+	}
+
+	if err := c.SetCapability("sensor", "measure_temperature", 30); err == nil || !strings.Contains(err.Error(), "not setable") {
+		t.Errorf("expected read-only capability to be rejected, got %v", err)
+	}
+	if err := c.SetCapability("nope", "onoff", true); err == nil || !strings.Contains(err.Error(), "status 404") {
+		t.Errorf("expected 404 for unknown device, got %v", err)
+	}
+
+	calls := h.Calls()
+	if len(calls) != 1 || calls[0].Path != "/api/manager/devices/device/lamp/capability/onoff" {
+		t.Errorf("expected one recorded call, got %+v", calls)
+	}
+}
+
+func TestCollections(t *testing.T) {
+	h, c := newTestClient(t)
+
+	result, err := c.CreateZone(map[string]interface{}{"name": "Garage", "parent": "hall"})
+	if err != nil {
+		t.Fatalf("CreateZone failed: %v", err)
+	}
+	var zone struct{ ID string }
+	json.Unmarshal(result, &zone)
+	if zone.ID == "" || h.Object(Zones, zone.ID)["name"] != "Garage" {
+		t.Errorf("expected created zone, got %s", result)
+	}
+
+	if err := c.UpdateZone("kitchen", map[string]interface{}{"icon": "kitchen"}); err != nil {
+		t.Fatalf("UpdateZone failed: %v", err)
+	}
+	if k := h.Object(Zones, "kitchen"); k["icon"] != "kitchen" || k["name"] != "Kitchen" {
+		t.Errorf("expected merged update, got %v", k)
+	}
+
+	if err := c.DeleteZone("hall"); err != nil {
+		t.Fatalf("DeleteZone failed: %v", err)
+	}
+	if h.Object(Zones, "hall") != nil {
+		t.Error("expected hall to be deleted")
+	}
+}
+
+func TestMoodsPresenceAndValues(t *testing.T) {
+	h, c := newTestClient(t)
+
+	if err := c.SetMood("movie"); err != nil {
+		t.Fatalf("SetMood failed: %v", err)
+	}
+	if v, _ := h.CapabilityValue("lamp", "dim"); v != 0.2 {
+		t.Errorf("expected mood to dim lamp, got %v", v)
+	}
+
+	if err := c.SetPresentMe(false); err != nil {
+		t.Fatalf("SetPresentMe failed: %v", err)
+	}
+	data, _ := c.GetPresent("anna")
+	if string(data) != `{"value":false}` {
+		t.Errorf("unexpected presence: %s", data)
+	}
+
+	data, err := c.GetEnergyLive()
+	if err != nil || string(data) != `{"W":420}` {
+		t.Errorf("unexpected energy live: %s (%v)", data, err)
+	}
+
+	if _, err := c.RunFlowCardAction("homey:manager:notifications", "homey:manager:notifications:create_notification", map[string]interface{}{"text": "hi"}); err != nil {
+		t.Fatalf("notification card failed: %v", err)
+	}
+	if ids := h.IDs(Notifications); len(ids) != 1 || h.Object(Notifications, ids[0])["excerpt"] != "hi" {
+		t.Errorf("expected notification, got %v", ids)
+	}
+}
+
+func TestToken(t *testing.T) {
+	_, server := newTestServer(t, nil)
+	h := server.Config.Handler.(*Homey)
+	h.SetToken("secret")
+
+	c := client.New(&config.Config{Mode: "local", Local: config.LocalConfig{Address: server.URL, Token: "wrong"}})
+	if _, err := c.GetDevices(); err == nil || !strings.Contains(err.Error(), "status 401") {
+		t.Errorf("expected 401 for wrong token, got %v", err)
+	}
+}
+
+func TestObjectsUnmarshal(t *testing.T) {
+	var o Objects
+	if err := json.Unmarshal([]byte(`[{"name":"x"}]`), &o); err == nil {
+		t.Error("expected error for list item without id")
+	}
+	if err := json.Unmarshal([]byte(`{"a":{"name":"x"}}`), &o); err != nil || o["a"]["id"] != "a" {
+		t.Errorf("expected id filled from key, got %v (%v)", o, err)
+	}
+}
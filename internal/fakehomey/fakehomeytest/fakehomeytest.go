@@ -0,0 +1,20 @@
+// Package fakehomeytest starts fake Homeys for tests. It is separate from
+// fakehomey so the binary does not link the testing package.
+package fakehomeytest
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fishfisher/homeyctl/internal/fakehomey"
+)
+
+// NewServer starts a fake Homey seeded with seed and closes it when the
+// test ends. Point a client at the returned server's URL.
+func NewServer(tb testing.TB, seed *fakehomey.Seed) (*fakehomey.Homey, *httptest.Server) {
+	tb.Helper()
+	h := fakehomey.New(seed)
+	server := httptest.NewServer(h)
+	tb.Cleanup(server.Close)
+	return h, server
+}
@@ -0,0 +1,572 @@
+package fakehomey
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fishfisher/homeyctl/internal/client"
+)
+
+const (
+	managerPrefix     = "/api/manager/"
+	homeyScriptPrefix = "/api/app/com.athom.homeyscript/"
+)
+
+// apiError is written as {"error": message} with its status
+type apiError struct {
+	status  int
+	message string
+}
+
+func notFound(format string, args ...interface{}) *apiError {
+	return &apiError{status: http.StatusNotFound, message: fmt.Sprintf(format, args...)}
+}
+
+func badRequest(format string, args ...interface{}) *apiError {
+	return &apiError{status: http.StatusBadRequest, message: fmt.Sprintf(format, args...)}
+}
+
+// request is a decoded API request
+type request struct {
+	method string
+	path   string   // below /api/manager/, without query
+	segs   []string // unescaped path segments
+	query  url.Values
+	body   map[string]interface{}
+}
+
+func (h *Homey) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.token != "" && r.Header.Get("Authorization") != "Bearer "+h.token {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "Invalid token"})
+		return
+	}
+
+	var path string
+	switch {
+	case strings.HasPrefix(r.URL.EscapedPath(), managerPrefix):
+		path = strings.TrimPrefix(r.URL.EscapedPath(), managerPrefix)
+	case strings.HasPrefix(r.URL.EscapedPath(), homeyScriptPrefix):
+		path = "homeyscript/" + strings.TrimPrefix(r.URL.EscapedPath(), homeyScriptPrefix)
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Not found"})
+		return
+	}
+
+	req := &request{method: r.Method, path: strings.Trim(path, "/"), query: r.URL.Query()}
+	for _, seg := range strings.Split(req.path, "/") {
+		if unescaped, err := url.PathUnescape(seg); err == nil {
+			seg = unescaped
+		}
+		req.segs = append(req.segs, seg)
+	}
+	if r.Body != nil {
+		data, _ := io.ReadAll(r.Body)
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, &req.body); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid JSON body"})
+				return
+			}
+		}
+	}
+
+	result, apiErr := h.route(req)
+	if apiErr != nil {
+		writeJSON(w, apiErr.status, map[string]string{"error": apiErr.message})
+		return
+	}
+	if r.Method != http.MethodGet {
+		h.calls = append(h.calls, Call{Method: r.Method, Path: r.URL.RequestURI(), Body: req.body})
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		status, data = http.StatusInternalServerError, []byte(`{"error":"failed to encode response"}`)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+func (h *Homey) route(r *request) (interface{}, *apiError) {
+	s := r.segs
+	at := func(i int) string {
+		if i < len(s) {
+			return s[i]
+		}
+		return ""
+	}
+	collection := at(0) + "/" + at(1)
+
+	switch {
+	case collection == Devices && len(s) == 5 && s[3] == "capability" && r.method == http.MethodPut:
+		return h.setCapability(s[2], s[4], r.body)
+	case collection == Devices && len(s) == 4 && s[3] == "settings_obj" && r.method == http.MethodGet:
+		device, err := h.object(Devices, s[2])
+		if err != nil {
+			return nil, err
+		}
+		if settings, ok := device["settings"]; ok {
+			return settings, nil
+		}
+		return map[string]interface{}{}, nil
+	case collection == Devices && len(s) == 4 && s[3] == "settings" && r.method == http.MethodPut:
+		device, err := h.object(Devices, s[2])
+		if err != nil {
+			return nil, err
+		}
+		settings, _ := device["settings"].(map[string]interface{})
+		if settings == nil {
+			settings = map[string]interface{}{}
+		}
+		for k, v := range r.body {
+			settings[k] = v
+		}
+		device["settings"] = settings
+		return settings, nil
+	case collection == Groups && len(s) == 5 && s[3] == "device" && r.method == http.MethodDelete:
+		group, err := h.object(Groups, s[2])
+		if err != nil {
+			return nil, err
+		}
+		devices, _ := group["devices"].([]interface{})
+		kept := []interface{}{}
+		for _, d := range devices {
+			if d != s[4] {
+				kept = append(kept, d)
+			}
+		}
+		group["devices"] = kept
+		return group, nil
+
+	case (collection == Flows || collection == AdvancedFlows) && len(s) == 4 && s[3] == "trigger" && r.method == http.MethodPost:
+		if _, err := h.object(collection, s[2]); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{}, nil
+	case at(0) == "flow" && strings.HasPrefix(at(1), "flowcard") && len(s) == 2 && r.method == http.MethodGet:
+		return h.flowCards(strings.TrimPrefix(at(1), "flowcard"))
+	case at(0) == "flow" && at(1) == "flowcardaction" && len(s) == 5 && s[4] == "run" && r.method == http.MethodPost:
+		return h.runAction(s[2], s[3], r.body)
+	case at(0) == "flow" && at(1) == "flowcardcondition" && len(s) == 5 && s[4] == "run" && r.method == http.MethodPost:
+		return h.runCondition(s[3])
+	case at(0) == "flow" && strings.HasPrefix(at(1), "flowcard") && len(s) == 5 && s[4] == "autocomplete" && r.method == http.MethodGet:
+		return h.autocomplete(strings.TrimPrefix(at(1), "flowcard"), s[3], r.query.Get("name"), r.query.Get("query"))
+
+	case collection == Moods && len(s) == 4 && s[3] == "set" && r.method == http.MethodPost:
+		return h.setMood(s[2])
+
+	case at(0) == "presence" && len(s) == 3 && (s[2] == "present" || s[2] == "asleep"):
+		return h.presence(s[1], s[2], r)
+
+	case collection == Users && at(2) == "me" && len(s) == 3 && r.method == http.MethodGet:
+		return h.me()
+	case collection == PATs && len(s) == 2 && r.method == http.MethodGet:
+		return h.list(PATs), nil
+	case collection == PATs && len(s) == 2 && r.method == http.MethodPost:
+		pat, err := h.create(PATs, r.body)
+		if err != nil {
+			return nil, err
+		}
+		pat["createdAt"] = h.Now().UTC().Format(time.RFC3339)
+		result := copyValue(pat).(map[string]interface{})
+		result["token"] = "fake-pat-" + pat["id"].(string)
+		return result, nil
+
+	case collection == Apps && len(s) == 4:
+		return h.appAction(s[2], s[3], r)
+	case collection == Apps && len(s) == 5 && s[3] == "setting" && r.method == http.MethodPut:
+		app, err := h.object(Apps, s[2])
+		if err != nil {
+			return nil, err
+		}
+		settings, _ := app["settings"].(map[string]interface{})
+		if settings == nil {
+			settings = map[string]interface{}{}
+		}
+		settings[s[4]] = r.body["value"]
+		app["settings"] = settings
+		return map[string]interface{}{}, nil
+
+	case collection == Scripts && len(s) == 4 && s[3] == "run" && r.method == http.MethodPost:
+		script, err := h.object(Scripts, s[2])
+		if err != nil {
+			return nil, err
+		}
+		if result, ok := script["result"]; ok {
+			return result, nil
+		}
+		return map[string]interface{}{"success": true, "returnValue": nil}, nil
+
+	case collection == Scripts && len(s) == 2 && r.method == http.MethodPost:
+		script, err := h.create(Scripts, r.body)
+		if err != nil {
+			return nil, err
+		}
+		script["version"] = 1.0
+		return script, nil
+	case collection == Scripts && len(s) == 3 && r.method == http.MethodPut:
+		return h.updateScript(s[2], r.body)
+
+	case collection == Notifications && len(s) == 2 && r.method == http.MethodDelete:
+		h.collections[Notifications] = Objects{}
+		return map[string]interface{}{}, nil
+
+	case at(0) == "system" && len(s) == 1 && r.method == http.MethodGet:
+		return h.system, nil
+	case at(0) == "system" && at(1) == "name" && len(s) == 2:
+		if r.method == http.MethodPut {
+			h.system["name"] = r.body["name"]
+		}
+		return h.system["name"], nil
+	case at(0) == "system" && at(1) == "reboot" && r.method == http.MethodPost:
+		return map[string]interface{}{}, nil
+
+	case at(0) == "energy" && at(1) == "price" && at(2) == "electricity" && len(s) == 4 && s[3] != "type" && s[3] != "dynamic" && r.method == http.MethodPut:
+		h.values["energy/price/electricity/type"], _ = json.Marshal(s[3])
+		return map[string]interface{}{}, nil
+	}
+
+	if _, ok := h.collections[collection]; ok {
+		switch len(s) {
+		case 2:
+			switch r.method {
+			case http.MethodGet:
+				return h.list(collection), nil
+			case http.MethodPost:
+				return h.create(collection, r.body)
+			}
+		case 3:
+			switch r.method {
+			case http.MethodGet:
+				return h.object(collection, s[2])
+			case http.MethodPut:
+				obj, err := h.object(collection, s[2])
+				if err != nil {
+					return nil, err
+				}
+				obj = client.MergeUpdate(obj, r.body)
+				h.collections[collection][s[2]] = obj
+				return obj, nil
+			case http.MethodDelete:
+				if _, err := h.object(collection, s[2]); err != nil {
+					return nil, err
+				}
+				delete(h.collections[collection], s[2])
+				return map[string]interface{}{}, nil
+			}
+		}
+	}
+
+	return h.value(r)
+}
+
+// value serves paths without their own behaviour from the seed's Values
+func (h *Homey) value(r *request) (interface{}, *apiError) {
+	keys := []string{r.path}
+	if len(r.query) > 0 {
+		keys = []string{r.path + "?" + r.query.Encode(), r.path}
+	}
+	switch r.method {
+	case http.MethodGet:
+		for _, key := range keys {
+			if v, ok := h.values[key]; ok {
+				return v, nil
+			}
+		}
+	case http.MethodPut:
+		data, _ := json.Marshal(r.body)
+		h.values[keys[0]] = data
+		return r.body, nil
+	case http.MethodDelete:
+		for _, key := range keys {
+			if _, ok := h.values[key]; ok {
+				delete(h.values, key)
+				return map[string]interface{}{}, nil
+			}
+		}
+	}
+	return nil, notFound("Not found: %s %s", r.method, r.path)
+}
+
+func (h *Homey) object(collection, id string) (map[string]interface{}, *apiError) {
+	obj, ok := h.collections[collection][id]
+	if !ok {
+		return nil, notFound("Not found: %s %s", collection, id)
+	}
+	return obj, nil
+}
+
+// list returns a collection keyed by ID, or as a list for PATs
+func (h *Homey) list(collection string) interface{} {
+	if collection == PATs {
+		ids := make([]string, 0, len(h.collections[PATs]))
+		for id := range h.collections[PATs] {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		list := make([]map[string]interface{}, 0, len(ids))
+		for _, id := range ids {
+			list = append(list, h.collections[PATs][id])
+		}
+		return list
+	}
+	return h.collections[collection]
+}
+
+func (h *Homey) create(collection string, body map[string]interface{}) (map[string]interface{}, *apiError) {
+	obj := copyValue(body).(map[string]interface{})
+	if obj == nil {
+		obj = map[string]interface{}{}
+	}
+	id, _ := obj["id"].(string)
+	if id == "" {
+		id = h.newID()
+	}
+	if _, exists := h.collections[collection][id]; exists {
+		return nil, &apiError{status: http.StatusConflict, message: "Already exists: " + id}
+	}
+	obj["id"] = id
+	h.collections[collection][id] = obj
+	return obj, nil
+}
+
+// updateScript rejects updates based on an outdated version, like
+// HomeyScript does, and bumps the version on success
+func (h *Homey) updateScript(id string, body map[string]interface{}) (interface{}, *apiError) {
+	script, err := h.object(Scripts, id)
+	if err != nil {
+		return nil, err
+	}
+	current, _ := script["version"].(float64)
+	if v, ok := body["version"].(float64); ok && v != current {
+		return nil, &apiError{status: http.StatusConflict, message: fmt.Sprintf("Script has been modified (version %g, got %g)", current, v)}
+	}
+	for k, v := range body {
+		script[k] = v
+	}
+	script["version"] = current + 1
+	return script, nil
+}
+
+func (h *Homey) setCapability(deviceID, capability string, body map[string]interface{}) (interface{}, *apiError) {
+	device, err := h.object(Devices, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	capObj, ok := capabilityObj(device, capability)
+	if !ok {
+		return nil, notFound("Capability not found: %s", capability)
+	}
+	value, ok := body["value"]
+	if !ok {
+		return nil, badRequest("Missing value")
+	}
+	if setable, ok := capObj["setable"].(bool); ok && !setable {
+		return nil, badRequest("Capability %s is not setable", capability)
+	}
+	capObj["value"] = value
+	capObj["lastUpdated"] = h.Now().UTC().Format(time.RFC3339Nano)
+	return map[string]interface{}{}, nil
+}
+
+func (h *Homey) flowCards(kind string) (interface{}, *apiError) {
+	cards, ok := h.cards[kind]
+	if !ok {
+		return nil, notFound("Unknown card type: %s", kind)
+	}
+	if cards == nil {
+		return []map[string]interface{}{}, nil
+	}
+	return cards, nil
+}
+
+// autocomplete serves the "values" listed on a card argument in the seed,
+// filtered by name on the query
+func (h *Homey) autocomplete(kind, id, name, query string) (interface{}, *apiError) {
+	for _, card := range h.cards[kind] {
+		if card["id"] != id {
+			continue
+		}
+		args, _ := card["args"].([]interface{})
+		for _, a := range args {
+			arg, _ := a.(map[string]interface{})
+			if arg["name"] != name {
+				continue
+			}
+			values, _ := arg["values"].([]interface{})
+			items := []interface{}{}
+			for _, v := range values {
+				item, _ := v.(map[string]interface{})
+				label, _ := item["name"].(string)
+				if strings.Contains(strings.ToLower(label), strings.ToLower(query)) {
+					items = append(items, v)
+				}
+			}
+			return items, nil
+		}
+		return nil, notFound("Flow card argument not found: %s", name)
+	}
+	return nil, notFound("Flow card not found: %s", id)
+}
+
+// runCondition evaluates a condition card to the "result" in its seed,
+// true when not set
+func (h *Homey) runCondition(id string) (interface{}, *apiError) {
+	for _, card := range h.cards["condition"] {
+		if card["id"] == id {
+			result, ok := card["result"].(bool)
+			return map[string]interface{}{"result": result || !ok}, nil
+		}
+	}
+	return nil, notFound("Flow card not found: %s", id)
+}
+
+// runAction runs a flow action card. Notification cards create a
+// notification; other cards only succeed if they exist.
+func (h *Homey) runAction(uri, id string, body map[string]interface{}) (interface{}, *apiError) {
+	if uri == "homey:manager:notifications" && id == "homey:manager:notifications:create_notification" {
+		args, _ := body["args"].(map[string]interface{})
+		text, _ := args["text"].(string)
+		if text == "" {
+			return nil, badRequest("Missing argument: text")
+		}
+		nid := h.newID()
+		h.collections[Notifications][nid] = map[string]interface{}{
+			"id":          nid,
+			"excerpt":     text,
+			"ownerUri":    uri,
+			"dateCreated": h.Now().UTC().Format(time.RFC3339),
+		}
+		return map[string]interface{}{}, nil
+	}
+
+	for _, card := range h.cards["action"] {
+		cardID, _ := card["id"].(string)
+		if cardID == id || cardID == uri+":"+id {
+			return map[string]interface{}{}, nil
+		}
+	}
+	return nil, notFound("Flow card not found: %s", id)
+}
+
+// setMood applies each device state in the mood and marks it active
+func (h *Homey) setMood(id string) (interface{}, *apiError) {
+	mood, err := h.object(Moods, id)
+	if err != nil {
+		return nil, err
+	}
+	devices, _ := mood["devices"].(map[string]interface{})
+	for deviceID, state := range devices {
+		values, _ := state.(map[string]interface{})
+		if nested, ok := values["state"].(map[string]interface{}); ok {
+			values = nested
+		}
+		for capability, value := range values {
+			if device, ok := h.collections[Devices][deviceID]; ok {
+				if capObj, ok := capabilityObj(device, capability); ok {
+					capObj["value"] = value
+					capObj["lastUpdated"] = h.Now().UTC().Format(time.RFC3339Nano)
+				}
+			}
+		}
+	}
+	for _, other := range h.collections[Moods] {
+		other["active"] = false
+	}
+	mood["active"] = true
+	return map[string]interface{}{}, nil
+}
+
+func (h *Homey) me() (interface{}, *apiError) {
+	ids := make([]string, 0, len(h.collections[Users]))
+	for id := range h.collections[Users] {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if h.collections[Users][id]["role"] == "owner" {
+			return h.collections[Users][id], nil
+		}
+	}
+	if len(ids) > 0 {
+		return h.collections[Users][ids[0]], nil
+	}
+	return nil, notFound("No users")
+}
+
+func (h *Homey) presence(userID, field string, r *request) (interface{}, *apiError) {
+	var user map[string]interface{}
+	if userID == "me" {
+		me, err := h.me()
+		if err != nil {
+			return nil, err
+		}
+		user = me.(map[string]interface{})
+	} else {
+		var err *apiError
+		if user, err = h.object(Users, userID); err != nil {
+			return nil, err
+		}
+	}
+	switch r.method {
+	case http.MethodGet:
+		value, _ := user[field].(bool)
+		return map[string]interface{}{"value": value}, nil
+	case http.MethodPut:
+		value, ok := r.body["value"].(bool)
+		if !ok {
+			return nil, badRequest("value must be a boolean")
+		}
+		user[field] = value
+		return map[string]interface{}{}, nil
+	}
+	return nil, notFound("Not found: %s presence/%s/%s", r.method, userID, field)
+}
+
+func (h *Homey) appAction(id, action string, r *request) (interface{}, *apiError) {
+	app, err := h.object(Apps, id)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case action == "restart" && r.method == http.MethodPost:
+		app["ready"] = true
+		app["restarts"] = toFloat(app["restarts"]) + 1
+		return map[string]interface{}{}, nil
+	case action == "enable" && r.method == http.MethodPut:
+		app["enabled"] = true
+		return map[string]interface{}{}, nil
+	case action == "disable" && r.method == http.MethodPut:
+		app["enabled"] = false
+		app["ready"] = false
+		return map[string]interface{}{}, nil
+	case action == "setting" && r.method == http.MethodGet:
+		if settings, ok := app["settings"]; ok {
+			return settings, nil
+		}
+		return map[string]interface{}{}, nil
+	case action == "usage" && r.method == http.MethodGet:
+		if usage, ok := app["usage"]; ok {
+			return usage, nil
+		}
+		return map[string]interface{}{}, nil
+	}
+	return nil, notFound("Not found: %s apps/app/%s/%s", r.method, id, action)
+}
+
+func toFloat(v interface{}) float64 {
+	f, _ := v.(float64)
+	return f
+}
@@ -0,0 +1,536 @@
+// Package flowdsl parses and prints a compact text language for Homey flows.
+//
+// A flow is one or more rules:
+//
+//	flow "Welcome home"
+//	when presence.user_enter(user: "Anna")
+//	if "Hall sensor".measure_temperature < 20
+//	and not "Hall heater".on
+//	then "Hall heater".on
+//	then "Hall heater".target_temperature_set(target_temperature: 22)
+//	else notifications.create_notification(text: "Warm enough")
+//
+// Card references take three forms:
+//
+//	presence.user_enter       manager card (homey:manager:presence:user_enter)
+//	"Hall heater".on          device card, resolved by device name
+//	"homey:app:com.x:card"    full card ID
+//
+// Conditions are either cards or comparisons of a droptoken against a value
+// ("Device".capability < 20). Conditions joined with "and" form a group;
+// "or" starts a new group, like the OR groups of a simple flow.
+//
+// The package only deals with syntax. Resolving names to IDs and building
+// flow JSON is left to the caller.
+package flowdsl
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RefKind tells how a card reference names its owner
+type RefKind int
+
+const (
+	// RefManager is a Homey manager card, e.g. presence.user_enter
+	RefManager RefKind = iota
+	// RefDevice is a device card or capability, e.g. "Hall heater".on
+	RefDevice
+	// RefRaw is a full card ID or droptoken in quotes
+	RefRaw
+)
+
+// Ref points to a card, or to a droptoken in a comparison
+type Ref struct {
+	Kind  RefKind
+	Owner string // Manager ID or device name
+	Name  string // Card name, capability, or the raw ID
+}
+
+// Card is a card reference with its arguments
+type Card struct {
+	Ref  Ref
+	Args map[string]interface{}
+}
+
+// Comparison compares a droptoken with a value using <, > or ==
+type Comparison struct {
+	Token Ref
+	Op    string
+	Value interface{}
+}
+
+// Condition is a card or a comparison, optionally inverted
+type Condition struct {
+	Not     bool
+	Card    *Card
+	Compare *Comparison
+}
+
+// Rule is one trigger with its conditions and actions
+type Rule struct {
+	Trigger    Card
+	Conditions [][]Condition // Groups of and-ed conditions, or-ed together
+	Then       []Card
+	Else       []Card
+}
+
+// Flow is a parsed flow file
+type Flow struct {
+	Name     string
+	Advanced bool
+	Disabled bool
+	Rules    []Rule
+}
+
+var keywords = map[string]bool{
+	"flow": true, "advanced": true, "disabled": true,
+	"when": true, "if": true, "and": true, "or": true, "not": true,
+	"then": true, "else": true, "true": true, "false": true, "null": true,
+}
+
+// Parse parses a flow in the text form
+func Parse(src string) (*Flow, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	return p.parseFlow()
+}
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isKeyword(word string) bool {
+	t := p.peek()
+	return t.kind == tokIdent && t.text == word
+}
+
+func (p *parser) isPunct(s string) bool {
+	t := p.peek()
+	return t.kind == tokPunct && t.text == s
+}
+
+func (p *parser) expectPunct(s string) error {
+	if !p.isPunct(s) {
+		return p.errorf("expected %q, got %s", s, p.peek())
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	t := p.peek()
+	return fmt.Errorf("line %d, col %d: %s", t.line, t.col, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) parseFlow() (*Flow, error) {
+	f := &Flow{}
+	for {
+		switch {
+		case p.isKeyword("flow"):
+			p.next()
+			t := p.next()
+			if t.kind != tokString {
+				return nil, p.errorf("expected flow name in quotes")
+			}
+			f.Name = t.text
+			continue
+		case p.isKeyword("advanced"):
+			p.next()
+			f.Advanced = true
+			continue
+		case p.isKeyword("disabled"):
+			p.next()
+			f.Disabled = true
+			continue
+		}
+		break
+	}
+
+	for p.isKeyword("when") {
+		r, err := p.parseRule()
+		if err != nil {
+			return nil, err
+		}
+		f.Rules = append(f.Rules, *r)
+	}
+
+	if p.peek().kind != tokEOF {
+		return nil, p.errorf("expected 'when', got %s", p.peek())
+	}
+	if len(f.Rules) == 0 {
+		return nil, fmt.Errorf("flow has no 'when' rule")
+	}
+	return f, nil
+}
+
+func (p *parser) parseRule() (*Rule, error) {
+	p.next() // when
+	trigger, err := p.parseCard()
+	if err != nil {
+		return nil, err
+	}
+	r := &Rule{Trigger: *trigger}
+
+	list := ""
+	for {
+		switch {
+		case p.isKeyword("if"), p.isKeyword("and"), p.isKeyword("or"):
+			word := p.next().text
+			if list != "" {
+				return nil, p.errorf("'%s' must come before 'then' and 'else'", word)
+			}
+			if (word == "if") != (len(r.Conditions) == 0) {
+				if word == "if" {
+					return nil, p.errorf("use 'and' or 'or' for additional conditions")
+				}
+				return nil, p.errorf("'%s' without 'if'", word)
+			}
+			c, err := p.parseCondition()
+			if err != nil {
+				return nil, err
+			}
+			if word == "and" {
+				r.Conditions[len(r.Conditions)-1] = append(r.Conditions[len(r.Conditions)-1], *c)
+			} else {
+				r.Conditions = append(r.Conditions, []Condition{*c})
+			}
+		case p.isKeyword("then"), p.isKeyword("else"), p.isPunct(",") && list != "":
+			if t := p.next(); t.kind == tokIdent {
+				list = t.text
+			}
+			c, err := p.parseCard()
+			if err != nil {
+				return nil, err
+			}
+			if list == "then" {
+				r.Then = append(r.Then, *c)
+			} else {
+				r.Else = append(r.Else, *c)
+			}
+		default:
+			return r, nil
+		}
+	}
+}
+
+func (p *parser) parseRef() (Ref, error) {
+	t := p.next()
+	switch t.kind {
+	case tokString:
+		if p.isPunct(".") {
+			p.next()
+			name := p.next()
+			if name.kind != tokIdent && name.kind != tokString {
+				return Ref{}, p.errorf("expected card name after %q.", t.text)
+			}
+			return Ref{Kind: RefDevice, Owner: t.text, Name: name.text}, nil
+		}
+		if !strings.ContainsAny(t.text, ":|") {
+			return Ref{}, fmt.Errorf("line %d, col %d: %q is not a card ID; use \"Device\".card for device cards", t.line, t.col, t.text)
+		}
+		return Ref{Kind: RefRaw, Name: t.text}, nil
+	case tokIdent:
+		if keywords[t.text] {
+			return Ref{}, fmt.Errorf("line %d, col %d: expected card, got '%s'", t.line, t.col, t.text)
+		}
+		if err := p.expectPunct("."); err != nil {
+			return Ref{}, err
+		}
+		name := p.next()
+		if name.kind != tokIdent && name.kind != tokString {
+			return Ref{}, p.errorf("expected card name after %s.", t.text)
+		}
+		return Ref{Kind: RefManager, Owner: t.text, Name: name.text}, nil
+	}
+	return Ref{}, fmt.Errorf("line %d, col %d: expected card, got %s", t.line, t.col, t)
+}
+
+func (p *parser) parseCard() (*Card, error) {
+	ref, err := p.parseRef()
+	if err != nil {
+		return nil, err
+	}
+	c := &Card{Ref: ref}
+	if p.isPunct("(") {
+		p.next()
+		c.Args = map[string]interface{}{}
+		for !p.isPunct(")") {
+			key := p.next()
+			if key.kind != tokIdent && key.kind != tokString {
+				return nil, fmt.Errorf("line %d, col %d: expected argument name, got %s", key.line, key.col, key)
+			}
+			if err := p.expectPunct(":"); err != nil {
+				return nil, err
+			}
+			v, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			c.Args[key.text] = v
+			if !p.isPunct(",") {
+				break
+			}
+			p.next()
+		}
+		if err := p.expectPunct(")"); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (p *parser) parseCondition() (*Condition, error) {
+	c := &Condition{}
+	if p.isKeyword("not") {
+		p.next()
+		c.Not = true
+	}
+
+	start := p.pos
+	ref, err := p.parseRef()
+	if err != nil {
+		return nil, err
+	}
+	// Homey's logic cards only compare with <, > and ==; the rest are
+	// written with not
+	for _, op := range [][2]string{{"<=", ">"}, {">=", "<"}, {"!=", "=="}} {
+		if p.isPunct(op[0]) {
+			return nil, p.errorf("%s is not supported, write 'not %s %s <value>'", op[0], formatRef(ref), op[1])
+		}
+	}
+	for _, op := range []string{"<", ">", "=="} {
+		if p.isPunct(op) {
+			p.next()
+			if ref.Kind == RefManager || (ref.Kind == RefRaw && !strings.Contains(ref.Name, "|")) {
+				return nil, fmt.Errorf("comparison needs a device capability or droptoken, got %s", formatRef(ref))
+			}
+			v, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			c.Compare = &Comparison{Token: ref, Op: op, Value: v}
+			return c, nil
+		}
+	}
+
+	p.pos = start
+	card, err := p.parseCard()
+	if err != nil {
+		return nil, err
+	}
+	c.Card = card
+	return c, nil
+}
+
+func (p *parser) parseValue() (interface{}, error) {
+	t := p.next()
+	switch t.kind {
+	case tokString:
+		return t.text, nil
+	case tokNumber:
+		v, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d, col %d: invalid number %s", t.line, t.col, t.text)
+		}
+		return v, nil
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+	case tokPunct:
+		switch t.text {
+		case "[":
+			list := []interface{}{}
+			for !p.isPunct("]") {
+				v, err := p.parseValue()
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, v)
+				if !p.isPunct(",") {
+					break
+				}
+				p.next()
+			}
+			return list, p.expectPunct("]")
+		case "{":
+			obj := map[string]interface{}{}
+			for !p.isPunct("}") {
+				key := p.next()
+				if key.kind != tokIdent && key.kind != tokString {
+					return nil, fmt.Errorf("line %d, col %d: expected key, got %s", key.line, key.col, key)
+				}
+				if err := p.expectPunct(":"); err != nil {
+					return nil, err
+				}
+				v, err := p.parseValue()
+				if err != nil {
+					return nil, err
+				}
+				obj[key.text] = v
+				if !p.isPunct(",") {
+					break
+				}
+				p.next()
+			}
+			return obj, p.expectPunct("}")
+		}
+	}
+	return nil, fmt.Errorf("line %d, col %d: expected value, got %s", t.line, t.col, t)
+}
+
+// Format prints a flow in the text form accepted by Parse
+func Format(f *Flow) string {
+	var b strings.Builder
+	if f.Name != "" {
+		fmt.Fprintf(&b, "flow %s\n", strconv.Quote(f.Name))
+	}
+	if f.Advanced {
+		b.WriteString("advanced\n")
+	}
+	if f.Disabled {
+		b.WriteString("disabled\n")
+	}
+
+	for _, r := range f.Rules {
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "when %s\n", FormatCard(r.Trigger))
+		for gi, group := range r.Conditions {
+			for ci, c := range group {
+				word := "and"
+				if ci == 0 {
+					word = "or"
+					if gi == 0 {
+						word = "if"
+					}
+				}
+				fmt.Fprintf(&b, "%s %s\n", word, FormatCondition(c))
+			}
+		}
+		for _, c := range r.Then {
+			fmt.Fprintf(&b, "then %s\n", FormatCard(c))
+		}
+		for _, c := range r.Else {
+			fmt.Fprintf(&b, "else %s\n", FormatCard(c))
+		}
+	}
+	return b.String()
+}
+
+func formatRef(r Ref) string {
+	switch r.Kind {
+	case RefManager:
+		return r.Owner + "." + formatKey(r.Name)
+	case RefDevice:
+		return strconv.Quote(r.Owner) + "." + formatKey(r.Name)
+	}
+	return strconv.Quote(r.Name)
+}
+
+// FormatCard prints a card reference with its arguments
+func FormatCard(c Card) string {
+	s := formatRef(c.Ref)
+	if len(c.Args) > 0 {
+		s += "(" + formatPairs(c.Args) + ")"
+	}
+	return s
+}
+
+// FormatCondition prints a condition as written after if/and/or
+func FormatCondition(c Condition) string {
+	s := ""
+	if c.Not {
+		s = "not "
+	}
+	if c.Compare != nil {
+		return s + fmt.Sprintf("%s %s %s", formatRef(c.Compare.Token), c.Compare.Op, FormatValue(c.Compare.Value))
+	}
+	return s + FormatCard(*c.Card)
+}
+
+func formatPairs(m map[string]interface{}) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = formatKey(k) + ": " + FormatValue(m[k])
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatKey(k string) string {
+	if IsIdent(k) && !keywords[k] {
+		return k
+	}
+	return strconv.Quote(k)
+}
+
+// FormatValue prints an argument value as a DSL literal
+func FormatValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return strconv.Quote(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, e := range v {
+			parts[i] = FormatValue(e)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case map[string]interface{}:
+		return "{" + formatPairs(v) + "}"
+	}
+	return strconv.Quote(fmt.Sprint(v))
+}
+
+// IsIdent reports whether s can be written as a bare identifier
+func IsIdent(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		if !isIdentRune(r) || (i == 0 && !isIdentStart(r)) {
+			return false
+		}
+	}
+	return true
+}
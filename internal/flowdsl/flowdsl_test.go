@@ -0,0 +1,153 @@
+package flowdsl
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	src := `# Heat the hall when Anna gets home
+flow "Welcome home"
+when presence.user_enter(user: "Anna")
+if "Hall sensor".measure_temperature < 20
+and not "Hall heater".on
+or "homey:manager:logic:eq"(a: 1)
+then "Hall heater".on, "Hall heater".target_temperature_set(target_temperature: 22.5)
+else notifications.create_notification(text: "Warm \"enough\"", tags: ["a", {x: true}])
+`
+	f, err := Parse(src)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if f.Name != "Welcome home" || f.Advanced || len(f.Rules) != 1 {
+		t.Fatalf("unexpected flow header: %+v", f)
+	}
+
+	r := f.Rules[0]
+	want := Ref{Kind: RefManager, Owner: "presence", Name: "user_enter"}
+	if r.Trigger.Ref != want || r.Trigger.Args["user"] != "Anna" {
+		t.Errorf("unexpected trigger: %+v", r.Trigger)
+	}
+
+	if len(r.Conditions) != 2 || len(r.Conditions[0]) != 2 || len(r.Conditions[1]) != 1 {
+		t.Fatalf("unexpected condition groups: %+v", r.Conditions)
+	}
+	cmp := r.Conditions[0][0].Compare
+	if cmp == nil || cmp.Token.Owner != "Hall sensor" || cmp.Token.Name != "measure_temperature" || cmp.Op != "<" || cmp.Value != 20.0 {
+		t.Errorf("unexpected comparison: %+v", cmp)
+	}
+	if c := r.Conditions[0][1]; !c.Not || c.Card == nil || c.Card.Ref.Kind != RefDevice {
+		t.Errorf("unexpected inverted condition: %+v", c)
+	}
+	if c := r.Conditions[1][0]; c.Card == nil || c.Card.Ref.Kind != RefRaw || c.Card.Ref.Name != "homey:manager:logic:eq" {
+		t.Errorf("unexpected raw condition: %+v", c)
+	}
+
+	if len(r.Then) != 2 || r.Then[1].Args["target_temperature"] != 22.5 {
+		t.Errorf("unexpected then: %+v", r.Then)
+	}
+	if len(r.Else) != 1 {
+		t.Fatalf("unexpected else: %+v", r.Else)
+	}
+	wantTags := []interface{}{"a", map[string]interface{}{"x": true}}
+	if r.Else[0].Args["text"] != `Warm "enough"` || !reflect.DeepEqual(r.Else[0].Args["tags"], wantTags) {
+		t.Errorf("unexpected else args: %+v", r.Else[0].Args)
+	}
+}
+
+func TestParse_MultipleRules(t *testing.T) {
+	f, err := Parse(`advanced
+when "Button".button_pressed then "Lamp".toggle
+when cron.time_exactly(time: "07:00") then "Lamp".on`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !f.Advanced || len(f.Rules) != 2 {
+		t.Errorf("expected advanced flow with 2 rules, got %+v", f)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"no rule", `flow "x"`, "no 'when'"},
+		{"and without if", `when a.b and c.d then e.f`, "without 'if'"},
+		{"if after then", `when a.b then c.d if e.f`, "before 'then'"},
+		{"second if", `when a.b if c.d if e.f`, "use 'and' or 'or'"},
+		{"bare string", `when "Lamp" then a.b`, "not a card ID"},
+		{"compare manager", `when a.b if logic.x < 3`, "comparison needs"},
+		{"less or equal", `when a.b if "Hall".measure_temperature <= 20 then c.d`, `<= is not supported, write 'not "Hall".measure_temperature > <value>'`},
+		{"not equal", `when a.b if "Hall".measure_temperature != 20 then c.d`, "line 1, col 40: != is not supported"},
+		{"bang", `when a.b if !c.d`, "unexpected character '!'"},
+		{"unterminated", `when "Lamp.on`, "unterminated"},
+		{"bad char", `when a.b @`, "unexpected character"},
+		{"trailing", `when a.b then c.d 5`, "line 1, col 19"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.src)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestFormat_RoundTrip(t *testing.T) {
+	src := `flow "Welcome home"
+advanced
+disabled
+
+when presence.user_enter(user: "Anna")
+if "Hall sensor".measure_temperature < 20
+and not "Hall heater".on
+or "homey:device:abc|measure_power" > -1.5
+then "Hall heater".on
+then "Hall heater"."dim.1"(dim: 0.5, "odd key": null)
+else "homey:app:com.x:card"(list: [1, 2], obj: {id: "u1", name: "Anna"})
+
+when cron.time_exactly(time: "07:00")
+then "Lamp".on
+`
+	f, err := Parse(src)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := Format(f); got != src {
+		t.Errorf("round trip mismatch:\n--- got\n%s\n--- want\n%s", got, src)
+	}
+}
+
+func TestParseYAML(t *testing.T) {
+	data := []byte(`name: Welcome home
+when: 'presence.user_enter(user: "Anna")'
+if:
+  - '"Hall sensor".measure_temperature < 20'
+  - 'not "Hall heater".on'
+then: '"Hall heater".on'
+rules:
+  - when: 'cron.time_exactly(time: "07:00")'
+    then: ['"Lamp".on', '"Radio".on']
+`)
+	f, err := ParseYAML(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if f.Name != "Welcome home" || len(f.Rules) != 2 {
+		t.Fatalf("unexpected flow: %+v", f)
+	}
+	if len(f.Rules[0].Conditions) != 1 || len(f.Rules[0].Conditions[0]) != 2 || len(f.Rules[0].Then) != 1 {
+		t.Errorf("unexpected first rule: %+v", f.Rules[0])
+	}
+	if len(f.Rules[1].Then) != 2 {
+		t.Errorf("unexpected second rule: %+v", f.Rules[1])
+	}
+
+	if _, err := ParseYAML([]byte("name: x\nrules:\n  - then: a.b\n")); err == nil {
+		t.Error("expected error for rule without when")
+	}
+}
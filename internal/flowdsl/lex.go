@@ -0,0 +1,136 @@
+package flowdsl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	line int
+	col  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of file"
+	case tokString:
+		return strconv.Quote(t.text)
+	}
+	return "'" + t.text + "'"
+}
+
+func isIdentStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || r == '-' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// lex splits src into tokens. Comments start with # and run to end of line.
+func lex(src string) ([]token, error) {
+	var toks []token
+	runes := []rune(src)
+	line, col := 1, 1
+
+	advance := func(n int) {
+		for i := 0; i < n; i++ {
+			if runes[0] == '\n' {
+				line++
+				col = 1
+			} else {
+				col++
+			}
+			runes = runes[1:]
+		}
+	}
+
+	for len(runes) > 0 {
+		r := runes[0]
+		start := token{line: line, col: col}
+
+		switch {
+		case unicode.IsSpace(r):
+			advance(1)
+
+		case r == '#':
+			for len(runes) > 0 && runes[0] != '\n' {
+				advance(1)
+			}
+
+		case r == '"':
+			n := 1
+			for ; n < len(runes) && runes[n] != '"'; n++ {
+				if runes[n] == '\\' {
+					n++
+				}
+				if n < len(runes) && runes[n] == '\n' {
+					return nil, fmt.Errorf("line %d, col %d: unterminated string", line, col)
+				}
+			}
+			if n >= len(runes) {
+				return nil, fmt.Errorf("line %d, col %d: unterminated string", line, col)
+			}
+			s, err := strconv.Unquote(string(runes[:n+1]))
+			if err != nil {
+				return nil, fmt.Errorf("line %d, col %d: invalid string: %w", line, col, err)
+			}
+			start.kind, start.text = tokString, s
+			toks = append(toks, start)
+			advance(n + 1)
+
+		case unicode.IsDigit(r) || (r == '-' && len(runes) > 1 && unicode.IsDigit(runes[1])):
+			n := 1
+			for n < len(runes) && (unicode.IsDigit(runes[n]) || strings.ContainsRune(".eE+-", runes[n])) {
+				if (runes[n] == '+' || runes[n] == '-') && runes[n-1] != 'e' && runes[n-1] != 'E' {
+					break
+				}
+				n++
+			}
+			start.kind, start.text = tokNumber, string(runes[:n])
+			toks = append(toks, start)
+			advance(n)
+
+		case isIdentStart(r):
+			n := 1
+			for n < len(runes) && isIdentRune(runes[n]) {
+				n++
+			}
+			start.kind, start.text = tokIdent, string(runes[:n])
+			toks = append(toks, start)
+			advance(n)
+
+		// <=, >= and != are lexed so the parser can explain that Homey has
+		// no cards for them
+		case strings.ContainsRune("=<>!", r) && len(runes) > 1 && runes[1] == '=':
+			start.kind, start.text = tokPunct, string(runes[:2])
+			toks = append(toks, start)
+			advance(2)
+
+		case strings.ContainsRune(".,:()[]{}<>", r):
+			start.kind, start.text = tokPunct, string(r)
+			toks = append(toks, start)
+			advance(1)
+
+		default:
+			return nil, fmt.Errorf("line %d, col %d: unexpected character %q", line, col, r)
+		}
+	}
+
+	return append(toks, token{kind: tokEOF, line: line, col: col}), nil
+}
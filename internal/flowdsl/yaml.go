@@ -0,0 +1,95 @@
+package flowdsl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// yamlRule is one rule in the YAML form. Each entry is written in the text
+// syntax; "if" entries are joined with "and".
+type yamlRule struct {
+	When string       `yaml:"when"`
+	If   stringOrList `yaml:"if"`
+	Then stringOrList `yaml:"then"`
+	Else stringOrList `yaml:"else"`
+}
+
+type yamlFlow struct {
+	Name     string `yaml:"name"`
+	Advanced bool   `yaml:"advanced"`
+	Enabled  *bool  `yaml:"enabled"`
+	yamlRule `yaml:",inline"`
+	Rules    []yamlRule `yaml:"rules"`
+}
+
+// stringOrList accepts a single string or a list of strings
+type stringOrList []string
+
+func (s *stringOrList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*s = []string{node.Value}
+		return nil
+	}
+	var list []string
+	if err := node.Decode(&list); err != nil {
+		return err
+	}
+	*s = list
+	return nil
+}
+
+// ParseYAML parses a flow in the YAML form:
+//
+//	name: Welcome home
+//	advanced: false
+//	when: 'presence.user_enter(user: "Anna")'
+//	if: '"Hall sensor".measure_temperature < 20'
+//	then:
+//	  - '"Hall heater".on'
+//
+// Advanced flows with several triggers list them under "rules".
+func ParseYAML(data []byte) (*Flow, error) {
+	var y yamlFlow
+	if err := yaml.Unmarshal(data, &y); err != nil {
+		return nil, fmt.Errorf("invalid YAML: %w", err)
+	}
+
+	rules := y.Rules
+	if y.When != "" {
+		rules = append([]yamlRule{y.yamlRule}, rules...)
+	}
+
+	var b strings.Builder
+	if y.Name != "" {
+		fmt.Fprintf(&b, "flow %s\n", strconv.Quote(y.Name))
+	}
+	if y.Advanced {
+		b.WriteString("advanced\n")
+	}
+	if y.Enabled != nil && !*y.Enabled {
+		b.WriteString("disabled\n")
+	}
+	for _, r := range rules {
+		if r.When == "" {
+			return nil, fmt.Errorf("rule is missing 'when'")
+		}
+		fmt.Fprintf(&b, "when %s\n", r.When)
+		for i, c := range r.If {
+			word := "and"
+			if i == 0 {
+				word = "if"
+			}
+			fmt.Fprintf(&b, "%s %s\n", word, c)
+		}
+		for _, a := range r.Then {
+			fmt.Fprintf(&b, "then %s\n", a)
+		}
+		for _, a := range r.Else {
+			fmt.Fprintf(&b, "else %s\n", a)
+		}
+	}
+	return Parse(b.String())
+}
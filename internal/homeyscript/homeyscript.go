@@ -0,0 +1,511 @@
+// Package homeyscript runs HomeyScript code locally in an embedded
+// JavaScript engine. The Homey global is backed by an in-memory fake Homey
+// seeded from a fixture, so scripts can read realistic state while every
+// call that would change something is recorded instead of reaching a real
+// Homey.
+package homeyscript
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/fishfisher/homeyctl/internal/fakehomey"
+	"github.com/fishfisher/homeyctl/internal/jsondiff"
+)
+
+// Call is a mutating API call a script made
+type Call struct {
+	API  string      `json:"api"`
+	Args interface{} `json:"args,omitempty"`
+}
+
+func (c Call) String() string {
+	if c.Args == nil {
+		return c.API + "()"
+	}
+	args, _ := json.Marshal(c.Args)
+	return fmt.Sprintf("%s(%s)", c.API, args)
+}
+
+// Options control a run
+type Options struct {
+	// Args is the script's args array
+	Args []string
+	// Timeout stops scripts that never finish. Zero means 10 seconds.
+	Timeout time.Duration
+}
+
+// Result is the outcome of a run
+type Result struct {
+	Logs    []string      `json:"logs"`
+	Returns interface{}   `json:"returns,omitempty"`
+	Error   string        `json:"error,omitempty"`
+	Stack   string        `json:"stack,omitempty"`
+	Calls   []Call        `json:"calls"`
+	Tags    []Call        `json:"tags,omitempty"`
+	Changes []string      `json:"changes"`
+	Elapsed time.Duration `json:"-"`
+}
+
+// Failed reports whether the script threw or timed out
+func (r *Result) Failed() bool {
+	return r.Error != ""
+}
+
+type runner struct {
+	vm     *goja.Runtime
+	homey  *fakehomey.Homey
+	result *Result
+	global map[string]goja.Value
+}
+
+// Run executes a script against a fake Homey built from seed. Like
+// HomeyScript, the code runs as the body of an async function, so it may
+// use top-level await and return a value.
+func Run(name, code string, seed *fakehomey.Seed, opts Options) (*Result, error) {
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+
+	r := &runner{
+		vm:     goja.New(),
+		homey:  fakehomey.New(seed),
+		result: &Result{Logs: []string{}, Calls: []Call{}, Changes: []string{}},
+		global: map[string]goja.Value{},
+	}
+	before := r.homey.Snapshot()
+
+	if err := r.install(opts.Args); err != nil {
+		return nil, err
+	}
+
+	timer := time.AfterFunc(opts.Timeout, func() {
+		r.vm.Interrupt(fmt.Sprintf("script did not finish within %s", opts.Timeout))
+	})
+	defer timer.Stop()
+
+	start := time.Now()
+	wrapped := "(async function() {\n" + code + "\n})()"
+	value, err := r.vm.RunScript(name, wrapped)
+	r.result.Elapsed = time.Since(start)
+
+	if err != nil {
+		var interrupted *goja.InterruptedError
+		var exception *goja.Exception
+		switch {
+		case errors.As(err, &interrupted):
+			r.result.Error = fmt.Sprint(interrupted.Value())
+		case errors.As(err, &exception):
+			r.setError(exception.Value())
+		default:
+			return nil, err
+		}
+	} else if promise, ok := value.Export().(*goja.Promise); ok {
+		switch promise.State() {
+		case goja.PromiseStateFulfilled:
+			r.result.Returns = exportJSON(r.vm, promise.Result())
+		case goja.PromiseStateRejected:
+			r.setError(promise.Result())
+		default:
+			r.result.Error = "script is still waiting for something that never happens (a pending promise)"
+		}
+	}
+
+	r.result.Changes = stateChanges(before, r.homey.Snapshot())
+	return r.result, nil
+}
+
+func (r *runner) setError(v goja.Value) {
+	if obj, ok := v.(*goja.Object); ok {
+		if msg := obj.Get("message"); msg != nil && !goja.IsUndefined(msg) {
+			r.result.Error = msg.String()
+			if stack := obj.Get("stack"); stack != nil && !goja.IsUndefined(stack) {
+				r.result.Stack = stack.String()
+			}
+			return
+		}
+	}
+	r.result.Error = v.String()
+}
+
+// exportJSON converts a JS value to plain Go values the way JSON.stringify
+// sees it
+func exportJSON(vm *goja.Runtime, v goja.Value) interface{} {
+	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+		return nil
+	}
+	text, ok := stringify(vm, v, "")
+	if !ok {
+		return v.String()
+	}
+	var out interface{}
+	if json.Unmarshal([]byte(text), &out) != nil {
+		return v.String()
+	}
+	return out
+}
+
+func stringify(vm *goja.Runtime, v goja.Value, indent string) (string, bool) {
+	fn, ok := goja.AssertFunction(vm.Get("JSON").ToObject(vm).Get("stringify"))
+	if !ok {
+		return "", false
+	}
+	out, err := fn(goja.Undefined(), v, goja.Undefined(), vm.ToValue(indent))
+	if err != nil || goja.IsUndefined(out) {
+		return "", false
+	}
+	return out.String(), true
+}
+
+func (r *runner) logLine(args []goja.Value) {
+	parts := make([]string, len(args))
+	for i, a := range args {
+		if obj, ok := a.(*goja.Object); ok && obj.ClassName() != "Function" && obj.ClassName() != "Error" {
+			if text, ok := stringify(r.vm, obj, "  "); ok {
+				parts[i] = text
+				continue
+			}
+		}
+		parts[i] = a.String()
+	}
+	r.result.Logs = append(r.result.Logs, strings.Join(parts, " "))
+}
+
+// install sets up the globals HomeyScript provides
+func (r *runner) install(args []string) error {
+	vm := r.vm
+	if args == nil {
+		args = []string{}
+	}
+
+	logFn := func(call goja.FunctionCall) goja.Value {
+		r.logLine(call.Arguments)
+		return goja.Undefined()
+	}
+	console := vm.NewObject()
+	for _, name := range []string{"log", "info", "warn", "error", "debug"} {
+		console.Set(name, logFn)
+	}
+
+	global := vm.NewObject()
+	global.Set("get", func(key string) goja.Value {
+		if v, ok := r.global[key]; ok {
+			return v
+		}
+		return goja.Undefined()
+	})
+	global.Set("set", func(key string, v goja.Value) {
+		r.global[key] = v
+	})
+	global.Set("keys", func() []string {
+		keys := make([]string, 0, len(r.global))
+		for k := range r.global {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return keys
+	})
+
+	homey, err := r.homeyAPI()
+	if err != nil {
+		return err
+	}
+
+	for name, v := range map[string]interface{}{
+		"log":     logFn,
+		"console": console,
+		"args":    args,
+		"global":  global,
+		"Homey":   homey,
+		"wait": func(ms int64) *goja.Promise {
+			// Time does not pass in tests; waiting resolves right away
+			p, resolve, _ := vm.NewPromise()
+			resolve(goja.Undefined())
+			return p
+		},
+		"say": func(text string) *goja.Promise {
+			r.result.Calls = append(r.result.Calls, Call{API: "say", Args: text})
+			p, resolve, _ := vm.NewPromise()
+			resolve(goja.Undefined())
+			return p
+		},
+		"tag": func(id string, value goja.Value) *goja.Promise {
+			r.result.Tags = append(r.result.Tags, Call{API: id, Args: exportJSON(vm, value)})
+			p, resolve, _ := vm.NewPromise()
+			resolve(goja.Undefined())
+			return p
+		},
+	} {
+		if err := vm.Set(name, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// responseBuffer records a response from the fake Homey, like
+// httptest.ResponseRecorder but without linking the testing package
+type responseBuffer struct {
+	header http.Header
+	Code   int
+	Body   bytes.Buffer
+}
+
+func (b *responseBuffer) Header() http.Header         { return b.header }
+func (b *responseBuffer) Write(p []byte) (int, error) { return b.Body.Write(p) }
+func (b *responseBuffer) WriteHeader(code int)        { b.Code = code }
+
+// request serves an API call from the fake Homey
+func (r *runner) request(method, path string, body interface{}) (interface{}, error) {
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req, err := http.NewRequest(method, "/api/manager/"+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	rec := &responseBuffer{header: http.Header{}, Code: http.StatusOK}
+	r.homey.ServeHTTP(rec, req)
+
+	var out interface{}
+	json.Unmarshal(rec.Body.Bytes(), &out)
+	if rec.Code >= 400 {
+		msg := strings.TrimSpace(rec.Body.String())
+		if obj, ok := out.(map[string]interface{}); ok {
+			if e, ok := obj["error"].(string); ok {
+				msg = e
+			}
+		}
+		return nil, fmt.Errorf("%s (status %d)", msg, rec.Code)
+	}
+	return out, nil
+}
+
+// apiMethod describes one method on a Homey manager. Mutating methods are
+// recorded as calls.
+type apiMethod struct {
+	mutating bool
+	do       func(opts map[string]interface{}) (interface{}, error)
+}
+
+func optString(opts map[string]interface{}, key string) (string, error) {
+	s, _ := opts[key].(string)
+	if s == "" {
+		return "", fmt.Errorf("missing %q", key)
+	}
+	return url.PathEscape(s), nil
+}
+
+func (r *runner) get(path string) func(map[string]interface{}) (interface{}, error) {
+	return func(map[string]interface{}) (interface{}, error) {
+		return r.request(http.MethodGet, path, nil)
+	}
+}
+
+func (r *runner) getByID(collection string) func(map[string]interface{}) (interface{}, error) {
+	return func(opts map[string]interface{}) (interface{}, error) {
+		id, err := optString(opts, "id")
+		if err != nil {
+			return nil, err
+		}
+		return r.request(http.MethodGet, collection+"/"+id, nil)
+	}
+}
+
+func (r *runner) setCapabilityValue(opts map[string]interface{}) (interface{}, error) {
+	deviceID, err := optString(opts, "deviceId")
+	if err != nil {
+		return nil, err
+	}
+	capabilityID, err := optString(opts, "capabilityId")
+	if err != nil {
+		return nil, err
+	}
+	return r.request(http.MethodPut, fakehomey.Devices+"/"+deviceID+"/capability/"+capabilityID, map[string]interface{}{"value": opts["value"]})
+}
+
+func (r *runner) managers() map[string]map[string]apiMethod {
+	trigger := func(collection string) func(map[string]interface{}) (interface{}, error) {
+		return func(opts map[string]interface{}) (interface{}, error) {
+			id, err := optString(opts, "id")
+			if err != nil {
+				return nil, err
+			}
+			return r.request(http.MethodPost, collection+"/"+id+"/trigger", nil)
+		}
+	}
+
+	return map[string]map[string]apiMethod{
+		"devices": {
+			"getDevices":         {do: r.get(fakehomey.Devices)},
+			"getDevice":          {do: r.getByID(fakehomey.Devices)},
+			"setCapabilityValue": {mutating: true, do: r.setCapabilityValue},
+		},
+		"zones": {
+			"getZones": {do: r.get(fakehomey.Zones)},
+			"getZone":  {do: r.getByID(fakehomey.Zones)},
+		},
+		"flow": {
+			"getFlows":            {do: r.get(fakehomey.Flows)},
+			"getFlow":             {do: r.getByID(fakehomey.Flows)},
+			"getAdvancedFlows":    {do: r.get(fakehomey.AdvancedFlows)},
+			"getAdvancedFlow":     {do: r.getByID(fakehomey.AdvancedFlows)},
+			"triggerFlow":         {mutating: true, do: trigger(fakehomey.Flows)},
+			"triggerAdvancedFlow": {mutating: true, do: trigger(fakehomey.AdvancedFlows)},
+			"runFlowCardAction": {mutating: true, do: func(opts map[string]interface{}) (interface{}, error) {
+				uri, err := optString(opts, "uri")
+				if err != nil {
+					return nil, err
+				}
+				id, err := optString(opts, "id")
+				if err != nil {
+					return nil, err
+				}
+				return r.request(http.MethodPost, "flow/flowcardaction/"+uri+"/"+id+"/run", map[string]interface{}{"args": opts["args"]})
+			}},
+		},
+		"logic": {
+			"getVariables": {do: r.get(fakehomey.Variables)},
+			"getVariable":  {do: r.getByID(fakehomey.Variables)},
+			"updateVariable": {mutating: true, do: func(opts map[string]interface{}) (interface{}, error) {
+				id, err := optString(opts, "id")
+				if err != nil {
+					return nil, err
+				}
+				variable, _ := opts["variable"].(map[string]interface{})
+				if variable == nil {
+					return nil, fmt.Errorf("missing \"variable\"")
+				}
+				return r.request(http.MethodPut, fakehomey.Variables+"/"+id, variable)
+			}},
+		},
+		"notifications": {
+			"getNotifications": {do: r.get(fakehomey.Notifications)},
+			"createNotification": {mutating: true, do: func(opts map[string]interface{}) (interface{}, error) {
+				text, _ := opts["excerpt"].(string)
+				if text == "" {
+					return nil, fmt.Errorf("missing \"excerpt\"")
+				}
+				return r.request(http.MethodPost, "flow/flowcardaction/homey:manager:notifications/homey:manager:notifications:create_notification/run",
+					map[string]interface{}{"args": map[string]interface{}{"text": text}})
+			}},
+		},
+	}
+}
+
+// homeyAPI builds the Homey global. Every method takes one options object,
+// like the Homey Web API client, and returns a promise.
+func (r *runner) homeyAPI() (*goja.Object, error) {
+	vm := r.vm
+	homey := vm.NewObject()
+	for managerName, methods := range r.managers() {
+		manager := vm.NewObject()
+		for methodName, m := range methods {
+			api := managerName + "." + methodName
+			m := m
+			manager.Set(methodName, func(call goja.FunctionCall) goja.Value {
+				opts := map[string]interface{}{}
+				if exported, ok := exportJSON(vm, call.Argument(0)).(map[string]interface{}); ok {
+					opts = exported
+				}
+				if m.mutating {
+					r.result.Calls = append(r.result.Calls, Call{API: api, Args: opts})
+				}
+				return r.settle(api, m.do, opts)
+			})
+		}
+		if err := homey.Set(managerName, manager); err != nil {
+			return nil, err
+		}
+	}
+	return homey, nil
+}
+
+// settle runs an API method and returns a promise for its result, turning
+// JSON objects into JS objects and giving devices their methods
+func (r *runner) settle(api string, do func(map[string]interface{}) (interface{}, error), opts map[string]interface{}) goja.Value {
+	vm := r.vm
+	promise, resolve, reject := vm.NewPromise()
+	out, err := do(opts)
+	if err != nil {
+		reject(vm.NewGoError(fmt.Errorf("%s: %w", api, err)))
+		return vm.ToValue(promise)
+	}
+
+	data, _ := json.Marshal(out)
+	value, err := vm.RunString("(" + string(data) + ")")
+	if err != nil {
+		reject(vm.NewGoError(err))
+		return vm.ToValue(promise)
+	}
+
+	switch api {
+	case "devices.getDevices":
+		obj := value.ToObject(vm)
+		for _, key := range obj.Keys() {
+			r.addDeviceMethods(obj.Get(key).ToObject(vm))
+		}
+	case "devices.getDevice":
+		r.addDeviceMethods(value.ToObject(vm))
+	}
+	resolve(value)
+	return vm.ToValue(promise)
+}
+
+// addDeviceMethods lets scripts call device.setCapabilityValue(capability,
+// value) or device.setCapabilityValue({ capabilityId, value })
+func (r *runner) addDeviceMethods(device *goja.Object) {
+	vm := r.vm
+	id := device.Get("id")
+	if id == nil {
+		return
+	}
+	deviceID := id.String()
+	device.Set("setCapabilityValue", func(call goja.FunctionCall) goja.Value {
+		opts := map[string]interface{}{"deviceId": deviceID}
+		if first, ok := exportJSON(vm, call.Argument(0)).(map[string]interface{}); ok {
+			opts["capabilityId"], opts["value"] = first["capabilityId"], first["value"]
+		} else {
+			opts["capabilityId"], opts["value"] = call.Argument(0).String(), exportJSON(vm, call.Argument(1))
+		}
+		r.result.Calls = append(r.result.Calls, Call{API: "devices.setCapabilityValue", Args: opts})
+		return r.settle("devices.setCapabilityValue", r.setCapabilityValue, opts)
+	})
+}
+
+// stateChanges lists what changed in devices, variables and notifications,
+// leaving out timestamps the fake Homey updates on every write
+func stateChanges(before, after *fakehomey.Seed) []string {
+	pick := func(s *fakehomey.Seed) map[string]interface{} {
+		return map[string]interface{}{
+			"devices":       s.Devices,
+			"variables":     s.Variables,
+			"notifications": s.Notifications,
+			"flows":         s.Flows,
+			"advancedFlows": s.AdvancedFlows,
+		}
+	}
+	changes := []string{}
+	for _, c := range jsondiff.Diff(pick(before), pick(after)) {
+		if strings.HasSuffix(c.Path, "/lastUpdated") || strings.HasSuffix(c.Path, "/dateCreated") {
+			continue
+		}
+		changes = append(changes, c.String())
+	}
+	return changes
+}
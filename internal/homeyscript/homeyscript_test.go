@@ -0,0 +1,131 @@
+package homeyscript
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fishfisher/homeyctl/internal/fakehomey"
+)
+
+const seedJSON = `{
+  "devices": [
+    {"id": "lamp", "name": "Kitchen lamp", "class": "light",
+     "capabilitiesObj": {"onoff": {"id": "onoff", "value": false}}},
+    {"id": "sensor", "name": "Hall sensor", "class": "sensor",
+     "capabilitiesObj": {"measure_temperature": {"id": "measure_temperature", "value": 17, "setable": false}}}
+  ],
+  "variables": [{"id": "v1", "name": "counter", "type": "number", "value": 1}],
+  "flows": [{"id": "f1", "name": "Door", "enabled": true}]
+}`
+
+func testSeed(t *testing.T) *fakehomey.Seed {
+	t.Helper()
+	var seed fakehomey.Seed
+	if err := json.Unmarshal([]byte(seedJSON), &seed); err != nil {
+		t.Fatal(err)
+	}
+	return &seed
+}
+
+func TestRun_ReadsAndRecordsCalls(t *testing.T) {
+	code := `
+const devices = await Homey.devices.getDevices();
+const lamp = Object.values(devices).find(d => d.name === 'Kitchen lamp');
+const temp = devices.sensor.capabilitiesObj.measure_temperature.value;
+log('temperature', temp, {room: 'hall'});
+if (temp < 18) {
+  await lamp.setCapabilityValue('onoff', true);
+  await Homey.logic.updateVariable({ id: 'v1', variable: { value: 2 } });
+  await Homey.flow.triggerFlow({ id: 'f1' });
+  await Homey.notifications.createNotification({ excerpt: 'Cold in the hall' });
+}
+await tag('cold', true);
+const lampNow = await Homey.devices.getDevice({ id: 'lamp' });
+return { on: lampNow.capabilitiesObj.onoff.value, args };
+`
+	result, err := Run("cold.js", code, testSeed(t), Options{Args: []string{"x"}})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if result.Failed() {
+		t.Fatalf("script failed: %s\n%s", result.Error, result.Stack)
+	}
+
+	if want := []string{"temperature 17 {\n  \"room\": \"hall\"\n}"}; !reflect.DeepEqual(result.Logs, want) {
+		t.Errorf("logs = %q, want %q", result.Logs, want)
+	}
+	if want := map[string]interface{}{"on": true, "args": []interface{}{"x"}}; !reflect.DeepEqual(result.Returns, want) {
+		t.Errorf("returns = %v, want %v", result.Returns, want)
+	}
+
+	var apis []string
+	for _, c := range result.Calls {
+		apis = append(apis, c.API)
+	}
+	want := []string{"devices.setCapabilityValue", "logic.updateVariable", "flow.triggerFlow", "notifications.createNotification"}
+	if !reflect.DeepEqual(apis, want) {
+		t.Errorf("calls = %v, want %v", apis, want)
+	}
+	if got := result.Calls[0].String(); got != `devices.setCapabilityValue({"capabilityId":"onoff","deviceId":"lamp","value":true})` {
+		t.Errorf("unexpected call: %s", got)
+	}
+	if len(result.Tags) != 1 || result.Tags[0].API != "cold" || result.Tags[0].Args != true {
+		t.Errorf("unexpected tags: %v", result.Tags)
+	}
+
+	changes := strings.Join(result.Changes, "\n")
+	for _, c := range []string{"~ /devices/lamp/capabilitiesObj/onoff/value: false → true", "~ /variables/v1/value: 1 → 2"} {
+		if !strings.Contains(changes, c) {
+			t.Errorf("changes missing %q:\n%s", c, changes)
+		}
+	}
+	if strings.Contains(changes, "lastUpdated") {
+		t.Errorf("changes should not include timestamps:\n%s", changes)
+	}
+}
+
+func TestRun_Errors(t *testing.T) {
+	seed := testSeed(t)
+
+	result, err := Run("boom.js", "await wait(100);\nthrow new Error('boom');", seed, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Error != "boom" || !strings.Contains(result.Stack, "boom.js") {
+		t.Errorf("expected thrown error with stack, got %q / %q", result.Error, result.Stack)
+	}
+
+	result, _ = Run("ro.js", "await Homey.devices.setCapabilityValue({deviceId: 'sensor', capabilityId: 'measure_temperature', value: 3});", seed, Options{})
+	if !strings.Contains(result.Error, "not setable") {
+		t.Errorf("expected API error to reject, got %q", result.Error)
+	}
+	if len(result.Calls) != 1 {
+		t.Errorf("expected the attempted call to be recorded, got %v", result.Calls)
+	}
+
+	result, _ = Run("syntax.js", "return (;", seed, Options{})
+	if !result.Failed() {
+		t.Error("expected syntax error")
+	}
+
+	result, _ = Run("loop.js", "while (true) {}", seed, Options{Timeout: 50 * time.Millisecond})
+	if !strings.Contains(result.Error, "did not finish within 50ms") {
+		t.Errorf("expected timeout, got %q", result.Error)
+	}
+
+	result, _ = Run("pending.js", "await new Promise(() => {});", seed, Options{})
+	if !strings.Contains(result.Error, "pending promise") {
+		t.Errorf("expected pending promise error, got %q", result.Error)
+	}
+}
+
+func TestRun_DoesNotChangeSeed(t *testing.T) {
+	seed := testSeed(t)
+	Run("x.js", "await Homey.logic.updateVariable({id: 'v1', variable: {value: 5}});", seed, Options{})
+	if seed.Variables["v1"]["value"] != 1.0 {
+		t.Errorf("seed was modified: %v", seed.Variables["v1"])
+	}
+}
@@ -0,0 +1,137 @@
+// Package jsondiff compares two JSON documents and lists the changed paths.
+package jsondiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/fishfisher/homeyctl/internal/jsonpatch"
+)
+
+// Change kinds
+const (
+	Added   = "added"
+	Removed = "removed"
+	Changed = "changed"
+)
+
+// Change is one difference between two documents
+type Change struct {
+	Path string      `json:"path"` // JSON Pointer
+	Kind string      `json:"kind"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// String formats a change as "+ path: new", "- path: old" or "~ path: old → new"
+func (c Change) String() string {
+	path := c.Path
+	if path == "" {
+		path = "/"
+	}
+	switch c.Kind {
+	case Added:
+		return fmt.Sprintf("+ %s: %s", path, compact(c.New))
+	case Removed:
+		return fmt.Sprintf("- %s: %s", path, compact(c.Old))
+	}
+	return fmt.Sprintf("~ %s: %s → %s", path, compact(c.Old), compact(c.New))
+}
+
+// Diff lists the differences from a to b. Objects are compared member by
+// member and arrays item by item; other values are compared whole. Values
+// are normalized through JSON first, so Go structs and decoded JSON compare
+// equal when they encode the same.
+func Diff(a, b interface{}) []Change {
+	var changes []Change
+	diff(normalize(a), normalize(b), nil, &changes)
+	return changes
+}
+
+func diff(a, b interface{}, tokens []string, changes *[]Change) {
+	path := jsonpatch.FormatPointer(tokens)
+
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make(map[string]bool)
+		for k := range av {
+			keys[k] = true
+		}
+		for k := range bv {
+			keys[k] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+		for _, k := range sorted {
+			child := append(append([]string(nil), tokens...), k)
+			x, inA := av[k]
+			y, inB := bv[k]
+			switch {
+			case !inA:
+				*changes = append(*changes, Change{Path: jsonpatch.FormatPointer(child), Kind: Added, New: y})
+			case !inB:
+				*changes = append(*changes, Change{Path: jsonpatch.FormatPointer(child), Kind: Removed, Old: x})
+			default:
+				diff(x, y, child, changes)
+			}
+		}
+		return
+
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+		for i := 0; i < len(av) || i < len(bv); i++ {
+			child := append(append([]string(nil), tokens...), strconv.Itoa(i))
+			switch {
+			case i >= len(av):
+				*changes = append(*changes, Change{Path: jsonpatch.FormatPointer(child), Kind: Added, New: bv[i]})
+			case i >= len(bv):
+				*changes = append(*changes, Change{Path: jsonpatch.FormatPointer(child), Kind: Removed, Old: av[i]})
+			default:
+				diff(av[i], bv[i], child, changes)
+			}
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(a, b) {
+		*changes = append(*changes, Change{Path: path, Kind: Changed, Old: a, New: b})
+	}
+}
+
+func normalize(v interface{}) interface{} {
+	if raw, ok := v.(json.RawMessage); ok {
+		var out interface{}
+		if json.Unmarshal(raw, &out) == nil {
+			return out
+		}
+		return v
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out interface{}
+	json.Unmarshal(data, &out)
+	return out
+}
+
+func compact(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
@@ -0,0 +1,58 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	a := json.RawMessage(`{"name":"A","args":{"dim":0.5,"x":1},"list":[1,2,3],"same":{"k":[true]}}`)
+	b := map[string]interface{}{
+		"name": "B",
+		"args": map[string]interface{}{"dim": 0.5, "y": 2},
+		"list": []int{1, 5},
+		"same": map[string]interface{}{"k": []bool{true}},
+	}
+
+	got := Diff(a, b)
+	want := []Change{
+		{Path: "/args/x", Kind: Removed, Old: 1.0},
+		{Path: "/args/y", Kind: Added, New: 2.0},
+		{Path: "/list/1", Kind: Changed, Old: 2.0, New: 5.0},
+		{Path: "/list/2", Kind: Removed, Old: 3.0},
+		{Path: "/name", Kind: Changed, Old: "A", New: "B"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestDiff_TypeChangeAndEqual(t *testing.T) {
+	if got := Diff(map[string]interface{}{"a": 1}, map[string]interface{}{"a": 1.0}); len(got) != 0 {
+		t.Errorf("expected int and float to compare equal, got %+v", got)
+	}
+	got := Diff(map[string]interface{}{"a": []int{1}}, map[string]interface{}{"a": "x"})
+	if len(got) != 1 || got[0].Kind != Changed || got[0].Path != "/a" {
+		t.Errorf("expected whole value change, got %+v", got)
+	}
+	if got := Diff("a", "b"); len(got) != 1 || got[0].Path != "" {
+		t.Errorf("expected root change, got %+v", got)
+	}
+}
+
+func TestChangeString(t *testing.T) {
+	tests := []struct {
+		c    Change
+		want string
+	}{
+		{Change{Path: "/a/b~1c", Kind: Added, New: "x"}, `+ /a/b~1c: "x"`},
+		{Change{Path: "/a", Kind: Removed, Old: 1}, `- /a: 1`},
+		{Change{Path: "", Kind: Changed, Old: true, New: false}, `~ /: true → false`},
+	}
+	for _, tt := range tests {
+		if got := tt.c.String(); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+// Package jsonpatch applies RFC 6902 JSON Patch operations and resolves a
+// small JSONPath subset to JSON Pointers, working on values decoded by
+// encoding/json (map[string]interface{}, []interface{}, and scalars).
+package jsonpatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Operation is one JSON Patch operation
+type Operation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	From  string      `json:"from,omitempty"`
+	Value interface{} `json:"value"`
+}
+
+// ParsePointer splits a JSON Pointer into unescaped reference tokens
+func ParsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q: must start with /", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(t)
+	}
+	return tokens, nil
+}
+
+// FormatPointer joins reference tokens into a JSON Pointer
+func FormatPointer(tokens []string) string {
+	var b strings.Builder
+	for _, t := range tokens {
+		b.WriteString("/")
+		b.WriteString(strings.NewReplacer("~", "~0", "/", "~1").Replace(t))
+	}
+	return b.String()
+}
+
+// Get returns the value at a JSON Pointer
+func Get(doc interface{}, pointer string) (interface{}, error) {
+	tokens, err := ParsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	node := doc
+	for i, t := range tokens {
+		switch n := node.(type) {
+		case map[string]interface{}:
+			v, ok := n[t]
+			if !ok {
+				return nil, fmt.Errorf("path %s not found", FormatPointer(tokens[:i+1]))
+			}
+			node = v
+		case []interface{}:
+			idx, err := arrayIndex(t, len(n), false)
+			if err != nil {
+				return nil, fmt.Errorf("path %s: %w", FormatPointer(tokens[:i+1]), err)
+			}
+			node = n[idx]
+		default:
+			return nil, fmt.Errorf("path %s not found", FormatPointer(tokens[:i+1]))
+		}
+	}
+	return node, nil
+}
+
+// Apply applies a patch to a copy of doc. Either all operations succeed or
+// the original document is returned unchanged with the error.
+func Apply(doc interface{}, patch []Operation) (interface{}, error) {
+	result := deepCopy(doc)
+	for i, op := range patch {
+		var err error
+		result, err = applyOne(result, op)
+		if err != nil {
+			return doc, fmt.Errorf("operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+	return result, nil
+}
+
+func applyOne(doc interface{}, op Operation) (interface{}, error) {
+	tokens, err := ParsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add":
+		return modify(doc, tokens, func(c interface{}, key string) (interface{}, error) {
+			return add(c, key, deepCopy(op.Value))
+		}, op.Value)
+	case "remove":
+		if len(tokens) == 0 {
+			return nil, fmt.Errorf("cannot remove the whole document")
+		}
+		return modify(doc, tokens, remove, nil)
+	case "replace":
+		return modify(doc, tokens, func(c interface{}, key string) (interface{}, error) {
+			c, err := remove(c, key)
+			if err != nil {
+				return nil, err
+			}
+			return add(c, key, deepCopy(op.Value))
+		}, op.Value)
+	case "test":
+		v, err := Get(doc, op.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !Equal(v, op.Value) {
+			return nil, fmt.Errorf("test failed: value is %s", compact(v))
+		}
+		return doc, nil
+	case "move", "copy":
+		v, err := Get(doc, op.From)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "move" {
+			if strings.HasPrefix(op.Path+"/", op.From+"/") && op.Path != op.From {
+				return nil, fmt.Errorf("cannot move a value into itself")
+			}
+			from, _ := ParsePointer(op.From)
+			if doc, err = modify(doc, from, remove, nil); err != nil {
+				return nil, err
+			}
+		}
+		v = deepCopy(v)
+		return modify(doc, tokens, func(c interface{}, key string) (interface{}, error) {
+			return add(c, key, v)
+		}, v)
+	}
+	return nil, fmt.Errorf("unknown op %q", op.Op)
+}
+
+// modify walks to the parent of the last token and calls fn on it,
+// writing the (possibly new) container back into its parent. An empty
+// path replaces the whole document with root.
+func modify(node interface{}, tokens []string, fn func(container interface{}, key string) (interface{}, error), root interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		return deepCopy(root), nil
+	}
+	if len(tokens) == 1 {
+		return fn(node, tokens[0])
+	}
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		child, ok := n[tokens[0]]
+		if !ok {
+			return nil, fmt.Errorf("path segment %q not found", tokens[0])
+		}
+		updated, err := modify(child, tokens[1:], fn, root)
+		if err != nil {
+			return nil, err
+		}
+		n[tokens[0]] = updated
+		return n, nil
+	case []interface{}:
+		idx, err := arrayIndex(tokens[0], len(n), false)
+		if err != nil {
+			return nil, err
+		}
+		updated, err := modify(n[idx], tokens[1:], fn, root)
+		if err != nil {
+			return nil, err
+		}
+		n[idx] = updated
+		return n, nil
+	}
+	return nil, fmt.Errorf("path segment %q not found", tokens[0])
+}
+
+func add(container interface{}, key string, value interface{}) (interface{}, error) {
+	switch c := container.(type) {
+	case map[string]interface{}:
+		c[key] = value
+		return c, nil
+	case []interface{}:
+		if key == "-" {
+			return append(c, value), nil
+		}
+		idx, err := arrayIndex(key, len(c), true)
+		if err != nil {
+			return nil, err
+		}
+		c = append(c, nil)
+		copy(c[idx+1:], c[idx:])
+		c[idx] = value
+		return c, nil
+	}
+	return nil, fmt.Errorf("cannot add %q to a %s", key, typeName(container))
+}
+
+func remove(container interface{}, key string) (interface{}, error) {
+	switch c := container.(type) {
+	case map[string]interface{}:
+		if _, ok := c[key]; !ok {
+			return nil, fmt.Errorf("path segment %q not found", key)
+		}
+		delete(c, key)
+		return c, nil
+	case []interface{}:
+		idx, err := arrayIndex(key, len(c), false)
+		if err != nil {
+			return nil, err
+		}
+		return append(c[:idx], c[idx+1:]...), nil
+	}
+	return nil, fmt.Errorf("path segment %q not found", key)
+}
+
+func arrayIndex(token string, length int, allowEnd bool) (int, error) {
+	idx, err := strconv.Atoi(token)
+	if err != nil || idx < 0 || (token != "0" && strings.HasPrefix(token, "0")) {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	if idx > length || (idx == length && !allowEnd) {
+		return 0, fmt.Errorf("array index %d out of range", idx)
+	}
+	return idx, nil
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case nil:
+		return "null"
+	}
+	return "value"
+}
+
+// Equal compares two decoded JSON values
+func Equal(a, b interface{}) bool {
+	return reflect.DeepEqual(normalize(a), normalize(b))
+}
+
+// normalize round-trips a value through JSON so Go ints and float64s compare equal
+func normalize(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out interface{}
+	json.Unmarshal(data, &out)
+	return out
+}
+
+func deepCopy(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			out[k] = deepCopy(e)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			out[i] = deepCopy(e)
+		}
+		return out
+	}
+	return v
+}
+
+func compact(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
@@ -0,0 +1,128 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func decode(t *testing.T, s string) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatalf("invalid JSON %s: %v", s, err)
+	}
+	return v
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+	}{
+		{"add member", `{"a":1}`, `[{"op":"add","path":"/b","value":2}]`, `{"a":1,"b":2}`},
+		{"add array item", `{"a":[1,3]}`, `[{"op":"add","path":"/a/1","value":2}]`, `{"a":[1,2,3]}`},
+		{"append", `{"a":[1]}`, `[{"op":"add","path":"/a/-","value":2}]`, `{"a":[1,2]}`},
+		{"remove", `{"a":{"b":1,"c":2}}`, `[{"op":"remove","path":"/a/b"}]`, `{"a":{"c":2}}`},
+		{"remove array item", `{"a":[1,2,3]}`, `[{"op":"remove","path":"/a/0"}]`, `{"a":[2,3]}`},
+		{"replace", `{"a":{"b":1}}`, `[{"op":"replace","path":"/a/b","value":false}]`, `{"a":{"b":false}}`},
+		{"move", `{"a":{"b":1},"c":{}}`, `[{"op":"move","from":"/a/b","path":"/c/d"}]`, `{"a":{},"c":{"d":1}}`},
+		{"copy", `{"a":[1]}`, `[{"op":"copy","from":"/a","path":"/b"}]`, `{"a":[1],"b":[1]}`},
+		{"test passes", `{"a":"x"}`, `[{"op":"test","path":"/a","value":"x"}]`, `{"a":"x"}`},
+		{"escaped pointer", `{"a/b":{"~":1}}`, `[{"op":"replace","path":"/a~1b/~0","value":2}]`, `{"a/b":{"~":2}}`},
+		{"replace root", `{"a":1}`, `[{"op":"replace","path":"","value":[1]}]`, `[1]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var patch []Operation
+			if err := json.Unmarshal([]byte(tt.patch), &patch); err != nil {
+				t.Fatal(err)
+			}
+			got, err := Apply(decode(t, tt.doc), patch)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if want := decode(t, tt.want); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestApply_ErrorsLeaveDocumentUnchanged(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+		want  string
+	}{
+		{"missing member", `[{"op":"remove","path":"/nope"}]`, "not found"},
+		{"bad index", `[{"op":"replace","path":"/a/5","value":1}]`, "out of range"},
+		{"test fails", `[{"op":"test","path":"/b","value":2}]`, "test failed"},
+		{"unknown op", `[{"op":"merge","path":"/a"}]`, "unknown op"},
+		{"bad pointer", `[{"op":"add","path":"a","value":1}]`, "must start with /"},
+		{"second op fails", `[{"op":"add","path":"/c","value":1},{"op":"remove","path":"/x"}]`, "operation 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := decode(t, `{"a":[1],"b":1}`)
+			var patch []Operation
+			json.Unmarshal([]byte(tt.patch), &patch)
+			got, err := Apply(doc, patch)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+			if !reflect.DeepEqual(got, decode(t, `{"a":[1],"b":1}`)) {
+				t.Errorf("document changed on error: %v", got)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	doc := decode(t, `{"cards":{
+		"c1":{"type":"trigger","id":"homey:manager:cron:time","args":{"time":"07:00"}},
+		"c2":{"type":"action","id":"homey:device:lamp:on","args":{}},
+		"c3":{"type":"action","id":"homey:device:radio:on","args":{"volume":{"level":3}}}
+	},"list":[{"n":1},{"n":2}]}`)
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"$.cards.c1.args.time", []string{"/cards/c1/args/time"}},
+		{"$.cards['c2'].args.dim", []string{"/cards/c2/args/dim"}},
+		{"$.cards[*].type", []string{"/cards/c1/type", "/cards/c2/type", "/cards/c3/type"}},
+		{"$.cards.*.id", []string{"/cards/c1/id", "/cards/c2/id", "/cards/c3/id"}},
+		{"$.cards[?(@.type=='action')].args.dim", []string{"/cards/c2/args/dim", "/cards/c3/args/dim"}},
+		{"$.cards[?(@.type != 'action')]", []string{"/cards/c1"}},
+		{`$.cards[?(@.args.volume.level==3)].id`, []string{"/cards/c3/id"}},
+		{"$.list[1].n", []string{"/list/1/n"}},
+		{"$.list[?(@.n==2)]", []string{"/list/1"}},
+		{"$.cards.missing.args", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := Resolve(doc, tt.path)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolve_Errors(t *testing.T) {
+	for _, path := range []string{"cards", "$.cards[", "$.cards[?(@.a<1)]", "$.cards[foo]", "$..x"} {
+		if _, err := Resolve(map[string]interface{}{}, path); err == nil {
+			t.Errorf("expected error for %q", path)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// pathSegment is one step of a JSONPath expression
+type pathSegment struct {
+	name     string // Member name or array index
+	wildcard bool   // [*] or .*
+	filter   *pathFilter
+}
+
+// pathFilter is [?(@.field == value)] or [?(@.field != value)]
+type pathFilter struct {
+	field []string
+	op    string
+	value interface{}
+}
+
+// Resolve evaluates a JSONPath expression and returns the JSON Pointers of
+// every match, sorted. Supported syntax:
+//
+//	$.cards.<key>.args.text       member access (also ['key'] and [0])
+//	$.cards[*].args.text          wildcard over object members or array items
+//	$.cards[?(@.id=='x')].args    filter on a (nested) field with == or !=
+//
+// The last member name may be missing, so paths can point at a value to add.
+func Resolve(doc interface{}, path string) ([]string, error) {
+	segments, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	type match struct {
+		node   interface{}
+		tokens []string
+	}
+	matches := []match{{node: doc}}
+
+	for i, seg := range segments {
+		last := i == len(segments)-1
+		var next []match
+		for _, m := range matches {
+			if seg.wildcard || seg.filter != nil {
+				for _, child := range children(m.node) {
+					if seg.filter == nil || seg.filter.matches(child.value) {
+						next = append(next, match{node: child.value, tokens: appendToken(m.tokens, child.key)})
+					}
+				}
+				continue
+			}
+
+			switch n := m.node.(type) {
+			case map[string]interface{}:
+				if v, ok := n[seg.name]; ok || last {
+					next = append(next, match{node: v, tokens: appendToken(m.tokens, seg.name)})
+				}
+			case []interface{}:
+				if idx, err := strconv.Atoi(seg.name); err == nil && idx >= 0 && idx < len(n) {
+					next = append(next, match{node: n[idx], tokens: appendToken(m.tokens, seg.name)})
+				}
+			}
+		}
+		matches = next
+	}
+
+	pointers := make([]string, len(matches))
+	for i, m := range matches {
+		pointers[i] = FormatPointer(m.tokens)
+	}
+	sort.Strings(pointers)
+	return pointers, nil
+}
+
+func appendToken(tokens []string, t string) []string {
+	return append(append([]string(nil), tokens...), t)
+}
+
+type keyedValue struct {
+	key   string
+	value interface{}
+}
+
+// children lists object members (sorted by key) or array items
+func children(node interface{}) []keyedValue {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(n))
+		for k := range n {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		out := make([]keyedValue, len(keys))
+		for i, k := range keys {
+			out[i] = keyedValue{k, n[k]}
+		}
+		return out
+	case []interface{}:
+		out := make([]keyedValue, len(n))
+		for i, v := range n {
+			out[i] = keyedValue{strconv.Itoa(i), v}
+		}
+		return out
+	}
+	return nil
+}
+
+func (f *pathFilter) matches(node interface{}) bool {
+	v := node
+	for _, name := range f.field {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if v, ok = obj[name]; !ok {
+			return f.op == "!="
+		}
+	}
+	return Equal(v, f.value) == (f.op == "==")
+}
+
+func parsePath(path string) ([]pathSegment, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("invalid JSONPath %q: must start with $", path)
+	}
+	rest := path[1:]
+	var segments []pathSegment
+
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, ".*"):
+			segments = append(segments, pathSegment{wildcard: true})
+			rest = rest[2:]
+		case rest[0] == '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end == -1 {
+				end = len(rest) - 1
+			}
+			name := rest[1 : end+1]
+			if name == "" {
+				return nil, fmt.Errorf("invalid JSONPath %q: empty member name", path)
+			}
+			segments = append(segments, pathSegment{name: name})
+			rest = rest[end+1:]
+		case rest[0] == '[':
+			end := closingBracket(rest)
+			if end == -1 {
+				return nil, fmt.Errorf("invalid JSONPath %q: missing ]", path)
+			}
+			seg, err := parseBracket(rest[1:end])
+			if err != nil {
+				return nil, fmt.Errorf("invalid JSONPath %q: %w", path, err)
+			}
+			segments = append(segments, seg)
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid JSONPath %q at %q", path, rest)
+		}
+	}
+	return segments, nil
+}
+
+// closingBracket finds the ] matching the [ at s[0], skipping quoted strings
+func closingBracket(s string) int {
+	var quote byte
+	for i := 1; i < len(s); i++ {
+		switch {
+		case quote != 0:
+			if s[i] == '\\' {
+				i++
+			} else if s[i] == quote {
+				quote = 0
+			}
+		case s[i] == '\'' || s[i] == '"':
+			quote = s[i]
+		case s[i] == ']':
+			return i
+		}
+	}
+	return -1
+}
+
+func parseBracket(inner string) (pathSegment, error) {
+	inner = strings.TrimSpace(inner)
+	switch {
+	case inner == "*":
+		return pathSegment{wildcard: true}, nil
+	case strings.HasPrefix(inner, "?(") && strings.HasSuffix(inner, ")"):
+		f, err := parseFilter(strings.TrimSpace(inner[2 : len(inner)-1]))
+		if err != nil {
+			return pathSegment{}, err
+		}
+		return pathSegment{filter: f}, nil
+	case strings.HasPrefix(inner, "'") || strings.HasPrefix(inner, "\""):
+		s, err := unquote(inner)
+		if err != nil {
+			return pathSegment{}, err
+		}
+		return pathSegment{name: s}, nil
+	}
+	if _, err := strconv.Atoi(inner); err != nil {
+		return pathSegment{}, fmt.Errorf("unsupported selector [%s]", inner)
+	}
+	return pathSegment{name: inner}, nil
+}
+
+func parseFilter(expr string) (*pathFilter, error) {
+	var op string
+	var idx int
+	for _, candidate := range []string{"==", "!="} {
+		if i := strings.Index(expr, candidate); i != -1 {
+			op, idx = candidate, i
+			break
+		}
+	}
+	if op == "" {
+		return nil, fmt.Errorf("filter %q must use == or !=", expr)
+	}
+
+	left := strings.TrimSpace(expr[:idx])
+	right := strings.TrimSpace(expr[idx+2:])
+	if !strings.HasPrefix(left, "@.") {
+		return nil, fmt.Errorf("filter %q must compare a field of @", expr)
+	}
+
+	var value interface{}
+	if strings.HasPrefix(right, "'") {
+		s, err := unquote(right)
+		if err != nil {
+			return nil, err
+		}
+		value = s
+	} else if err := json.Unmarshal([]byte(right), &value); err != nil {
+		return nil, fmt.Errorf("invalid filter value %s", right)
+	}
+
+	return &pathFilter{field: strings.Split(left[2:], "."), op: op, value: value}, nil
+}
+
+// unquote accepts single- or double-quoted strings
+func unquote(s string) (string, error) {
+	if len(s) < 2 || s[0] != s[len(s)-1] {
+		return "", fmt.Errorf("invalid string %s", s)
+	}
+	if s[0] == '\'' {
+		s = "\"" + strings.ReplaceAll(strings.ReplaceAll(s[1:len(s)-1], "\\'", "'"), "\"", "\\\"") + "\""
+	}
+	return strconv.Unquote(s)
+}
@@ -0,0 +1,268 @@
+// Package notify delivers messages to channels outside Homey: email over
+// SMTP, ntfy topics and generic webhooks with a body template.
+package notify
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/fishfisher/homeyctl/internal/config"
+)
+
+// Message is a notification to deliver
+type Message struct {
+	ID      string    `json:"id,omitempty"`
+	Title   string    `json:"title"`
+	Message string    `json:"message"`
+	Source  string    `json:"source,omitempty"` // Where it came from, like a Homey notification owner
+	Time    time.Time `json:"time"`
+}
+
+// DefaultWebhookTemplate posts the message as JSON
+const DefaultWebhookTemplate = `{"title": {{json .Title}}, "message": {{json .Message}}, "source": {{json .Source}}, "time": {{json .Time}}}`
+
+var httpClient = &http.Client{Timeout: 15 * time.Second}
+
+// Validate checks that a channel has what its type needs
+func Validate(name string, ch config.NotifyChannel) error {
+	switch ch.Type {
+	case "smtp":
+		if ch.Host == "" || ch.From == "" || len(ch.To) == 0 {
+			return fmt.Errorf("channel %s: smtp needs host, from and to", name)
+		}
+		if _, err := render(ch.Subject, Message{}); err != nil {
+			return fmt.Errorf("channel %s: subject: %w", name, err)
+		}
+	case "ntfy":
+		if ch.URL == "" {
+			return fmt.Errorf("channel %s: ntfy needs url (server and topic)", name)
+		}
+	case "webhook":
+		if ch.URL == "" {
+			return fmt.Errorf("channel %s: webhook needs url", name)
+		}
+		if _, err := render(ch.Template, Message{}); err != nil {
+			return fmt.Errorf("channel %s: template: %w", name, err)
+		}
+	case "":
+		return fmt.Errorf("channel %s: no type (use smtp, ntfy or webhook)", name)
+	default:
+		return fmt.Errorf("channel %s: unknown type %q (use smtp, ntfy or webhook)", name, ch.Type)
+	}
+	return nil
+}
+
+// Send delivers msg to a channel
+func Send(ch config.NotifyChannel, msg Message) error {
+	if msg.Time.IsZero() {
+		msg.Time = time.Now()
+	}
+	switch ch.Type {
+	case "smtp":
+		return sendSMTP(ch, msg)
+	case "ntfy":
+		return sendNtfy(ch, msg)
+	case "webhook":
+		return sendWebhook(ch, msg)
+	}
+	return fmt.Errorf("unknown channel type %q", ch.Type)
+}
+
+var funcs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		out, err := json.Marshal(v)
+		return string(out), err
+	},
+}
+
+func render(tmpl string, msg Message) (string, error) {
+	t, err := template.New("").Funcs(funcs).Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, msg); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func sendNtfy(ch config.NotifyChannel, msg Message) error {
+	req, err := http.NewRequest("POST", ch.URL, strings.NewReader(msg.Message))
+	if err != nil {
+		return err
+	}
+	if msg.Title != "" {
+		req.Header.Set("Title", msg.Title)
+	}
+	if ch.Priority != "" {
+		req.Header.Set("Priority", ch.Priority)
+	}
+	if len(ch.Tags) > 0 {
+		req.Header.Set("Tags", strings.Join(ch.Tags, ","))
+	}
+	return do(ch, req)
+}
+
+func sendWebhook(ch config.NotifyChannel, msg Message) error {
+	tmpl := ch.Template
+	if tmpl == "" {
+		tmpl = DefaultWebhookTemplate
+	}
+	body, err := render(tmpl, msg)
+	if err != nil {
+		return fmt.Errorf("template: %w", err)
+	}
+	method := ch.Method
+	if method == "" {
+		method = "POST"
+	}
+	req, err := http.NewRequest(strings.ToUpper(method), ch.URL, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	contentType := ch.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	req.Header.Set("Content-Type", contentType)
+	return do(ch, req)
+}
+
+func do(ch config.NotifyChannel, req *http.Request) error {
+	if ch.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+ch.Token)
+	}
+	for k, v := range ch.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned %s: %s", req.URL.Host, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// buildEmail formats a plain text email
+func buildEmail(ch config.NotifyChannel, msg Message) ([]byte, error) {
+	subjectTmpl := ch.Subject
+	if subjectTmpl == "" {
+		subjectTmpl = "{{.Title}}"
+	}
+	subject, err := render(subjectTmpl, msg)
+	if err != nil {
+		return nil, fmt.Errorf("subject: %w", err)
+	}
+	subject = strings.ReplaceAll(strings.ReplaceAll(subject, "\r", ""), "\n", " ")
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", ch.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(ch.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", encodeHeader(subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", msg.Time.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	body := msg.Message
+	if msg.Source != "" {
+		body += "\n\n-- \n" + msg.Source
+	}
+	buf.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	buf.WriteString("\r\n")
+	return buf.Bytes(), nil
+}
+
+// encodeHeader uses RFC 2047 encoding for non-ASCII header values
+func encodeHeader(s string) string {
+	for _, r := range s {
+		if r > 127 {
+			return "=?utf-8?q?" + qEncode(s) + "?="
+		}
+	}
+	return s
+}
+
+func qEncode(s string) string {
+	var sb strings.Builder
+	for _, b := range []byte(s) {
+		switch {
+		case b == ' ':
+			sb.WriteByte('_')
+		case b >= '0' && b <= '9', b >= 'a' && b <= 'z', b >= 'A' && b <= 'Z':
+			sb.WriteByte(b)
+		default:
+			fmt.Fprintf(&sb, "=%02X", b)
+		}
+	}
+	return sb.String()
+}
+
+func sendSMTP(ch config.NotifyChannel, msg Message) error {
+	data, err := buildEmail(ch, msg)
+	if err != nil {
+		return err
+	}
+	port := ch.Port
+	if port == 0 {
+		port = 587
+	}
+	addr := net.JoinHostPort(ch.Host, strconv.Itoa(port))
+	var auth smtp.Auth
+	if ch.Username != "" {
+		auth = smtp.PlainAuth("", ch.Username, ch.Password, ch.Host)
+	}
+	if port != 465 {
+		// SendMail upgrades to STARTTLS when the server offers it
+		return smtp.SendMail(addr, auth, ch.From, ch.To, data)
+	}
+
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 15 * time.Second}, "tcp", addr, &tls.Config{ServerName: ch.Host})
+	if err != nil {
+		return err
+	}
+	c, err := smtp.NewClient(conn, ch.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if auth != nil {
+		if err := c.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(ch.From); err != nil {
+		return err
+	}
+	for _, to := range ch.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
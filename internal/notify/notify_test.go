@@ -0,0 +1,174 @@
+package notify
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fishfisher/homeyctl/internal/config"
+)
+
+var testMessage = Message{
+	ID:      "n1",
+	Title:   "Homey",
+	Message: "Smoke detected in the kitchen",
+	Source:  "homey:manager:alarms",
+	Time:    time.Date(2026, 3, 2, 7, 0, 0, 0, time.UTC),
+}
+
+type capture struct {
+	method, body string
+	header       http.Header
+}
+
+func captureServer(t *testing.T, status int) (*httptest.Server, *capture) {
+	t.Helper()
+	c := &capture{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		c.method, c.body, c.header = r.Method, string(body), r.Header
+		w.WriteHeader(status)
+		io.WriteString(w, "nope")
+	}))
+	t.Cleanup(server.Close)
+	return server, c
+}
+
+func TestSendNtfy(t *testing.T) {
+	server, got := captureServer(t, http.StatusOK)
+	ch := config.NotifyChannel{Type: "ntfy", URL: server.URL + "/homey", Priority: "urgent", Tags: []string{"rotating_light"}, Token: "tk"}
+	if err := Send(ch, testMessage); err != nil {
+		t.Fatal(err)
+	}
+	if got.body != testMessage.Message || got.header.Get("Title") != "Homey" || got.header.Get("Priority") != "urgent" ||
+		got.header.Get("Tags") != "rotating_light" || got.header.Get("Authorization") != "Bearer tk" {
+		t.Errorf("unexpected request: %+v", got)
+	}
+}
+
+func TestSendWebhook(t *testing.T) {
+	server, got := captureServer(t, http.StatusNoContent)
+	ch := config.NotifyChannel{Type: "webhook", URL: server.URL, Template: `{"text": {{json (printf "%s: %s" .Title .Message)}}}`, Headers: map[string]string{"x-test": "1"}}
+	if err := Send(ch, testMessage); err != nil {
+		t.Fatal(err)
+	}
+	if got.method != "POST" || got.body != `{"text": "Homey: Smoke detected in the kitchen"}` || got.header.Get("X-Test") != "1" || got.header.Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected request: %+v", got)
+	}
+
+	ch.Template = ""
+	if err := Send(ch, testMessage); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got.body, `"source": "homey:manager:alarms"`) || !strings.Contains(got.body, `"time": "2026-03-02T07:00:00Z"`) {
+		t.Errorf("unexpected default body: %s", got.body)
+	}
+
+	failing, _ := captureServer(t, http.StatusForbidden)
+	ch.URL = failing.URL
+	if err := Send(ch, testMessage); err == nil || !strings.Contains(err.Error(), "403 Forbidden: nope") {
+		t.Errorf("expected HTTP error, got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		ch   config.NotifyChannel
+		want string
+	}{
+		{config.NotifyChannel{}, "no type"},
+		{config.NotifyChannel{Type: "pigeon"}, "unknown type"},
+		{config.NotifyChannel{Type: "smtp", Host: "mail"}, "needs host, from and to"},
+		{config.NotifyChannel{Type: "ntfy"}, "needs url"},
+		{config.NotifyChannel{Type: "webhook", URL: "http://x", Template: "{{.Nope}}"}, "template"},
+		{config.NotifyChannel{Type: "webhook", URL: "http://x", Template: "{{json .Message}}"}, ""},
+	}
+	for _, tt := range tests {
+		err := Validate("test", tt.ch)
+		if (tt.want == "") != (err == nil) || (err != nil && !strings.Contains(err.Error(), tt.want)) {
+			t.Errorf("Validate(%+v) = %v, want %q", tt.ch, err, tt.want)
+		}
+	}
+}
+
+// fakeSMTP accepts one message and returns the DATA section
+func fakeSMTP(t *testing.T) (int, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	data := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { io.WriteString(conn, s+"\r\n") }
+		reply("220 fake ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"):
+				reply("250-fake")
+				reply("250 AUTH PLAIN")
+			case strings.HasPrefix(cmd, "AUTH"):
+				reply("235 ok")
+			case cmd == "DATA":
+				reply("354 go on")
+				var body strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil || l == ".\r\n" {
+						break
+					}
+					body.WriteString(l)
+				}
+				data <- body.String()
+				reply("250 queued")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port, data
+}
+
+func TestSendSMTP(t *testing.T) {
+	port, data := fakeSMTP(t)
+	ch := config.NotifyChannel{
+		// PlainAuth only allows unencrypted connections to localhost
+		Type: "smtp", Host: "localhost", Port: port, Username: "homey", Password: "pw",
+		From: "homey@example.com", To: []string{"ann@example.com", "bob@example.com"},
+		Subject: "{{.Title}} alarm: {{.Message}} ✓",
+	}
+	if err := Send(ch, testMessage); err != nil {
+		t.Fatal(err)
+	}
+	email := <-data
+	for _, want := range []string{
+		"To: ann@example.com, bob@example.com\r\n",
+		"Subject: =?utf-8?q?Homey_alarm=3A_Smoke_detected_in_the_kitchen_=E2=9C=93?=\r\n",
+		"Content-Type: text/plain; charset=utf-8\r\n",
+		"\r\n\r\nSmoke detected in the kitchen\r\n\r\n-- \r\nhomey:manager:alarms\r\n",
+	} {
+		if !strings.Contains(email, want) {
+			t.Errorf("email missing %q:\n%s", want, email)
+		}
+	}
+}
@@ -0,0 +1,476 @@
+package rules
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/fishfisher/homeyctl/internal/expr"
+)
+
+// Event kinds
+const (
+	KindCapability = "capability"
+	KindVariable   = "variable"
+	KindPresence   = "presence"
+	KindPrice      = "price"
+	KindTick       = "tick"
+)
+
+// Event is a value seen on the Homey at a point in time. Name is the device,
+// variable or user name; Property is the capability ID, or "present" or
+// "asleep" for presence. Events of kind "tick" only advance time.
+type Event struct {
+	Time     time.Time   `json:"time"`
+	Kind     string      `json:"kind"`
+	Name     string      `json:"name,omitempty"`
+	Property string      `json:"property,omitempty"`
+	Value    interface{} `json:"value,omitempty"`
+}
+
+// Actions carries out what rules do
+type Actions interface {
+	SetCapability(device, capability string, value interface{}) error
+	TriggerFlow(name string) error
+	SetMood(name string) error
+	SendNotification(text string) error
+}
+
+// Firing is a rule that triggered. Skipped says why its actions did not
+// run; Errors lists actions or conditions that failed.
+type Firing struct {
+	Time    time.Time `json:"time"`
+	Rule    string    `json:"rule"`
+	Trigger string    `json:"trigger"`
+	Skipped string    `json:"skipped,omitempty"`
+	Actions []string  `json:"actions,omitempty"`
+	Errors  []string  `json:"errors,omitempty"`
+}
+
+type stateKey struct {
+	kind, name, property string
+}
+
+func keyOf(kind, name, property string) stateKey {
+	return stateKey{kind, strings.ToLower(name), property}
+}
+
+type stateValue struct {
+	name  string
+	value interface{}
+}
+
+type triggerState struct {
+	trigger *Trigger
+	rule    *ruleState
+	key     stateKey
+
+	seen            bool
+	active          bool
+	fired           bool
+	since           time.Time
+	value, previous interface{}
+}
+
+type ruleState struct {
+	rule      *Rule
+	lastFired time.Time
+	triggers  []*triggerState
+}
+
+// Engine evaluates rules against events. It is not safe for concurrent use.
+type Engine struct {
+	actions  Actions
+	rules    []*ruleState
+	values   map[stateKey]stateValue
+	lastTick time.Time
+}
+
+// New creates an engine for the enabled rules in f
+func New(f *File, actions Actions) *Engine {
+	e := &Engine{actions: actions, values: map[stateKey]stateValue{}}
+	for _, r := range f.Rules {
+		if !r.IsEnabled() {
+			continue
+		}
+		rs := &ruleState{rule: r}
+		for _, t := range r.When {
+			rs.triggers = append(rs.triggers, &triggerState{trigger: t, rule: rs, key: t.key()})
+		}
+		e.rules = append(e.rules, rs)
+	}
+	return e
+}
+
+// Watches reports which kinds of events the rules need, so a poller can
+// skip the rest
+func (e *Engine) Watches(kind string) bool {
+	for _, rs := range e.rules {
+		for _, ts := range rs.triggers {
+			if ts.key.kind == kind {
+				return true
+			}
+		}
+		// Conditions may read any state, so only skip what no trigger or
+		// condition mentions
+		for _, c := range rs.rule.conditions {
+			for _, name := range c.Names() {
+				if envNames[name] == kind {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// envNames maps the top-level names in conditions to the event kind that
+// provides them
+var envNames = map[string]string{
+	"device": KindCapability,
+	"vars":   KindVariable,
+	"user":   KindPresence,
+	"price":  KindPrice,
+}
+
+func (t *Trigger) key() stateKey {
+	switch {
+	case t.Device != "":
+		return keyOf(KindCapability, t.Device, t.Capability)
+	case t.Variable != "":
+		return keyOf(KindVariable, t.Variable, "")
+	case t.Presence != "":
+		if t.Becomes == "asleep" || t.Becomes == "awake" {
+			return keyOf(KindPresence, t.Presence, "asleep")
+		}
+		return keyOf(KindPresence, t.Presence, "present")
+	case t.Price != "":
+		return keyOf(KindPrice, "", "")
+	}
+	return stateKey{kind: KindTick}
+}
+
+// Handle processes one event and returns the rules it fired, including
+// delayed rules that came due before the event's time
+func (e *Engine) Handle(ev Event) []Firing {
+	firings := e.Tick(ev.Time)
+	if ev.Kind == KindTick {
+		return firings
+	}
+
+	key := keyOf(ev.Kind, ev.Name, ev.Property)
+	e.values[key] = stateValue{name: ev.Name, value: ev.Value}
+
+	for _, rs := range e.rules {
+		for _, ts := range rs.triggers {
+			if ts.key != key {
+				continue
+			}
+			if f, ok := e.update(ts, ev); ok {
+				firings = append(firings, f)
+			}
+		}
+	}
+	return firings
+}
+
+// update applies a new value to a trigger and fires it if due
+func (e *Engine) update(ts *triggerState, ev Event) (Firing, bool) {
+	t := ts.trigger
+	first := !ts.seen
+	changed := first || !equalValues(ts.value, ev.Value)
+	ts.seen = true
+	if changed {
+		ts.previous, ts.value = ts.value, ev.Value
+	}
+
+	// Without a condition, every change after the first value fires
+	if t.Becomes == nil && t.Above == nil && t.Below == nil {
+		if changed && !first {
+			return e.fire(ts, ev.Time), true
+		}
+		return Firing{}, false
+	}
+
+	active := t.holds(ev.Value, ts.active)
+	switch {
+	case active && !ts.active:
+		ts.active, ts.since, ts.fired = true, ev.Time, false
+		// The first value only sets the starting state; a condition that
+		// already holds is not an edge, but "for" still counts from now
+		if first || t.For != nil {
+			return Firing{}, false
+		}
+		ts.fired = true
+		return e.fire(ts, ev.Time), true
+	case !active:
+		ts.active, ts.fired = false, false
+	}
+	return Firing{}, false
+}
+
+// holds reports whether the trigger's condition is met by v. For above and
+// below, active is the current state so hysteresis can keep it on.
+func (t *Trigger) holds(v interface{}, active bool) bool {
+	if t.Presence != "" {
+		b, _ := v.(bool)
+		switch t.Becomes {
+		case "home", "asleep":
+			return b
+		default:
+			return !b
+		}
+	}
+	if t.Becomes != nil {
+		return equalValues(v, t.Becomes)
+	}
+	n, ok := toNumber(v)
+	if !ok {
+		return false
+	}
+	if t.Above != nil {
+		if active {
+			return n > *t.Above-t.Hysteresis
+		}
+		return n > *t.Above
+	}
+	if active {
+		return n < *t.Below+t.Hysteresis
+	}
+	return n < *t.Below
+}
+
+// Tick advances time to now, firing "for" delays and time triggers that
+// came due, in order
+func (e *Engine) Tick(now time.Time) []Firing {
+	type due struct {
+		at time.Time
+		ts *triggerState
+	}
+	var pending []due
+
+	for _, rs := range e.rules {
+		for _, ts := range rs.triggers {
+			t := ts.trigger
+			if t.For != nil && ts.active && !ts.fired {
+				if at := ts.since.Add(t.For.Duration); !at.After(now) {
+					ts.fired = true
+					pending = append(pending, due{at, ts})
+				}
+			}
+			if t.Time != "" && !e.lastTick.IsZero() {
+				for _, at := range t.occurrences(e.lastTick, now) {
+					pending = append(pending, due{at, ts})
+				}
+			}
+		}
+	}
+	if now.After(e.lastTick) {
+		e.lastTick = now
+	}
+
+	sort.SliceStable(pending, func(i, j int) bool { return pending[i].at.Before(pending[j].at) })
+	var firings []Firing
+	for _, p := range pending {
+		firings = append(firings, e.fire(p.ts, p.at))
+	}
+	return firings
+}
+
+// occurrences lists the times in (from, to] at which a time trigger fires
+func (t *Trigger) occurrences(from, to time.Time) []time.Time {
+	var out []time.Time
+	from, to = from.Local(), to.Local()
+	// Long gaps (a suspended laptop) only catch up on the last week
+	start := from
+	if to.Sub(start) > 7*24*time.Hour {
+		start = to.Add(-7 * 24 * time.Hour)
+	}
+	for day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.Local); !day.After(to); day = day.AddDate(0, 0, 1) {
+		at := time.Date(day.Year(), day.Month(), day.Day(), t.hour, t.minute, 0, 0, time.Local)
+		if at.After(from) && !at.After(to) && (t.days == nil || t.days[at.Weekday()]) {
+			out = append(out, at)
+		}
+	}
+	return out
+}
+
+func (e *Engine) fire(ts *triggerState, at time.Time) Firing {
+	rs := ts.rule
+	r := rs.rule
+	f := Firing{Time: at, Rule: r.Name, Trigger: ts.trigger.Describe()}
+
+	if r.Debounce != nil && !rs.lastFired.IsZero() && at.Sub(rs.lastFired) < r.Debounce.Duration {
+		f.Skipped = fmt.Sprintf("debounced (last ran %s earlier)", at.Sub(rs.lastFired).Round(time.Second))
+		return f
+	}
+
+	env := e.env(at, ts)
+	for i, c := range r.conditions {
+		v, err := c.Eval(env)
+		if err != nil {
+			f.Skipped = "condition failed"
+			f.Errors = append(f.Errors, fmt.Sprintf("%s: %v", r.If[i], err))
+			return f
+		}
+		if !expr.Truthy(v) {
+			f.Skipped = "condition not met: " + r.If[i]
+			return f
+		}
+	}
+
+	rs.lastFired = at
+	for _, a := range r.Then {
+		desc, err := e.run(a, env)
+		f.Actions = append(f.Actions, desc)
+		if err != nil {
+			f.Errors = append(f.Errors, fmt.Sprintf("%s: %v", desc, err))
+		}
+	}
+	return f
+}
+
+func (e *Engine) run(a Action, env expr.Env) (string, error) {
+	switch {
+	case a.Set != nil:
+		value := a.Set.Value
+		if a.Set.expr != nil {
+			v, err := a.Set.expr.Eval(env)
+			desc := fmt.Sprintf("set %s.%s = %s", a.Set.Device, a.Set.Capability, a.Set.Expr)
+			if err != nil {
+				return desc, err
+			}
+			value = v
+		}
+		desc := fmt.Sprintf("set %s.%s = %s", a.Set.Device, a.Set.Capability, formatValue(value))
+		return desc, e.actions.SetCapability(a.Set.Device, a.Set.Capability, value)
+	case a.Flow != "":
+		desc := "trigger flow " + a.Flow
+		return desc, e.actions.TriggerFlow(a.Flow)
+	case a.Mood != "":
+		desc := "set mood " + a.Mood
+		return desc, e.actions.SetMood(a.Mood)
+	default:
+		text, err := renderTemplate(a.Notify, env)
+		if err != nil {
+			return "notify " + strconv.Quote(a.Notify), err
+		}
+		return "notify " + strconv.Quote(text), e.actions.SendNotification(text)
+	}
+}
+
+// env exposes the current state to conditions and templates:
+//
+//	device.<device>.<capability>, vars.<variable>,
+//	user.<user>.present / .asleep, price,
+//	time.hour / .minute / .weekday, value and previous
+//
+// Names are lowercased with runs of other characters replaced by "_",
+// so "Hall sensor" becomes hall_sensor.
+func (e *Engine) env(at time.Time, ts *triggerState) expr.Env {
+	devices := map[string]interface{}{}
+	vars := map[string]interface{}{}
+	users := map[string]interface{}{}
+	var price interface{}
+
+	nested := func(m map[string]interface{}, name string) map[string]interface{} {
+		id := Identifier(name)
+		inner, ok := m[id].(map[string]interface{})
+		if !ok {
+			inner = map[string]interface{}{}
+			m[id] = inner
+		}
+		return inner
+	}
+	for k, v := range e.values {
+		switch k.kind {
+		case KindCapability:
+			nested(devices, v.name)[k.property] = v.value
+		case KindVariable:
+			vars[Identifier(v.name)] = v.value
+		case KindPresence:
+			nested(users, v.name)[k.property] = v.value
+		case KindPrice:
+			price = v.value
+		}
+	}
+
+	local := at.Local()
+	return expr.Env{
+		"device": devices,
+		"vars":   vars,
+		"user":   users,
+		"price":  price,
+		"time": map[string]interface{}{
+			"hour":    float64(local.Hour()),
+			"minute":  float64(local.Minute()),
+			"weekday": strings.ToLower(local.Weekday().String()[:3]),
+		},
+		"value":    ts.value,
+		"previous": ts.previous,
+	}
+}
+
+// Identifier turns a device, variable or user name into the name used in
+// expressions: "Hall sensor" becomes "hall_sensor"
+func Identifier(name string) string {
+	var sb strings.Builder
+	underscore := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			sb.WriteRune(r)
+			underscore = false
+		} else if !underscore && sb.Len() > 0 {
+			sb.WriteRune('_')
+			underscore = true
+		}
+	}
+	id := strings.TrimSuffix(sb.String(), "_")
+	if id != "" && unicode.IsDigit([]rune(id)[0]) {
+		id = "_" + id
+	}
+	return id
+}
+
+func toNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// equalValues compares event values, treating YAML integers and JSON
+// floats as the same number
+func equalValues(a, b interface{}) bool {
+	an, aok := toNumber(a)
+	bn, bok := toNumber(b)
+	if aok && bok {
+		_, as := a.(string)
+		_, bs := b.(string)
+		if as == bs {
+			return an == bn
+		}
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+func formatValue(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return "null"
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case string:
+		return x
+	}
+	return fmt.Sprint(v)
+}
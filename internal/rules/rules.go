@@ -0,0 +1,387 @@
+// Package rules is a small event-driven automation engine. Rules are read
+// from YAML; each rule has triggers on capability, variable, presence,
+// price or time changes, conditions written as expressions over the current
+// state, and actions that set capabilities, trigger flows, set moods or
+// send notifications.
+//
+//	rules:
+//	  - name: Hall light off
+//	    when:
+//	      device: Hall sensor
+//	      capability: alarm_motion
+//	      becomes: false
+//	      for: 20m
+//	    if: time.hour >= 7
+//	    then:
+//	      - set: { device: Hall light, capability: onoff, value: false }
+//
+// The engine is fed Events, from polling a Homey or from a recorded log,
+// and Ticks that advance time for "for" delays and time triggers.
+package rules
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/fishfisher/homeyctl/internal/expr"
+	"github.com/fishfisher/homeyctl/internal/yamltime"
+	"go.yaml.in/yaml/v3"
+)
+
+// File is a rules file
+type File struct {
+	Rules []*Rule `yaml:"rules"`
+}
+
+// Rule is one automation
+type Rule struct {
+	Name     string             `yaml:"name"`
+	Enabled  *bool              `yaml:"enabled"`
+	When     Triggers           `yaml:"when"`
+	If       Exprs              `yaml:"if"`
+	Then     []Action           `yaml:"then"`
+	Debounce *yamltime.Duration `yaml:"debounce"`
+
+	conditions []*expr.Expr
+}
+
+// Trigger watches one value. Exactly one of Device (with Capability),
+// Variable, Presence, Price or Time selects what is watched.
+//
+// Becomes, Above and Below turn the value into an on/off condition and the
+// trigger fires when it turns on; without them it fires on every change.
+// Hysteresis keeps an Above/Below condition on until the value has moved
+// back past the threshold by that margin. For requires the condition to
+// hold for a while before firing, e.g. "no motion for 20 minutes".
+type Trigger struct {
+	Device     string             `yaml:"device"`
+	Capability string             `yaml:"capability"`
+	Variable   string             `yaml:"variable"`
+	Presence   string             `yaml:"presence"`
+	Price      string             `yaml:"price"`
+	Time       string             `yaml:"time"`
+	Days       []string           `yaml:"days"`
+	Becomes    interface{}        `yaml:"becomes"`
+	Above      *float64           `yaml:"above"`
+	Below      *float64           `yaml:"below"`
+	Hysteresis float64            `yaml:"hysteresis"`
+	For        *yamltime.Duration `yaml:"for"`
+
+	hour, minute int
+	days         map[time.Weekday]bool
+}
+
+// Action is one thing a rule does. Exactly one field is set.
+type Action struct {
+	Set    *SetAction `yaml:"set"`
+	Flow   string     `yaml:"flow"`
+	Mood   string     `yaml:"mood"`
+	Notify string     `yaml:"notify"`
+}
+
+// SetAction sets a device capability to Value, or to the result of Expr
+type SetAction struct {
+	Device     string      `yaml:"device"`
+	Capability string      `yaml:"capability"`
+	Value      interface{} `yaml:"value"`
+	Expr       string      `yaml:"expr"`
+
+	expr *expr.Expr
+}
+
+// Triggers accepts a single trigger or a list
+type Triggers []*Trigger
+
+func (t *Triggers) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.MappingNode {
+		var one Trigger
+		if err := node.Decode(&one); err != nil {
+			return err
+		}
+		*t = Triggers{&one}
+		return nil
+	}
+	var list []*Trigger
+	if err := node.Decode(&list); err != nil {
+		return err
+	}
+	*t = list
+	return nil
+}
+
+// Exprs accepts a single expression or a list, all of which must hold
+type Exprs []string
+
+func (e *Exprs) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*e = Exprs{node.Value}
+		return nil
+	}
+	var list []string
+	if err := node.Decode(&list); err != nil {
+		return err
+	}
+	*e = list
+	return nil
+}
+
+// Load reads and validates a rules file
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse parses and validates rules
+func Parse(data []byte) (*File, error) {
+	var f File
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid YAML: %w", err)
+	}
+	if len(f.Rules) == 0 {
+		return nil, fmt.Errorf("no rules defined")
+	}
+	names := map[string]bool{}
+	for i, r := range f.Rules {
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule %d", i+1)
+		}
+		if names[r.Name] {
+			return nil, fmt.Errorf("duplicate rule name %q", r.Name)
+		}
+		names[r.Name] = true
+		if err := r.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", r.Name, err)
+		}
+	}
+	return &f, nil
+}
+
+// IsEnabled reports whether the rule should run
+func (r *Rule) IsEnabled() bool {
+	return r.Enabled == nil || *r.Enabled
+}
+
+func (r *Rule) validate() error {
+	if len(r.When) == 0 {
+		return fmt.Errorf("no trigger (when)")
+	}
+	for _, t := range r.When {
+		if err := t.validate(); err != nil {
+			return err
+		}
+	}
+	for _, src := range r.If {
+		e, err := expr.Parse(src)
+		if err != nil {
+			return fmt.Errorf("invalid condition %q: %w", src, err)
+		}
+		r.conditions = append(r.conditions, e)
+	}
+	if len(r.Then) == 0 {
+		return fmt.Errorf("no actions (then)")
+	}
+	for i := range r.Then {
+		if err := r.Then[i].validate(); err != nil {
+			return fmt.Errorf("action %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+var timeOfDay = regexp.MustCompile(`^([01]?\d|2[0-3]):([0-5]\d)$`)
+
+// weekdays maps full day names and their three-letter abbreviations
+var weekdays = map[string]time.Weekday{}
+
+func init() {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		weekdays[name] = d
+		weekdays[name[:3]] = d
+	}
+}
+
+func (t *Trigger) validate() error {
+	sources := 0
+	for _, set := range []bool{t.Device != "", t.Variable != "", t.Presence != "", t.Price != "", t.Time != ""} {
+		if set {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return fmt.Errorf("a trigger needs exactly one of device, variable, presence, price or time")
+	}
+	if t.Device != "" && t.Capability == "" {
+		return fmt.Errorf("device trigger on %q needs a capability", t.Device)
+	}
+	if t.Above != nil && t.Below != nil {
+		return fmt.Errorf("use either above or below in one trigger, not both")
+	}
+	if t.Becomes != nil && (t.Above != nil || t.Below != nil) {
+		return fmt.Errorf("use either becomes or above/below in one trigger, not both")
+	}
+	if t.Hysteresis < 0 {
+		return fmt.Errorf("hysteresis cannot be negative")
+	}
+	if t.Hysteresis > 0 && t.Above == nil && t.Below == nil {
+		return fmt.Errorf("hysteresis needs above or below")
+	}
+	if t.For != nil && t.Becomes == nil && t.Above == nil && t.Below == nil {
+		return fmt.Errorf("for needs becomes, above or below")
+	}
+
+	if t.Price != "" && t.Price != "now" {
+		return fmt.Errorf("invalid price %q (use price: now)", t.Price)
+	}
+
+	if t.Presence != "" {
+		switch t.Becomes {
+		case "home", "away", "asleep", "awake":
+		default:
+			return fmt.Errorf("presence trigger needs becomes: home, away, asleep or awake")
+		}
+	}
+
+	if t.Time != "" {
+		m := timeOfDay.FindStringSubmatch(t.Time)
+		if m == nil {
+			return fmt.Errorf("invalid time %q (use HH:MM)", t.Time)
+		}
+		fmt.Sscanf(m[1], "%d", &t.hour)
+		fmt.Sscanf(m[2], "%d", &t.minute)
+		if t.Becomes != nil || t.Above != nil || t.Below != nil || t.For != nil {
+			return fmt.Errorf("time triggers take only time and days")
+		}
+	} else if len(t.Days) > 0 {
+		return fmt.Errorf("days only apply to time triggers")
+	}
+	if len(t.Days) > 0 {
+		t.days = map[time.Weekday]bool{}
+		for _, d := range t.Days {
+			wd, ok := weekdays[strings.ToLower(d)]
+			if !ok {
+				return fmt.Errorf("invalid day %q", d)
+			}
+			t.days[wd] = true
+		}
+	}
+	return nil
+}
+
+func (a *Action) validate() error {
+	count := 0
+	for _, set := range []bool{a.Set != nil, a.Flow != "", a.Mood != "", a.Notify != ""} {
+		if set {
+			count++
+		}
+	}
+	if count != 1 {
+		return fmt.Errorf("an action needs exactly one of set, flow, mood or notify")
+	}
+	if a.Notify != "" {
+		if _, err := parseTemplate(a.Notify); err != nil {
+			return err
+		}
+	}
+	if s := a.Set; s != nil {
+		if s.Device == "" || s.Capability == "" {
+			return fmt.Errorf("set needs device and capability")
+		}
+		if (s.Value == nil) == (s.Expr == "") {
+			return fmt.Errorf("set needs either value or expr")
+		}
+		if s.Expr != "" {
+			e, err := expr.Parse(s.Expr)
+			if err != nil {
+				return fmt.Errorf("invalid expr %q: %w", s.Expr, err)
+			}
+			s.expr = e
+		}
+	}
+	return nil
+}
+
+// Describe returns a short human description of a trigger
+func (t *Trigger) Describe() string {
+	var subject string
+	switch {
+	case t.Device != "":
+		subject = t.Device + "." + t.Capability
+	case t.Variable != "":
+		subject = "variable " + t.Variable
+	case t.Presence != "":
+		subject = t.Presence
+	case t.Price != "":
+		subject = "price"
+	case t.Time != "":
+		s := "at " + t.Time
+		if len(t.Days) > 0 {
+			s += " on " + strings.Join(t.Days, ",")
+		}
+		return s
+	}
+	switch {
+	case t.Becomes != nil:
+		subject += fmt.Sprintf(" becomes %v", t.Becomes)
+	case t.Above != nil:
+		subject += fmt.Sprintf(" above %v", *t.Above)
+	case t.Below != nil:
+		subject += fmt.Sprintf(" below %v", *t.Below)
+	default:
+		subject += " changes"
+	}
+	if t.For != nil {
+		subject += " for " + t.For.String()
+	}
+	return subject
+}
+
+// templatePart is literal text or an expression in {{ }}
+type templatePart struct {
+	text string
+	expr *expr.Expr
+}
+
+var templateExpr = regexp.MustCompile(`\{\{(.*?)\}\}`)
+
+func parseTemplate(s string) ([]templatePart, error) {
+	var parts []templatePart
+	last := 0
+	for _, m := range templateExpr.FindAllStringSubmatchIndex(s, -1) {
+		parts = append(parts, templatePart{text: s[last:m[0]]})
+		e, err := expr.Parse(strings.TrimSpace(s[m[2]:m[3]]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid template expression %q: %w", s[m[0]:m[1]], err)
+		}
+		parts = append(parts, templatePart{expr: e})
+		last = m[1]
+	}
+	return append(parts, templatePart{text: s[last:]}), nil
+}
+
+// renderTemplate fills in {{ expression }} placeholders
+func renderTemplate(s string, env expr.Env) (string, error) {
+	parts, err := parseTemplate(s)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for _, p := range parts {
+		if p.expr == nil {
+			sb.WriteString(p.text)
+			continue
+		}
+		v, err := p.expr.Eval(env)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(formatValue(v))
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,263 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+type recorder struct {
+	calls []string
+	fail  bool
+}
+
+func (r *recorder) record(format string, args ...interface{}) error {
+	r.calls = append(r.calls, fmt.Sprintf(format, args...))
+	if r.fail {
+		return fmt.Errorf("offline")
+	}
+	return nil
+}
+
+func (r *recorder) SetCapability(device, capability string, value interface{}) error {
+	return r.record("set %s.%s=%v", device, capability, value)
+}
+func (r *recorder) TriggerFlow(name string) error      { return r.record("flow %s", name) }
+func (r *recorder) SetMood(name string) error          { return r.record("mood %s", name) }
+func (r *recorder) SendNotification(text string) error { return r.record("notify %s", text) }
+
+func mustParse(t *testing.T, src string) *File {
+	t.Helper()
+	f, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	return f
+}
+
+var t0 = time.Date(2026, 3, 2, 12, 0, 0, 0, time.Local) // a Monday
+
+func at(d time.Duration) time.Time { return t0.Add(d) }
+
+func capEvent(d time.Duration, device, capability string, value interface{}) Event {
+	return Event{Time: at(d), Kind: KindCapability, Name: device, Property: capability, Value: value}
+}
+
+func ran(firings []Firing) []string {
+	var out []string
+	for _, f := range firings {
+		if f.Skipped == "" {
+			out = append(out, f.Rule)
+		}
+	}
+	return out
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`rules: []`, "no rules defined"},
+		{"rules:\n  - then: [{flow: x}]", "no trigger"},
+		{"rules:\n  - when: {device: a}\n    then: [{flow: x}]", "needs a capability"},
+		{"rules:\n  - when: {device: a, capability: b, variable: c}\n    then: [{flow: x}]", "exactly one of device"},
+		{"rules:\n  - when: {device: a, capability: b}", "no actions"},
+		{"rules:\n  - when: {presence: Ann, becomes: out}\n    then: [{flow: x}]", "home, away, asleep or awake"},
+		{"rules:\n  - when: {time: '25:00'}\n    then: [{flow: x}]", "invalid time"},
+		{"rules:\n  - when: {time: '07:00', days: [funday]}\n    then: [{flow: x}]", "invalid day"},
+		{"rules:\n  - when: {time: '07:00', days: [\"\u212a\"]}\n    then: [{flow: x}]", "invalid day"},
+		{"rules:\n  - when: {time: '07:00', days: [monkey]}\n    then: [{flow: x}]", "invalid day"},
+		{"rules:\n  - when: {price: tomorrow}\n    then: [{flow: x}]", "use price: now"},
+		{"rules:\n  - when: {price: now, hysteresis: 1}\n    then: [{flow: x}]", "hysteresis needs above or below"},
+		{"rules:\n  - when: {price: now, for: 5m}\n    then: [{flow: x}]", "for needs becomes"},
+		{"rules:\n  - when: {price: now, for: soon, above: 1}\n    then: [{flow: x}]", "invalid duration"},
+		{"rules:\n  - when: {price: now}\n    if: 'price >'\n    then: [{flow: x}]", "invalid condition"},
+		{"rules:\n  - when: {price: now}\n    then: [{flow: x, mood: y}]", "exactly one of set"},
+		{"rules:\n  - when: {price: now}\n    then: [{set: {device: a, capability: b}}]", "either value or expr"},
+		{"rules:\n  - when: {price: now}\n    then: [{notify: 'hi {{ + }}'}]", "invalid template"},
+		{"rules:\n  - {name: a, when: {price: now}, then: [{flow: x}]}\n  - {name: a, when: {price: now}, then: [{flow: x}]}", "duplicate rule name"},
+	}
+	for _, tt := range tests {
+		_, err := Parse([]byte(tt.src))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Parse(%q) = %v, want error containing %q", tt.src, err, tt.want)
+		}
+	}
+}
+
+func TestNoMotionFor(t *testing.T) {
+	f := mustParse(t, `
+rules:
+  - name: Hall light off
+    when:
+      device: Hall sensor
+      capability: alarm_motion
+      becomes: false
+      for: 20m
+    then:
+      - set: { device: Hall light, capability: onoff, value: false }
+`)
+	rec := &recorder{}
+	e := New(f, rec)
+
+	e.Handle(capEvent(0, "Hall sensor", "alarm_motion", true))
+	e.Handle(capEvent(time.Minute, "Hall sensor", "alarm_motion", false))
+	// Motion again before the 20 minutes are up restarts the wait
+	if got := ran(e.Handle(capEvent(15*time.Minute, "Hall sensor", "alarm_motion", true))); len(got) != 0 {
+		t.Fatalf("fired early: %v", got)
+	}
+	e.Handle(capEvent(16*time.Minute, "Hall sensor", "alarm_motion", false))
+	if got := ran(e.Tick(at(35 * time.Minute))); len(got) != 0 {
+		t.Fatalf("fired before 20m without motion: %v", got)
+	}
+	firings := e.Tick(at(40 * time.Minute))
+	if len(firings) != 1 || !firings[0].Time.Equal(at(36*time.Minute)) {
+		t.Fatalf("expected one firing at +36m, got %+v", firings)
+	}
+	if got := e.Tick(at(time.Hour)); len(got) != 0 {
+		t.Errorf("fired twice for one quiet period: %+v", got)
+	}
+	if strings.Join(rec.calls, ",") != "set Hall light.onoff=false" {
+		t.Errorf("unexpected calls: %v", rec.calls)
+	}
+	if firings[0].Trigger != "Hall sensor.alarm_motion becomes false for 20m0s" {
+		t.Errorf("unexpected trigger description %q", firings[0].Trigger)
+	}
+}
+
+func TestHysteresisAndDebounce(t *testing.T) {
+	f := mustParse(t, `
+rules:
+  - name: Too warm
+    when: { device: Office, capability: measure_temperature, above: 24, hysteresis: 1 }
+    then: [{ flow: Cool down }]
+  - name: Price spike
+    when: { price: now, above: 2 }
+    debounce: 1h
+    then: [{ mood: Saver }]
+`)
+	e := New(f, &recorder{})
+
+	var fired []string
+	for i, v := range []float64{22, 24.5, 23.5, 24.5, 22.9, 24.2} {
+		fired = append(fired, ran(e.Handle(capEvent(time.Duration(i)*time.Minute, "office", "measure_temperature", v)))...)
+	}
+	// 24.5 fires; 23.5 stays on within the margin; 22.9 resets; 24.2 fires again
+	if len(fired) != 2 {
+		t.Errorf("expected 2 firings with hysteresis, got %v", fired)
+	}
+
+	price := func(d time.Duration, v float64) []Firing {
+		return e.Handle(Event{Time: at(d), Kind: KindPrice, Value: v})
+	}
+	price(0, 1)
+	if got := ran(price(time.Minute, 3)); len(got) != 1 {
+		t.Fatalf("expected price spike, got %v", got)
+	}
+	price(2*time.Minute, 1)
+	got := price(3*time.Minute, 3)
+	if len(got) != 1 || !strings.HasPrefix(got[0].Skipped, "debounced") {
+		t.Errorf("expected debounced firing, got %+v", got)
+	}
+	price(4*time.Minute, 1)
+	if got := ran(price(2*time.Hour, 3)); len(got) != 1 {
+		t.Errorf("expected firing after debounce, got %v", got)
+	}
+}
+
+func TestConditionsAndTemplates(t *testing.T) {
+	f := mustParse(t, `
+rules:
+  - name: Welcome
+    when: { presence: Ann, becomes: home }
+    if:
+      - vars.guest_mode == false
+      - device.hall_light.onoff == false
+    then:
+      - notify: "Welcome {{ value ? 'home' : 'out' }}, it is {{ device.office.measure_temperature }}°"
+      - set: { device: Hall light, capability: dim, expr: "min(1, price / 2)" }
+`)
+	rec := &recorder{}
+	e := New(f, rec)
+	for _, ev := range []Event{
+		{Time: at(0), Kind: KindVariable, Name: "Guest mode", Value: true},
+		{Time: at(0), Kind: KindCapability, Name: "Hall light", Property: "onoff", Value: false},
+		{Time: at(0), Kind: KindCapability, Name: "Office", Property: "measure_temperature", Value: 21.5},
+		{Time: at(0), Kind: KindPrice, Value: 1.0},
+		{Time: at(0), Kind: KindPresence, Name: "Ann", Property: "present", Value: false},
+	} {
+		e.Handle(ev)
+	}
+
+	got := e.Handle(Event{Time: at(time.Minute), Kind: KindPresence, Name: "ann", Property: "present", Value: true})
+	if len(got) != 1 || got[0].Skipped != "condition not met: vars.guest_mode == false" {
+		t.Fatalf("expected condition to block, got %+v", got)
+	}
+
+	e.Handle(Event{Time: at(2 * time.Minute), Kind: KindVariable, Name: "Guest mode", Value: false})
+	e.Handle(Event{Time: at(3 * time.Minute), Kind: KindPresence, Name: "Ann", Property: "present", Value: false})
+	got = e.Handle(Event{Time: at(4 * time.Minute), Kind: KindPresence, Name: "Ann", Property: "present", Value: true})
+	if len(got) != 1 || got[0].Skipped != "" {
+		t.Fatalf("expected rule to run, got %+v", got)
+	}
+	want := []string{"notify Welcome home, it is 21.5°", "set Hall light.dim=0.5"}
+	if strings.Join(rec.calls, "|") != strings.Join(want, "|") {
+		t.Errorf("calls = %v, want %v", rec.calls, want)
+	}
+}
+
+func TestTimeTriggersAndErrors(t *testing.T) {
+	f := mustParse(t, `
+rules:
+  - name: Weekday morning
+    when: { time: "07:00", days: [Monday, tue, wed, thu, friday] }
+    then: [{ flow: Morning }]
+  - name: Any change
+    when: { variable: Mode }
+    if: unknown_name > 1
+    then: [{ flow: Never }]
+  - name: Disabled
+    enabled: false
+    when: { variable: Mode }
+    then: [{ flow: Never }]
+`)
+	rec := &recorder{fail: true}
+	e := New(f, rec)
+
+	sunday := time.Date(2026, 3, 1, 6, 0, 0, 0, time.Local)
+	e.Tick(sunday)
+	if got := e.Tick(sunday.Add(2 * time.Hour)); len(got) != 0 {
+		t.Errorf("fired on a Sunday: %+v", got)
+	}
+	got := e.Tick(sunday.Add(26 * time.Hour))
+	if len(got) != 1 || got[0].Time.Hour() != 7 || got[0].Time.Weekday() != time.Monday {
+		t.Fatalf("expected Monday 07:00 firing, got %+v", got)
+	}
+	if len(got[0].Errors) != 1 || !strings.Contains(got[0].Errors[0], "offline") {
+		t.Errorf("expected action error, got %+v", got[0])
+	}
+
+	e.Handle(Event{Time: sunday.Add(27 * time.Hour), Kind: KindVariable, Name: "Mode", Value: "home"})
+	got = e.Handle(Event{Time: sunday.Add(28 * time.Hour), Kind: KindVariable, Name: "Mode", Value: "away"})
+	if len(got) != 1 || got[0].Skipped != "condition failed" || len(got[0].Errors) != 1 {
+		t.Errorf("expected failing condition, got %+v", got)
+	}
+	if !e.Watches(KindVariable) || e.Watches(KindPresence) {
+		t.Error("unexpected Watches result")
+	}
+}
+
+func TestIdentifier(t *testing.T) {
+	for in, want := range map[string]string{
+		"Hall sensor":    "hall_sensor",
+		"  Living-Room ": "living_room",
+		"2nd floor":      "_2nd_floor",
+		"Kjøkken lys":    "kjøkken_lys",
+	} {
+		if got := Identifier(in); got != want {
+			t.Errorf("Identifier(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
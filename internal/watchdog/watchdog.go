@@ -0,0 +1,353 @@
+// Package watchdog decides when to restart Homey apps that hang. A config
+// file lists the apps to watch and what counts as unhealthy: not ready or
+// crashed, memory use above a limit, or devices the app drives that have
+// stopped reporting.
+//
+//	interval: 1m
+//	backoff: 2m
+//	max_restarts: 5
+//	window: 24h
+//	notify: [ntfy]
+//	apps:
+//	  - app: Tibber
+//	    memory: 150MB
+//	    stale:
+//	      - device: Tibber Pulse
+//	        capability: measure_power
+//	        for: 5m
+//
+// The Watchdog is fed the problems found for each app and answers whether
+// to restart it now. Restarts back off exponentially while an app stays
+// unhealthy, and stop when the app has used its restart budget for the
+// window.
+package watchdog
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fishfisher/homeyctl/internal/yamltime"
+	"go.yaml.in/yaml/v3"
+)
+
+// Config is a watchdog config file
+type Config struct {
+	Interval    yamltime.Duration `yaml:"interval"`     // Time between checks
+	Backoff     yamltime.Duration `yaml:"backoff"`      // Wait after the first restart, doubled after each one
+	MaxBackoff  yamltime.Duration `yaml:"max_backoff"`  // Longest wait between restarts
+	MaxRestarts int               `yaml:"max_restarts"` // Restart budget per app per window; 0 never restarts
+	Window      yamltime.Duration `yaml:"window"`
+	Notify      []string          `yaml:"notify"` // Channels to notify of each action
+	Apps        []*App            `yaml:"apps"`
+}
+
+// Defaults for settings a config leaves out
+const (
+	DefaultInterval    = time.Minute
+	DefaultBackoff     = 2 * time.Minute
+	DefaultMaxBackoff  = time.Hour
+	DefaultMaxRestarts = 5
+	DefaultWindow      = 24 * time.Hour
+)
+
+// App is one app to watch
+type App struct {
+	App    string   `yaml:"app"`    // Name or ID
+	Ready  *bool    `yaml:"ready"`  // Restart when not ready or crashed; on unless false
+	Memory ByteSize `yaml:"memory"` // Restart above this memory use; 0 for no limit
+	Stale  []Probe  `yaml:"stale"`
+}
+
+// Probe is a device capability the app should keep updating, e.g. "no
+// power reading for 5 minutes"
+type Probe struct {
+	Device     string            `yaml:"device"`
+	Capability string            `yaml:"capability"`
+	For        yamltime.Duration `yaml:"for"`
+}
+
+// ChecksReady reports whether readiness is checked
+func (a *App) ChecksReady() bool {
+	return a.Ready == nil || *a.Ready
+}
+
+// ByteSize is a memory size, written like 150MB or 1.5GB. Units are
+// powers of 1024, as in 'apps usage'.
+type ByteSize int64
+
+var byteSizePattern = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)?)\s*([KMG]?)B?$`)
+
+func (b *ByteSize) UnmarshalYAML(node *yaml.Node) error {
+	n, err := ParseByteSize(node.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	*b = n
+	return nil
+}
+
+// ParseByteSize parses a size like 150MB, 512K or 2G; a plain number is
+// bytes
+func ParseByteSize(s string) (ByteSize, error) {
+	m := byteSizePattern.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(s)))
+	if m == nil {
+		return 0, fmt.Errorf("invalid size %q (use e.g. 150MB)", s)
+	}
+	n, _ := strconv.ParseFloat(m[1], 64)
+	switch m[2] {
+	case "K":
+		n *= 1 << 10
+	case "M":
+		n *= 1 << 20
+	case "G":
+		n *= 1 << 30
+	}
+	return ByteSize(n), nil
+}
+
+func (b ByteSize) String() string {
+	return fmt.Sprintf("%.1f MB", float64(b)/(1<<20))
+}
+
+// Load reads and validates a config file
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read watchdog config: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse parses and validates a config, filling in defaults
+func Parse(data []byte) (*Config, error) {
+	var c Config
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("invalid YAML: %w", err)
+	}
+	// max_restarts: 0 turns restarts off, so only a missing value defaults
+	var set struct {
+		MaxRestarts *int `yaml:"max_restarts"`
+	}
+	yaml.Unmarshal(data, &set)
+	if len(c.Apps) == 0 {
+		return nil, fmt.Errorf("no apps defined")
+	}
+
+	if c.Interval.Duration == 0 {
+		c.Interval.Duration = DefaultInterval
+	}
+	if c.Backoff.Duration == 0 {
+		c.Backoff.Duration = DefaultBackoff
+	}
+	if c.MaxBackoff.Duration == 0 {
+		c.MaxBackoff.Duration = DefaultMaxBackoff
+	}
+	if set.MaxRestarts == nil {
+		c.MaxRestarts = DefaultMaxRestarts
+	}
+	if c.Window.Duration == 0 {
+		c.Window.Duration = DefaultWindow
+	}
+	if c.Interval.Duration < time.Second {
+		return nil, fmt.Errorf("interval must be at least 1s")
+	}
+	if c.Backoff.Duration < 0 || c.MaxBackoff.Duration < c.Backoff.Duration {
+		return nil, fmt.Errorf("max_backoff must be at least backoff")
+	}
+	if c.MaxRestarts < 0 {
+		return nil, fmt.Errorf("max_restarts cannot be negative")
+	}
+
+	seen := map[string]bool{}
+	for i, a := range c.Apps {
+		if a.App == "" {
+			return nil, fmt.Errorf("app %d: missing app name or ID", i+1)
+		}
+		if seen[strings.ToLower(a.App)] {
+			return nil, fmt.Errorf("app %q is listed twice", a.App)
+		}
+		seen[strings.ToLower(a.App)] = true
+		if a.Memory < 0 {
+			return nil, fmt.Errorf("%s: memory cannot be negative", a.App)
+		}
+		if !a.ChecksReady() && a.Memory == 0 && len(a.Stale) == 0 {
+			return nil, fmt.Errorf("%s: nothing to check (ready is off and there is no memory limit or stale probe)", a.App)
+		}
+		for j, p := range a.Stale {
+			if p.Device == "" || p.Capability == "" {
+				return nil, fmt.Errorf("%s: stale probe %d needs a device and a capability", a.App, j+1)
+			}
+			if p.For.Duration <= 0 {
+				return nil, fmt.Errorf("%s: stale probe on %s needs for, like 5m", a.App, p.Device)
+			}
+		}
+	}
+	return &c, nil
+}
+
+// Observation is what was seen of an app in one check
+type Observation struct {
+	Enabled        bool
+	Ready          bool
+	Crashed        bool
+	CrashedMessage string
+	Memory         int64       // Bytes, or -1 when unknown
+	Updated        []time.Time // Last update of each stale probe, zero when never
+}
+
+// Problems lists what is wrong with an app. Disabled apps have none.
+func (a *App) Problems(obs Observation, now time.Time) []string {
+	if !obs.Enabled {
+		return nil
+	}
+	var problems []string
+	if a.ChecksReady() {
+		switch {
+		case obs.Crashed && obs.CrashedMessage != "":
+			problems = append(problems, "crashed: "+obs.CrashedMessage)
+		case obs.Crashed:
+			problems = append(problems, "crashed")
+		case !obs.Ready:
+			problems = append(problems, "not ready")
+		}
+	}
+	if a.Memory > 0 && obs.Memory > int64(a.Memory) {
+		problems = append(problems, fmt.Sprintf("memory %s above %s", ByteSize(obs.Memory), a.Memory))
+	}
+	for i, p := range a.Stale {
+		var updated time.Time
+		if i < len(obs.Updated) {
+			updated = obs.Updated[i]
+		}
+		if updated.IsZero() {
+			problems = append(problems, fmt.Sprintf("no %s reading from %s", p.Capability, p.Device))
+		} else if age := now.Sub(updated); age > p.For.Duration {
+			problems = append(problems, fmt.Sprintf("no %s reading from %s for %s", p.Capability, p.Device, age.Truncate(time.Second)))
+		}
+	}
+	return problems
+}
+
+// Action is what the watchdog decided for an app
+type Action string
+
+const (
+	None      Action = ""          // Healthy, or waiting out a backoff
+	Restart   Action = "restart"   // Restart the app now
+	Recovered Action = "recovered" // Healthy again after restarts
+	Exhausted Action = "exhausted" // Unhealthy, but the restart budget is used up
+)
+
+// Decision is the outcome of checking one app
+type Decision struct {
+	App      string     `json:"app"`
+	Action   Action     `json:"action"`
+	Problems []string   `json:"problems,omitempty"`
+	Restarts int        `json:"restarts"`       // Restarts within the window, including this one
+	Next     *time.Time `json:"next,omitempty"` // Earliest next restart
+}
+
+// AppState is the restart history of one app. It is saved between runs
+// so the budget and backoff hold when checks run from cron.
+type AppState struct {
+	Restarts    []time.Time `json:"restarts,omitempty"`    // Within the window
+	Consecutive int         `json:"consecutive,omitempty"` // Restarts since the app was last healthy
+	NextAllowed time.Time   `json:"nextAllowed"`
+	Exhausted   bool        `json:"exhausted,omitempty"`
+}
+
+// Watchdog tracks restarts per app. It is not safe for concurrent use.
+type Watchdog struct {
+	cfg   *Config
+	state map[string]*AppState
+}
+
+func New(cfg *Config) *Watchdog {
+	return &Watchdog{cfg: cfg, state: map[string]*AppState{}}
+}
+
+// State returns the restart history by app, for saving
+func (w *Watchdog) State() map[string]*AppState {
+	return w.state
+}
+
+// Restore continues from restart history saved by State
+func (w *Watchdog) Restore(state map[string]*AppState) {
+	for app, st := range state {
+		if st != nil {
+			w.state[app] = st
+		}
+	}
+}
+
+// Backoff is the wait after the nth consecutive restart
+func (w *Watchdog) Backoff(n int) time.Duration {
+	d := w.cfg.Backoff.Duration
+	for i := 1; i < n && d < w.cfg.MaxBackoff.Duration; i++ {
+		d *= 2
+	}
+	if d > w.cfg.MaxBackoff.Duration {
+		d = w.cfg.MaxBackoff.Duration
+	}
+	return d
+}
+
+// Check decides what to do about an app given its current problems. A
+// Restart decision is counted against the budget whether or not the
+// restart succeeds. Exhausted is only returned once until the budget
+// frees up again.
+func (w *Watchdog) Check(app string, problems []string, now time.Time) Decision {
+	st := w.state[app]
+	if st == nil {
+		st = &AppState{}
+		w.state[app] = st
+	}
+	kept := st.Restarts[:0]
+	for _, t := range st.Restarts {
+		if now.Sub(t) < w.cfg.Window.Duration {
+			kept = append(kept, t)
+		}
+	}
+	st.Restarts = kept
+
+	d := Decision{App: app, Problems: problems, Restarts: len(st.Restarts)}
+	if len(problems) == 0 {
+		if st.Consecutive > 0 {
+			d.Action = Recovered
+		}
+		st.Consecutive = 0
+		st.NextAllowed = time.Time{}
+		st.Exhausted = false
+		return d
+	}
+	if now.Before(st.NextAllowed) {
+		next := st.NextAllowed
+		d.Next = &next
+		return d
+	}
+	if len(st.Restarts) >= w.cfg.MaxRestarts {
+		if !st.Exhausted {
+			st.Exhausted = true
+			d.Action = Exhausted
+		}
+		if len(st.Restarts) > 0 {
+			next := st.Restarts[0].Add(w.cfg.Window.Duration)
+			d.Next = &next
+		}
+		return d
+	}
+
+	st.Exhausted = false
+	st.Restarts = append(st.Restarts, now)
+	st.Consecutive++
+	st.NextAllowed = now.Add(w.Backoff(st.Consecutive))
+	d.Action = Restart
+	d.Restarts = len(st.Restarts)
+	next := st.NextAllowed
+	d.Next = &next
+	return d
+}
@@ -0,0 +1,180 @@
+package watchdog
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustParse(t *testing.T, src string) *Config {
+	t.Helper()
+	c, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	return c
+}
+
+var t0 = time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+
+func at(d time.Duration) time.Time { return t0.Add(d) }
+
+func TestParseDefaultsAndSizes(t *testing.T) {
+	c := mustParse(t, `
+apps:
+  - app: Tibber
+    memory: 150MB
+  - app: com.sonos
+    memory: 1.5G
+    ready: false
+`)
+	if c.Interval.Duration != DefaultInterval || c.Backoff.Duration != DefaultBackoff || c.MaxRestarts != DefaultMaxRestarts || c.Window.Duration != DefaultWindow {
+		t.Errorf("defaults not applied: %+v", c)
+	}
+	if c.Apps[0].Memory != 150<<20 || c.Apps[1].Memory != 3<<29 {
+		t.Errorf("unexpected sizes: %d, %d", c.Apps[0].Memory, c.Apps[1].Memory)
+	}
+	if !c.Apps[0].ChecksReady() || c.Apps[1].ChecksReady() {
+		t.Error("ready should default to on and be turned off by ready: false")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`interval: 1m`, "no apps defined"},
+		{"apps:\n  - memory: 10MB", "missing app name"},
+		{"apps:\n  - app: A\n  - app: a", "listed twice"},
+		{"apps:\n  - app: A\n    memory: lots", "invalid size"},
+		{"interval: 100ms\napps:\n  - app: A", "at least 1s"},
+		{"backoff: 10m\nmax_backoff: 1m\napps:\n  - app: A", "max_backoff must be at least backoff"},
+		{"apps:\n  - app: A\n    ready: false", "nothing to check"},
+		{"apps:\n  - app: A\n    stale:\n      - device: Pulse\n        for: 5m", "needs a device and a capability"},
+		{"apps:\n  - app: A\n    stale:\n      - device: Pulse\n        capability: measure_power", "needs for"},
+		{"window: soon\napps:\n  - app: A", "invalid duration"},
+	}
+	for _, tt := range tests {
+		_, err := Parse([]byte(tt.src))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q: expected error containing %q, got %v", tt.src, tt.want, err)
+		}
+	}
+}
+
+func TestProblems(t *testing.T) {
+	c := mustParse(t, `
+apps:
+  - app: Tibber
+    memory: 100MB
+    stale:
+      - device: Pulse
+        capability: measure_power
+        for: 5m
+      - device: Pulse
+        capability: meter_power
+        for: 1h
+`)
+	a := c.Apps[0]
+	healthy := Observation{Enabled: true, Ready: true, Memory: 50 << 20, Updated: []time.Time{at(-time.Minute), at(-30 * time.Minute)}}
+	if p := a.Problems(healthy, t0); len(p) != 0 {
+		t.Errorf("expected no problems, got %v", p)
+	}
+
+	sick := Observation{Enabled: true, Crashed: true, CrashedMessage: "TypeError", Memory: 120 << 20, Updated: []time.Time{at(-7 * time.Minute)}}
+	got := strings.Join(a.Problems(sick, t0), "; ")
+	want := "crashed: TypeError; memory 120.0 MB above 100.0 MB; no measure_power reading from Pulse for 7m0s; no meter_power reading from Pulse"
+	if got != want {
+		t.Errorf("got %q\nwant %q", got, want)
+	}
+
+	if p := a.Problems(Observation{Enabled: false}, t0); p != nil {
+		t.Errorf("disabled apps should have no problems, got %v", p)
+	}
+	if p := a.Problems(Observation{Enabled: true, Ready: true, Memory: -1, Updated: healthy.Updated}, t0); len(p) != 0 {
+		t.Errorf("unknown memory should not be a problem, got %v", p)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	w := New(mustParse(t, "backoff: 1m\nmax_backoff: 5m\napps:\n  - app: A"))
+	var got []time.Duration
+	for n := 1; n <= 5; n++ {
+		got = append(got, w.Backoff(n))
+	}
+	want := []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestCheckBacksOffAndKeepsBudget(t *testing.T) {
+	w := New(mustParse(t, "backoff: 1m\nmax_backoff: 10m\nmax_restarts: 3\nwindow: 1h\napps:\n  - app: A"))
+	sick := []string{"not ready"}
+
+	var actions []string
+	for m := 0; m <= 40; m++ {
+		d := w.Check("A", sick, at(time.Duration(m)*time.Minute))
+		if d.Action != None {
+			actions = append(actions, string(d.Action)+"@"+time.Duration(m*int(time.Minute)).String())
+		}
+	}
+	// Restarts at 0, +1m and +2m+1m=3m, then the budget of 3 is used
+	// up at 3m+4m=7m
+	want := "restart@0s restart@1m0s restart@3m0s exhausted@7m0s"
+	if got := strings.Join(actions, " "); got != want {
+		t.Errorf("got %s\nwant %s", got, want)
+	}
+
+	// The restarts at 0 and 1m have left the window by 61m
+	d := w.Check("A", sick, at(61*time.Minute))
+	if d.Action != Restart || d.Restarts != 2 {
+		t.Errorf("expected a restart once the budget frees up, got %+v", d)
+	}
+
+	d = w.Check("A", nil, at(62*time.Minute))
+	if d.Action != Recovered {
+		t.Errorf("expected recovered, got %+v", d)
+	}
+	if d = w.Check("A", nil, at(63*time.Minute)); d.Action != None {
+		t.Errorf("expected nothing for a healthy app, got %+v", d)
+	}
+}
+
+func TestCheckResetsBackoffAfterRecovery(t *testing.T) {
+	w := New(mustParse(t, "backoff: 1m\nmax_restarts: 10\napps:\n  - app: A"))
+	w.Check("A", []string{"not ready"}, at(0))
+	w.Check("A", []string{"not ready"}, at(time.Minute))
+	w.Check("A", nil, at(2*time.Minute))
+
+	d := w.Check("A", []string{"not ready"}, at(3*time.Minute))
+	if d.Action != Restart || d.Next.Sub(at(3*time.Minute)) != time.Minute {
+		t.Errorf("expected a restart with the first backoff again, got %+v", d)
+	}
+	if d.Restarts != 3 {
+		t.Errorf("restarts in the window should still count, got %d", d.Restarts)
+	}
+}
+
+func TestMaxRestartsZeroNeverRestarts(t *testing.T) {
+	w := New(mustParse(t, "max_restarts: 0\napps:\n  - app: A"))
+	if d := w.Check("A", []string{"not ready"}, at(0)); d.Action != Exhausted {
+		t.Errorf("expected no restart with max_restarts: 0, got %+v", d)
+	}
+}
+
+func TestRestoreContinuesBackoff(t *testing.T) {
+	c := mustParse(t, "backoff: 10m\napps:\n  - app: A")
+	first := New(c)
+	first.Check("A", []string{"not ready"}, at(0))
+
+	// A later run, as from cron, picks up the saved history
+	second := New(c)
+	second.Restore(first.State())
+	if d := second.Check("A", []string{"not ready"}, at(time.Minute)); d.Action != None || d.Next == nil {
+		t.Errorf("expected the backoff to hold across runs, got %+v", d)
+	}
+}
@@ -0,0 +1,24 @@
+// Package yamltime holds time types for YAML config files, shared by the
+// rules engine and the app watchdog.
+package yamltime
+
+import (
+	"fmt"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Duration is a time.Duration written like "20m" or "1h30m"
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	parsed, err := time.ParseDuration(node.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: invalid duration %q", node.Line, node.Value)
+	}
+	d.Duration = parsed
+	return nil
+}
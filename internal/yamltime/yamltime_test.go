@@ -0,0 +1,21 @@
+package yamltime
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+func TestDuration(t *testing.T) {
+	var v struct {
+		For Duration `yaml:"for"`
+	}
+	if err := yaml.Unmarshal([]byte("for: 1h30m"), &v); err != nil || v.For.Duration != 90*time.Minute {
+		t.Errorf("expected 1h30m, got %v (%v)", v.For.Duration, err)
+	}
+	if err := yaml.Unmarshal([]byte("\nfor: soon"), &v); err == nil || !strings.Contains(err.Error(), `line 2: invalid duration "soon"`) {
+		t.Errorf("expected an invalid duration error, got %v", err)
+	}
+}